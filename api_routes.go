@@ -0,0 +1,531 @@
+package main
+
+import (
+	"net/http"
+
+	"go.mongodb.org/mongo-driver/mongo"
+
+	"fitnesshub/handlers"
+	"fitnesshub/middleware"
+	"fitnesshub/models"
+)
+
+// members — все роли вошедших пользователей
+var members = []string{"user", "trainer", "staff", "administrator"}
+
+// apiLimits — ограничения частоты для маршрутов входа, регистрации, восстановления доступа и GraphQL
+type apiLimits struct {
+	login, signup, email, reset, graphql middleware.RateLimiter
+}
+
+// routeTable регистрирует маршрут одновременно в ServeMux и в спецификации OpenAPI
+type routeTable struct {
+	mux  *http.ServeMux
+	spec *handlers.APISpec
+}
+
+func (t routeTable) route(pattern string, handler http.HandlerFunc, roles ...string) {
+	t.spec.Add(pattern, roles...)
+	if len(roles) == 0 {
+		t.mux.Handle(pattern, handler)
+	} else {
+		t.mux.Handle(pattern, middleware.RoleBasedAccessControl(handler, roles...))
+	}
+}
+
+func (t routeTable) limitedRoute(pattern string, limit middleware.RateLimiter, handler http.HandlerFunc) {
+	t.spec.Add(pattern)
+	t.mux.Handle(pattern, middleware.RateLimit(handler, limit))
+}
+
+func (t routeTable) scopedRoute(pattern, scope string, validate middleware.AccessTokenValidator, handler http.HandlerFunc) {
+	t.spec.AddScoped(pattern, scope)
+	t.mux.Handle(pattern, middleware.RequireScope(handler, validate, scope))
+}
+
+// apiRoutes собирает версионированный JSON API. Метод и параметры пути задаются шаблонами
+// ServeMux; на запрос с другим методом ServeMux сам отвечает 405 с заголовком Allow.
+// Каждый маршрут попадает и в спецификацию OpenAPI, которая возвращается вместе с маршрутами.
+func apiRoutes(database *mongo.Database, limits apiLimits) (*http.ServeMux, *handlers.APISpec) {
+	userCollection := database.Collection("users")
+	productCollection := database.Collection("products")
+	classTypeCollection := database.Collection("class_types")
+	roomCollection := database.Collection("rooms")
+	instructorCollection := database.Collection("instructors")
+	scheduleCollection := database.Collection("class_schedules")
+	sessionCollection := database.Collection("class_sessions")
+	bookingCollection := database.Collection("bookings")
+	trainerCollection := database.Collection("trainers")
+	appointmentCollection := database.Collection("appointments")
+	visitCollection := database.Collection("visits")
+	exerciseCollection := database.Collection("exercises")
+	workoutCollection := database.Collection("workouts")
+	measurementCollection := database.Collection("measurements")
+	foodCollection := database.Collection("foods")
+	mealCollection := database.Collection("meals")
+	programCollection := database.Collection("programs")
+	assignmentCollection := database.Collection("program_assignments")
+	importJobCollection := database.Collection("import_jobs")
+	auditCollection := database.Collection("audit_log")
+	loginAttemptCollection := database.Collection("login_attempts")
+	passkeyCollection := database.Collection("passkeys")
+	oauthClientCollection := database.Collection("oauth_clients")
+	oauthTokenCollection := database.Collection("oauth_tokens")
+	oauthConsentCollection := database.Collection("oauth_consents")
+	apiKeyCollection := database.Collection("api_keys")
+	webauthnSessionCollection := database.Collection("webauthn_sessions")
+
+	api := http.NewServeMux()
+	apiSpec := handlers.NewAPISpec()
+	routes := routeTable{mux: api, spec: apiSpec}
+	apiRoute, apiLimitedRoute := routes.route, routes.limitedRoute
+
+	// Аутентификация
+	apiLimitedRoute("POST /api/v1/auth/signup", limits.signup, func(w http.ResponseWriter, r *http.Request) {
+		handlers.SignUpHandler(w, r, userCollection, auditCollection)
+	})
+	apiLimitedRoute("POST /api/v1/auth/login", limits.login, func(w http.ResponseWriter, r *http.Request) {
+		handlers.LoginHandler(w, r, userCollection, loginAttemptCollection, auditCollection)
+	})
+	apiLimitedRoute("POST /api/v1/auth/login/2fa", limits.login, func(w http.ResponseWriter, r *http.Request) {
+		handlers.LoginTwoFactorHandler(w, r, userCollection, loginAttemptCollection, auditCollection)
+	})
+	apiLimitedRoute("POST /api/v1/auth/login/2fa/setup", limits.login, func(w http.ResponseWriter, r *http.Request) {
+		handlers.LoginTwoFactorSetupHandler(w, r, userCollection)
+	})
+	apiLimitedRoute("POST /api/v1/auth/login/2fa/enable", limits.login, func(w http.ResponseWriter, r *http.Request) {
+		handlers.LoginTwoFactorEnableHandler(w, r, userCollection, loginAttemptCollection, auditCollection)
+	})
+	apiLimitedRoute("POST /api/v1/auth/verify/resend", limits.email, func(w http.ResponseWriter, r *http.Request) {
+		handlers.ResendVerificationHandler(w, r, userCollection)
+	})
+	apiLimitedRoute("POST /api/v1/auth/password/forgot", limits.email, func(w http.ResponseWriter, r *http.Request) {
+		handlers.ForgotPasswordHandler(w, r, userCollection, auditCollection)
+	})
+	apiLimitedRoute("POST /api/v1/auth/password/reset", limits.reset, func(w http.ResponseWriter, r *http.Request) {
+		handlers.ResetPasswordHandler(w, r, userCollection, loginAttemptCollection, auditCollection)
+	})
+	apiRoute("GET /api/v1/auth/oidc/providers", handlers.GetOIDCProvidersHandler)
+
+	// Каталог и расписание
+	apiRoute("GET /api/v1/products", func(w http.ResponseWriter, r *http.Request) { handlers.GetAllProductsHandler(w, r, productCollection) })
+	apiRoute("GET /api/v1/products/{id}", func(w http.ResponseWriter, r *http.Request) { handlers.GetProductByIDHandler(w, r, productCollection) })
+	apiRoute("POST /api/v1/products", func(w http.ResponseWriter, r *http.Request) {
+		handlers.CreateProductHandler(w, r, productCollection, auditCollection)
+	}, "administrator")
+	apiRoute("PUT /api/v1/products/{id}", func(w http.ResponseWriter, r *http.Request) {
+		handlers.UpdateProductByIDHandler(w, r, productCollection, auditCollection)
+	}, "administrator")
+	apiRoute("DELETE /api/v1/products/{id}", func(w http.ResponseWriter, r *http.Request) {
+		handlers.DeleteProductByIDHandler(w, r, productCollection, auditCollection)
+	}, "administrator")
+	apiRoute("GET /api/v1/exercises", func(w http.ResponseWriter, r *http.Request) {
+		handlers.GetAllExercisesHandler(w, r, exerciseCollection)
+	})
+	apiRoute("POST /api/v1/exercises", func(w http.ResponseWriter, r *http.Request) {
+		handlers.CreateExerciseHandler(w, r, exerciseCollection, auditCollection)
+	}, "trainer", "administrator")
+	apiRoute("PUT /api/v1/exercises/{id}", func(w http.ResponseWriter, r *http.Request) {
+		handlers.UpdateExerciseByIDHandler(w, r, exerciseCollection, auditCollection)
+	}, "trainer", "administrator")
+	apiRoute("DELETE /api/v1/exercises/{id}", func(w http.ResponseWriter, r *http.Request) {
+		handlers.DeleteExerciseByIDHandler(w, r, exerciseCollection, auditCollection)
+	}, "trainer", "administrator")
+	apiRoute("GET /api/v1/foods", func(w http.ResponseWriter, r *http.Request) { handlers.GetAllFoodItemsHandler(w, r, foodCollection) })
+	apiRoute("POST /api/v1/foods", func(w http.ResponseWriter, r *http.Request) {
+		handlers.CreateFoodItemHandler(w, r, foodCollection, auditCollection)
+	}, "trainer", "administrator")
+	apiRoute("PUT /api/v1/foods/{id}", func(w http.ResponseWriter, r *http.Request) {
+		handlers.UpdateFoodItemByIDHandler(w, r, foodCollection, auditCollection)
+	}, "trainer", "administrator")
+	apiRoute("DELETE /api/v1/foods/{id}", func(w http.ResponseWriter, r *http.Request) {
+		handlers.DeleteFoodItemByIDHandler(w, r, foodCollection, auditCollection)
+	}, "trainer", "administrator")
+	apiRoute("GET /api/v1/timetable", func(w http.ResponseWriter, r *http.Request) { handlers.TimetableHandler(w, r, sessionCollection) })
+	apiRoute("GET /api/v1/trainers", func(w http.ResponseWriter, r *http.Request) { handlers.GetAllTrainersHandler(w, r, trainerCollection) })
+	apiRoute("GET /api/v1/trainers/{id}", func(w http.ResponseWriter, r *http.Request) { handlers.GetTrainerByIDHandler(w, r, trainerCollection) })
+	apiRoute("GET /api/v1/trainers/{trainer_id}/slots", func(w http.ResponseWriter, r *http.Request) {
+		handlers.GetTrainerSlotsHandler(w, r, trainerCollection, appointmentCollection)
+	})
+
+	// Профиль текущего пользователя
+	apiRoute("GET /api/v1/profile", func(w http.ResponseWriter, r *http.Request) { handlers.GetUserProfileHandler(w, r, userCollection) }, members...)
+	apiRoute("PATCH /api/v1/profile", func(w http.ResponseWriter, r *http.Request) {
+		handlers.UpdateUserProfileHandler(w, r, userCollection, auditCollection)
+	}, members...)
+	apiRoute("POST /api/v1/profile/password", func(w http.ResponseWriter, r *http.Request) {
+		handlers.ChangeUserPasswordHandler(w, r, userCollection, auditCollection)
+	}, members...)
+	apiRoute("PUT /api/v1/profile/units", func(w http.ResponseWriter, r *http.Request) {
+		handlers.UpdateUnitPreferencesHandler(w, r, userCollection)
+	}, members...)
+	apiRoute("GET /api/v1/profile/pass", handlers.GetMemberPassHandler, members...)
+	apiRoute("GET /api/v1/profile/pass.png", handlers.GetMemberPassQRHandler, members...)
+	apiRoute("GET /api/v1/profile/2fa", func(w http.ResponseWriter, r *http.Request) { handlers.GetTwoFactorStatusHandler(w, r, userCollection) }, members...)
+	apiRoute("POST /api/v1/profile/2fa/setup", func(w http.ResponseWriter, r *http.Request) { handlers.TwoFactorSetupHandler(w, r, userCollection) }, members...)
+	apiRoute("POST /api/v1/profile/2fa/enable", func(w http.ResponseWriter, r *http.Request) {
+		handlers.TwoFactorEnableHandler(w, r, userCollection, auditCollection)
+	}, members...)
+	apiRoute("POST /api/v1/profile/2fa/disable", func(w http.ResponseWriter, r *http.Request) {
+		handlers.TwoFactorDisableHandler(w, r, userCollection, auditCollection)
+	}, members...)
+	apiRoute("POST /api/v1/profile/2fa/recovery-codes", func(w http.ResponseWriter, r *http.Request) {
+		handlers.RegenerateRecoveryCodesHandler(w, r, userCollection, auditCollection)
+	}, members...)
+	apiRoute("GET /api/v1/profile/passkeys", func(w http.ResponseWriter, r *http.Request) { handlers.GetPasskeysHandler(w, r, passkeyCollection) }, members...)
+	apiRoute("DELETE /api/v1/profile/passkeys/{id}", func(w http.ResponseWriter, r *http.Request) {
+		handlers.DeletePasskeyHandler(w, r, passkeyCollection, auditCollection)
+	}, members...)
+	apiRoute("POST /api/v1/profile/passkeys/register/begin", func(w http.ResponseWriter, r *http.Request) {
+		handlers.BeginPasskeyRegistrationHandler(w, r, userCollection, passkeyCollection, webauthnSessionCollection)
+	}, members...)
+	apiRoute("POST /api/v1/profile/passkeys/register/finish", func(w http.ResponseWriter, r *http.Request) {
+		handlers.FinishPasskeyRegistrationHandler(w, r, userCollection, passkeyCollection, webauthnSessionCollection, auditCollection)
+	}, members...)
+	apiRoute("GET /api/v1/profile/identities", func(w http.ResponseWriter, r *http.Request) { handlers.GetIdentitiesHandler(w, r, userCollection) }, members...)
+	apiRoute("DELETE /api/v1/profile/identities/{provider}", func(w http.ResponseWriter, r *http.Request) {
+		handlers.UnlinkIdentityHandler(w, r, userCollection, auditCollection)
+	}, members...)
+	apiRoute("GET /api/v1/profile/api-keys", func(w http.ResponseWriter, r *http.Request) { handlers.GetMyAPIKeysHandler(w, r, apiKeyCollection) }, members...)
+	apiRoute("POST /api/v1/profile/api-keys", func(w http.ResponseWriter, r *http.Request) {
+		handlers.CreateMyAPIKeyHandler(w, r, apiKeyCollection, auditCollection)
+	}, members...)
+	apiRoute("DELETE /api/v1/profile/api-keys/{id}", func(w http.ResponseWriter, r *http.Request) {
+		handlers.RevokeMyAPIKeyHandler(w, r, apiKeyCollection, auditCollection)
+	}, members...)
+	apiRoute("GET /api/v1/profile/oauth-consents", func(w http.ResponseWriter, r *http.Request) {
+		handlers.GetOAuthConsentsHandler(w, r, oauthConsentCollection)
+	}, members...)
+	apiRoute("DELETE /api/v1/profile/oauth-consents/{client_id}", func(w http.ResponseWriter, r *http.Request) {
+		handlers.RevokeOAuthConsentHandler(w, r, oauthConsentCollection, oauthTokenCollection, auditCollection)
+	}, members...)
+
+	// Записи на занятия, персональные тренировки и посещения
+	apiRoute("GET /api/v1/bookings", func(w http.ResponseWriter, r *http.Request) { handlers.GetMyBookingsHandler(w, r, bookingCollection) }, "user", "trainer", "administrator")
+	apiRoute("POST /api/v1/bookings", func(w http.ResponseWriter, r *http.Request) {
+		handlers.BookSessionHandler(w, r, bookingCollection, sessionCollection)
+	}, "user", "trainer", "administrator")
+	apiRoute("DELETE /api/v1/bookings/{id}", func(w http.ResponseWriter, r *http.Request) {
+		handlers.CancelBookingHandler(w, r, bookingCollection, sessionCollection, userCollection)
+	}, "user", "trainer", "administrator")
+	apiRoute("GET /api/v1/appointments", func(w http.ResponseWriter, r *http.Request) {
+		handlers.GetMyAppointmentsHandler(w, r, appointmentCollection)
+	}, "user", "trainer", "administrator")
+	apiRoute("POST /api/v1/appointments", func(w http.ResponseWriter, r *http.Request) {
+		handlers.BookAppointmentHandler(w, r, trainerCollection, appointmentCollection, sessionCollection, instructorCollection)
+	}, "user", "trainer", "administrator")
+	apiRoute("DELETE /api/v1/appointments/{id}", func(w http.ResponseWriter, r *http.Request) {
+		handlers.CancelAppointmentHandler(w, r, appointmentCollection)
+	}, "user", "trainer", "administrator")
+	apiRoute("GET /api/v1/visits", func(w http.ResponseWriter, r *http.Request) { handlers.GetMyVisitsHandler(w, r, visitCollection) }, members...)
+	apiRoute("POST /api/v1/checkins", func(w http.ResponseWriter, r *http.Request) {
+		handlers.CheckInHandler(w, r, userCollection, visitCollection)
+	}, "staff", "trainer", "administrator")
+	apiRoute("GET /api/v1/occupancy", func(w http.ResponseWriter, r *http.Request) { handlers.OccupancyHandler(w, r, visitCollection) }, "staff", "trainer", "administrator")
+
+	// Дневники тренировок, замеров и питания
+	apiRoute("GET /api/v1/workouts", func(w http.ResponseWriter, r *http.Request) {
+		handlers.GetWorkoutHistoryHandler(w, r, workoutCollection)
+	}, members...)
+	apiRoute("POST /api/v1/workouts", func(w http.ResponseWriter, r *http.Request) {
+		handlers.LogWorkoutHandler(w, r, workoutCollection, exerciseCollection)
+	}, members...)
+	apiRoute("DELETE /api/v1/workouts/{id}", func(w http.ResponseWriter, r *http.Request) {
+		handlers.DeleteWorkoutByIDHandler(w, r, workoutCollection)
+	}, members...)
+	apiRoute("GET /api/v1/workouts/records", func(w http.ResponseWriter, r *http.Request) {
+		handlers.GetPersonalRecordsHandler(w, r, workoutCollection)
+	}, members...)
+	apiRoute("GET /api/v1/workouts/volume", func(w http.ResponseWriter, r *http.Request) { handlers.GetWeeklyVolumeHandler(w, r, workoutCollection) }, members...)
+	apiRoute("GET /api/v1/measurements", func(w http.ResponseWriter, r *http.Request) {
+		handlers.GetMeasurementsHandler(w, r, measurementCollection, userCollection)
+	}, members...)
+	apiRoute("POST /api/v1/measurements", func(w http.ResponseWriter, r *http.Request) {
+		handlers.CreateMeasurementHandler(w, r, measurementCollection, userCollection)
+	}, members...)
+	apiRoute("DELETE /api/v1/measurements/{id}", func(w http.ResponseWriter, r *http.Request) {
+		handlers.DeleteMeasurementByIDHandler(w, r, measurementCollection)
+	}, members...)
+	apiRoute("GET /api/v1/measurements/trends", func(w http.ResponseWriter, r *http.Request) {
+		handlers.GetMeasurementTrendHandler(w, r, measurementCollection, userCollection)
+	}, members...)
+	apiRoute("GET /api/v1/measurements/{id}/photo", func(w http.ResponseWriter, r *http.Request) {
+		handlers.GetProgressPhotoHandler(w, r, measurementCollection, userCollection)
+	}, members...)
+	apiRoute("POST /api/v1/measurements/{id}/photo", func(w http.ResponseWriter, r *http.Request) {
+		handlers.UploadProgressPhotoHandler(w, r, measurementCollection)
+	}, members...)
+	apiRoute("GET /api/v1/meals", func(w http.ResponseWriter, r *http.Request) { handlers.GetMealsHandler(w, r, mealCollection) }, members...)
+	apiRoute("POST /api/v1/meals", func(w http.ResponseWriter, r *http.Request) {
+		handlers.LogMealHandler(w, r, mealCollection, foodCollection)
+	}, members...)
+	apiRoute("DELETE /api/v1/meals/{id}", func(w http.ResponseWriter, r *http.Request) { handlers.DeleteMealByIDHandler(w, r, mealCollection) }, members...)
+	apiRoute("PUT /api/v1/nutrition/settings", func(w http.ResponseWriter, r *http.Request) {
+		handlers.UpdateNutritionSettingsHandler(w, r, userCollection)
+	}, members...)
+	apiRoute("GET /api/v1/nutrition/targets", func(w http.ResponseWriter, r *http.Request) {
+		handlers.GetNutritionTargetsHandler(w, r, userCollection, measurementCollection)
+	}, members...)
+	apiRoute("GET /api/v1/nutrition/summary/daily", func(w http.ResponseWriter, r *http.Request) {
+		handlers.GetDailyNutritionSummaryHandler(w, r, mealCollection, userCollection, measurementCollection)
+	}, members...)
+	apiRoute("GET /api/v1/nutrition/summary/weekly", func(w http.ResponseWriter, r *http.Request) {
+		handlers.GetWeeklyNutritionSummaryHandler(w, r, mealCollection, userCollection, measurementCollection)
+	}, members...)
+
+	// Программы тренировок
+	apiRoute("GET /api/v1/programs", func(w http.ResponseWriter, r *http.Request) {
+		handlers.GetTrainerProgramsHandler(w, r, programCollection)
+	}, "trainer", "administrator")
+	apiRoute("POST /api/v1/programs", func(w http.ResponseWriter, r *http.Request) {
+		handlers.CreateProgramHandler(w, r, programCollection, auditCollection)
+	}, "trainer", "administrator")
+	apiRoute("PUT /api/v1/programs/{id}", func(w http.ResponseWriter, r *http.Request) {
+		handlers.UpdateProgramByIDHandler(w, r, programCollection, auditCollection)
+	}, "trainer", "administrator")
+	apiRoute("DELETE /api/v1/programs/{id}", func(w http.ResponseWriter, r *http.Request) {
+		handlers.DeleteProgramByIDHandler(w, r, programCollection, auditCollection)
+	}, "trainer", "administrator")
+	apiRoute("POST /api/v1/programs/assignments", func(w http.ResponseWriter, r *http.Request) {
+		handlers.AssignProgramHandler(w, r, programCollection, assignmentCollection, userCollection, auditCollection)
+	}, "trainer", "administrator")
+	apiRoute("GET /api/v1/programs/assignments", func(w http.ResponseWriter, r *http.Request) {
+		handlers.GetMyProgramAssignmentsHandler(w, r, assignmentCollection)
+	}, members...)
+	apiRoute("GET /api/v1/programs/assignments/{assignment_id}/adherence", func(w http.ResponseWriter, r *http.Request) {
+		handlers.GetProgramAdherenceHandler(w, r, programCollection, assignmentCollection, workoutCollection)
+	}, members...)
+	apiRoute("GET /api/v1/programs/today", func(w http.ResponseWriter, r *http.Request) {
+		handlers.GetTodaysWorkoutHandler(w, r, programCollection, assignmentCollection, workoutCollection)
+	}, members...)
+
+	// Кабинет тренера
+	apiRoute("PUT /api/v1/trainer/profile", func(w http.ResponseWriter, r *http.Request) {
+		handlers.UpdateTrainerProfileHandler(w, r, trainerCollection, auditCollection)
+	}, "trainer")
+	apiRoute("GET /api/v1/trainer/appointments", func(w http.ResponseWriter, r *http.Request) {
+		handlers.GetTrainerAppointmentsHandler(w, r, appointmentCollection)
+	}, "trainer")
+	apiRoute("DELETE /api/v1/trainer/appointments/{id}", func(w http.ResponseWriter, r *http.Request) {
+		handlers.CancelAppointmentHandler(w, r, appointmentCollection)
+	}, "trainer")
+	apiRoute("GET /api/v1/trainer/clients", func(w http.ResponseWriter, r *http.Request) { handlers.GetTrainerClientsHandler(w, r, userCollection) }, "trainer")
+	apiRoute("GET /api/v1/trainer/clients/{user_id}", func(w http.ResponseWriter, r *http.Request) { handlers.GetClientProfileHandler(w, r, userCollection) }, "trainer")
+
+	// Администрирование
+	apiRoute("POST /api/v1/admin/users", func(w http.ResponseWriter, r *http.Request) {
+		handlers.AdminCreateUserHandler(w, r, userCollection, auditCollection)
+	}, "administrator")
+	apiRoute("PUT /api/v1/admin/users/{id}", func(w http.ResponseWriter, r *http.Request) {
+		handlers.AdminUpdateUserByIDHandler(w, r, userCollection, auditCollection)
+	}, "administrator")
+	apiRoute("DELETE /api/v1/admin/users/{id}", func(w http.ResponseWriter, r *http.Request) {
+		handlers.AdminDeleteUserByIDHandler(w, r, userCollection, auditCollection)
+	}, "administrator")
+	apiRoute("POST /api/v1/admin/users/{id}/2fa/reset", func(w http.ResponseWriter, r *http.Request) {
+		handlers.AdminResetTwoFactorHandler(w, r, userCollection, auditCollection)
+	}, "administrator")
+	apiRoute("GET /api/v1/admin/sessions/{session_id}/bookings", func(w http.ResponseWriter, r *http.Request) {
+		handlers.GetSessionBookingsHandler(w, r, bookingCollection)
+	}, "administrator")
+	apiRoute("POST /api/v1/admin/bookings/attendance", func(w http.ResponseWriter, r *http.Request) {
+		handlers.MarkAttendanceHandler(w, r, bookingCollection, auditCollection)
+	}, "administrator")
+	apiRoute("GET /api/v1/admin/class-types", func(w http.ResponseWriter, r *http.Request) {
+		handlers.GetAllClassTypesHandler(w, r, classTypeCollection)
+	}, "administrator")
+	apiRoute("POST /api/v1/admin/class-types", func(w http.ResponseWriter, r *http.Request) {
+		handlers.CreateClassTypeHandler(w, r, classTypeCollection, auditCollection)
+	}, "administrator")
+	apiRoute("PUT /api/v1/admin/class-types/{id}", func(w http.ResponseWriter, r *http.Request) {
+		handlers.UpdateClassTypeByIDHandler(w, r, classTypeCollection, auditCollection)
+	}, "administrator")
+	apiRoute("DELETE /api/v1/admin/class-types/{id}", func(w http.ResponseWriter, r *http.Request) {
+		handlers.DeleteClassTypeByIDHandler(w, r, classTypeCollection, auditCollection)
+	}, "administrator")
+	apiRoute("GET /api/v1/admin/rooms", func(w http.ResponseWriter, r *http.Request) { handlers.GetAllRoomsHandler(w, r, roomCollection) }, "administrator")
+	apiRoute("POST /api/v1/admin/rooms", func(w http.ResponseWriter, r *http.Request) {
+		handlers.CreateRoomHandler(w, r, roomCollection, auditCollection)
+	}, "administrator")
+	apiRoute("PUT /api/v1/admin/rooms/{id}", func(w http.ResponseWriter, r *http.Request) {
+		handlers.UpdateRoomByIDHandler(w, r, roomCollection, auditCollection)
+	}, "administrator")
+	apiRoute("DELETE /api/v1/admin/rooms/{id}", func(w http.ResponseWriter, r *http.Request) {
+		handlers.DeleteRoomByIDHandler(w, r, roomCollection, auditCollection)
+	}, "administrator")
+	apiRoute("GET /api/v1/admin/instructors", func(w http.ResponseWriter, r *http.Request) {
+		handlers.GetAllInstructorsHandler(w, r, instructorCollection)
+	}, "administrator")
+	apiRoute("POST /api/v1/admin/instructors", func(w http.ResponseWriter, r *http.Request) {
+		handlers.CreateInstructorHandler(w, r, instructorCollection, auditCollection)
+	}, "administrator")
+	apiRoute("PUT /api/v1/admin/instructors/{id}", func(w http.ResponseWriter, r *http.Request) {
+		handlers.UpdateInstructorByIDHandler(w, r, instructorCollection, auditCollection)
+	}, "administrator")
+	apiRoute("DELETE /api/v1/admin/instructors/{id}", func(w http.ResponseWriter, r *http.Request) {
+		handlers.DeleteInstructorByIDHandler(w, r, instructorCollection, auditCollection)
+	}, "administrator")
+	apiRoute("GET /api/v1/admin/schedules", func(w http.ResponseWriter, r *http.Request) {
+		handlers.GetAllSchedulesHandler(w, r, scheduleCollection)
+	}, "administrator")
+	apiRoute("POST /api/v1/admin/schedules", func(w http.ResponseWriter, r *http.Request) {
+		handlers.CreateScheduleHandler(w, r, scheduleCollection, classTypeCollection, roomCollection, sessionCollection, auditCollection)
+	}, "administrator")
+	apiRoute("PUT /api/v1/admin/schedules/{id}", func(w http.ResponseWriter, r *http.Request) {
+		handlers.UpdateScheduleByIDHandler(w, r, scheduleCollection, classTypeCollection, roomCollection, sessionCollection, auditCollection)
+	}, "administrator")
+	apiRoute("DELETE /api/v1/admin/schedules/{id}", func(w http.ResponseWriter, r *http.Request) {
+		handlers.DeleteScheduleByIDHandler(w, r, scheduleCollection, sessionCollection, auditCollection)
+	}, "administrator")
+	apiRoute("POST /api/v1/admin/schedules/materialize", func(w http.ResponseWriter, r *http.Request) {
+		handlers.MaterializeAllSchedulesHandler(w, r, scheduleCollection, classTypeCollection, roomCollection, sessionCollection, auditCollection)
+	}, "administrator")
+	apiRoute("GET /api/v1/admin/imports/{id}", func(w http.ResponseWriter, r *http.Request) { handlers.GetImportJobHandler(w, r, importJobCollection) }, "administrator")
+	apiRoute("POST /api/v1/admin/users/import", func(w http.ResponseWriter, r *http.Request) {
+		handlers.ImportUsersHandler(w, r, userCollection, importJobCollection, auditCollection)
+	}, "administrator")
+	apiRoute("GET /api/v1/admin/users/export", func(w http.ResponseWriter, r *http.Request) { handlers.ExportUsersHandler(w, r, userCollection) }, "administrator")
+	apiRoute("GET /api/v1/admin/users/email-conflicts", func(w http.ResponseWriter, r *http.Request) {
+		handlers.AdminEmailConflictsHandler(w, r, userCollection)
+	}, "administrator")
+	apiRoute("POST /api/v1/admin/products/import", func(w http.ResponseWriter, r *http.Request) {
+		handlers.ImportProductsHandler(w, r, productCollection, importJobCollection, auditCollection)
+	}, "administrator")
+	apiRoute("GET /api/v1/admin/products/export", func(w http.ResponseWriter, r *http.Request) {
+		handlers.ExportProductsHandler(w, r, productCollection)
+	}, "administrator")
+	apiRoute("POST /api/v1/admin/foods/import", func(w http.ResponseWriter, r *http.Request) {
+		handlers.ImportFoodItemsHandler(w, r, foodCollection, auditCollection)
+	}, "administrator")
+	apiRoute("GET /api/v1/admin/trash", func(w http.ResponseWriter, r *http.Request) {
+		handlers.AdminTrashHandler(w, r, userCollection, productCollection)
+	}, "administrator")
+	apiRoute("POST /api/v1/admin/trash/restore", func(w http.ResponseWriter, r *http.Request) {
+		handlers.RestoreFromTrashHandler(w, r, userCollection, productCollection, auditCollection)
+	}, "administrator")
+	apiRoute("GET /api/v1/admin/audit", func(w http.ResponseWriter, r *http.Request) { handlers.AdminAuditHandler(w, r, auditCollection) }, "administrator")
+	apiRoute("GET /api/v1/admin/audit/export", func(w http.ResponseWriter, r *http.Request) { handlers.ExportAuditHandler(w, r, auditCollection) }, "administrator")
+	apiRoute("GET /api/v1/admin/audit/verify", func(w http.ResponseWriter, r *http.Request) {
+		handlers.VerifyAuditChainHandler(w, r, auditCollection)
+	}, "administrator")
+	apiRoute("GET /api/v1/admin/api-keys", func(w http.ResponseWriter, r *http.Request) { handlers.AdminGetAPIKeysHandler(w, r, apiKeyCollection) }, "administrator")
+	apiRoute("POST /api/v1/admin/api-keys", func(w http.ResponseWriter, r *http.Request) {
+		handlers.AdminCreateServiceKeyHandler(w, r, apiKeyCollection, auditCollection)
+	}, "administrator")
+	apiRoute("DELETE /api/v1/admin/api-keys/{id}", func(w http.ResponseWriter, r *http.Request) {
+		handlers.AdminRevokeAPIKeyHandler(w, r, apiKeyCollection, auditCollection)
+	}, "administrator")
+	apiRoute("GET /api/v1/admin/oauth/clients", func(w http.ResponseWriter, r *http.Request) {
+		handlers.GetOAuthClientsHandler(w, r, oauthClientCollection)
+	}, "administrator")
+	apiRoute("POST /api/v1/admin/oauth/clients", func(w http.ResponseWriter, r *http.Request) {
+		handlers.CreateOAuthClientHandler(w, r, oauthClientCollection, auditCollection)
+	}, "administrator")
+	apiRoute("DELETE /api/v1/admin/oauth/clients/{id}", func(w http.ResponseWriter, r *http.Request) {
+		handlers.RevokeOAuthClientHandler(w, r, oauthClientCollection, oauthTokenCollection, auditCollection)
+	}, "administrator")
+
+	return api, apiSpec
+}
+
+// protocolRoutes регистрирует в mux маршруты вне /api/v1, пути которых задают протоколы и браузерные
+// сценарии: вход по ключу доступа и через OIDC, сервер OAuth2 с партнёрским API и GraphQL.
+// Маршруты попадают в ту же спецификацию OpenAPI, что и /api/v1.
+func protocolRoutes(mux *http.ServeMux, spec *handlers.APISpec, database *mongo.Database, limits apiLimits, graphQLPersistedOnly bool) error {
+	userCollection := database.Collection("users")
+	productCollection := database.Collection("products")
+	classTypeCollection := database.Collection("class_types")
+	roomCollection := database.Collection("rooms")
+	instructorCollection := database.Collection("instructors")
+	sessionCollection := database.Collection("class_sessions")
+	bookingCollection := database.Collection("bookings")
+	trainerCollection := database.Collection("trainers")
+	visitCollection := database.Collection("visits")
+	workoutCollection := database.Collection("workouts")
+	auditCollection := database.Collection("audit_log")
+	loginAttemptCollection := database.Collection("login_attempts")
+	passkeyCollection := database.Collection("passkeys")
+	webauthnSessionCollection := database.Collection("webauthn_sessions")
+	oidcStateCollection := database.Collection("oidc_states")
+	oauthClientCollection := database.Collection("oauth_clients")
+	oauthCodeCollection := database.Collection("oauth_codes")
+	oauthTokenCollection := database.Collection("oauth_tokens")
+	oauthConsentCollection := database.Collection("oauth_consents")
+	persistedQueryCollection := database.Collection("graphql_queries")
+
+	routes := routeTable{mux: mux, spec: spec}
+
+	// Вход по ключу доступа (passkey) без пароля
+	routes.limitedRoute("POST /login/passkey/begin", limits.login, func(w http.ResponseWriter, r *http.Request) {
+		handlers.BeginPasskeyLoginHandler(w, r, webauthnSessionCollection)
+	})
+	routes.limitedRoute("POST /login/passkey/finish", limits.login, func(w http.ResponseWriter, r *http.Request) {
+		handlers.FinishPasskeyLoginHandler(w, r, userCollection, passkeyCollection, webauthnSessionCollection, loginAttemptCollection, auditCollection)
+	})
+
+	// Вход и регистрация через внешних OIDC-провайдеров
+	routes.route("GET /login/oidc/providers", handlers.GetOIDCProvidersHandler)
+	routes.limitedRoute("GET /login/oidc", limits.login, func(w http.ResponseWriter, r *http.Request) {
+		handlers.BeginOIDCLoginHandler(w, r, oidcStateCollection)
+	})
+	routes.limitedRoute("GET /login/oidc/callback", limits.login, func(w http.ResponseWriter, r *http.Request) {
+		handlers.OIDCCallbackHandler(w, r, userCollection, oidcStateCollection, loginAttemptCollection, auditCollection)
+	})
+	routes.route("GET /profile/identities/link", func(w http.ResponseWriter, r *http.Request) {
+		handlers.LinkOIDCIdentityHandler(w, r, oidcStateCollection)
+	}, members...)
+
+	// Сервер авторизации OAuth2 для партнёрских приложений
+	routes.route("GET /oauth/authorize", func(w http.ResponseWriter, r *http.Request) {
+		handlers.AuthorizeHandler(w, r, oauthClientCollection, oauthCodeCollection, oauthConsentCollection)
+	}, members...)
+	routes.route("POST /oauth/authorize", func(w http.ResponseWriter, r *http.Request) {
+		handlers.AuthorizeDecisionHandler(w, r, oauthClientCollection, oauthCodeCollection, oauthConsentCollection, auditCollection)
+	}, members...)
+	routes.limitedRoute("POST /oauth/token", limits.login, func(w http.ResponseWriter, r *http.Request) {
+		handlers.TokenHandler(w, r, oauthClientCollection, oauthCodeCollection, oauthTokenCollection)
+	})
+	routes.route("POST /oauth/introspect", func(w http.ResponseWriter, r *http.Request) {
+		handlers.IntrospectHandler(w, r, oauthClientCollection, oauthTokenCollection)
+	})
+	routes.route("POST /oauth/revoke", func(w http.ResponseWriter, r *http.Request) {
+		handlers.RevokeTokenHandler(w, r, oauthClientCollection, oauthTokenCollection)
+	})
+
+	// Данные участника для партнёрских приложений по токену доступа с нужной областью
+	validateAccessToken := handlers.ValidateAccessToken(oauthTokenCollection)
+	routes.scopedRoute("GET /oauth/api/profile", models.ScopeProfileRead, validateAccessToken, func(w http.ResponseWriter, r *http.Request) {
+		handlers.GetUserProfileHandler(w, r, userCollection)
+	})
+	routes.scopedRoute("GET /oauth/api/workouts", models.ScopeWorkoutsRead, validateAccessToken, func(w http.ResponseWriter, r *http.Request) {
+		handlers.GetWorkoutHistoryHandler(w, r, workoutCollection)
+	})
+	routes.scopedRoute("GET /oauth/api/visits", models.ScopeVisitsRead, validateAccessToken, func(w http.ResponseWriter, r *http.Request) {
+		handlers.GetMyVisitsHandler(w, r, visitCollection)
+	})
+
+	// GraphQL: каталог доступен анонимно, остальное — по правам на уровне полей схемы.
+	// graphQLPersistedOnly разрешает только сохранённые запросы
+	graphQLAPI, err := handlers.NewGraphQLAPI(handlers.GraphQLCollections{
+		Users:            userCollection,
+		Products:         productCollection,
+		Bookings:         bookingCollection,
+		Sessions:         sessionCollection,
+		ClassTypes:       classTypeCollection,
+		Rooms:            roomCollection,
+		Instructors:      instructorCollection,
+		Trainers:         trainerCollection,
+		PersistedQueries: persistedQueryCollection,
+	}, graphQLPersistedOnly)
+	if err != nil {
+		return err
+	}
+	graphQLHandler := middleware.RateLimit(middleware.OptionalAuthentication(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		handlers.GraphQLHandler(w, r, graphQLAPI)
+	}), members...), limits.graphql)
+	for _, pattern := range []string{"GET /graphql", "POST /graphql"} {
+		spec.Add(pattern)
+		mux.Handle(pattern, graphQLHandler)
+	}
+	return nil
+}
@@ -3,109 +3,341 @@ package handlers
 import (
 	"context"
 	"encoding/json"
+	"errors"
+	"html/template"
 	"net/http"
+	"strings"
+	"time"
 
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/bson/primitive"
 	"go.mongodb.org/mongo-driver/mongo"
+	"golang.org/x/crypto/bcrypt"
 
+	"fitnesshub/middleware"
 	"fitnesshub/models"
 )
 
+// adminUserRequest — данные пользователя из JSON или HTML-формы администратора
+type adminUserRequest struct {
+	ID                string `json:"id"`
+	Name              string `json:"name"`
+	Email             string `json:"email"`
+	Password          string `json:"password"`
+	Role              string `json:"role"`
+	Verified          *bool  `json:"verified"`
+	MembershipExpires string `json:"membership_expires"`
+}
+
+func decodeAdminUserRequest(r *http.Request) (adminUserRequest, error) {
+	var request adminUserRequest
+	if !isFormRequest(r) {
+		err := json.NewDecoder(r.Body).Decode(&request)
+		return request, err
+	}
+
+	if err := r.ParseForm(); err != nil {
+		return request, err
+	}
+	verified := r.PostForm.Get("verified") == "on" || r.PostForm.Get("verified") == "true"
+	request = adminUserRequest{
+		ID:                r.PostForm.Get("id"),
+		Name:              r.PostForm.Get("name"),
+		Email:             r.PostForm.Get("email"),
+		Password:          r.PostForm.Get("password"),
+		Role:              r.PostForm.Get("role"),
+		MembershipExpires: r.PostForm.Get("membership_expires"),
+	}
+	if r.PostForm.Has("verified") || r.PostForm.Has("id") {
+		request.Verified = &verified
+	}
+	return request, nil
+}
+
+// AdminGetAllUsersHandler возвращает страницу пользователей с фильтрами, сортировкой и курсором
 func AdminGetAllUsersHandler(w http.ResponseWriter, r *http.Request, collection *mongo.Collection) {
-	var users []models.User
-	cursor, err := collection.Find(context.TODO(), bson.M{})
+	users, next, err := SearchUsers(collection, ParseUserQuery(r.URL.Query()))
+	if errors.Is(err, errInvalidCursor) {
+		http.Error(w, "Invalid cursor", http.StatusBadRequest)
+		return
+	}
 	if err != nil {
 		http.Error(w, "Error fetching users", http.StatusInternalServerError)
 		return
 	}
-	defer cursor.Close(context.TODO())
-
-	for cursor.Next(context.TODO()) {
-		var user models.User
-		cursor.Decode(&user)
-		users = append(users, user)
-	}
 
 	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(users)
+	json.NewEncoder(w).Encode(map[string]interface{}{"users": users, "next_cursor": next})
 }
 
-func AdminDeleteUserByIDHandler(w http.ResponseWriter, r *http.Request, collection *mongo.Collection) {
-	id := r.URL.Query().Get("id")
-	objID, err := primitive.ObjectIDFromHex(id)
+func AdminDeleteUserByIDHandler(w http.ResponseWriter, r *http.Request, collection, auditCollection *mongo.Collection) {
+	objID, err := primitive.ObjectIDFromHex(requestID(r))
 	if err != nil {
-		http.Error(w, "Invalid user ID", http.StatusBadRequest)
+		writeResult(w, r, "/admin/users", http.StatusBadRequest, "Invalid user ID")
+		return
+	}
+
+	if objID.Hex() == middleware.GetUserID(r) {
+		writeResult(w, r, "/admin/users", http.StatusBadRequest, "You cannot delete your own account")
 		return
 	}
 
-	_, err = collection.DeleteOne(context.TODO(), bson.M{"_id": objID})
+	before, after, err := softDelete(r, collection, objID)
+	if err == mongo.ErrNoDocuments {
+		writeResult(w, r, "/admin/users", http.StatusNotFound, "User not found")
+		return
+	}
 	if err != nil {
-		http.Error(w, "Error deleting user", http.StatusInternalServerError)
+		writeResult(w, r, "/admin/users", http.StatusInternalServerError, "Error deleting user")
 		return
 	}
 
-	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(map[string]string{"status": "success", "message": "User deleted successfully"})
+	event := auditEvent(r, "users.delete", "users", objID.Hex())
+	event.Changes = auditDiff(before, after)
+	recordAudit(auditCollection, event)
+
+	writeResult(w, r, "/admin/users", http.StatusOK, "User moved to trash")
 }
 
-func AdminCreateUserHandler(w http.ResponseWriter, r *http.Request, collection *mongo.Collection) {
-	var user models.User
-	err := json.NewDecoder(r.Body).Decode(&user)
+func AdminCreateUserHandler(w http.ResponseWriter, r *http.Request, collection, auditCollection *mongo.Collection) {
+	request, err := decodeAdminUserRequest(r)
+	if err != nil {
+		writeResult(w, r, "/admin/users", http.StatusBadRequest, "Invalid request")
+		return
+	}
+
+	request.Email = normalizeEmail(request.Email)
+	if request.Email == "" || request.Password == "" {
+		writeResult(w, r, "/admin/users", http.StatusBadRequest, "Email and password are required")
+		return
+	}
+	if request.Role == "" {
+		request.Role = "user"
+	}
+	if !models.IsValidRole(request.Role) {
+		writeResult(w, r, "/admin/users", http.StatusBadRequest, "Invalid role")
+		return
+	}
+
+	count, err := collection.CountDocuments(context.TODO(), notDeleted(bson.M{"email": request.Email}))
+	if err != nil || count > 0 {
+		writeResult(w, r, "/admin/users", http.StatusConflict, "A user with this email already exists")
+		return
+	}
+
+	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(request.Password), bcrypt.DefaultCost)
 	if err != nil {
-		http.Error(w, "Invalid JSON message", http.StatusBadRequest)
+		writeResult(w, r, "/admin/users", http.StatusInternalServerError, "Error hashing password")
 		return
 	}
 
-	_, err = collection.InsertOne(context.TODO(), user)
+	firstName, lastName, _ := strings.Cut(strings.TrimSpace(request.Name), " ")
+	user := models.User{
+		Email:    request.Email,
+		Password: string(hashedPassword),
+		Verified: request.Verified != nil && *request.Verified,
+		Role:     request.Role,
+		Profile:  models.Profile{FirstName: firstName, LastName: strings.TrimSpace(lastName)},
+	}
+
+	result, err := collection.InsertOne(context.TODO(), user)
+	if mongo.IsDuplicateKeyError(err) {
+		writeResult(w, r, "/admin/users", http.StatusConflict, "A user with this email already exists")
+		return
+	}
 	if err != nil {
-		http.Error(w, "Error adding user", http.StatusInternalServerError)
+		writeResult(w, r, "/admin/users", http.StatusInternalServerError, "Error adding user")
 		return
 	}
+	user.ID = result.InsertedID.(primitive.ObjectID)
 
-	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(map[string]string{"status": "success", "message": "User added successfully"})
+	event := auditEvent(r, "users.create", "users", user.ID.Hex())
+	event.Changes = auditDiff(nil, toAuditDoc(user))
+	recordAudit(auditCollection, event)
+
+	writeResult(w, r, "/admin/users", http.StatusOK, "User added successfully")
 }
 
-func AdminUpdateUserByIDHandler(w http.ResponseWriter, r *http.Request, collection *mongo.Collection) {
-	var user models.User
-	err := json.NewDecoder(r.Body).Decode(&user)
+// AdminUpdateUserByIDHandler обновляет email, имя, роль, статус подтверждения, срок абонемента
+// и, если он передан, пароль пользователя
+func AdminUpdateUserByIDHandler(w http.ResponseWriter, r *http.Request, collection, auditCollection *mongo.Collection) {
+	request, err := decodeAdminUserRequest(r)
+	if err != nil {
+		writeResult(w, r, "/admin/users", http.StatusBadRequest, "Invalid request")
+		return
+	}
+
+	if id := r.PathValue("id"); id != "" {
+		request.ID = id
+	}
+	objID, err := primitive.ObjectIDFromHex(request.ID)
+	if err != nil {
+		writeResult(w, r, "/admin/users", http.StatusBadRequest, "User ID is required")
+		return
+	}
+	editPage := "/admin/users/edit?id=" + objID.Hex()
+
+	fields := bson.M{}
+	if email := normalizeEmail(request.Email); email != "" {
+		count, err := collection.CountDocuments(context.TODO(), notDeleted(bson.M{"email": email, "_id": bson.M{"$ne": objID}}))
+		if err != nil || count > 0 {
+			writeResult(w, r, editPage, http.StatusConflict, "A user with this email already exists")
+			return
+		}
+		fields["email"] = email
+	}
+	if request.Role != "" {
+		if !models.IsValidRole(request.Role) {
+			writeResult(w, r, editPage, http.StatusBadRequest, "Invalid role")
+			return
+		}
+		fields["role"] = request.Role
+	}
+	if request.Verified != nil {
+		fields["verified"] = *request.Verified
+	}
+	if request.Name != "" {
+		firstName, lastName, _ := strings.Cut(strings.TrimSpace(request.Name), " ")
+		fields["profile.first_name"] = firstName
+		fields["profile.last_name"] = strings.TrimSpace(lastName)
+	}
+	if request.MembershipExpires != "" {
+		expires, err := time.ParseInLocation("2006-01-02", request.MembershipExpires, time.Local)
+		if err != nil {
+			writeResult(w, r, editPage, http.StatusBadRequest, "Invalid membership expiry date")
+			return
+		}
+		fields["membership_expires"] = expires.AddDate(0, 0, 1)
+	}
+	if request.Password != "" {
+		hashedPassword, err := bcrypt.GenerateFromPassword([]byte(request.Password), bcrypt.DefaultCost)
+		if err != nil {
+			writeResult(w, r, editPage, http.StatusInternalServerError, "Error hashing password")
+			return
+		}
+		fields["password"] = string(hashedPassword)
+	}
+	if len(fields) == 0 {
+		writeResult(w, r, editPage, http.StatusBadRequest, "Nothing to update")
+		return
+	}
+
+	before, after, err := auditedUpdate(collection, notDeleted(bson.M{"_id": objID}), bson.M{"$set": fields})
+	if err == mongo.ErrNoDocuments {
+		writeResult(w, r, "/admin/users", http.StatusNotFound, "User not found")
+		return
+	}
+	if mongo.IsDuplicateKeyError(err) {
+		writeResult(w, r, editPage, http.StatusConflict, "A user with this email already exists")
+		return
+	}
 	if err != nil {
-		http.Error(w, "Invalid JSON message", http.StatusBadRequest)
+		writeResult(w, r, editPage, http.StatusInternalServerError, "Error updating user")
+		return
+	}
+
+	event := auditEvent(r, "users.update", "users", objID.Hex())
+	event.Changes = auditDiff(before, after)
+	recordAudit(auditCollection, event)
+
+	writeResult(w, r, "/admin/users", http.StatusOK, "User updated successfully")
+}
+
+// AdminUsersPageHandler отображает страницу управления пользователями с поиском и постраничным выводом
+func AdminUsersPageHandler(w http.ResponseWriter, r *http.Request, collection *mongo.Collection) {
+	if wantsJSON(r) {
+		AdminGetAllUsersHandler(w, r, collection)
 		return
 	}
 
-	if user.ID.IsZero() {
-		http.Error(w, "User ID is required", http.StatusBadRequest)
+	query := ParseUserQuery(r.URL.Query())
+	users, next, err := SearchUsers(collection, query)
+	if errors.Is(err, errInvalidCursor) {
+		http.Error(w, "Invalid cursor", http.StatusBadRequest)
+		return
+	}
+	if err != nil {
+		http.Error(w, "Error fetching users", http.StatusInternalServerError)
 		return
 	}
 
-	filter := bson.M{"_id": user.ID}
-	update := bson.M{"$set": user}
+	firstPage := query.Values()
+	nextPage := query.Values()
+	nextPage.Set("cursor", next)
+
+	renderAdminPage(w, r, "templates/admin_users.html", map[string]interface{}{
+		"Users":     users,
+		"Roles":     models.ValidRoles,
+		"Query":     query,
+		"FirstPage": "/admin/users?" + firstPage.Encode(),
+		"NextPage":  "/admin/users?" + nextPage.Encode(),
+		"ExportURL": "/admin/users/export?" + firstPage.Encode(),
+		"HasNext":   next != "",
+		"Paged":     query.Cursor != "",
+	})
+}
 
-	_, err = collection.UpdateOne(context.TODO(), filter, update)
+// AdminUserEditPageHandler отображает форму редактирования пользователя ?id=
+func AdminUserEditPageHandler(w http.ResponseWriter, r *http.Request, collection *mongo.Collection) {
+	objID, err := primitive.ObjectIDFromHex(routeParam(r, "id"))
 	if err != nil {
-		http.Error(w, "Error updating user", http.StatusInternalServerError)
+		http.Error(w, "Invalid user ID", http.StatusBadRequest)
 		return
 	}
 
-	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(map[string]string{"status": "success", "message": "User updated successfully"})
+	var user models.User
+	err = collection.FindOne(context.TODO(), notDeleted(bson.M{"_id": objID})).Decode(&user)
+	if err != nil {
+		http.Error(w, "User not found", http.StatusNotFound)
+		return
+	}
+
+	renderAdminPage(w, r, "templates/admin_user_edit.html", map[string]interface{}{
+		"User":  user,
+		"Roles": models.ValidRoles,
+	})
 }
 
-func GetAllUsers(collection *mongo.Collection) []models.User {
-	var users []models.User
-	cursor, err := collection.Find(context.TODO(), bson.M{})
+// AdminUserDeletePageHandler запрашивает подтверждение удаления пользователя ?id=
+func AdminUserDeletePageHandler(w http.ResponseWriter, r *http.Request, collection *mongo.Collection) {
+	objID, err := primitive.ObjectIDFromHex(routeParam(r, "id"))
+	if err != nil {
+		http.Error(w, "Invalid user ID", http.StatusBadRequest)
+		return
+	}
+
+	var user models.User
+	err = collection.FindOne(context.TODO(), notDeleted(bson.M{"_id": objID})).Decode(&user)
 	if err != nil {
-		return users
+		http.Error(w, "User not found", http.StatusNotFound)
+		return
 	}
-	defer cursor.Close(context.TODO())
 
-	for cursor.Next(context.TODO()) {
-		var user models.User
-		cursor.Decode(&user)
-		users = append(users, user)
+	renderAdminPage(w, r, "templates/admin_confirm_delete.html", map[string]interface{}{
+		"Kind":   "user",
+		"Name":   user.Email,
+		"ID":     user.ID.Hex(),
+		"Action": "/admin/users/delete",
+		"Back":   "/admin/users",
+	})
+}
+
+// renderAdminPage добавляет к данным шаблона flash-сообщение и CSRF-токен и отображает страницу
+func renderAdminPage(w http.ResponseWriter, r *http.Request, page string, data map[string]interface{}) {
+	data["Flash"] = popFlash(w, r)
+	data["CSRFToken"] = middleware.CSRFToken(r)
+	renderPage(w, r, page, data)
+}
+
+// renderPage отображает шаблон страницы; встроенные скрипты и стили помечаются nonce из CSP
+func renderPage(w http.ResponseWriter, r *http.Request, page string, data map[string]interface{}) {
+	tmpl, err := template.ParseFiles(page)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
 	}
 
-	return users
+	data["CSPNonce"] = middleware.CSPNonce(r)
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	tmpl.Execute(w, data)
 }
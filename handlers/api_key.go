@@ -0,0 +1,333 @@
+package handlers
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"regexp"
+	"strings"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+
+	"fitnesshub/middleware"
+	"fitnesshub/models"
+	"fitnesshub/utils"
+)
+
+const (
+	APIKeyDefaultTTL = 90 * 24 * time.Hour
+	APIKeyMaxTTL     = 365 * 24 * time.Hour
+	// Отметка об использовании обновляется не чаще раза в минуту, чтобы не писать в базу на каждый запрос
+	apiKeyLastUsedInterval = time.Minute
+)
+
+var apiKeyScopePattern = regexp.MustCompile(`^(\*|[a-z_-]+(\.[a-z_-]+)?):(read|write)$`)
+
+var errInvalidAPIKey = errors.New("invalid API key")
+
+// generateAPIKey возвращает ключ вида fhk_<8 символов префикса>_<секрет> и его префикс
+func generateAPIKey() (string, string, error) {
+	prefix := make([]byte, 4)
+	if _, err := rand.Read(prefix); err != nil {
+		return "", "", err
+	}
+	secret := make([]byte, 24)
+	if _, err := rand.Read(secret); err != nil {
+		return "", "", err
+	}
+	keyPrefix := middleware.APIKeyPrefix + hex.EncodeToString(prefix)
+	return keyPrefix + "_" + hex.EncodeToString(secret), keyPrefix, nil
+}
+
+// ValidateAPIKey проверяет API-ключ для middleware.RoleBasedAccessControl: ключ должен быть
+// не отозван и не просрочен, а владелец пользовательского ключа — существовать
+func ValidateAPIKey(keyCollection, userCollection *mongo.Collection) middleware.APIKeyValidator {
+	return func(key string, r *http.Request) (middleware.APIKeyIdentity, error) {
+		var identity middleware.APIKeyIdentity
+		keyPrefix, _, ok := strings.Cut(key[len(middleware.APIKeyPrefix):], "_")
+		if !ok {
+			return identity, errInvalidAPIKey
+		}
+
+		var apiKey models.APIKey
+		err := keyCollection.FindOne(context.TODO(), bson.M{"prefix": middleware.APIKeyPrefix + keyPrefix, "revoked_at": nil}).Decode(&apiKey)
+		if err != nil {
+			return identity, errInvalidAPIKey
+		}
+		now := time.Now()
+		if subtle.ConstantTimeCompare([]byte(utils.HashToken(key)), []byte(apiKey.KeyHash)) != 1 || now.After(apiKey.ExpiresAt) {
+			return identity, errInvalidAPIKey
+		}
+
+		identity = middleware.APIKeyIdentity{KeyID: apiKey.ID.Hex(), Prefix: apiKey.Prefix, Role: apiKey.Role, Scopes: apiKey.Scopes}
+		if !apiKey.IsService() {
+			// Ключ пользователя действует с его текущей ролью: понижение роли сразу ограничивает и ключи
+			var owner models.User
+			err := userCollection.FindOne(context.TODO(), notDeleted(bson.M{"_id": apiKey.UserID})).Decode(&owner)
+			if err != nil {
+				return identity, errInvalidAPIKey
+			}
+			identity.UserID, identity.Role = owner.ID.Hex(), owner.Role
+		}
+
+		keyCollection.UpdateOne(context.TODO(), bson.M{
+			"_id": apiKey.ID,
+			"$or": bson.A{bson.M{"last_used_at": nil}, bson.M{"last_used_at": bson.M{"$lt": now.Add(-apiKeyLastUsedInterval)}}},
+		}, bson.M{"$set": bson.M{"last_used_at": now, "last_used_ip": middleware.ClientIP(r)}})
+		return identity, nil
+	}
+}
+
+// apiKeyRequest — параметры нового ключа; role задаётся только для сервисных ключей
+type apiKeyRequest struct {
+	Name          string   `json:"name"`
+	Scopes        []string `json:"scopes"`
+	ExpiresInDays int      `json:"expires_in_days"`
+	Role          string   `json:"role"`
+}
+
+// newAPIKey проверяет запрос и заполняет ключ; срок действия по умолчанию 90 дней, не больше года
+func newAPIKey(request apiKeyRequest, createdBy primitive.ObjectID, now time.Time) (models.APIKey, error) {
+	var apiKey models.APIKey
+	request.Name = strings.TrimSpace(request.Name)
+	if request.Name == "" {
+		return apiKey, errors.New("Name is required")
+	}
+	if len(request.Scopes) == 0 {
+		return apiKey, errors.New("At least one scope is required")
+	}
+	for _, scope := range request.Scopes {
+		if !apiKeyScopePattern.MatchString(scope) {
+			return apiKey, errors.New("Invalid scope: " + scope)
+		}
+	}
+	ttl := APIKeyDefaultTTL
+	if request.ExpiresInDays != 0 {
+		ttl = time.Duration(request.ExpiresInDays) * 24 * time.Hour
+	}
+	if ttl <= 0 || ttl > APIKeyMaxTTL {
+		return apiKey, errors.New("Expiry must be between 1 and 365 days")
+	}
+
+	return models.APIKey{
+		Name:      request.Name,
+		Scopes:    request.Scopes,
+		CreatedBy: createdBy,
+		CreatedAt: now,
+		ExpiresAt: now.Add(ttl),
+	}, nil
+}
+
+// insertAPIKey сохраняет хеш ключа и возвращает сам ключ в ответе — больше его не увидеть
+func insertAPIKey(w http.ResponseWriter, r *http.Request, keyCollection, auditCollection *mongo.Collection, apiKey models.APIKey) {
+	key, prefix, err := generateAPIKey()
+	if err != nil {
+		http.Error(w, "Error generating API key", http.StatusInternalServerError)
+		return
+	}
+	apiKey.Prefix, apiKey.KeyHash = prefix, utils.HashToken(key)
+
+	result, err := keyCollection.InsertOne(context.TODO(), apiKey)
+	if err != nil {
+		http.Error(w, "Error saving API key", http.StatusInternalServerError)
+		return
+	}
+	apiKey.ID = result.InsertedID.(primitive.ObjectID)
+
+	event := auditEvent(r, "api_keys.create", "api_keys", apiKey.ID.Hex())
+	event.Changes = auditDiff(nil, toAuditDoc(apiKey))
+	recordAudit(auditCollection, event)
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	json.NewEncoder(w).Encode(map[string]interface{}{"api_key": apiKey, "key": key})
+}
+
+func findAPIKeys(collection *mongo.Collection, filter bson.M) ([]models.APIKey, error) {
+	apiKeys := []models.APIKey{}
+	cursor, err := collection.Find(context.TODO(), filter, options.Find().SetSort(bson.M{"created_at": -1}))
+	if err != nil {
+		return apiKeys, err
+	}
+	err = cursor.All(context.TODO(), &apiKeys)
+	return apiKeys, err
+}
+
+// revokeAPIKey отзывает ключ, подходящий под filter, и записывает это в журнал
+func revokeAPIKey(w http.ResponseWriter, r *http.Request, keyCollection, auditCollection *mongo.Collection, filter bson.M) {
+	filter["revoked_at"] = nil
+	before, after, err := auditedUpdate(keyCollection, filter, bson.M{"$set": bson.M{"revoked_at": time.Now()}})
+	if err == mongo.ErrNoDocuments {
+		http.Error(w, "API key not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		http.Error(w, "Error revoking API key", http.StatusInternalServerError)
+		return
+	}
+
+	event := auditEvent(r, "api_keys.revoke", "api_keys", filter["_id"].(primitive.ObjectID).Hex())
+	event.Changes = auditDiff(before, after)
+	recordAudit(auditCollection, event)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{"status": "success", "message": "API key revoked"})
+}
+
+// GetMyAPIKeysHandler возвращает API-ключи текущего пользователя
+func GetMyAPIKeysHandler(w http.ResponseWriter, r *http.Request, keyCollection *mongo.Collection) {
+	userID, err := primitive.ObjectIDFromHex(middleware.GetUserID(r))
+	if err != nil {
+		http.Error(w, "Invalid user ID", http.StatusBadRequest)
+		return
+	}
+
+	apiKeys, err := findAPIKeys(keyCollection, bson.M{"user_id": userID})
+	if err != nil {
+		http.Error(w, "Error fetching API keys", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(apiKeys)
+}
+
+// requireSession отклоняет запрос, аутентифицированный API-ключом. Способы входа (провайдеры,
+// ключи доступа, второй фактор) меняются только из сессии: иначе ключ, даже только на чтение,
+// позволил бы привязать к учётной записи чужой способ входа и захватить её.
+func requireSession(w http.ResponseWriter, r *http.Request) bool {
+	if middleware.GetAPIKeyPrefix(r) != "" {
+		http.Error(w, "This action is not available with an API key", http.StatusForbidden)
+		return false
+	}
+	return true
+}
+
+// CreateMyAPIKeyHandler выпускает ключ, действующий от имени текущего пользователя.
+// Выпускать ключи можно только из сессии — ключ не может создать другой ключ.
+func CreateMyAPIKeyHandler(w http.ResponseWriter, r *http.Request, keyCollection, auditCollection *mongo.Collection) {
+	if middleware.GetAPIKeyPrefix(r) != "" {
+		http.Error(w, "API keys cannot be created with an API key", http.StatusForbidden)
+		return
+	}
+	userID, err := primitive.ObjectIDFromHex(middleware.GetUserID(r))
+	if err != nil {
+		http.Error(w, "Invalid user ID", http.StatusBadRequest)
+		return
+	}
+
+	var request apiKeyRequest
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		http.Error(w, "Invalid JSON message", http.StatusBadRequest)
+		return
+	}
+	apiKey, err := newAPIKey(request, userID, time.Now())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	apiKey.UserID = userID
+
+	insertAPIKey(w, r, keyCollection, auditCollection, apiKey)
+}
+
+// RevokeMyAPIKeyHandler отзывает ключ ?id= текущего пользователя
+func RevokeMyAPIKeyHandler(w http.ResponseWriter, r *http.Request, keyCollection, auditCollection *mongo.Collection) {
+	userID, err := primitive.ObjectIDFromHex(middleware.GetUserID(r))
+	if err != nil {
+		http.Error(w, "Invalid user ID", http.StatusBadRequest)
+		return
+	}
+	objID, err := primitive.ObjectIDFromHex(requestID(r))
+	if err != nil {
+		http.Error(w, "Invalid API key ID", http.StatusBadRequest)
+		return
+	}
+
+	revokeAPIKey(w, r, keyCollection, auditCollection, bson.M{"_id": objID, "user_id": userID})
+}
+
+// AdminGetAPIKeysHandler возвращает все ключи; ?user_id= — ключи одного пользователя,
+// ?service=true — только сервисные
+func AdminGetAPIKeysHandler(w http.ResponseWriter, r *http.Request, keyCollection *mongo.Collection) {
+	filter := bson.M{}
+	if userID := r.URL.Query().Get("user_id"); userID != "" {
+		objID, err := primitive.ObjectIDFromHex(userID)
+		if err != nil {
+			http.Error(w, "Invalid user ID", http.StatusBadRequest)
+			return
+		}
+		filter["user_id"] = objID
+	}
+	if r.URL.Query().Get("service") == "true" {
+		filter["user_id"] = nil
+	}
+
+	apiKeys, err := findAPIKeys(keyCollection, filter)
+	if err != nil {
+		http.Error(w, "Error fetching API keys", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(apiKeys)
+}
+
+// AdminCreateServiceKeyHandler выпускает сервисный ключ с заданной ролью, не привязанный к пользователю
+func AdminCreateServiceKeyHandler(w http.ResponseWriter, r *http.Request, keyCollection, auditCollection *mongo.Collection) {
+	if middleware.GetAPIKeyPrefix(r) != "" {
+		http.Error(w, "API keys cannot be created with an API key", http.StatusForbidden)
+		return
+	}
+	adminID, err := primitive.ObjectIDFromHex(middleware.GetUserID(r))
+	if err != nil {
+		http.Error(w, "Invalid user ID", http.StatusBadRequest)
+		return
+	}
+
+	var request apiKeyRequest
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		http.Error(w, "Invalid JSON message", http.StatusBadRequest)
+		return
+	}
+	if !models.IsValidRole(request.Role) {
+		http.Error(w, "Invalid role", http.StatusBadRequest)
+		return
+	}
+	apiKey, err := newAPIKey(request, adminID, time.Now())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	apiKey.Role = request.Role
+
+	insertAPIKey(w, r, keyCollection, auditCollection, apiKey)
+}
+
+// AdminRevokeAPIKeyHandler отзывает любой ключ ?id=
+func AdminRevokeAPIKeyHandler(w http.ResponseWriter, r *http.Request, keyCollection, auditCollection *mongo.Collection) {
+	objID, err := primitive.ObjectIDFromHex(requestID(r))
+	if err != nil {
+		http.Error(w, "Invalid API key ID", http.StatusBadRequest)
+		return
+	}
+
+	revokeAPIKey(w, r, keyCollection, auditCollection, bson.M{"_id": objID})
+}
+
+// EnsureAPIKeyIndexes создаёт уникальный индекс префиксов ключей и индекс по владельцу
+func EnsureAPIKeyIndexes(keyCollection *mongo.Collection) error {
+	_, err := keyCollection.Indexes().CreateMany(context.TODO(), []mongo.IndexModel{
+		{Keys: bson.D{{Key: "prefix", Value: 1}}, Options: options.Index().SetUnique(true)},
+		{Keys: bson.D{{Key: "user_id", Value: 1}, {Key: "created_at", Value: -1}}},
+	})
+	return err
+}
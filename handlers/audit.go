@@ -0,0 +1,404 @@
+package handlers
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"log"
+	"net/http"
+	"net/url"
+	"reflect"
+	"strconv"
+	"sync"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+
+	"fitnesshub/middleware"
+	"fitnesshub/models"
+)
+
+const (
+	DefaultAuditPageSize = 50
+	MaxAuditPageSize     = 200
+)
+
+// Поля, значения которых не попадают в журнал — фиксируется только факт изменения
+var auditRedactedFields = map[string]bool{
+	"password":             true,
+	"verification_token":   true,
+	"password_reset_token": true,
+	"unlock_token":         true,
+	"secret":               true,
+	"pending_secret":       true,
+	"recovery_codes":       true,
+	"key_hash":             true,
+}
+
+// auditMu упорядочивает запись в журнал внутри процесса; между процессами порядок
+// обеспечивает уникальный индекс по seq
+var auditMu sync.Mutex
+
+// auditEvent заполняет автора, IP и идентификатор запроса для события action над target;
+// автором запроса с сервисным API-ключом записывается сам ключ
+func auditEvent(r *http.Request, action, targetType, targetID string) models.AuditEvent {
+	event := models.AuditEvent{
+		ActorID:    middleware.GetUserID(r),
+		ActorRole:  middleware.GetUserRole(r),
+		Action:     action,
+		TargetType: targetType,
+		TargetID:   targetID,
+		IP:         middleware.ClientIP(r),
+		RequestID:  middleware.GetRequestID(r),
+	}
+	if prefix := middleware.GetAPIKeyPrefix(r); prefix != "" && event.ActorID == "" {
+		event.ActorID = "api_key:" + prefix
+	}
+	return event
+}
+
+// recordAudit добавляет событие в конец цепочки. Ошибка записи не прерывает
+// основной запрос, но попадает в лог сервера.
+func recordAudit(collection *mongo.Collection, event models.AuditEvent) {
+	auditMu.Lock()
+	defer auditMu.Unlock()
+
+	event.Time = time.Now().UTC().Truncate(time.Millisecond)
+	for attempt := 0; attempt < 3; attempt++ {
+		var last models.AuditEvent
+		err := collection.FindOne(context.TODO(), bson.M{}, options.FindOne().SetSort(bson.D{{Key: "seq", Value: -1}})).Decode(&last)
+		if err != nil && err != mongo.ErrNoDocuments {
+			log.Println("Error reading audit log:", err)
+			return
+		}
+
+		event.Seq = last.Seq + 1
+		event.PrevHash = last.Hash
+		event.Hash = auditHash(event)
+		_, err = collection.InsertOne(context.TODO(), event)
+		if mongo.IsDuplicateKeyError(err) {
+			continue
+		}
+		if err != nil {
+			log.Println("Error writing audit log:", err)
+		}
+		return
+	}
+	log.Println("Error writing audit log: sequence conflict for", event.Action)
+}
+
+// auditHash — SHA-256 от содержимого события вместе с хешем предыдущего
+func auditHash(event models.AuditEvent) string {
+	event.ID = primitive.NilObjectID
+	event.Hash = ""
+	event.Time = event.Time.UTC()
+	data, _ := json.Marshal(event)
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+// toAuditDoc приводит модель к документу BSON, чтобы сравнить состояния до и после
+func toAuditDoc(value interface{}) bson.M {
+	if value == nil {
+		return nil
+	}
+	data, err := bson.Marshal(value)
+	if err != nil {
+		return nil
+	}
+	var doc bson.M
+	bson.Unmarshal(data, &doc)
+	return doc
+}
+
+// auditDiff возвращает изменившиеся поля (вложенные документы — через точку).
+// before равен nil для созданных записей, after — для удалённых.
+func auditDiff(before, after bson.M) map[string]models.AuditChange {
+	beforeFields := map[string]interface{}{}
+	afterFields := map[string]interface{}{}
+	flattenAuditDoc("", before, beforeFields)
+	flattenAuditDoc("", after, afterFields)
+
+	changes := map[string]models.AuditChange{}
+	for key := range mergeKeys(beforeFields, afterFields) {
+		if key == "_id" {
+			continue
+		}
+		oldValue, newValue := auditValue(beforeFields[key]), auditValue(afterFields[key])
+		if oldValue == newValue {
+			continue
+		}
+		// Для созданной или удалённой записи пустые поля не показываем
+		if (before == nil && isZeroAuditValue(newValue)) || (after == nil && isZeroAuditValue(oldValue)) {
+			continue
+		}
+		if auditRedactedFields[lastKey(key)] {
+			oldValue, newValue = redactAuditValue(oldValue), redactAuditValue(newValue)
+		}
+		changes[key] = models.AuditChange{Before: oldValue, After: newValue}
+	}
+	return changes
+}
+
+func flattenAuditDoc(prefix string, doc interface{}, fields map[string]interface{}) {
+	switch value := doc.(type) {
+	case bson.M:
+		for key, nested := range value {
+			flattenAuditDoc(prefix+key+".", nested, fields)
+		}
+	case bson.D:
+		for _, element := range value {
+			flattenAuditDoc(prefix+element.Key+".", element.Value, fields)
+		}
+	case nil:
+		if prefix != "" {
+			fields[prefix[:len(prefix)-1]] = nil
+		}
+	default:
+		fields[prefix[:len(prefix)-1]] = value
+	}
+}
+
+func mergeKeys(a, b map[string]interface{}) map[string]bool {
+	keys := map[string]bool{}
+	for key := range a {
+		keys[key] = true
+	}
+	for key := range b {
+		keys[key] = true
+	}
+	return keys
+}
+
+func lastKey(key string) string {
+	for i := len(key) - 1; i >= 0; i-- {
+		if key[i] == '.' {
+			return key[i+1:]
+		}
+	}
+	return key
+}
+
+func auditValue(value interface{}) string {
+	if value == nil || (reflect.ValueOf(value).Kind() == reflect.Slice && reflect.ValueOf(value).Len() == 0) {
+		return ""
+	}
+	data, _ := json.Marshal(value)
+	return string(data)
+}
+
+func isZeroAuditValue(value string) bool {
+	switch value {
+	case "", `""`, "0", "false", "null":
+		return true
+	}
+	return false
+}
+
+func redactAuditValue(value string) string {
+	if value == "" {
+		return ""
+	}
+	return `"[redacted]"`
+}
+
+// auditedUpdate обновляет одну запись и возвращает её состояние до и после изменения
+func auditedUpdate(collection *mongo.Collection, filter, update bson.M) (bson.M, bson.M, error) {
+	var before bson.M
+	err := collection.FindOneAndUpdate(context.TODO(), filter, update).Decode(&before)
+	if err != nil {
+		return nil, nil, err
+	}
+	var after bson.M
+	err = collection.FindOne(context.TODO(), bson.M{"_id": before["_id"]}).Decode(&after)
+	return before, after, err
+}
+
+// auditedUpsert — auditedUpdate с созданием документа, если его нет; before тогда nil
+func auditedUpsert(collection *mongo.Collection, filter, update bson.M) (bson.M, bson.M, error) {
+	var before bson.M
+	err := collection.FindOneAndUpdate(context.TODO(), filter, update, options.FindOneAndUpdate().SetUpsert(true)).Decode(&before)
+	if err != nil && err != mongo.ErrNoDocuments {
+		return nil, nil, err
+	}
+	var after bson.M
+	err = collection.FindOne(context.TODO(), filter).Decode(&after)
+	return before, after, err
+}
+
+// auditedDelete удаляет документ и возвращает его для журнала
+func auditedDelete(collection *mongo.Collection, filter bson.M) (bson.M, error) {
+	var before bson.M
+	err := collection.FindOneAndDelete(context.TODO(), filter).Decode(&before)
+	return before, err
+}
+
+// auditQuery — фильтры просмотра журнала: actor, action, target_type, target_id, from, to
+func auditQuery(values url.Values) bson.M {
+	filter := bson.M{}
+	for param, field := range map[string]string{
+		"actor": "actor_id", "action": "action", "target_type": "target_type", "target_id": "target_id",
+	} {
+		if value := values.Get(param); value != "" {
+			filter[field] = value
+		}
+	}
+
+	period := bson.M{}
+	if from, err := time.ParseInLocation("2006-01-02", values.Get("from"), time.Local); err == nil {
+		period["$gte"] = from
+	}
+	if to, err := time.ParseInLocation("2006-01-02", values.Get("to"), time.Local); err == nil {
+		period["$lt"] = to.AddDate(0, 0, 1)
+	}
+	if len(period) > 0 {
+		filter["time"] = period
+	}
+	return filter
+}
+
+// AdminAuditHandler показывает журнал аудита от новых событий к старым.
+// Следующая страница запрашивается параметром before=<seq>.
+func AdminAuditHandler(w http.ResponseWriter, r *http.Request, collection *mongo.Collection) {
+	values := r.URL.Query()
+	filter := auditQuery(values)
+	if before, err := strconv.ParseInt(values.Get("before"), 10, 64); err == nil {
+		filter["seq"] = bson.M{"$lt": before}
+	}
+	limit, _ := strconv.Atoi(values.Get("limit"))
+	if limit <= 0 || limit > MaxAuditPageSize {
+		limit = DefaultAuditPageSize
+	}
+
+	events := []models.AuditEvent{}
+	findOptions := options.Find().SetSort(bson.D{{Key: "seq", Value: -1}}).SetLimit(int64(limit + 1))
+	cursor, err := collection.Find(context.TODO(), filter, findOptions)
+	if err != nil {
+		http.Error(w, "Error fetching audit log", http.StatusInternalServerError)
+		return
+	}
+	defer cursor.Close(context.TODO())
+	if err = cursor.All(context.TODO(), &events); err != nil {
+		http.Error(w, "Error decoding audit log", http.StatusInternalServerError)
+		return
+	}
+
+	next := ""
+	if len(events) > limit {
+		events = events[:limit]
+		nextValues := url.Values{}
+		for key := range values {
+			nextValues.Set(key, values.Get(key))
+		}
+		nextValues.Set("before", strconv.FormatInt(events[len(events)-1].Seq, 10))
+		next = r.URL.Path + "?" + nextValues.Encode()
+	}
+
+	if wantsJSON(r) {
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]interface{}{"events": events, "next": next})
+		return
+	}
+
+	exportValues := url.Values{}
+	for _, key := range []string{"actor", "action", "target_type", "target_id", "from", "to"} {
+		if value := values.Get(key); value != "" {
+			exportValues.Set(key, value)
+		}
+	}
+	renderAdminPage(w, r, "templates/admin_audit.html", map[string]interface{}{
+		"Events":    events,
+		"Filter":    values,
+		"NextPage":  next,
+		"ExportURL": "/admin/audit/export?" + exportValues.Encode(),
+	})
+}
+
+// ExportAuditHandler выгружает журнал в CSV или JSON Lines по возрастанию seq с теми же фильтрами
+func ExportAuditHandler(w http.ResponseWriter, r *http.Request, collection *mongo.Collection) {
+	cursor, err := collection.Find(context.TODO(), auditQuery(r.URL.Query()), options.Find().SetSort(bson.D{{Key: "seq", Value: 1}}))
+	if err != nil {
+		http.Error(w, "Error fetching audit log", http.StatusInternalServerError)
+		return
+	}
+	defer cursor.Close(context.TODO())
+
+	export := newExportWriter(w, r, "audit", []string{
+		"seq", "time", "actor_id", "actor_role", "action", "target_type", "target_id",
+		"changes", "details", "ip", "request_id", "prev_hash", "hash",
+	})
+	for cursor.Next(context.TODO()) {
+		var event models.AuditEvent
+		if err := cursor.Decode(&event); err != nil {
+			continue
+		}
+		changes := ""
+		if len(event.Changes) > 0 {
+			data, _ := json.Marshal(event.Changes)
+			changes = string(data)
+		}
+		export.Write(event, []string{
+			strconv.FormatInt(event.Seq, 10), event.Time.Format(time.RFC3339), event.ActorID, event.ActorRole,
+			event.Action, event.TargetType, event.TargetID, changes, event.Details, event.IP, event.RequestID,
+			event.PrevHash, event.Hash,
+		})
+	}
+	export.Flush()
+}
+
+// VerifyAuditChainHandler проходит журнал целиком и проверяет непрерывность seq и цепочку хешей
+func VerifyAuditChainHandler(w http.ResponseWriter, r *http.Request, collection *mongo.Collection) {
+	cursor, err := collection.Find(context.TODO(), bson.M{}, options.Find().SetSort(bson.D{{Key: "seq", Value: 1}}))
+	if err != nil {
+		http.Error(w, "Error fetching audit log", http.StatusInternalServerError)
+		return
+	}
+	defer cursor.Close(context.TODO())
+
+	result := map[string]interface{}{"valid": true}
+	var checked int64
+	prevHash := ""
+	for cursor.Next(context.TODO()) {
+		var event models.AuditEvent
+		if err := cursor.Decode(&event); err != nil {
+			http.Error(w, "Error decoding audit log", http.StatusInternalServerError)
+			return
+		}
+
+		reason := ""
+		switch {
+		case event.Seq != checked+1:
+			reason = "missing events before this sequence number"
+		case event.PrevHash != prevHash:
+			reason = "previous hash does not match"
+		case auditHash(event) != event.Hash:
+			reason = "event content does not match its hash"
+		}
+		if reason != "" {
+			result = map[string]interface{}{"valid": false, "broken_at": event.Seq, "reason": reason}
+			break
+		}
+		checked++
+		prevHash = event.Hash
+	}
+	result["checked"] = checked
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(result)
+}
+
+// EnsureAuditIndexes создаёт индексы журнала; уникальный seq не даёт разветвить цепочку
+func EnsureAuditIndexes(collection *mongo.Collection) error {
+	_, err := collection.Indexes().CreateMany(context.TODO(), []mongo.IndexModel{
+		{Keys: bson.D{{Key: "seq", Value: 1}}, Options: options.Index().SetUnique(true)},
+		{Keys: bson.D{{Key: "actor_id", Value: 1}, {Key: "seq", Value: -1}}},
+		{Keys: bson.D{{Key: "target_id", Value: 1}, {Key: "seq", Value: -1}}},
+		{Keys: bson.D{{Key: "action", Value: 1}, {Key: "seq", Value: -1}}},
+		{Keys: bson.D{{Key: "time", Value: 1}}},
+	})
+	return err
+}
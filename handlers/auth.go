@@ -5,25 +5,49 @@ import (
 	"encoding/json"
 	"net/http"
 	"net/url"
+	"strings"
 	"time"
 
 	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
 	"go.mongodb.org/mongo-driver/mongo"
 	"golang.org/x/crypto/bcrypt"
-	"gopkg.in/mail.v2"
 
+	"fitnesshub/middleware"
 	"fitnesshub/models"
 	"fitnesshub/utils"
 )
 
-func SignUpHandler(w http.ResponseWriter, r *http.Request, collection *mongo.Collection) {
-	var user models.User
-	err := json.NewDecoder(r.Body).Decode(&user)
+// signUpRequest — тело запроса регистрации; name используется, если не переданы first_name и last_name
+type signUpRequest struct {
+	Name      string `json:"name"`
+	FirstName string `json:"first_name"`
+	LastName  string `json:"last_name"`
+	Email     string `json:"email"`
+	Password  string `json:"password"`
+}
+
+func SignUpHandler(w http.ResponseWriter, r *http.Request, collection, auditCollection *mongo.Collection) {
+	var request signUpRequest
+	err := json.NewDecoder(r.Body).Decode(&request)
 	if err != nil {
 		http.Error(w, "Invalid JSON message", http.StatusBadRequest)
 		return
 	}
 
+	// Форма регистрации передаёт имя одной строкой — делим его на имя и фамилию
+	if request.FirstName == "" && request.LastName == "" {
+		request.FirstName, request.LastName, _ = strings.Cut(strings.TrimSpace(request.Name), " ")
+	}
+	user := models.User{
+		Email:    normalizeEmail(request.Email),
+		Password: request.Password,
+		Profile: models.Profile{
+			FirstName: strings.TrimSpace(request.FirstName),
+			LastName:  strings.TrimSpace(request.LastName),
+		},
+	}
+
 	// Хешируем пароль
 	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(user.Password), bcrypt.DefaultCost)
 	if err != nil {
@@ -42,24 +66,27 @@ func SignUpHandler(w http.ResponseWriter, r *http.Request, collection *mongo.Col
 	}
 	user.VerificationToken = verificationToken
 
-	_, err = collection.InsertOne(context.TODO(), user)
+	result, err := collection.InsertOne(context.TODO(), user)
+	if mongo.IsDuplicateKeyError(err) {
+		http.Error(w, "A user with this email already exists", http.StatusConflict)
+		return
+	}
 	if err != nil {
 		http.Error(w, "Error adding user", http.StatusInternalServerError)
 		return
 	}
+	user.ID = result.InsertedID.(primitive.ObjectID)
+
+	event := auditEvent(r, "auth.signup", "users", user.ID.Hex())
+	event.ActorID = user.ID.Hex()
+	event.Changes = auditDiff(nil, toAuditDoc(user))
+	recordAudit(auditCollection, event)
 
 	// Ссылка на подтверждение
 	verificationLink := "http://localhost:8081/verify?token=" + url.QueryEscape(verificationToken)
 
 	// Отправляем email
-	m := mail.NewMessage()
-	m.SetHeader("From", "no-reply@fitnesshub.com")
-	m.SetHeader("To", user.Email)
-	m.SetHeader("Subject", "Verify your email")
-	m.SetBody("text/plain", "Please verify your email by clicking the following link: "+verificationLink)
-
-	d := mail.NewDialer("smtp.mailtrap.io", 2525, "9c521257de733d", "ae506c9d02f243")
-	err = d.DialAndSend(m)
+	err = utils.SendEmail(user.Email, "Verify your email", "Please verify your email by clicking the following link: "+verificationLink)
 	if err != nil {
 		http.Error(w, "Error sending verification email", http.StatusInternalServerError)
 		return
@@ -69,7 +96,7 @@ func SignUpHandler(w http.ResponseWriter, r *http.Request, collection *mongo.Col
 	json.NewEncoder(w).Encode(map[string]string{"status": "success", "message": "User registered successfully. Check your email for verification."})
 }
 
-func VerifyEmailHandler(w http.ResponseWriter, r *http.Request, collection *mongo.Collection) {
+func VerifyEmailHandler(w http.ResponseWriter, r *http.Request, collection, auditCollection *mongo.Collection) {
 	token := r.URL.Query().Get("token")
 	if token == "" {
 		http.Error(w, "Verification token is required", http.StatusBadRequest)
@@ -77,7 +104,7 @@ func VerifyEmailHandler(w http.ResponseWriter, r *http.Request, collection *mong
 	}
 
 	var user models.User
-	err := collection.FindOne(context.TODO(), bson.M{"verification_token": token}).Decode(&user)
+	err := collection.FindOne(context.TODO(), notDeleted(bson.M{"verification_token": token})).Decode(&user)
 	if err != nil {
 		http.Error(w, "Invalid or expired verification token", http.StatusBadRequest)
 		return
@@ -92,55 +119,209 @@ func VerifyEmailHandler(w http.ResponseWriter, r *http.Request, collection *mong
 		return
 	}
 
+	event := auditEvent(r, "auth.verify_email", "users", user.ID.Hex())
+	event.ActorID = user.ID.Hex()
+	recordAudit(auditCollection, event)
+
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(map[string]string{"status": "success", "message": "Email verified successfully"})
 }
 
-func LoginHandler(w http.ResponseWriter, r *http.Request, collection *mongo.Collection) {
-	var credentials struct {
-		Email    string `json:"email"`
-		Password string `json:"password"`
-	}
+// loginRequest — email и пароль для входа
+type loginRequest struct {
+	Email    string `json:"email"`
+	Password string `json:"password"`
+}
+
+func LoginHandler(w http.ResponseWriter, r *http.Request, collection, attemptCollection, auditCollection *mongo.Collection) {
+	var credentials loginRequest
 	err := json.NewDecoder(r.Body).Decode(&credentials)
 	if err != nil {
 		http.Error(w, "Invalid JSON message", http.StatusBadRequest)
 		return
 	}
+	credentials.Email = normalizeEmail(credentials.Email)
+
+	// Пока действует задержка или блокировка, пароль даже не проверяем
+	now := time.Now()
+	if wait := loginRetryAfter(attemptCollection, now, accountAttemptsKey(credentials.Email), ipAttemptsKey(r)); wait > 0 {
+		// Неудачные попытки записываем в журнал с email в качестве цели — пользователя может не существовать
+		failed := auditEvent(r, "auth.login_failed", "users", credentials.Email)
+		failed.Details = "throttled"
+		recordAudit(auditCollection, failed)
+		writeTooManyAttempts(w, wait)
+		return
+	}
 
 	var user models.User
-	err = collection.FindOne(context.TODO(), bson.M{"email": credentials.Email}).Decode(&user)
+	err = collection.FindOne(context.TODO(), notDeleted(bson.M{"email": credentials.Email})).Decode(&user)
 	if err != nil {
+		registerLoginFailure(r, attemptCollection, auditCollection, credentials.Email, nil, "unknown email", now)
 		http.Error(w, "Invalid email or password", http.StatusUnauthorized)
 		return
 	}
 
 	err = bcrypt.CompareHashAndPassword([]byte(user.Password), []byte(credentials.Password))
 	if err != nil {
+		registerLoginFailure(r, attemptCollection, auditCollection, credentials.Email, &user, "wrong password", now)
 		http.Error(w, "Invalid email or password", http.StatusUnauthorized)
 		return
 	}
 
 	if !user.Verified {
+		failed := auditEvent(r, "auth.login_failed", "users", credentials.Email)
+		failed.Details = "email not verified"
+		recordAudit(auditCollection, failed)
 		http.Error(w, "Email not verified", http.StatusUnauthorized)
 		return
 	}
 
-	// Генерация JWT токена
-	token, err := utils.GenerateJWT(user.ID.Hex(), user.Role)
+	if requireSecondFactor(w, user) {
+		return
+	}
+
+	token, err := startSession(w, r, user, false, "password", attemptCollection, auditCollection)
 	if err != nil {
 		http.Error(w, "Error generating token", http.StatusInternalServerError)
 		return
 	}
 
+	writeLoginSuccess(w, r, token, nil)
+}
+
+// requireSecondFactor при включённом втором факторе (или если он обязателен для роли)
+// вместо сессии возвращает короткоживущий токен для ввода кода и сообщает, что ответ уже отправлен
+func requireSecondFactor(w http.ResponseWriter, user models.User) bool {
+	status, preAuthToken, err := secondFactorStep(user)
+	if status == "" {
+		return false
+	}
+	if err != nil {
+		http.Error(w, "Error generating token", http.StatusInternalServerError)
+		return true
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{"status": status, "pre_auth_token": preAuthToken})
+	return true
+}
+
+// secondFactorStep возвращает шаг входа, который ждёт пользователя (2fa_required или
+// 2fa_enrollment_required), и токен для него; пустой шаг — второй фактор не нужен
+func secondFactorStep(user models.User) (string, string, error) {
+	if !user.TwoFactor.Enabled && !utils.TwoFactorRequired(user.Role) {
+		return "", "", nil
+	}
+
+	purpose, status := PreAuthTwoFactor, "2fa_required"
+	if !user.TwoFactor.Enabled {
+		purpose, status = PreAuthTwoFactorEnroll, "2fa_enrollment_required"
+	}
+	preAuthToken, err := utils.GeneratePreAuthToken(user.ID.Hex(), purpose)
+	return status, preAuthToken, err
+}
+
+// setPreAuthCookie передаёт токен шага 2FA странице входа после перенаправления: в адресе
+// он попал бы в историю браузера и журналы. Cookie доступна только запросам /login/...
+func setPreAuthCookie(w http.ResponseWriter, preAuthToken string) {
+	http.SetCookie(w, &http.Cookie{
+		Name:     PreAuthCookie,
+		Value:    preAuthToken,
+		Path:     "/login",
+		MaxAge:   int(utils.PreAuthTokenTTL.Seconds()),
+		HttpOnly: true,
+		Secure:   utils.SecureCookies(),
+		SameSite: http.SameSiteLaxMode,
+	})
+}
+
+// registerLoginFailure записывает неудачную попытку входа в журнал и счётчики, блокирует
+// учётную запись или IP при превышении порога и отправляет владельцу письмо для разблокировки
+func registerLoginFailure(r *http.Request, attemptCollection, auditCollection *mongo.Collection, email string, user *models.User, reason string, now time.Time) {
+	failed := auditEvent(r, "auth.login_failed", "users", email)
+	failed.Details = reason
+	recordAudit(auditCollection, failed)
+
+	accountKey := accountAttemptsKey(email)
+	if _, locked := recordLoginFailure(attemptCollection, accountKey, AccountLockThreshold, now); locked {
+		recordAudit(auditCollection, auditEvent(r, "auth.lockout", "users", email))
+		if user != nil {
+			sendUnlockEmail(attemptCollection, accountKey, user.Email)
+		}
+	}
+	if _, locked := recordLoginFailure(attemptCollection, ipAttemptsKey(r), IPLockThreshold, now); locked {
+		recordAudit(auditCollection, auditEvent(r, "auth.lockout", "ip", middleware.ClientIP(r)))
+	}
+}
+
+// startSession выдаёт сессию, сбрасывает счётчик неудачных входов и записывает вход
+// в журнал; method — чем подтверждён вход (password, totp, recovery_code, passkey, oidc:<провайдер>)
+func startSession(w http.ResponseWriter, r *http.Request, user models.User, twoFactor bool, method string, attemptCollection, auditCollection *mongo.Collection) (string, error) {
+	token, err := setSessionCookie(w, user, twoFactor)
+	if err != nil {
+		return "", err
+	}
+
+	clearLoginFailures(attemptCollection, accountAttemptsKey(user.Email))
+	// Токен шага 2FA после входа через провайдера больше не нужен
+	if _, err := r.Cookie(PreAuthCookie); err == nil {
+		http.SetCookie(w, &http.Cookie{Name: PreAuthCookie, Path: "/login", MaxAge: -1})
+	}
+
+	event := auditEvent(r, "auth.login", "users", user.ID.Hex())
+	event.ActorID, event.ActorRole = user.ID.Hex(), user.Role
+	event.Details = method
+	recordAudit(auditCollection, event)
+	return token, nil
+}
+
+// setSessionCookie выдаёт сессионный JWT в cookie token
+func setSessionCookie(w http.ResponseWriter, user models.User, twoFactor bool) (string, error) {
+	// Генерация JWT токена
+	token, err := utils.GenerateJWT(user.ID.Hex(), user.Role, twoFactor)
+	if err != nil {
+		return "", err
+	}
+
+	expires := time.Now().Add(time.Hour * 24)
 	http.SetCookie(w, &http.Cookie{
 		Name:     "token",
 		Value:    token,
-		Expires:  time.Now().Add(time.Hour * 24),
+		Path:     "/",
+		Expires:  expires,
 		HttpOnly: true,
-		Secure:   true,
+		Secure:   utils.SecureCookies(),
 		SameSite: http.SameSiteLaxMode,
 	})
+	// CSRF-токен сессии кладём в cookie без HttpOnly, чтобы скрипт мог передать его в заголовке
+	http.SetCookie(w, &http.Cookie{
+		Name:     middleware.CSRFCookie,
+		Value:    utils.GenerateCSRFToken(token),
+		Path:     "/",
+		Expires:  expires,
+		Secure:   utils.SecureCookies(),
+		SameSite: http.SameSiteStrictMode,
+	})
+	return token, nil
+}
 
+// isBrowserRequest отличает запросы браузера (fetch и формы всегда передают Origin
+// или Sec-Fetch-Mode) от скриптов и мобильных клиентов
+func isBrowserRequest(r *http.Request) bool {
+	return r.Header.Get("Origin") != "" || r.Header.Get("Sec-Fetch-Mode") != ""
+}
+
+// writeLoginSuccess сообщает об успешном входе. Браузеру JWT в теле ответа не отдаём — он
+// уже в HttpOnly cookie; остальным клиентам возвращаем его вместе с CSRF-токеном.
+func writeLoginSuccess(w http.ResponseWriter, r *http.Request, token string, fields map[string]interface{}) {
+	response := map[string]interface{}{"status": "success"}
+	for key, value := range fields {
+		response[key] = value
+	}
+	if !isBrowserRequest(r) {
+		response["token"] = token
+		response["csrf_token"] = utils.GenerateCSRFToken(token)
+	}
 	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(map[string]string{"status": "success", "token": token})
+	w.Header().Set("Cache-Control", "no-store")
+	json.NewEncoder(w).Encode(response)
 }
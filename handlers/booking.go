@@ -0,0 +1,319 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"net/http"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+
+	"fitnesshub/middleware"
+	"fitnesshub/models"
+	"fitnesshub/utils"
+)
+
+const (
+	// Отменить запись можно не позднее чем за CancellationCutoff до начала занятия
+	CancellationCutoff = 2 * time.Hour
+	// После NoShowLimit неявок за NoShowWindow запись на занятия блокируется
+	NoShowLimit  = 3
+	NoShowWindow = 30 * 24 * time.Hour
+)
+
+// EnsureBookingIndexes создаёт уникальный индекс, не позволяющий записаться на одно занятие дважды,
+// и индекс для выборки записей пользователя
+func EnsureBookingIndexes(collection *mongo.Collection) error {
+	_, err := collection.Indexes().CreateMany(context.TODO(), []mongo.IndexModel{
+		{
+			Keys:    bson.D{{Key: "session_id", Value: 1}, {Key: "user_id", Value: 1}},
+			Options: options.Index().SetUnique(true),
+		},
+		// Записи пользователя: /bookings и пакетная загрузка в GraphQL
+		{Keys: bson.D{{Key: "user_id", Value: 1}, {Key: "created_at", Value: -1}}},
+	})
+	return err
+}
+
+// bookingRequest — занятие, на которое записывается участник
+type bookingRequest struct {
+	SessionID primitive.ObjectID `json:"session_id"`
+}
+
+// BookSessionHandler записывает пользователя на занятие или ставит в лист ожидания, если мест нет
+func BookSessionHandler(w http.ResponseWriter, r *http.Request, bookingCollection, sessionCollection *mongo.Collection) {
+	var request bookingRequest
+	err := json.NewDecoder(r.Body).Decode(&request)
+	if err != nil {
+		http.Error(w, "Invalid JSON message", http.StatusBadRequest)
+		return
+	}
+
+	userID, err := primitive.ObjectIDFromHex(middleware.GetUserID(r))
+	if err != nil {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	noShows, err := bookingCollection.CountDocuments(context.TODO(), bson.M{
+		"user_id":    userID,
+		"status":     models.BookingStatusNoShow,
+		"updated_at": bson.M{"$gte": time.Now().Add(-NoShowWindow)},
+	})
+	if err != nil {
+		http.Error(w, "Error checking booking history", http.StatusInternalServerError)
+		return
+	}
+	if noShows >= NoShowLimit {
+		http.Error(w, "Booking is restricted due to repeated no-shows", http.StatusForbidden)
+		return
+	}
+
+	var session models.ClassSession
+	err = sessionCollection.FindOne(context.TODO(), bson.M{
+		"_id":       request.SessionID,
+		"cancelled": false,
+		"starts_at": bson.M{"$gt": time.Now()},
+	}).Decode(&session)
+	if err != nil {
+		http.Error(w, "Session not found", http.StatusNotFound)
+		return
+	}
+
+	var existing models.Booking
+	err = bookingCollection.FindOne(context.TODO(), bson.M{"session_id": session.ID, "user_id": userID}).Decode(&existing)
+	if err == nil && existing.Status != models.BookingStatusCancelled {
+		http.Error(w, "You already have a booking for this session", http.StatusConflict)
+		return
+	}
+
+	// Место занимается атомарно: счётчик увеличивается, только если он меньше вместимости
+	status := models.BookingStatusBooked
+	err = sessionCollection.FindOneAndUpdate(context.TODO(),
+		bson.M{"_id": session.ID, "$expr": bson.M{"$lt": bson.A{"$booked", "$capacity"}}},
+		bson.M{"$inc": bson.M{"booked": 1}}).Err()
+	if err == mongo.ErrNoDocuments {
+		status = models.BookingStatusWaitlisted
+	} else if err != nil {
+		http.Error(w, "Error booking session", http.StatusInternalServerError)
+		return
+	}
+
+	now := time.Now()
+	_, err = bookingCollection.UpdateOne(context.TODO(),
+		bson.M{"session_id": session.ID, "user_id": userID, "status": bson.M{"$in": bson.A{nil, models.BookingStatusCancelled}}},
+		bson.M{
+			"$set":   bson.M{"status": status, "created_at": now, "updated_at": now},
+			"$unset": bson.M{"cancelled_at": ""},
+		},
+		options.Update().SetUpsert(true))
+	if err != nil {
+		// Параллельный запрос того же пользователя уже создал запись — возвращаем место
+		if status == models.BookingStatusBooked {
+			sessionCollection.UpdateOne(context.TODO(), bson.M{"_id": session.ID}, bson.M{"$inc": bson.M{"booked": -1}})
+		}
+		if mongo.IsDuplicateKeyError(err) {
+			http.Error(w, "You already have a booking for this session", http.StatusConflict)
+			return
+		}
+		http.Error(w, "Error booking session", http.StatusInternalServerError)
+		return
+	}
+
+	message := "Session booked successfully"
+	if status == models.BookingStatusWaitlisted {
+		message = "Session is full, you have been added to the waitlist"
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{"status": "success", "booking_status": status, "message": message})
+}
+
+// CancelBookingHandler отменяет запись пользователя и переводит первого из листа ожидания на освободившееся место
+func CancelBookingHandler(w http.ResponseWriter, r *http.Request, bookingCollection, sessionCollection, userCollection *mongo.Collection) {
+	objID, err := primitive.ObjectIDFromHex(routeParam(r, "id"))
+	if err != nil {
+		http.Error(w, "Invalid booking ID", http.StatusBadRequest)
+		return
+	}
+
+	userID, err := primitive.ObjectIDFromHex(middleware.GetUserID(r))
+	if err != nil {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	var booking models.Booking
+	err = bookingCollection.FindOne(context.TODO(), bson.M{"_id": objID, "user_id": userID}).Decode(&booking)
+	if err != nil {
+		http.Error(w, "Booking not found", http.StatusNotFound)
+		return
+	}
+
+	if booking.Status != models.BookingStatusBooked && booking.Status != models.BookingStatusWaitlisted {
+		http.Error(w, "Booking cannot be cancelled", http.StatusConflict)
+		return
+	}
+
+	var session models.ClassSession
+	err = sessionCollection.FindOne(context.TODO(), bson.M{"_id": booking.SessionID}).Decode(&session)
+	if err != nil {
+		http.Error(w, "Session not found", http.StatusNotFound)
+		return
+	}
+
+	if booking.Status == models.BookingStatusBooked && time.Until(session.StartsAt) < CancellationCutoff {
+		http.Error(w, "Bookings can no longer be cancelled for this session", http.StatusConflict)
+		return
+	}
+
+	now := time.Now()
+	result, err := bookingCollection.UpdateOne(context.TODO(),
+		bson.M{"_id": booking.ID, "status": booking.Status},
+		bson.M{"$set": bson.M{"status": models.BookingStatusCancelled, "cancelled_at": now, "updated_at": now}})
+	if err != nil {
+		http.Error(w, "Error cancelling booking", http.StatusInternalServerError)
+		return
+	}
+	if result.ModifiedCount == 0 {
+		http.Error(w, "Booking cannot be cancelled", http.StatusConflict)
+		return
+	}
+
+	if booking.Status == models.BookingStatusBooked {
+		err = releaseSpot(session, bookingCollection, sessionCollection, userCollection)
+		if err != nil {
+			http.Error(w, "Error updating session", http.StatusInternalServerError)
+			return
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{"status": "success", "message": "Booking cancelled successfully"})
+}
+
+// releaseSpot передаёт освободившееся место первому в листе ожидания, а если лист пуст — уменьшает счётчик записей
+func releaseSpot(session models.ClassSession, bookingCollection, sessionCollection, userCollection *mongo.Collection) error {
+	var promoted models.Booking
+	err := bookingCollection.FindOneAndUpdate(context.TODO(),
+		bson.M{"session_id": session.ID, "status": models.BookingStatusWaitlisted},
+		bson.M{"$set": bson.M{"status": models.BookingStatusBooked, "updated_at": time.Now()}},
+		options.FindOneAndUpdate().SetSort(bson.D{{Key: "created_at", Value: 1}}).SetReturnDocument(options.After),
+	).Decode(&promoted)
+	if err == mongo.ErrNoDocuments {
+		_, err = sessionCollection.UpdateOne(context.TODO(), bson.M{"_id": session.ID}, bson.M{"$inc": bson.M{"booked": -1}})
+		return err
+	}
+	if err != nil {
+		return err
+	}
+
+	var user models.User
+	err = userCollection.FindOne(context.TODO(), notDeleted(bson.M{"_id": promoted.UserID})).Decode(&user)
+	if err != nil {
+		log.Println("Waitlist promotion: user not found:", promoted.UserID.Hex())
+		return nil
+	}
+
+	body := "A spot has opened up and you are now booked for " + session.ClassName +
+		" on " + session.StartsAt.Format("Monday, 02 January 2006 at 15:04") + "."
+	if err = utils.SendEmail(user.Email, "You're booked: "+session.ClassName, body); err != nil {
+		log.Println("Waitlist promotion: error sending email:", err)
+	}
+	return nil
+}
+
+func GetMyBookingsHandler(w http.ResponseWriter, r *http.Request, collection *mongo.Collection) {
+	userID, err := primitive.ObjectIDFromHex(middleware.GetUserID(r))
+	if err != nil {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	var bookings []models.Booking
+	cursor, err := collection.Find(context.TODO(), bson.M{"user_id": userID}, options.Find().SetSort(bson.D{{Key: "created_at", Value: -1}}))
+	if err != nil {
+		http.Error(w, "Error fetching bookings", http.StatusInternalServerError)
+		return
+	}
+	defer cursor.Close(context.TODO())
+
+	for cursor.Next(context.TODO()) {
+		var booking models.Booking
+		cursor.Decode(&booking)
+		bookings = append(bookings, booking)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(bookings)
+}
+
+// GetSessionBookingsHandler возвращает список записавшихся и лист ожидания для ?session_id=
+func GetSessionBookingsHandler(w http.ResponseWriter, r *http.Request, collection *mongo.Collection) {
+	sessionID, err := primitive.ObjectIDFromHex(routeParam(r, "session_id"))
+	if err != nil {
+		http.Error(w, "Invalid session ID", http.StatusBadRequest)
+		return
+	}
+
+	var bookings []models.Booking
+	cursor, err := collection.Find(context.TODO(), bson.M{"session_id": sessionID}, options.Find().SetSort(bson.D{{Key: "created_at", Value: 1}}))
+	if err != nil {
+		http.Error(w, "Error fetching bookings", http.StatusInternalServerError)
+		return
+	}
+	defer cursor.Close(context.TODO())
+
+	for cursor.Next(context.TODO()) {
+		var booking models.Booking
+		cursor.Decode(&booking)
+		bookings = append(bookings, booking)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(bookings)
+}
+
+// attendanceRequest — отметка посещения записи
+type attendanceRequest struct {
+	BookingID primitive.ObjectID `json:"booking_id"`
+	Status    string             `json:"status"`
+}
+
+// MarkAttendanceHandler отмечает посещение или неявку по записи
+func MarkAttendanceHandler(w http.ResponseWriter, r *http.Request, collection, auditCollection *mongo.Collection) {
+	var request attendanceRequest
+	err := json.NewDecoder(r.Body).Decode(&request)
+	if err != nil {
+		http.Error(w, "Invalid JSON message", http.StatusBadRequest)
+		return
+	}
+
+	if request.Status != models.BookingStatusAttended && request.Status != models.BookingStatusNoShow {
+		http.Error(w, "Status must be attended or no_show", http.StatusBadRequest)
+		return
+	}
+
+	before, after, err := auditedUpdate(collection,
+		bson.M{"_id": request.BookingID, "status": bson.M{"$in": bson.A{models.BookingStatusBooked, models.BookingStatusAttended, models.BookingStatusNoShow}}},
+		bson.M{"$set": bson.M{"status": request.Status, "updated_at": time.Now()}})
+	if err == mongo.ErrNoDocuments {
+		http.Error(w, "Booking not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		http.Error(w, "Error updating booking", http.StatusInternalServerError)
+		return
+	}
+
+	event := auditEvent(r, "bookings.attendance", "bookings", request.BookingID.Hex())
+	event.Changes = auditDiff(before, after)
+	recordAudit(auditCollection, event)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{"status": "success", "message": "Attendance updated successfully"})
+}
@@ -0,0 +1,650 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+
+	"fitnesshub/models"
+	"fitnesshub/utils"
+)
+
+// Количество недель вперёд, на которые из расписания создаются занятия
+const MaterializationWeeks = 8
+
+func CreateClassTypeHandler(w http.ResponseWriter, r *http.Request, collection, auditCollection *mongo.Collection) {
+	var classType models.ClassType
+	err := json.NewDecoder(r.Body).Decode(&classType)
+	if err != nil {
+		http.Error(w, "Invalid JSON message", http.StatusBadRequest)
+		return
+	}
+
+	if classType.Name == "" || classType.DurationMinutes <= 0 {
+		http.Error(w, "Name and positive duration_minutes are required", http.StatusBadRequest)
+		return
+	}
+
+	result, err := collection.InsertOne(context.TODO(), classType)
+	if err != nil {
+		http.Error(w, "Error adding class type", http.StatusInternalServerError)
+		return
+	}
+	classType.ID = result.InsertedID.(primitive.ObjectID)
+
+	event := auditEvent(r, "class_types.create", "class_types", classType.ID.Hex())
+	event.Changes = auditDiff(nil, toAuditDoc(classType))
+	recordAudit(auditCollection, event)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{"status": "success", "message": "Class type added successfully"})
+}
+
+func GetAllClassTypesHandler(w http.ResponseWriter, r *http.Request, collection *mongo.Collection) {
+	var classTypes []models.ClassType
+	cursor, err := collection.Find(context.TODO(), bson.M{})
+	if err != nil {
+		http.Error(w, "Error fetching class types", http.StatusInternalServerError)
+		return
+	}
+	defer cursor.Close(context.TODO())
+
+	for cursor.Next(context.TODO()) {
+		var classType models.ClassType
+		cursor.Decode(&classType)
+		classTypes = append(classTypes, classType)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(classTypes)
+}
+
+func UpdateClassTypeByIDHandler(w http.ResponseWriter, r *http.Request, collection, auditCollection *mongo.Collection) {
+	var classType models.ClassType
+	err := json.NewDecoder(r.Body).Decode(&classType)
+	if err != nil {
+		http.Error(w, "Invalid JSON message", http.StatusBadRequest)
+		return
+	}
+
+	pathObjectID(r, &classType.ID)
+	if classType.ID.IsZero() {
+		http.Error(w, "Class type ID is required", http.StatusBadRequest)
+		return
+	}
+
+	before, after, err := auditedUpdate(collection, bson.M{"_id": classType.ID}, bson.M{"$set": classType})
+	if err == mongo.ErrNoDocuments {
+		http.Error(w, "Class type not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		http.Error(w, "Error updating class type", http.StatusInternalServerError)
+		return
+	}
+
+	event := auditEvent(r, "class_types.update", "class_types", classType.ID.Hex())
+	event.Changes = auditDiff(before, after)
+	recordAudit(auditCollection, event)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{"status": "success", "message": "Class type updated successfully"})
+}
+
+func DeleteClassTypeByIDHandler(w http.ResponseWriter, r *http.Request, collection, auditCollection *mongo.Collection) {
+	objID, err := primitive.ObjectIDFromHex(routeParam(r, "id"))
+	if err != nil {
+		http.Error(w, "Invalid class type ID", http.StatusBadRequest)
+		return
+	}
+
+	before, err := auditedDelete(collection, bson.M{"_id": objID})
+	if err == mongo.ErrNoDocuments {
+		http.Error(w, "Class type not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		http.Error(w, "Error deleting class type", http.StatusInternalServerError)
+		return
+	}
+
+	event := auditEvent(r, "class_types.delete", "class_types", objID.Hex())
+	event.Changes = auditDiff(before, nil)
+	recordAudit(auditCollection, event)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{"status": "success", "message": "Class type deleted successfully"})
+}
+
+func CreateRoomHandler(w http.ResponseWriter, r *http.Request, collection, auditCollection *mongo.Collection) {
+	var room models.Room
+	err := json.NewDecoder(r.Body).Decode(&room)
+	if err != nil {
+		http.Error(w, "Invalid JSON message", http.StatusBadRequest)
+		return
+	}
+
+	if room.Name == "" || room.Capacity <= 0 {
+		http.Error(w, "Name and positive capacity are required", http.StatusBadRequest)
+		return
+	}
+
+	result, err := collection.InsertOne(context.TODO(), room)
+	if err != nil {
+		http.Error(w, "Error adding room", http.StatusInternalServerError)
+		return
+	}
+	room.ID = result.InsertedID.(primitive.ObjectID)
+
+	event := auditEvent(r, "rooms.create", "rooms", room.ID.Hex())
+	event.Changes = auditDiff(nil, toAuditDoc(room))
+	recordAudit(auditCollection, event)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{"status": "success", "message": "Room added successfully"})
+}
+
+func GetAllRoomsHandler(w http.ResponseWriter, r *http.Request, collection *mongo.Collection) {
+	var rooms []models.Room
+	cursor, err := collection.Find(context.TODO(), bson.M{})
+	if err != nil {
+		http.Error(w, "Error fetching rooms", http.StatusInternalServerError)
+		return
+	}
+	defer cursor.Close(context.TODO())
+
+	for cursor.Next(context.TODO()) {
+		var room models.Room
+		cursor.Decode(&room)
+		rooms = append(rooms, room)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(rooms)
+}
+
+func UpdateRoomByIDHandler(w http.ResponseWriter, r *http.Request, collection, auditCollection *mongo.Collection) {
+	var room models.Room
+	err := json.NewDecoder(r.Body).Decode(&room)
+	if err != nil {
+		http.Error(w, "Invalid JSON message", http.StatusBadRequest)
+		return
+	}
+
+	pathObjectID(r, &room.ID)
+	if room.ID.IsZero() {
+		http.Error(w, "Room ID is required", http.StatusBadRequest)
+		return
+	}
+
+	before, after, err := auditedUpdate(collection, bson.M{"_id": room.ID}, bson.M{"$set": room})
+	if err == mongo.ErrNoDocuments {
+		http.Error(w, "Room not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		http.Error(w, "Error updating room", http.StatusInternalServerError)
+		return
+	}
+
+	event := auditEvent(r, "rooms.update", "rooms", room.ID.Hex())
+	event.Changes = auditDiff(before, after)
+	recordAudit(auditCollection, event)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{"status": "success", "message": "Room updated successfully"})
+}
+
+func DeleteRoomByIDHandler(w http.ResponseWriter, r *http.Request, collection, auditCollection *mongo.Collection) {
+	objID, err := primitive.ObjectIDFromHex(routeParam(r, "id"))
+	if err != nil {
+		http.Error(w, "Invalid room ID", http.StatusBadRequest)
+		return
+	}
+
+	before, err := auditedDelete(collection, bson.M{"_id": objID})
+	if err == mongo.ErrNoDocuments {
+		http.Error(w, "Room not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		http.Error(w, "Error deleting room", http.StatusInternalServerError)
+		return
+	}
+
+	event := auditEvent(r, "rooms.delete", "rooms", objID.Hex())
+	event.Changes = auditDiff(before, nil)
+	recordAudit(auditCollection, event)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{"status": "success", "message": "Room deleted successfully"})
+}
+
+func CreateInstructorHandler(w http.ResponseWriter, r *http.Request, collection, auditCollection *mongo.Collection) {
+	var instructor models.Instructor
+	err := json.NewDecoder(r.Body).Decode(&instructor)
+	if err != nil {
+		http.Error(w, "Invalid JSON message", http.StatusBadRequest)
+		return
+	}
+
+	if instructor.Name == "" {
+		http.Error(w, "Name is required", http.StatusBadRequest)
+		return
+	}
+
+	result, err := collection.InsertOne(context.TODO(), instructor)
+	if err != nil {
+		http.Error(w, "Error adding instructor", http.StatusInternalServerError)
+		return
+	}
+	instructor.ID = result.InsertedID.(primitive.ObjectID)
+
+	event := auditEvent(r, "instructors.create", "instructors", instructor.ID.Hex())
+	event.Changes = auditDiff(nil, toAuditDoc(instructor))
+	recordAudit(auditCollection, event)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{"status": "success", "message": "Instructor added successfully"})
+}
+
+func GetAllInstructorsHandler(w http.ResponseWriter, r *http.Request, collection *mongo.Collection) {
+	var instructors []models.Instructor
+	cursor, err := collection.Find(context.TODO(), bson.M{})
+	if err != nil {
+		http.Error(w, "Error fetching instructors", http.StatusInternalServerError)
+		return
+	}
+	defer cursor.Close(context.TODO())
+
+	for cursor.Next(context.TODO()) {
+		var instructor models.Instructor
+		cursor.Decode(&instructor)
+		instructors = append(instructors, instructor)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(instructors)
+}
+
+func UpdateInstructorByIDHandler(w http.ResponseWriter, r *http.Request, collection, auditCollection *mongo.Collection) {
+	var instructor models.Instructor
+	err := json.NewDecoder(r.Body).Decode(&instructor)
+	if err != nil {
+		http.Error(w, "Invalid JSON message", http.StatusBadRequest)
+		return
+	}
+
+	pathObjectID(r, &instructor.ID)
+	if instructor.ID.IsZero() {
+		http.Error(w, "Instructor ID is required", http.StatusBadRequest)
+		return
+	}
+
+	before, after, err := auditedUpdate(collection, bson.M{"_id": instructor.ID}, bson.M{"$set": instructor})
+	if err == mongo.ErrNoDocuments {
+		http.Error(w, "Instructor not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		http.Error(w, "Error updating instructor", http.StatusInternalServerError)
+		return
+	}
+
+	event := auditEvent(r, "instructors.update", "instructors", instructor.ID.Hex())
+	event.Changes = auditDiff(before, after)
+	recordAudit(auditCollection, event)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{"status": "success", "message": "Instructor updated successfully"})
+}
+
+func DeleteInstructorByIDHandler(w http.ResponseWriter, r *http.Request, collection, auditCollection *mongo.Collection) {
+	objID, err := primitive.ObjectIDFromHex(routeParam(r, "id"))
+	if err != nil {
+		http.Error(w, "Invalid instructor ID", http.StatusBadRequest)
+		return
+	}
+
+	before, err := auditedDelete(collection, bson.M{"_id": objID})
+	if err == mongo.ErrNoDocuments {
+		http.Error(w, "Instructor not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		http.Error(w, "Error deleting instructor", http.StatusInternalServerError)
+		return
+	}
+
+	event := auditEvent(r, "instructors.delete", "instructors", objID.Hex())
+	event.Changes = auditDiff(before, nil)
+	recordAudit(auditCollection, event)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{"status": "success", "message": "Instructor deleted successfully"})
+}
+
+func CreateScheduleHandler(w http.ResponseWriter, r *http.Request, scheduleCollection, classTypeCollection, roomCollection, sessionCollection, auditCollection *mongo.Collection) {
+	var schedule models.ClassSchedule
+	err := json.NewDecoder(r.Body).Decode(&schedule)
+	if err != nil {
+		http.Error(w, "Invalid JSON message", http.StatusBadRequest)
+		return
+	}
+
+	if _, err = scheduleStart(schedule); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if _, err = utils.ParseWeeklyRule(schedule.Recurrence); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if status, err := checkScheduleReferences(schedule, classTypeCollection, roomCollection); err != nil {
+		http.Error(w, err.Error(), status)
+		return
+	}
+
+	result, err := scheduleCollection.InsertOne(context.TODO(), schedule)
+	if err != nil {
+		http.Error(w, "Error adding schedule", http.StatusInternalServerError)
+		return
+	}
+	schedule.ID = result.InsertedID.(primitive.ObjectID)
+
+	event := auditEvent(r, "class_schedules.create", "class_schedules", schedule.ID.Hex())
+	event.Changes = auditDiff(nil, toAuditDoc(schedule))
+	recordAudit(auditCollection, event)
+
+	err = MaterializeSchedule(schedule, classTypeCollection, roomCollection, sessionCollection, MaterializationWeeks)
+	if err != nil {
+		http.Error(w, "Error creating sessions: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{"status": "success", "message": "Schedule added successfully", "id": schedule.ID.Hex()})
+}
+
+func GetAllSchedulesHandler(w http.ResponseWriter, r *http.Request, collection *mongo.Collection) {
+	var schedules []models.ClassSchedule
+	cursor, err := collection.Find(context.TODO(), bson.M{})
+	if err != nil {
+		http.Error(w, "Error fetching schedules", http.StatusInternalServerError)
+		return
+	}
+	defer cursor.Close(context.TODO())
+
+	for cursor.Next(context.TODO()) {
+		var schedule models.ClassSchedule
+		cursor.Decode(&schedule)
+		schedules = append(schedules, schedule)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(schedules)
+}
+
+func UpdateScheduleByIDHandler(w http.ResponseWriter, r *http.Request, scheduleCollection, classTypeCollection, roomCollection, sessionCollection, auditCollection *mongo.Collection) {
+	var schedule models.ClassSchedule
+	err := json.NewDecoder(r.Body).Decode(&schedule)
+	if err != nil {
+		http.Error(w, "Invalid JSON message", http.StatusBadRequest)
+		return
+	}
+
+	pathObjectID(r, &schedule.ID)
+	if schedule.ID.IsZero() {
+		http.Error(w, "Schedule ID is required", http.StatusBadRequest)
+		return
+	}
+	if _, err = scheduleStart(schedule); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if _, err = utils.ParseWeeklyRule(schedule.Recurrence); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if status, err := checkScheduleReferences(schedule, classTypeCollection, roomCollection); err != nil {
+		http.Error(w, err.Error(), status)
+		return
+	}
+
+	before, after, err := auditedUpdate(scheduleCollection, bson.M{"_id": schedule.ID}, bson.M{"$set": schedule})
+	// Без этой проверки занятия создались бы для расписания, которого нет в базе
+	if err == mongo.ErrNoDocuments {
+		http.Error(w, "Schedule not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		http.Error(w, "Error updating schedule", http.StatusInternalServerError)
+		return
+	}
+
+	event := auditEvent(r, "class_schedules.update", "class_schedules", schedule.ID.Hex())
+	event.Changes = auditDiff(before, after)
+	recordAudit(auditCollection, event)
+
+	err = MaterializeSchedule(schedule, classTypeCollection, roomCollection, sessionCollection, MaterializationWeeks)
+	if err != nil {
+		http.Error(w, "Error updating sessions: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{"status": "success", "message": "Schedule updated successfully"})
+}
+
+func DeleteScheduleByIDHandler(w http.ResponseWriter, r *http.Request, scheduleCollection, sessionCollection, auditCollection *mongo.Collection) {
+	objID, err := primitive.ObjectIDFromHex(routeParam(r, "id"))
+	if err != nil {
+		http.Error(w, "Invalid schedule ID", http.StatusBadRequest)
+		return
+	}
+
+	before, err := auditedDelete(scheduleCollection, bson.M{"_id": objID})
+	if err == mongo.ErrNoDocuments {
+		http.Error(w, "Schedule not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		http.Error(w, "Error deleting schedule", http.StatusInternalServerError)
+		return
+	}
+
+	// Будущие занятия не удаляем, а отменяем, чтобы не потерять записи участников
+	cancelled, err := sessionCollection.UpdateMany(context.TODO(),
+		bson.M{"schedule_id": objID, "starts_at": bson.M{"$gte": time.Now()}},
+		bson.M{"$set": bson.M{"cancelled": true}})
+
+	event := auditEvent(r, "class_schedules.delete", "class_schedules", objID.Hex())
+	event.Changes = auditDiff(before, nil)
+	if err == nil {
+		event.Details = strconv.FormatInt(cancelled.ModifiedCount, 10) + " future sessions cancelled"
+	}
+	recordAudit(auditCollection, event)
+
+	if err != nil {
+		http.Error(w, "Error cancelling sessions", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{"status": "success", "message": "Schedule deleted successfully"})
+}
+
+// MaterializeAllSchedulesHandler пересоздаёт занятия по всем расписаниям на ?weeks= недель вперёд
+func MaterializeAllSchedulesHandler(w http.ResponseWriter, r *http.Request, scheduleCollection, classTypeCollection, roomCollection, sessionCollection, auditCollection *mongo.Collection) {
+	weeks, err := strconv.Atoi(r.URL.Query().Get("weeks"))
+	if err != nil || weeks <= 0 {
+		weeks = MaterializationWeeks
+	}
+
+	cursor, err := scheduleCollection.Find(context.TODO(), bson.M{})
+	if err != nil {
+		http.Error(w, "Error fetching schedules", http.StatusInternalServerError)
+		return
+	}
+	defer cursor.Close(context.TODO())
+
+	count := 0
+	for cursor.Next(context.TODO()) {
+		var schedule models.ClassSchedule
+		if cursor.Decode(&schedule) != nil {
+			continue
+		}
+		err = MaterializeSchedule(schedule, classTypeCollection, roomCollection, sessionCollection, weeks)
+		if err != nil {
+			http.Error(w, "Error creating sessions: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+		count++
+	}
+
+	event := auditEvent(r, "class_schedules.materialize", "class_schedules", "")
+	event.Details = strconv.Itoa(count) + " schedules, " + strconv.Itoa(weeks) + " weeks"
+	recordAudit(auditCollection, event)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{"status": "success", "schedules": count, "weeks": weeks})
+}
+
+// MaterializeSchedule создаёт занятия по расписанию на ближайшие weeks недель.
+// Занятия, которые больше не попадают в расписание (например, из-за новых исключений), отменяются.
+func MaterializeSchedule(schedule models.ClassSchedule, classTypeCollection, roomCollection, sessionCollection *mongo.Collection, weeks int) error {
+	rule, err := utils.ParseWeeklyRule(schedule.Recurrence)
+	if err != nil {
+		return err
+	}
+	start, err := scheduleStart(schedule)
+	if err != nil {
+		return err
+	}
+
+	var classType models.ClassType
+	err = classTypeCollection.FindOne(context.TODO(), bson.M{"_id": schedule.ClassTypeID}).Decode(&classType)
+	if err != nil {
+		return err
+	}
+
+	capacity := schedule.Capacity
+	var room models.Room
+	err = roomCollection.FindOne(context.TODO(), bson.M{"_id": schedule.RoomID}).Decode(&room)
+	if err != nil {
+		return err
+	}
+	if capacity <= 0 || capacity > room.Capacity {
+		capacity = room.Capacity
+	}
+
+	from := time.Now()
+	to := utils.StartOfWeek(from).AddDate(0, 0, 7*weeks)
+	occurrences := rule.Occurrences(start, from, to, schedule.Exceptions)
+
+	startTimes := make([]time.Time, 0, len(occurrences))
+	for _, startsAt := range occurrences {
+		startTimes = append(startTimes, startsAt)
+		filter := bson.M{"schedule_id": schedule.ID, "starts_at": startsAt}
+		update := bson.M{
+			"$set": bson.M{
+				"class_type_id": schedule.ClassTypeID,
+				"class_name":    classType.Name,
+				"room_id":       schedule.RoomID,
+				"instructor_id": schedule.InstructorID,
+				"ends_at":       startsAt.Add(time.Duration(classType.DurationMinutes) * time.Minute),
+				"capacity":      capacity,
+				"cancelled":     false,
+			},
+			"$setOnInsert": bson.M{"booked": 0},
+		}
+		_, err = sessionCollection.UpdateOne(context.TODO(), filter, update, options.Update().SetUpsert(true))
+		if err != nil {
+			return err
+		}
+	}
+
+	_, err = sessionCollection.UpdateMany(context.TODO(),
+		bson.M{
+			"schedule_id": schedule.ID,
+			"starts_at":   bson.M{"$gte": from, "$lt": to, "$nin": startTimes},
+		},
+		bson.M{"$set": bson.M{"cancelled": true}})
+	return err
+}
+
+// TimetableHandler возвращает расписание занятий на неделю, в которую попадает ?week=2006-01-02
+func TimetableHandler(w http.ResponseWriter, r *http.Request, collection *mongo.Collection) {
+	day := time.Now()
+	if week := r.URL.Query().Get("week"); week != "" {
+		parsed, err := time.ParseInLocation("2006-01-02", week, time.Local)
+		if err != nil {
+			http.Error(w, "Invalid week date", http.StatusBadRequest)
+			return
+		}
+		day = parsed
+	}
+
+	weekStart := utils.StartOfWeek(day)
+	filter := bson.M{
+		"starts_at": bson.M{"$gte": weekStart, "$lt": weekStart.AddDate(0, 0, 7)},
+		"cancelled": false,
+	}
+
+	var sessions []models.ClassSession
+	cursor, err := collection.Find(context.TODO(), filter, options.Find().SetSort(bson.D{{Key: "starts_at", Value: 1}}))
+	if err != nil {
+		http.Error(w, "Error fetching timetable", http.StatusInternalServerError)
+		return
+	}
+	defer cursor.Close(context.TODO())
+
+	for cursor.Next(context.TODO()) {
+		var session models.ClassSession
+		cursor.Decode(&session)
+		sessions = append(sessions, session)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{"week_start": weekStart.Format("2006-01-02"), "sessions": sessions})
+}
+
+// checkScheduleReferences проверяет, что тип занятия и зал расписания существуют, до его
+// сохранения: иначе в базе осталось бы расписание, по которому нельзя создать занятия
+func checkScheduleReferences(schedule models.ClassSchedule, classTypeCollection, roomCollection *mongo.Collection) (int, error) {
+	for _, reference := range []struct {
+		collection *mongo.Collection
+		id         primitive.ObjectID
+		message    string
+	}{
+		{classTypeCollection, schedule.ClassTypeID, "Unknown class_type_id"},
+		{roomCollection, schedule.RoomID, "Unknown room_id"},
+	} {
+		count, err := reference.collection.CountDocuments(context.TODO(), bson.M{"_id": reference.id})
+		if err != nil {
+			return http.StatusInternalServerError, errors.New("Error checking schedule references")
+		}
+		if count == 0 {
+			return http.StatusBadRequest, errors.New(reference.message)
+		}
+	}
+	return http.StatusOK, nil
+}
+
+func scheduleStart(schedule models.ClassSchedule) (time.Time, error) {
+	start, err := time.ParseInLocation("2006-01-02 15:04", schedule.StartDate+" "+schedule.StartTime, time.Local)
+	if err != nil {
+		return start, errInvalidScheduleStart
+	}
+	return start, nil
+}
+
+var errInvalidScheduleStart = errors.New("start_date (2006-01-02) and start_time (15:04) are required")
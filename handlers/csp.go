@@ -0,0 +1,68 @@
+package handlers
+
+import (
+	"encoding/json"
+	"io"
+	"log"
+	"mime"
+	"net/http"
+
+	"fitnesshub/middleware"
+)
+
+// cspViolation — поля отчёта о нарушении CSP, общие для report-uri и Reporting API
+type cspViolation struct {
+	DocumentURI        string `json:"document-uri"`
+	DocumentURL        string `json:"documentURL"`
+	ViolatedDirective  string `json:"violated-directive"`
+	EffectiveDirective string `json:"effectiveDirective"`
+	BlockedURI         string `json:"blocked-uri"`
+	BlockedURL         string `json:"blockedURL"`
+	Disposition        string `json:"disposition"`
+}
+
+// CSPReportHandler принимает отчёты браузера о нарушениях Content Security Policy
+// (application/csp-report или application/reports+json) и пишет их в лог сервера
+func CSPReportHandler(w http.ResponseWriter, r *http.Request) {
+	body, err := io.ReadAll(io.LimitReader(r.Body, 64<<10))
+	if err != nil {
+		http.Error(w, "Invalid report", http.StatusBadRequest)
+		return
+	}
+
+	var violations []cspViolation
+	mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if mediaType == "application/reports+json" {
+		var reports []struct {
+			Type string       `json:"type"`
+			Body cspViolation `json:"body"`
+		}
+		if err := json.Unmarshal(body, &reports); err != nil {
+			http.Error(w, "Invalid report", http.StatusBadRequest)
+			return
+		}
+		for _, report := range reports {
+			if report.Type == "csp-violation" {
+				violations = append(violations, report.Body)
+			}
+		}
+	} else {
+		var report struct {
+			CSPReport cspViolation `json:"csp-report"`
+		}
+		if err := json.Unmarshal(body, &report); err != nil {
+			http.Error(w, "Invalid report", http.StatusBadRequest)
+			return
+		}
+		violations = append(violations, report.CSPReport)
+	}
+
+	for _, violation := range violations {
+		document, directive, blocked := violation.DocumentURI, violation.ViolatedDirective, violation.BlockedURI
+		if document == "" {
+			document, directive, blocked = violation.DocumentURL, violation.EffectiveDirective, violation.BlockedURL
+		}
+		log.Printf("CSP violation (%s): document=%q directive=%q blocked=%q ip=%s", violation.Disposition, document, directive, blocked, middleware.ClientIP(r))
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,164 @@
+package handlers
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+
+	"fitnesshub/models"
+)
+
+// Через сколько записей отправлять накопленные данные клиенту
+const exportFlushEvery = 100
+
+// exportWriter пишет записи в CSV или JSON Lines по мере чтения из курсора
+type exportWriter struct {
+	w     http.ResponseWriter
+	csv   *csv.Writer
+	json  *json.Encoder
+	count int
+}
+
+func newExportWriter(w http.ResponseWriter, r *http.Request, name string, header []string) *exportWriter {
+	export := &exportWriter{w: w}
+	if r.URL.Query().Get("format") == "jsonl" {
+		w.Header().Set("Content-Type", "application/x-ndjson")
+		w.Header().Set("Content-Disposition", `attachment; filename="`+name+`.jsonl"`)
+		export.json = json.NewEncoder(w)
+		return export
+	}
+
+	w.Header().Set("Content-Type", "text/csv; charset=utf-8")
+	w.Header().Set("Content-Disposition", `attachment; filename="`+name+`.csv"`)
+	export.csv = csv.NewWriter(w)
+	export.csv.Write(header)
+	return export
+}
+
+// Write принимает запись для JSON Lines и её представление в виде строки CSV
+func (e *exportWriter) Write(record interface{}, row []string) {
+	if e.json != nil {
+		e.json.Encode(record)
+	} else {
+		e.csv.Write(csvSafeRow(row))
+	}
+	e.count++
+	if e.count%exportFlushEvery == 0 {
+		e.Flush()
+	}
+}
+
+// csvSafeRow экранирует ячейки, которые табличный редактор принял бы за формулу: имена и email
+// задают сами пользователи, а выгрузку открывает администратор
+func csvSafeRow(row []string) []string {
+	safe := make([]string, len(row))
+	for i, cell := range row {
+		if cell != "" && strings.ContainsRune("=+-@\t\r", rune(cell[0])) {
+			cell = "'" + cell
+		}
+		safe[i] = cell
+	}
+	return safe
+}
+
+func (e *exportWriter) Flush() {
+	if e.csv != nil {
+		e.csv.Flush()
+	}
+	if flusher, ok := e.w.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
+
+// exportUser — поля пользователя, попадающие в выгрузку
+type exportUser struct {
+	ID                string `json:"id"`
+	Email             string `json:"email"`
+	FirstName         string `json:"first_name"`
+	LastName          string `json:"last_name"`
+	Role              string `json:"role"`
+	Verified          bool   `json:"verified"`
+	MembershipExpires string `json:"membership_expires,omitempty"`
+}
+
+// ExportUsersHandler выгружает пользователей в CSV или JSON Lines (?format=jsonl)
+// с теми же фильтрами и сортировкой, что и список в административной панели
+func ExportUsersHandler(w http.ResponseWriter, r *http.Request, collection *mongo.Collection) {
+	query := ParseUserQuery(r.URL.Query())
+	sortOption := userSortOptions[query.Sort]
+	findOptions := options.Find().
+		SetSort(bson.D{{Key: sortOption.Field, Value: sortOption.Direction}}).
+		SetProjection(bson.M{"password": 0, "verification_token": 0})
+
+	cursor, err := collection.Find(context.TODO(), query.Filter(), findOptions)
+	if err != nil {
+		http.Error(w, "Error fetching users", http.StatusInternalServerError)
+		return
+	}
+	defer cursor.Close(context.TODO())
+
+	export := newExportWriter(w, r, "users", []string{"id", "email", "first_name", "last_name", "role", "verified", "membership_expires"})
+	for cursor.Next(context.TODO()) {
+		var user models.User
+		if err := cursor.Decode(&user); err != nil {
+			continue
+		}
+		record := exportUser{
+			ID:        user.ID.Hex(),
+			Email:     user.Email,
+			FirstName: user.Profile.FirstName,
+			LastName:  user.Profile.LastName,
+			Role:      user.Role,
+			Verified:  user.Verified,
+		}
+		// В базе хранится момент окончания, в файле — последний оплаченный день, как при импорте
+		if user.MembershipExpires != nil {
+			record.MembershipExpires = user.MembershipExpires.In(time.Local).AddDate(0, 0, -1).Format("2006-01-02")
+		}
+		export.Write(record, []string{
+			record.ID, record.Email, record.FirstName, record.LastName, record.Role,
+			strconv.FormatBool(record.Verified), record.MembershipExpires,
+		})
+	}
+	export.Flush()
+}
+
+// ExportProductsHandler выгружает товары в CSV или JSON Lines с фильтрами ?filter= (по названию) и ?category=
+func ExportProductsHandler(w http.ResponseWriter, r *http.Request, collection *mongo.Collection) {
+	filter := notDeleted(bson.M{})
+	if name := r.URL.Query().Get("filter"); name != "" {
+		filter["name"] = bson.M{"$regex": regexp.QuoteMeta(name), "$options": "i"}
+	}
+	if category := r.URL.Query().Get("category"); category != "" {
+		filter["category"] = category
+	}
+
+	cursor, err := collection.Find(context.TODO(), filter, options.Find().SetSort(bson.D{{Key: "name", Value: 1}}))
+	if err != nil {
+		http.Error(w, "Error fetching products", http.StatusInternalServerError)
+		return
+	}
+	defer cursor.Close(context.TODO())
+
+	export := newExportWriter(w, r, "products", []string{"id", "name", "description", "price", "category", "image_url"})
+	for cursor.Next(context.TODO()) {
+		var product models.Product
+		if err := cursor.Decode(&product); err != nil {
+			continue
+		}
+		export.Write(product, []string{
+			product.ID.Hex(), product.Name, product.Description,
+			strconv.FormatFloat(product.Price, 'f', -1, 64), product.Category, product.ImageURL,
+		})
+	}
+	export.Flush()
+}
@@ -0,0 +1,14 @@
+package handlers
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestCSVSafeRow(t *testing.T) {
+	row := []string{"=HYPERLINK(\"http://x\")", "+1", "-2", "@SUM(A1)", "\tcmd", "\rcmd", "Bob", "", "a=b"}
+	want := []string{"'=HYPERLINK(\"http://x\")", "'+1", "'-2", "'@SUM(A1)", "'\tcmd", "'\rcmd", "Bob", "", "a=b"}
+	if got := csvSafeRow(row); !reflect.DeepEqual(got, want) {
+		t.Fatalf("csvSafeRow() = %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,81 @@
+package handlers
+
+import (
+	"encoding/json"
+	"mime"
+	"net/http"
+	"net/url"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+
+	"fitnesshub/utils"
+)
+
+// isFormRequest сообщает, пришёл ли запрос из HTML-формы, а не от JSON-клиента
+func isFormRequest(r *http.Request) bool {
+	mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	return mediaType == "application/x-www-form-urlencoded" || mediaType == "multipart/form-data"
+}
+
+// setFlash сохраняет сообщение, которое будет показано на следующей странице
+func setFlash(w http.ResponseWriter, message string) {
+	http.SetCookie(w, &http.Cookie{
+		Name:     "flash",
+		Value:    url.QueryEscape(message),
+		Path:     "/",
+		HttpOnly: true,
+		Secure:   utils.SecureCookies(),
+		SameSite: http.SameSiteLaxMode,
+	})
+}
+
+// popFlash возвращает сохранённое сообщение и удаляет его
+func popFlash(w http.ResponseWriter, r *http.Request) string {
+	cookie, err := r.Cookie("flash")
+	if err != nil {
+		return ""
+	}
+	http.SetCookie(w, &http.Cookie{Name: "flash", Path: "/", MaxAge: -1})
+	message, _ := url.QueryUnescape(cookie.Value)
+	return message
+}
+
+// writeResult отвечает JSON-клиенту статусом и сообщением, а HTML-форме —
+// перенаправлением на redirectTo с flash-сообщением
+func writeResult(w http.ResponseWriter, r *http.Request, redirectTo string, status int, message string) {
+	if isFormRequest(r) {
+		setFlash(w, message)
+		http.Redirect(w, r, redirectTo, http.StatusSeeOther)
+		return
+	}
+
+	if status >= http.StatusBadRequest {
+		http.Error(w, message, status)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{"status": "success", "message": message})
+}
+
+// requestID возвращает ID из пути /api/v1 ({id}), строки запроса (?id=) или из поля формы id
+func requestID(r *http.Request) string {
+	if id := routeParam(r, "id"); id != "" {
+		return id
+	}
+	return r.PostFormValue("id")
+}
+
+// routeParam возвращает параметр из шаблона пути /api/v1, а для прежних маршрутов — из строки запроса
+func routeParam(r *http.Request, name string) string {
+	if value := r.PathValue(name); value != "" {
+		return value
+	}
+	return r.URL.Query().Get(name)
+}
+
+// pathObjectID заменяет ID из тела запроса на ID из пути /api/v1; неверный ID в пути обнуляет его
+func pathObjectID(r *http.Request, id *primitive.ObjectID) {
+	if value := r.PathValue("id"); value != "" {
+		*id, _ = primitive.ObjectIDFromHex(value)
+	}
+}
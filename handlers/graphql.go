@@ -0,0 +1,487 @@
+package handlers
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/graphql-go/graphql"
+	"github.com/graphql-go/graphql/gqlerrors"
+	"github.com/graphql-go/graphql/language/ast"
+	"github.com/graphql-go/graphql/language/parser"
+	"github.com/graphql-go/graphql/language/source"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+
+	"fitnesshub/middleware"
+	"fitnesshub/models"
+)
+
+// Ограничения запроса GraphQL: размер тела, глубина вложенности полей и оценка числа полей в ответе
+const (
+	MaxGraphQLRequestSize = 64 << 10
+	GraphQLMaxDepth       = 8
+	GraphQLMaxComplexity  = 1000
+)
+
+// Ограничения сохранённых запросов: сколько их может сохранить один пользователь (кроме
+// администратора) и сколько держится в памяти; остальные читаются из MongoDB
+const (
+	GraphQLMaxQueriesPerUser = 100
+	GraphQLMaxCachedQueries  = 1000
+)
+
+// GraphQLCollections — коллекции, из которых читает схема /graphql
+type GraphQLCollections struct {
+	Users            *mongo.Collection
+	Products         *mongo.Collection
+	Bookings         *mongo.Collection
+	Sessions         *mongo.Collection
+	ClassTypes       *mongo.Collection
+	Rooms            *mongo.Collection
+	Instructors      *mongo.Collection
+	Trainers         *mongo.Collection
+	PersistedQueries *mongo.Collection
+}
+
+// GraphQLAPI — схема /graphql и кэш сохранённых запросов
+type GraphQLAPI struct {
+	schema        graphql.Schema
+	collections   GraphQLCollections
+	persistedOnly bool
+	queries       sync.Map
+	cachedQueries atomic.Int64
+}
+
+// NewGraphQLAPI строит схему. При persistedOnly выполняются только сохранённые запросы,
+// а сохранять новые может только администратор (например, при сборке мобильного приложения)
+func NewGraphQLAPI(collections GraphQLCollections, persistedOnly bool) (*GraphQLAPI, error) {
+	api := &GraphQLAPI{collections: collections, persistedOnly: persistedOnly}
+	schema, err := api.buildSchema()
+	if err != nil {
+		return nil, err
+	}
+	api.schema = schema
+	return api, nil
+}
+
+// graphQLError — ошибка с машиночитаемым кодом в extensions.code ответа
+type graphQLError struct {
+	Code    string
+	Message string
+}
+
+func (e graphQLError) Error() string {
+	return e.Message
+}
+
+func (e graphQLError) Extensions() map[string]interface{} {
+	return map[string]interface{}{"code": e.Code}
+}
+
+// graphQLRequest — запрос GraphQL over HTTP. Вместо текста query клиент может передать
+// SHA-256 ранее сохранённого запроса в extensions.persistedQuery (Automatic Persisted Queries)
+type graphQLRequest struct {
+	Query         string                 `json:"query"`
+	OperationName string                 `json:"operationName"`
+	Variables     map[string]interface{} `json:"variables"`
+	Extensions    struct {
+		PersistedQuery *struct {
+			Version    int    `json:"version"`
+			SHA256Hash string `json:"sha256Hash"`
+		} `json:"persistedQuery"`
+	} `json:"extensions"`
+}
+
+// decodeGraphQLRequest читает запрос из тела POST или параметров GET
+func decodeGraphQLRequest(w http.ResponseWriter, r *http.Request) (graphQLRequest, error) {
+	var request graphQLRequest
+	if r.Method == http.MethodPost {
+		err := json.NewDecoder(http.MaxBytesReader(w, r.Body, MaxGraphQLRequestSize)).Decode(&request)
+		return request, err
+	}
+
+	values := r.URL.Query()
+	request.Query = values.Get("query")
+	request.OperationName = values.Get("operationName")
+	if variables := values.Get("variables"); variables != "" {
+		if err := json.Unmarshal([]byte(variables), &request.Variables); err != nil {
+			return request, err
+		}
+	}
+	if extensions := values.Get("extensions"); extensions != "" {
+		if err := json.Unmarshal([]byte(extensions), &request.Extensions); err != nil {
+			return request, err
+		}
+	}
+	return request, nil
+}
+
+// GraphQLHandler выполняет запрос GraphQL от имени пользователя сессии или API-ключа,
+// а без них — анонимно; доступ к полям проверяет сама схема
+func GraphQLHandler(w http.ResponseWriter, r *http.Request, api *GraphQLAPI) {
+	request, err := decodeGraphQLRequest(w, r)
+	var tooLarge *http.MaxBytesError
+	if errors.As(err, &tooLarge) {
+		http.Error(w, "GraphQL request is too large", http.StatusRequestEntityTooLarge)
+		return
+	}
+	if err != nil {
+		http.Error(w, "Invalid GraphQL request", http.StatusBadRequest)
+		return
+	}
+
+	viewer := graphQLViewer{Role: middleware.GetUserRole(r)}
+	viewer.ID, _ = primitive.ObjectIDFromHex(middleware.GetUserID(r))
+
+	query, hash, err := api.resolveQuery(request, viewer)
+	if err != nil {
+		writeGraphQLError(w, err)
+		return
+	}
+
+	document, err := parser.Parse(parser.ParseParams{Source: source.NewSource(&source.Source{
+		Body: []byte(query),
+		Name: "GraphQL request",
+	})})
+	if err != nil {
+		writeGraphQLError(w, err)
+		return
+	}
+	// Ограничения проверяются до валидации, чтобы не тратить на неё время у отклоняемых запросов
+	if err := api.checkLimits(document, request); err != nil {
+		writeGraphQLError(w, err)
+		return
+	}
+	if validation := graphql.ValidateDocument(&api.schema, document, nil); !validation.IsValid {
+		writeGraphQLResult(w, &graphql.Result{Errors: validation.Errors})
+		return
+	}
+
+	// Сохраняется только запрос, прошедший проверки
+	if hash != "" {
+		api.savePersistedQuery(hash, query, viewer)
+	}
+
+	ctx := context.WithValue(r.Context(), graphQLContextKey{}, &graphQLRequestContext{
+		viewer:  viewer,
+		loaders: newGraphQLLoaders(api.collections),
+	})
+	writeGraphQLResult(w, graphql.Execute(graphql.ExecuteParams{
+		Schema:        api.schema,
+		AST:           document,
+		OperationName: request.OperationName,
+		Args:          request.Variables,
+		Context:       ctx,
+	}))
+}
+
+func writeGraphQLResult(w http.ResponseWriter, result *graphql.Result) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(result)
+}
+
+// writeGraphQLError отвечает ошибкой всего запроса; код graphQLError попадает в extensions
+func writeGraphQLError(w http.ResponseWriter, err error) {
+	formatted := gqlerrors.FormatError(err)
+	if extended, ok := err.(gqlerrors.ExtendedError); ok {
+		formatted.Extensions = extended.Extensions()
+	}
+	writeGraphQLResult(w, &graphql.Result{Errors: []gqlerrors.FormattedError{formatted}})
+}
+
+// resolveQuery возвращает текст запроса и, если его нужно сохранить, его SHA-256.
+// Сохраняет запросы только вошедший пользователь: анонимный запрос с текстом и хешем
+// выполняется, но не сохраняется
+func (api *GraphQLAPI) resolveQuery(request graphQLRequest, viewer graphQLViewer) (string, string, error) {
+	canRun := !api.persistedOnly || viewer.hasRole("administrator")
+
+	if request.Extensions.PersistedQuery == nil {
+		if !canRun {
+			return "", "", graphQLError{Code: "PERSISTED_QUERY_REQUIRED", Message: "Only persisted queries are allowed"}
+		}
+		return request.Query, "", nil
+	}
+
+	hash := strings.ToLower(request.Extensions.PersistedQuery.SHA256Hash)
+	if request.Query != "" {
+		sum := sha256.Sum256([]byte(request.Query))
+		if hex.EncodeToString(sum[:]) != hash {
+			return "", "", graphQLError{Code: "PERSISTED_QUERY_HASH_MISMATCH", Message: "provided sha does not match query"}
+		}
+	}
+
+	if stored := api.persistedQuery(hash); stored != "" {
+		return stored, "", nil
+	}
+	if request.Query == "" {
+		return "", "", graphQLError{Code: "PERSISTED_QUERY_NOT_FOUND", Message: "PersistedQueryNotFound"}
+	}
+	if !canRun {
+		return "", "", graphQLError{Code: "PERSISTED_QUERY_REQUIRED", Message: "Only persisted queries are allowed"}
+	}
+	if viewer.ID.IsZero() {
+		return request.Query, "", nil
+	}
+	return request.Query, hash, nil
+}
+
+// persistedQuery ищет сохранённый запрос сначала в памяти, затем в MongoDB
+func (api *GraphQLAPI) persistedQuery(hash string) string {
+	if query, ok := api.queries.Load(hash); ok {
+		return query.(string)
+	}
+
+	var stored models.PersistedQuery
+	err := api.collections.PersistedQueries.FindOne(context.TODO(), bson.M{"_id": hash}).Decode(&stored)
+	if err != nil {
+		if err != mongo.ErrNoDocuments {
+			log.Println("GraphQL: error loading persisted query:", err)
+		}
+		return ""
+	}
+	api.cacheQuery(hash, stored.Query)
+	return stored.Query
+}
+
+// cacheQuery держит в памяти не больше GraphQLMaxCachedQueries запросов
+func (api *GraphQLAPI) cacheQuery(hash, query string) {
+	if api.cachedQueries.Load() >= GraphQLMaxCachedQueries {
+		return
+	}
+	if _, loaded := api.queries.LoadOrStore(hash, query); !loaded {
+		api.cachedQueries.Add(1)
+	}
+}
+
+// savePersistedQuery сохраняет запрос; пользователь, исчерпавший GraphQLMaxQueriesPerUser,
+// получает ответ, но его новые запросы не сохраняются
+func (api *GraphQLAPI) savePersistedQuery(hash, query string, viewer graphQLViewer) {
+	if !viewer.hasRole("administrator") {
+		count, err := api.collections.PersistedQueries.CountDocuments(context.TODO(), bson.M{"created_by": viewer.ID})
+		if err != nil || count >= GraphQLMaxQueriesPerUser {
+			return
+		}
+	}
+
+	_, err := api.collections.PersistedQueries.UpdateOne(context.TODO(),
+		bson.M{"_id": hash},
+		bson.M{"$setOnInsert": models.PersistedQuery{ID: hash, Query: query, CreatedBy: viewer.ID, CreatedAt: time.Now()}},
+		options.Update().SetUpsert(true))
+	if err != nil {
+		log.Println("GraphQL: error saving persisted query:", err)
+		return
+	}
+	api.cacheQuery(hash, query)
+}
+
+// EnsureGraphQLIndexes создаёт индекс автора сохранённых запросов для подсчёта квоты
+func EnsureGraphQLIndexes(collection *mongo.Collection) error {
+	_, err := collection.Indexes().CreateOne(context.TODO(), mongo.IndexModel{
+		Keys: bson.D{{Key: "created_by", Value: 1}},
+	})
+	return err
+}
+
+// checkLimits отклоняет документ с циклом фрагментов и операцию, которая глубже
+// GraphQLMaxDepth или сложнее GraphQLMaxComplexity
+func (api *GraphQLAPI) checkLimits(document *ast.Document, request graphQLRequest) error {
+	cost := &queryCost{fragments: map[string]*ast.FragmentDefinition{}, variables: map[string]interface{}{}, visiting: map[string]bool{}}
+	var operation *ast.OperationDefinition
+	for _, definition := range document.Definitions {
+		switch definition := definition.(type) {
+		case *ast.FragmentDefinition:
+			cost.fragments[definition.Name.Value] = definition
+		case *ast.OperationDefinition:
+			if request.OperationName == "" || (definition.Name != nil && definition.Name.Value == request.OperationName) {
+				operation = definition
+			}
+		}
+	}
+	if hasFragmentCycle(cost.fragments) {
+		return graphQLError{Code: "GRAPHQL_VALIDATION_FAILED", Message: "Fragments must not spread themselves"}
+	}
+	if operation == nil {
+		return nil
+	}
+
+	for _, variable := range operation.VariableDefinitions {
+		name := variable.Variable.Name.Value
+		if value, ok := request.Variables[name]; ok {
+			cost.variables[name] = value
+		} else if limit, ok := variable.DefaultValue.(*ast.IntValue); ok {
+			cost.variables[name] = limit.Value
+		}
+	}
+
+	depth, complexity := cost.measure(operation.SelectionSet, api.schema.QueryType(), 1)
+	if depth > GraphQLMaxDepth {
+		return graphQLError{Code: "QUERY_TOO_DEEP", Message: fmt.Sprintf("Query depth %d exceeds the limit of %d", depth, GraphQLMaxDepth)}
+	}
+	if complexity > GraphQLMaxComplexity {
+		return graphQLError{Code: "QUERY_TOO_COMPLEX", Message: fmt.Sprintf("Query complexity %d exceeds the limit of %d", complexity, GraphQLMaxComplexity)}
+	}
+	return nil
+}
+
+// hasFragmentCycle сообщает, включает ли какой-либо фрагмент сам себя. Правила валидации
+// graphql-go уходят на таких фрагментах в бесконечную рекурсию, поэтому цикл ищется заранее
+func hasFragmentCycle(fragments map[string]*ast.FragmentDefinition) bool {
+	const visiting, checked = 1, 2
+	state := map[string]int{}
+
+	var spreadsCycle func(set *ast.SelectionSet) bool
+	var fragmentCycle func(name string) bool
+	spreadsCycle = func(set *ast.SelectionSet) bool {
+		if set == nil {
+			return false
+		}
+		for _, selection := range set.Selections {
+			switch selection := selection.(type) {
+			case *ast.Field:
+				if spreadsCycle(selection.SelectionSet) {
+					return true
+				}
+			case *ast.InlineFragment:
+				if spreadsCycle(selection.SelectionSet) {
+					return true
+				}
+			case *ast.FragmentSpread:
+				if fragmentCycle(selection.Name.Value) {
+					return true
+				}
+			}
+		}
+		return false
+	}
+	fragmentCycle = func(name string) bool {
+		switch state[name] {
+		case visiting:
+			return true
+		case checked:
+			return false
+		}
+		fragment := fragments[name]
+		if fragment == nil {
+			return false
+		}
+		state[name] = visiting
+		if spreadsCycle(fragment.SelectionSet) {
+			return true
+		}
+		state[name] = checked
+		return false
+	}
+
+	for name := range fragments {
+		if fragmentCycle(name) {
+			return true
+		}
+	}
+	return false
+}
+
+// queryCost оценивает глубину и сложность операции. Сложность — число полей в ответе: поле-список
+// повторяет вложенные поля столько раз, сколько элементов вернёт при своём limit. Поля
+// интроспекции (__schema, __type) не учитываются, чтобы работали инструменты вроде GraphiQL.
+type queryCost struct {
+	fragments map[string]*ast.FragmentDefinition
+	variables map[string]interface{}
+	visiting  map[string]bool
+}
+
+// measure возвращает наибольшую глубину и сложность полей набора, стоящих на глубине depth
+func (c *queryCost) measure(set *ast.SelectionSet, parent graphql.Type, depth int) (int, int) {
+	deepest, complexity := 0, 0
+	if set == nil {
+		return deepest, complexity
+	}
+
+	for _, selection := range set.Selections {
+		var selectionDepth, selectionCost int
+		switch selection := selection.(type) {
+		case *ast.Field:
+			if strings.HasPrefix(selection.Name.Value, "__") {
+				continue
+			}
+			selectionDepth, selectionCost = c.field(selection, parent, depth)
+		case *ast.InlineFragment:
+			selectionDepth, selectionCost = c.measure(selection.SelectionSet, parent, depth)
+		case *ast.FragmentSpread:
+			name := selection.Name.Value
+			fragment := c.fragments[name]
+			if fragment == nil || c.visiting[name] {
+				continue
+			}
+			c.visiting[name] = true
+			selectionDepth, selectionCost = c.measure(fragment.SelectionSet, parent, depth)
+			delete(c.visiting, name)
+		}
+		deepest = max(deepest, selectionDepth)
+		complexity += selectionCost
+	}
+	return deepest, complexity
+}
+
+func (c *queryCost) field(field *ast.Field, parent graphql.Type, depth int) (int, int) {
+	var fieldType graphql.Type
+	if object, ok := parent.(*graphql.Object); ok {
+		if definition := object.Fields()[field.Name.Value]; definition != nil {
+			fieldType = definition.Type
+		}
+	}
+
+	repeat := 1
+	for unwrapped := false; !unwrapped; {
+		switch wrapper := fieldType.(type) {
+		case *graphql.NonNull:
+			fieldType = wrapper.OfType
+		case *graphql.List:
+			repeat = c.listSize(field)
+			fieldType = wrapper.OfType
+		default:
+			unwrapped = true
+		}
+	}
+
+	if field.SelectionSet == nil {
+		return depth, 1
+	}
+	deepest, complexity := c.measure(field.SelectionSet, fieldType, depth+1)
+	return max(depth, deepest), 1 + repeat*complexity
+}
+
+// listSize — число элементов, которое вернёт поле-список при своём аргументе limit
+func (c *queryCost) listSize(field *ast.Field) int {
+	for _, argument := range field.Arguments {
+		if argument.Name.Value != "limit" {
+			continue
+		}
+		var limit int
+		switch value := argument.Value.(type) {
+		case *ast.IntValue:
+			limit, _ = strconv.Atoi(value.Value)
+		case *ast.Variable:
+			switch variable := c.variables[value.Name.Value].(type) {
+			case float64:
+				limit = int(variable)
+			case string:
+				limit, _ = strconv.Atoi(variable)
+			}
+		}
+		return pageLimit(map[string]interface{}{"limit": limit})
+	}
+	return DefaultGraphQLPageSize
+}
@@ -0,0 +1,211 @@
+package handlers
+
+import (
+	"context"
+	"sync"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+
+	"fitnesshub/models"
+)
+
+// batchLoader собирает ключи, запрошенные резолверами, и загружает их одним запросом к MongoDB.
+// Исполнитель GraphQL обходит ответ в ширину: сначала вызывает резолверы поля у всех элементов
+// списка, а затем — возвращённые ими функции, поэтому к первому вызову такой функции все ключи
+// этого уровня уже собраны и загружаются вместе.
+type batchLoader struct {
+	fetch   func(keys []string) (map[string]interface{}, error)
+	mu      sync.Mutex
+	pending []string
+	queued  map[string]bool
+	results map[string]interface{}
+	errs    map[string]error
+}
+
+func newBatchLoader(fetch func(keys []string) (map[string]interface{}, error)) *batchLoader {
+	return &batchLoader{
+		fetch:   fetch,
+		queued:  map[string]bool{},
+		results: map[string]interface{}{},
+		errs:    map[string]error{},
+	}
+}
+
+// Load ставит key в очередь и возвращает функцию, через которую исполнитель получит значение
+func (l *batchLoader) Load(key string) func() (interface{}, error) {
+	l.mu.Lock()
+	if !l.queued[key] {
+		l.queued[key] = true
+		l.pending = append(l.pending, key)
+	}
+	l.mu.Unlock()
+
+	return func() (interface{}, error) {
+		l.mu.Lock()
+		defer l.mu.Unlock()
+		if _, loaded := l.results[key]; !loaded && l.errs[key] == nil {
+			l.dispatch()
+		}
+		return l.results[key], l.errs[key]
+	}
+}
+
+// dispatch загружает все ключи из очереди; ненайденные ключи получают nil
+func (l *batchLoader) dispatch() {
+	keys := l.pending
+	l.pending = nil
+	values, err := l.fetch(keys)
+	for _, key := range keys {
+		if err != nil {
+			l.errs[key] = err
+			continue
+		}
+		l.results[key] = values[key]
+	}
+}
+
+// graphQLLoaders — загрузчики одного запроса GraphQL; кэш не переживает запрос,
+// поэтому изменения данных видны в следующем запросе
+type graphQLLoaders struct {
+	users              *batchLoader
+	trainersByUser     *batchLoader
+	sessions           *batchLoader
+	classTypes         *batchLoader
+	rooms              *batchLoader
+	instructors        *batchLoader
+	bookingsByUser     *batchLoader
+	bookingsBySession  *batchLoader
+	productsByCategory *batchLoader
+}
+
+func newGraphQLLoaders(collections GraphQLCollections) *graphQLLoaders {
+	byCreated := options.Find().SetSort(bson.D{{Key: "created_at", Value: -1}})
+	byName := options.Find().SetSort(bson.D{{Key: "name", Value: 1}})
+
+	return &graphQLLoaders{
+		users: newBatchLoader(func(keys []string) (map[string]interface{}, error) {
+			return loadOne(collections.Users, notDeleted(bson.M{"_id": bson.M{"$in": objectIDs(keys)}}), func(cursor *mongo.Cursor) (string, interface{}, error) {
+				var user models.User
+				err := cursor.Decode(&user)
+				return user.ID.Hex(), user, err
+			})
+		}),
+		trainersByUser: newBatchLoader(func(keys []string) (map[string]interface{}, error) {
+			return loadOne(collections.Trainers, bson.M{"user_id": bson.M{"$in": objectIDs(keys)}}, func(cursor *mongo.Cursor) (string, interface{}, error) {
+				var trainer models.TrainerProfile
+				err := cursor.Decode(&trainer)
+				return trainer.UserID.Hex(), trainer, err
+			})
+		}),
+		sessions: newBatchLoader(func(keys []string) (map[string]interface{}, error) {
+			return loadOne(collections.Sessions, bson.M{"_id": bson.M{"$in": objectIDs(keys)}}, func(cursor *mongo.Cursor) (string, interface{}, error) {
+				var session models.ClassSession
+				err := cursor.Decode(&session)
+				return session.ID.Hex(), session, err
+			})
+		}),
+		classTypes: newBatchLoader(func(keys []string) (map[string]interface{}, error) {
+			return loadOne(collections.ClassTypes, bson.M{"_id": bson.M{"$in": objectIDs(keys)}}, func(cursor *mongo.Cursor) (string, interface{}, error) {
+				var classType models.ClassType
+				err := cursor.Decode(&classType)
+				return classType.ID.Hex(), classType, err
+			})
+		}),
+		rooms: newBatchLoader(func(keys []string) (map[string]interface{}, error) {
+			return loadOne(collections.Rooms, bson.M{"_id": bson.M{"$in": objectIDs(keys)}}, func(cursor *mongo.Cursor) (string, interface{}, error) {
+				var room models.Room
+				err := cursor.Decode(&room)
+				return room.ID.Hex(), room, err
+			})
+		}),
+		instructors: newBatchLoader(func(keys []string) (map[string]interface{}, error) {
+			return loadOne(collections.Instructors, bson.M{"_id": bson.M{"$in": objectIDs(keys)}}, func(cursor *mongo.Cursor) (string, interface{}, error) {
+				var instructor models.Instructor
+				err := cursor.Decode(&instructor)
+				return instructor.ID.Hex(), instructor, err
+			})
+		}),
+		bookingsByUser: newBatchLoader(func(keys []string) (map[string]interface{}, error) {
+			return loadMany(collections.Bookings, keys, bson.M{"user_id": bson.M{"$in": objectIDs(keys)}}, byCreated, func(cursor *mongo.Cursor) (string, interface{}, error) {
+				var booking models.Booking
+				err := cursor.Decode(&booking)
+				return booking.UserID.Hex(), booking, err
+			})
+		}),
+		bookingsBySession: newBatchLoader(func(keys []string) (map[string]interface{}, error) {
+			return loadMany(collections.Bookings, keys, bson.M{"session_id": bson.M{"$in": objectIDs(keys)}}, byCreated, func(cursor *mongo.Cursor) (string, interface{}, error) {
+				var booking models.Booking
+				err := cursor.Decode(&booking)
+				return booking.SessionID.Hex(), booking, err
+			})
+		}),
+		productsByCategory: newBatchLoader(func(keys []string) (map[string]interface{}, error) {
+			return loadMany(collections.Products, keys, notDeleted(bson.M{"category": bson.M{"$in": keys}}), byName, func(cursor *mongo.Cursor) (string, interface{}, error) {
+				var product models.Product
+				err := cursor.Decode(&product)
+				return product.Category, product, err
+			})
+		}),
+	}
+}
+
+// objectIDs переводит ключи загрузчика в ObjectID, пропуская неверные
+func objectIDs(keys []string) []primitive.ObjectID {
+	ids := make([]primitive.ObjectID, 0, len(keys))
+	for _, key := range keys {
+		if id, err := primitive.ObjectIDFromHex(key); err == nil {
+			ids = append(ids, id)
+		}
+	}
+	return ids
+}
+
+// loadOne загружает документы по filter и раскладывает их по ключу, который возвращает decode
+func loadOne(collection *mongo.Collection, filter bson.M, decode func(*mongo.Cursor) (string, interface{}, error)) (map[string]interface{}, error) {
+	cursor, err := collection.Find(context.TODO(), filter)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(context.TODO())
+
+	values := map[string]interface{}{}
+	for cursor.Next(context.TODO()) {
+		key, value, err := decode(cursor)
+		if err != nil {
+			return nil, err
+		}
+		values[key] = value
+	}
+	return values, cursor.Err()
+}
+
+// loadMany загружает документы по filter и группирует их в списки по ключу;
+// ключам без документов достаётся пустой список
+func loadMany(collection *mongo.Collection, keys []string, filter bson.M, findOptions *options.FindOptions, decode func(*mongo.Cursor) (string, interface{}, error)) (map[string]interface{}, error) {
+	cursor, err := collection.Find(context.TODO(), filter, findOptions)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(context.TODO())
+
+	groups := map[string][]interface{}{}
+	for _, key := range keys {
+		groups[key] = []interface{}{}
+	}
+	for cursor.Next(context.TODO()) {
+		key, value, err := decode(cursor)
+		if err != nil {
+			return nil, err
+		}
+		groups[key] = append(groups[key], value)
+	}
+
+	values := make(map[string]interface{}, len(groups))
+	for key, group := range groups {
+		values[key] = group
+	}
+	return values, cursor.Err()
+}
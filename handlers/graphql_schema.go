@@ -0,0 +1,609 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"regexp"
+	"sort"
+	"time"
+
+	"github.com/graphql-go/graphql"
+	"github.com/graphql-go/graphql/language/ast"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo/options"
+
+	"fitnesshub/models"
+	"fitnesshub/utils"
+)
+
+const (
+	DefaultGraphQLPageSize = 20
+	MaxGraphQLPageSize     = 100
+)
+
+var (
+	errGraphQLUnauthorized = graphQLError{Code: "UNAUTHORIZED", Message: "Unauthorized"}
+	errGraphQLForbidden    = graphQLError{Code: "FORBIDDEN", Message: "Forbidden"}
+)
+
+// graphQLViewer — пользователь, от имени которого выполняется запрос GraphQL; у анонимного ID пуст
+type graphQLViewer struct {
+	ID   primitive.ObjectID
+	Role string
+}
+
+func (v graphQLViewer) hasRole(roles ...string) bool {
+	for _, role := range roles {
+		if v.Role == role {
+			return true
+		}
+	}
+	return false
+}
+
+// owns сообщает, принадлежит ли объект пользователю запроса
+func (v graphQLViewer) owns(source interface{}) bool {
+	if v.ID.IsZero() {
+		return false
+	}
+	switch source := source.(type) {
+	case models.User:
+		return source.ID == v.ID
+	case models.Booking:
+		return source.UserID == v.ID
+	}
+	return false
+}
+
+// canSeeUser — пользователя видят он сам, персонал, администратор и закреплённый тренер
+func (v graphQLViewer) canSeeUser(user models.User) bool {
+	return v.owns(user) || v.hasRole("staff", "administrator") || (!v.ID.IsZero() && user.TrainerID == v.ID)
+}
+
+type graphQLContextKey struct{}
+
+// graphQLRequestContext — пользователь и загрузчики одного запроса GraphQL
+type graphQLRequestContext struct {
+	viewer  graphQLViewer
+	loaders *graphQLLoaders
+}
+
+func graphQLContextFrom(ctx context.Context) *graphQLRequestContext {
+	requestContext, _ := ctx.Value(graphQLContextKey{}).(*graphQLRequestContext)
+	return requestContext
+}
+
+// fieldRule решает, доступно ли поле пользователю запроса; source — объект, которому принадлежит поле
+type fieldRule func(viewer graphQLViewer, source interface{}) bool
+
+func signedIn(viewer graphQLViewer, _ interface{}) bool {
+	return !viewer.ID.IsZero()
+}
+
+// ownerOr пускает владельца объекта и пользователей с одной из ролей
+func ownerOr(roles ...string) fieldRule {
+	return func(viewer graphQLViewer, source interface{}) bool {
+		return viewer.owns(source) || viewer.hasRole(roles...)
+	}
+}
+
+// hasRoles пускает пользователей с одной из ролей
+func hasRoles(roles ...string) fieldRule {
+	return func(viewer graphQLViewer, _ interface{}) bool {
+		return viewer.hasRole(roles...)
+	}
+}
+
+// authorized проверяет правило доступа перед резолвером поля; недоступное поле
+// получает null и ошибку с путём к полю, остальная часть ответа не страдает
+func authorized(rule fieldRule, resolve graphql.FieldResolveFn) graphql.FieldResolveFn {
+	if resolve == nil {
+		resolve = graphql.DefaultResolveFn
+	}
+	return func(p graphql.ResolveParams) (interface{}, error) {
+		viewer := graphQLContextFrom(p.Context).viewer
+		if !rule(viewer, p.Source) {
+			if viewer.ID.IsZero() {
+				return nil, errGraphQLUnauthorized
+			}
+			return nil, errGraphQLForbidden
+		}
+		return resolve(p)
+	}
+}
+
+// graphQLObjectID передаёт ObjectID строкой из 24 шестнадцатеричных символов
+var graphQLObjectID = graphql.NewScalar(graphql.ScalarConfig{
+	Name:        "ObjectID",
+	Description: "MongoDB document ID: 24 hexadecimal characters.",
+	Serialize: func(value interface{}) interface{} {
+		if id, ok := value.(primitive.ObjectID); ok && !id.IsZero() {
+			return id.Hex()
+		}
+		return nil
+	},
+	ParseValue: func(value interface{}) interface{} {
+		text, _ := value.(string)
+		if id, err := primitive.ObjectIDFromHex(text); err == nil {
+			return id
+		}
+		return nil
+	},
+	ParseLiteral: func(valueAST ast.Value) interface{} {
+		if text, ok := valueAST.(*ast.StringValue); ok {
+			if id, err := primitive.ObjectIDFromHex(text.Value); err == nil {
+				return id
+			}
+		}
+		return nil
+	},
+})
+
+// pageLimit возвращает аргумент limit, ограниченный MaxGraphQLPageSize
+func pageLimit(args map[string]interface{}) int {
+	limit, _ := args["limit"].(int)
+	if limit <= 0 || limit > MaxGraphQLPageSize {
+		return DefaultGraphQLPageSize
+	}
+	return limit
+}
+
+// limitArgs — аргумент limit для полей-списков; по нему же оценивается сложность запроса
+func limitArgs() graphql.FieldConfigArgument {
+	return graphql.FieldConfigArgument{
+		"limit": &graphql.ArgumentConfig{Type: graphql.Int, DefaultValue: DefaultGraphQLPageSize},
+	}
+}
+
+// loadedList применяет keep и limit к списку, который вернёт загрузчик
+func loadedList(load func() (interface{}, error), limit int, keep func(interface{}) bool) func() (interface{}, error) {
+	return func() (interface{}, error) {
+		value, err := load()
+		if err != nil {
+			return nil, err
+		}
+		items, _ := value.([]interface{})
+		result := []interface{}{}
+		for _, item := range items {
+			if len(result) == limit {
+				break
+			}
+			if keep == nil || keep(item) {
+				result = append(result, item)
+			}
+		}
+		return result, nil
+	}
+}
+
+// buildSchema описывает схему /graphql: каталог, профиль, расписание и записи на занятия
+func (api *GraphQLAPI) buildSchema() (graphql.Schema, error) {
+	unitsType := graphql.NewObject(graphql.ObjectConfig{
+		Name: "Units",
+		Fields: graphql.Fields{
+			"weight": &graphql.Field{Type: graphql.String},
+			"length": &graphql.Field{Type: graphql.String},
+		},
+	})
+
+	emergencyContactType := graphql.NewObject(graphql.ObjectConfig{
+		Name: "EmergencyContact",
+		Fields: graphql.Fields{
+			"name":         &graphql.Field{Type: graphql.String},
+			"phone":        &graphql.Field{Type: graphql.String},
+			"relationship": &graphql.Field{Type: graphql.String},
+		},
+	})
+
+	profileType := graphql.NewObject(graphql.ObjectConfig{
+		Name: "Profile",
+		Fields: graphql.Fields{
+			"first_name":        &graphql.Field{Type: graphql.String},
+			"last_name":         &graphql.Field{Type: graphql.String},
+			"phone":             &graphql.Field{Type: graphql.String},
+			"date_of_birth":     &graphql.Field{Type: graphql.DateTime},
+			"gender":            &graphql.Field{Type: graphql.String},
+			"emergency_contact": &graphql.Field{Type: emergencyContactType},
+			"fitness_goals":     &graphql.Field{Type: graphql.NewList(graphql.NewNonNull(graphql.String))},
+			"avatar_url":        &graphql.Field{Type: graphql.String},
+			"locale":            &graphql.Field{Type: graphql.String},
+			"timezone":          &graphql.Field{Type: graphql.String},
+		},
+	})
+
+	trainerType := graphql.NewObject(graphql.ObjectConfig{
+		Name: "Trainer",
+		Fields: graphql.Fields{
+			"id":             &graphql.Field{Type: graphql.NewNonNull(graphQLObjectID)},
+			"user_id":        &graphql.Field{Type: graphQLObjectID},
+			"name":           &graphql.Field{Type: graphql.String},
+			"bio":            &graphql.Field{Type: graphql.String},
+			"specialties":    &graphql.Field{Type: graphql.NewList(graphql.NewNonNull(graphql.String))},
+			"certifications": &graphql.Field{Type: graphql.NewList(graphql.NewNonNull(graphql.String))},
+			"photo_url":      &graphql.Field{Type: graphql.String},
+			"slot_minutes":   &graphql.Field{Type: graphql.Int},
+		},
+	})
+
+	productType := graphql.NewObject(graphql.ObjectConfig{
+		Name: "Product",
+		Fields: graphql.Fields{
+			"id":          &graphql.Field{Type: graphql.NewNonNull(graphQLObjectID)},
+			"name":        &graphql.Field{Type: graphql.String},
+			"description": &graphql.Field{Type: graphql.String},
+			"price":       &graphql.Field{Type: graphql.Float},
+			"category":    &graphql.Field{Type: graphql.String},
+			"image_url":   &graphql.Field{Type: graphql.String},
+		},
+	})
+
+	// Категория — значение поля category у товаров, отдельной коллекции нет
+	categoryType := graphql.NewObject(graphql.ObjectConfig{
+		Name: "Category",
+		Fields: graphql.Fields{
+			"name": &graphql.Field{
+				Type: graphql.NewNonNull(graphql.String),
+				Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+					return p.Source, nil
+				},
+			},
+			"products": &graphql.Field{
+				Type: graphql.NewNonNull(graphql.NewList(graphql.NewNonNull(productType))),
+				Args: limitArgs(),
+				Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+					category, _ := p.Source.(string)
+					load := graphQLContextFrom(p.Context).loaders.productsByCategory.Load(category)
+					return loadedList(load, pageLimit(p.Args), nil), nil
+				},
+			},
+		},
+	})
+
+	classTypeType := graphql.NewObject(graphql.ObjectConfig{
+		Name: "ClassType",
+		Fields: graphql.Fields{
+			"id":               &graphql.Field{Type: graphql.NewNonNull(graphQLObjectID)},
+			"name":             &graphql.Field{Type: graphql.String},
+			"description":      &graphql.Field{Type: graphql.String},
+			"duration_minutes": &graphql.Field{Type: graphql.Int},
+		},
+	})
+
+	roomType := graphql.NewObject(graphql.ObjectConfig{
+		Name: "Room",
+		Fields: graphql.Fields{
+			"id":       &graphql.Field{Type: graphql.NewNonNull(graphQLObjectID)},
+			"name":     &graphql.Field{Type: graphql.String},
+			"capacity": &graphql.Field{Type: graphql.Int},
+		},
+	})
+
+	instructorType := graphql.NewObject(graphql.ObjectConfig{
+		Name: "Instructor",
+		Fields: graphql.Fields{
+			"id":   &graphql.Field{Type: graphql.NewNonNull(graphQLObjectID)},
+			"name": &graphql.Field{Type: graphql.String},
+			"bio":  &graphql.Field{Type: graphql.String},
+		},
+	})
+
+	// Занятие, запись и пользователь ссылаются друг на друга, поэтому поля задаются функциями
+	var sessionType, bookingType, userType *graphql.Object
+
+	sessionType = graphql.NewObject(graphql.ObjectConfig{
+		Name: "ClassSession",
+		Fields: graphql.FieldsThunk(func() graphql.Fields {
+			return graphql.Fields{
+				"id":         &graphql.Field{Type: graphql.NewNonNull(graphQLObjectID)},
+				"class_name": &graphql.Field{Type: graphql.String},
+				"starts_at":  &graphql.Field{Type: graphql.DateTime},
+				"ends_at":    &graphql.Field{Type: graphql.DateTime},
+				"capacity":   &graphql.Field{Type: graphql.Int},
+				"booked":     &graphql.Field{Type: graphql.Int},
+				"cancelled":  &graphql.Field{Type: graphql.Boolean},
+				"spots_left": &graphql.Field{
+					Type: graphql.Int,
+					Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+						session := p.Source.(models.ClassSession)
+						return max(session.Capacity-session.Booked, 0), nil
+					},
+				},
+				"class_type": &graphql.Field{
+					Type: classTypeType,
+					Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+						session := p.Source.(models.ClassSession)
+						return graphQLContextFrom(p.Context).loaders.classTypes.Load(session.ClassTypeID.Hex()), nil
+					},
+				},
+				"room": &graphql.Field{
+					Type: roomType,
+					Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+						session := p.Source.(models.ClassSession)
+						return graphQLContextFrom(p.Context).loaders.rooms.Load(session.RoomID.Hex()), nil
+					},
+				},
+				"instructor": &graphql.Field{
+					Type: instructorType,
+					Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+						session := p.Source.(models.ClassSession)
+						return graphQLContextFrom(p.Context).loaders.instructors.Load(session.InstructorID.Hex()), nil
+					},
+				},
+				// Список записавшихся, как и /admin/sessions/{session_id}/bookings, только для администратора
+				"bookings": &graphql.Field{
+					Type: graphql.NewList(graphql.NewNonNull(bookingType)),
+					Args: limitArgs(),
+					Resolve: authorized(hasRoles("administrator"), func(p graphql.ResolveParams) (interface{}, error) {
+						session := p.Source.(models.ClassSession)
+						load := graphQLContextFrom(p.Context).loaders.bookingsBySession.Load(session.ID.Hex())
+						return loadedList(load, pageLimit(p.Args), nil), nil
+					}),
+				},
+			}
+		}),
+	})
+
+	bookingType = graphql.NewObject(graphql.ObjectConfig{
+		Name: "Booking",
+		Fields: graphql.FieldsThunk(func() graphql.Fields {
+			return graphql.Fields{
+				"id":           &graphql.Field{Type: graphql.NewNonNull(graphQLObjectID)},
+				"status":       &graphql.Field{Type: graphql.String},
+				"created_at":   &graphql.Field{Type: graphql.DateTime},
+				"updated_at":   &graphql.Field{Type: graphql.DateTime},
+				"cancelled_at": &graphql.Field{Type: graphql.DateTime},
+				"session": &graphql.Field{
+					Type: sessionType,
+					Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+						booking := p.Source.(models.Booking)
+						return graphQLContextFrom(p.Context).loaders.sessions.Load(booking.SessionID.Hex()), nil
+					},
+				},
+				"user": &graphql.Field{
+					Type: userType,
+					Resolve: authorized(ownerOr("administrator"), func(p graphql.ResolveParams) (interface{}, error) {
+						booking := p.Source.(models.Booking)
+						return graphQLContextFrom(p.Context).loaders.users.Load(booking.UserID.Hex()), nil
+					}),
+				},
+			}
+		}),
+	})
+
+	userType = graphql.NewObject(graphql.ObjectConfig{
+		Name: "User",
+		Fields: graphql.FieldsThunk(func() graphql.Fields {
+			return graphql.Fields{
+				"id": &graphql.Field{Type: graphql.NewNonNull(graphQLObjectID)},
+				"email": &graphql.Field{
+					Type: graphql.String,
+					Resolve: authorized(func(viewer graphQLViewer, source interface{}) bool {
+						return viewer.canSeeUser(source.(models.User))
+					}, nil),
+				},
+				"verified":           &graphql.Field{Type: graphql.Boolean, Resolve: authorized(ownerOr("staff", "administrator"), nil)},
+				"role":               &graphql.Field{Type: graphql.String, Resolve: authorized(ownerOr("staff", "administrator"), nil)},
+				"membership_expires": &graphql.Field{Type: graphql.DateTime, Resolve: authorized(ownerOr("staff", "administrator"), nil)},
+				"units": &graphql.Field{
+					Type: unitsType,
+					Resolve: authorized(ownerOr("staff", "administrator"), func(p graphql.ResolveParams) (interface{}, error) {
+						return p.Source.(models.User).PreferredUnits(), nil
+					}),
+				},
+				// Закреплённый тренер видит профиль с учётом настроек приватности клиента
+				"profile": &graphql.Field{
+					Type: profileType,
+					Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+						user := p.Source.(models.User)
+						viewer := graphQLContextFrom(p.Context).viewer
+						if viewer.owns(user) || viewer.hasRole("staff", "administrator") {
+							return user.Profile, nil
+						}
+						if viewer.canSeeUser(user) {
+							return user.Profile.ForTrainer(), nil
+						}
+						return nil, errGraphQLForbidden
+					},
+				},
+				"trainer": &graphql.Field{
+					Type: trainerType,
+					Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+						user := p.Source.(models.User)
+						if user.TrainerID.IsZero() {
+							return nil, nil
+						}
+						return graphQLContextFrom(p.Context).loaders.trainersByUser.Load(user.TrainerID.Hex()), nil
+					},
+				},
+				"bookings": &graphql.Field{
+					Type: graphql.NewList(graphql.NewNonNull(bookingType)),
+					Args: graphql.FieldConfigArgument{
+						"status": &graphql.ArgumentConfig{Type: graphql.String},
+						"limit":  &graphql.ArgumentConfig{Type: graphql.Int, DefaultValue: DefaultGraphQLPageSize},
+					},
+					Resolve: authorized(ownerOr("administrator"), func(p graphql.ResolveParams) (interface{}, error) {
+						user := p.Source.(models.User)
+						status, _ := p.Args["status"].(string)
+						load := graphQLContextFrom(p.Context).loaders.bookingsByUser.Load(user.ID.Hex())
+						return loadedList(load, pageLimit(p.Args), func(item interface{}) bool {
+							return status == "" || item.(models.Booking).Status == status
+						}), nil
+					}),
+				},
+			}
+		}),
+	})
+
+	productSortType := graphql.NewEnum(graphql.EnumConfig{
+		Name: "ProductSort",
+		Values: graphql.EnumValueConfigMap{
+			"NAME":     &graphql.EnumValueConfig{Value: "name"},
+			"PRICE":    &graphql.EnumValueConfig{Value: "price"},
+			"CATEGORY": &graphql.EnumValueConfig{Value: "category"},
+		},
+	})
+
+	queryType := graphql.NewObject(graphql.ObjectConfig{
+		Name: "Query",
+		Fields: graphql.Fields{
+			"products": &graphql.Field{
+				Type: graphql.NewNonNull(graphql.NewList(graphql.NewNonNull(productType))),
+				Args: graphql.FieldConfigArgument{
+					"filter":   &graphql.ArgumentConfig{Type: graphql.String},
+					"category": &graphql.ArgumentConfig{Type: graphql.String},
+					"sort":     &graphql.ArgumentConfig{Type: productSortType, DefaultValue: "name"},
+					"page":     &graphql.ArgumentConfig{Type: graphql.Int, DefaultValue: 1},
+					"limit":    &graphql.ArgumentConfig{Type: graphql.Int, DefaultValue: DefaultGraphQLPageSize},
+				},
+				Resolve: api.resolveProducts,
+			},
+			"product": &graphql.Field{
+				Type: productType,
+				Args: graphql.FieldConfigArgument{
+					"id": &graphql.ArgumentConfig{Type: graphql.NewNonNull(graphQLObjectID)},
+				},
+				Resolve: api.resolveProduct,
+			},
+			"categories": &graphql.Field{
+				Type:    graphql.NewNonNull(graphql.NewList(graphql.NewNonNull(categoryType))),
+				Resolve: api.resolveCategories,
+			},
+			"timetable": &graphql.Field{
+				Type: graphql.NewNonNull(graphql.NewList(graphql.NewNonNull(sessionType))),
+				Args: graphql.FieldConfigArgument{
+					"week": &graphql.ArgumentConfig{Type: graphql.String, Description: "Any date of the week, 2006-01-02; defaults to the current week."},
+				},
+				Resolve: api.resolveTimetable,
+			},
+			"trainers": &graphql.Field{
+				Type:    graphql.NewNonNull(graphql.NewList(graphql.NewNonNull(trainerType))),
+				Resolve: api.resolveTrainers,
+			},
+			"me": &graphql.Field{
+				Type: userType,
+				Resolve: authorized(signedIn, func(p graphql.ResolveParams) (interface{}, error) {
+					requestContext := graphQLContextFrom(p.Context)
+					return requestContext.loaders.users.Load(requestContext.viewer.ID.Hex()), nil
+				}),
+			},
+			"user": &graphql.Field{
+				Type: userType,
+				Args: graphql.FieldConfigArgument{
+					"id": &graphql.ArgumentConfig{Type: graphql.NewNonNull(graphQLObjectID)},
+				},
+				Resolve: authorized(signedIn, resolveUser),
+			},
+		},
+	})
+
+	return graphql.NewSchema(graphql.SchemaConfig{Query: queryType})
+}
+
+func (api *GraphQLAPI) resolveProducts(p graphql.ResolveParams) (interface{}, error) {
+	filter := notDeleted(bson.M{})
+	if name, _ := p.Args["filter"].(string); name != "" {
+		filter["name"] = bson.M{"$regex": regexp.QuoteMeta(name), "$options": "i"}
+	}
+	if category, _ := p.Args["category"].(string); category != "" {
+		filter["category"] = category
+	}
+	sortField, _ := p.Args["sort"].(string)
+	page, _ := p.Args["page"].(int)
+	page = max(page, 1)
+	limit := pageLimit(p.Args)
+
+	findOptions := options.Find().
+		SetSort(bson.D{{Key: sortField, Value: 1}, {Key: "_id", Value: 1}}).
+		SetSkip(int64((page - 1) * limit)).
+		SetLimit(int64(limit))
+	cursor, err := api.collections.Products.Find(context.TODO(), filter, findOptions)
+	if err != nil {
+		return nil, errors.New("Error fetching products")
+	}
+	products := []models.Product{}
+	if err := cursor.All(context.TODO(), &products); err != nil {
+		return nil, errors.New("Error fetching products")
+	}
+	return products, nil
+}
+
+func (api *GraphQLAPI) resolveProduct(p graphql.ResolveParams) (interface{}, error) {
+	var product models.Product
+	err := api.collections.Products.FindOne(context.TODO(), notDeleted(bson.M{"_id": p.Args["id"]})).Decode(&product)
+	if err != nil {
+		return nil, nil
+	}
+	return product, nil
+}
+
+func (api *GraphQLAPI) resolveCategories(p graphql.ResolveParams) (interface{}, error) {
+	values, err := api.collections.Products.Distinct(context.TODO(), "category", notDeleted(bson.M{}))
+	if err != nil {
+		return nil, errors.New("Error fetching categories")
+	}
+	categories := []string{}
+	for _, value := range values {
+		if category, ok := value.(string); ok && category != "" {
+			categories = append(categories, category)
+		}
+	}
+	sort.Strings(categories)
+	return categories, nil
+}
+
+// resolveTimetable возвращает занятия недели так же, как TimetableHandler
+func (api *GraphQLAPI) resolveTimetable(p graphql.ResolveParams) (interface{}, error) {
+	day := time.Now()
+	if week, _ := p.Args["week"].(string); week != "" {
+		parsed, err := time.ParseInLocation("2006-01-02", week, time.Local)
+		if err != nil {
+			return nil, errors.New("Invalid week date")
+		}
+		day = parsed
+	}
+
+	weekStart := utils.StartOfWeek(day)
+	filter := bson.M{
+		"starts_at": bson.M{"$gte": weekStart, "$lt": weekStart.AddDate(0, 0, 7)},
+		"cancelled": false,
+	}
+	cursor, err := api.collections.Sessions.Find(context.TODO(), filter, options.Find().SetSort(bson.D{{Key: "starts_at", Value: 1}}))
+	if err != nil {
+		return nil, errors.New("Error fetching timetable")
+	}
+	sessions := []models.ClassSession{}
+	if err := cursor.All(context.TODO(), &sessions); err != nil {
+		return nil, errors.New("Error fetching timetable")
+	}
+	return sessions, nil
+}
+
+func (api *GraphQLAPI) resolveTrainers(p graphql.ResolveParams) (interface{}, error) {
+	cursor, err := api.collections.Trainers.Find(context.TODO(), bson.M{}, options.Find().SetSort(bson.D{{Key: "name", Value: 1}}))
+	if err != nil {
+		return nil, errors.New("Error fetching trainers")
+	}
+	trainers := []models.TrainerProfile{}
+	if err := cursor.All(context.TODO(), &trainers); err != nil {
+		return nil, errors.New("Error fetching trainers")
+	}
+	return trainers, nil
+}
+
+// resolveUser возвращает пользователя по ID, если запрашивающий может его видеть
+func resolveUser(p graphql.ResolveParams) (interface{}, error) {
+	requestContext := graphQLContextFrom(p.Context)
+	id := p.Args["id"].(primitive.ObjectID)
+	load := requestContext.loaders.users.Load(id.Hex())
+	return func() (interface{}, error) {
+		value, err := load()
+		if err != nil || value == nil {
+			return nil, err
+		}
+		if !requestContext.viewer.canSeeUser(value.(models.User)) {
+			return nil, errGraphQLForbidden
+		}
+		return value, nil
+	}, nil
+}
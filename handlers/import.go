@@ -0,0 +1,372 @@
+package handlers
+
+import (
+	"bufio"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"mime"
+	"net/http"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"golang.org/x/crypto/bcrypt"
+
+	"fitnesshub/middleware"
+	"fitnesshub/models"
+)
+
+const (
+	// Файлы, в которых строк больше, чем BackgroundImportThreshold, импортируются в фоне
+	BackgroundImportThreshold = 500
+	MaxImportSize             = 50 << 20
+)
+
+var emailPattern = regexp.MustCompile(`^[^@\s]+@[^@\s]+\.[^@\s]+$`)
+
+// normalizeEmail приводит адрес к виду, в котором он хранится и ищется: без пробелов по краям
+// и в нижнем регистре
+func normalizeEmail(email string) string {
+	return strings.ToLower(strings.TrimSpace(email))
+}
+
+// importRow — строка файла импорта: номер строки и значения по названиям колонок
+type importRow struct {
+	Line   int
+	Fields map[string]string
+}
+
+// rowImporter превращает строку в фильтр по ключу и изменения для upsert. При пробном запуске
+// (dryRun) строка только проверяется — дорогие вычисления вроде хеша пароля не нужны.
+type rowImporter func(row importRow, dryRun bool) (bson.M, bson.M, error)
+
+// ImportUsersHandler импортирует пользователей из CSV или JSON Lines, обновляя существующих по email
+func ImportUsersHandler(w http.ResponseWriter, r *http.Request, userCollection, jobCollection, auditCollection *mongo.Collection) {
+	importHandler(w, r, "users", importUserRow, userCollection, jobCollection, auditCollection)
+}
+
+// ImportProductsHandler импортирует товары из CSV или JSON Lines, обновляя существующие по названию
+func ImportProductsHandler(w http.ResponseWriter, r *http.Request, productCollection, jobCollection, auditCollection *mongo.Collection) {
+	importHandler(w, r, "products", importProductRow, productCollection, jobCollection, auditCollection)
+}
+
+// GetImportJobHandler возвращает состояние и отчёт фонового импорта ?id=
+func GetImportJobHandler(w http.ResponseWriter, r *http.Request, collection *mongo.Collection) {
+	objID, err := primitive.ObjectIDFromHex(routeParam(r, "id"))
+	if err != nil {
+		http.Error(w, "Invalid import job ID", http.StatusBadRequest)
+		return
+	}
+
+	var job models.ImportJob
+	err = collection.FindOne(context.TODO(), bson.M{"_id": objID}).Decode(&job)
+	if err != nil {
+		http.Error(w, "Import job not found", http.StatusNotFound)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(job)
+}
+
+func importHandler(w http.ResponseWriter, r *http.Request, kind string, importer rowImporter, collection, jobCollection, auditCollection *mongo.Collection) {
+	r.Body = http.MaxBytesReader(w, r.Body, MaxImportSize)
+	rows, err := readImportRows(r)
+	if err != nil {
+		http.Error(w, "Invalid import file: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	dryRun := r.FormValue("dry_run") == "true"
+	event := auditEvent(r, kind+".import", kind, "")
+	if dryRun || len(rows) <= BackgroundImportThreshold {
+		report := runImport(rows, importer, collection, dryRun)
+		if !dryRun {
+			event.Details = importSummary(report)
+			recordAudit(auditCollection, event)
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(report)
+		return
+	}
+
+	createdBy, _ := primitive.ObjectIDFromHex(middleware.GetUserID(r))
+	job := models.ImportJob{
+		Kind:      kind,
+		Status:    models.ImportStatusRunning,
+		Report:    models.ImportReport{Total: len(rows)},
+		CreatedBy: createdBy,
+		CreatedAt: time.Now(),
+	}
+	result, err := jobCollection.InsertOne(context.TODO(), job)
+	if err != nil {
+		http.Error(w, "Error creating import job", http.StatusInternalServerError)
+		return
+	}
+	jobID := result.InsertedID.(primitive.ObjectID)
+
+	go func() {
+		report := runImport(rows, importer, collection, false)
+		finishedAt := time.Now()
+		_, err := jobCollection.UpdateOne(context.TODO(), bson.M{"_id": jobID}, bson.M{"$set": bson.M{
+			"status":      models.ImportStatusCompleted,
+			"report":      report,
+			"finished_at": finishedAt,
+		}})
+		if err != nil {
+			log.Println("Import job", jobID.Hex(), "failed to save report:", err)
+		}
+		event.Details = "job " + jobID.Hex() + ": " + importSummary(report)
+		recordAudit(auditCollection, event)
+	}()
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusAccepted)
+	json.NewEncoder(w).Encode(map[string]interface{}{"status": "accepted", "job_id": jobID, "rows": len(rows)})
+}
+
+// runImport проверяет строки и, если это не пробный запуск, сохраняет их с upsert по ключу
+func runImport(rows []importRow, importer rowImporter, collection *mongo.Collection, dryRun bool) models.ImportReport {
+	report := models.ImportReport{DryRun: dryRun, Total: len(rows), Errors: []models.ImportRowError{}}
+	seen := map[string]int{}
+
+	for _, row := range rows {
+		filter, update, err := importer(row, dryRun)
+		if err != nil {
+			report.Errors = append(report.Errors, models.ImportRowError{Line: row.Line, Message: err.Error()})
+			continue
+		}
+
+		key := fmt.Sprint(filter)
+		if line, ok := seen[key]; ok {
+			report.Errors = append(report.Errors, models.ImportRowError{Line: row.Line, Message: "duplicate of line " + strconv.Itoa(line)})
+			continue
+		}
+		seen[key] = row.Line
+
+		// Запись из корзины не перезаписываем и не дублируем — её нужно сначала восстановить
+		trashed := bson.M{"deleted_at": bson.M{"$ne": nil}}
+		for field, value := range filter {
+			trashed[field] = value
+		}
+		if count, err := collection.CountDocuments(context.TODO(), trashed); err == nil && count > 0 {
+			report.Errors = append(report.Errors, models.ImportRowError{Line: row.Line, Message: "record is in trash, restore it first"})
+			continue
+		}
+		report.Valid++
+
+		if dryRun {
+			continue
+		}
+
+		result, err := collection.UpdateOne(context.TODO(), notDeleted(filter), update, options.Update().SetUpsert(true))
+		if err != nil {
+			report.Valid--
+			report.Errors = append(report.Errors, models.ImportRowError{Line: row.Line, Message: "error saving row"})
+			continue
+		}
+		if result.UpsertedCount > 0 {
+			report.Created++
+		} else {
+			report.Updated++
+		}
+	}
+	return report
+}
+
+// importSummary — краткий итог импорта для журнала аудита
+func importSummary(report models.ImportReport) string {
+	return fmt.Sprintf("%d rows, %d created, %d updated, %d errors", report.Total, report.Created, report.Updated, len(report.Errors))
+}
+
+// readImportRows читает CSV (первая строка — заголовок) или JSON Lines из тела запроса
+// или поля формы file. Формат определяется по Content-Type или расширению файла.
+func readImportRows(r *http.Request) ([]importRow, error) {
+	var source io.Reader = r.Body
+	mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if mediaType == "multipart/form-data" {
+		file, header, err := r.FormFile("file")
+		if err != nil {
+			return nil, errors.New("file is required")
+		}
+		defer file.Close()
+		source = file
+		switch strings.ToLower(filepath.Ext(header.Filename)) {
+		case ".jsonl", ".ndjson":
+			mediaType = "application/x-ndjson"
+		default:
+			mediaType = "text/csv"
+		}
+	}
+
+	switch mediaType {
+	case "application/x-ndjson", "application/jsonl", "application/json":
+		return readJSONLines(source)
+	default:
+		return readCSV(source)
+	}
+}
+
+func readCSV(source io.Reader) ([]importRow, error) {
+	reader := csv.NewReader(source)
+	reader.TrimLeadingSpace = true
+	reader.FieldsPerRecord = -1
+
+	header, err := reader.Read()
+	if err != nil {
+		return nil, errors.New("missing CSV header")
+	}
+	for i := range header {
+		header[i] = strings.ToLower(strings.TrimSpace(header[i]))
+	}
+
+	rows := []importRow{}
+	for line := 2; ; line++ {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		fields := map[string]string{}
+		for i, value := range record {
+			if i < len(header) {
+				fields[header[i]] = strings.TrimSpace(value)
+			}
+		}
+		rows = append(rows, importRow{Line: line, Fields: fields})
+	}
+	return rows, nil
+}
+
+func readJSONLines(source io.Reader) ([]importRow, error) {
+	scanner := bufio.NewScanner(source)
+	scanner.Buffer(make([]byte, 64*1024), 1<<20)
+
+	rows := []importRow{}
+	for line := 1; scanner.Scan(); line++ {
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" {
+			continue
+		}
+		var values map[string]interface{}
+		if err := json.Unmarshal([]byte(text), &values); err != nil {
+			return nil, fmt.Errorf("line %d: invalid JSON", line)
+		}
+		fields := map[string]string{}
+		for key, value := range values {
+			if value != nil {
+				fields[strings.ToLower(key)] = strings.TrimSpace(fmt.Sprint(value))
+			}
+		}
+		rows = append(rows, importRow{Line: line, Fields: fields})
+	}
+	return rows, scanner.Err()
+}
+
+// importUserRow: email (ключ), name или first_name/last_name, role, verified, membership_expires, password
+func importUserRow(row importRow, dryRun bool) (bson.M, bson.M, error) {
+	fields := row.Fields
+	email := normalizeEmail(fields["email"])
+	if !emailPattern.MatchString(email) {
+		return nil, nil, errors.New("invalid email")
+	}
+
+	set := bson.M{}
+	setOnInsert := bson.M{"verified": false, "role": "user"}
+
+	firstName, lastName := fields["first_name"], fields["last_name"]
+	if firstName == "" && lastName == "" && fields["name"] != "" {
+		firstName, lastName, _ = strings.Cut(fields["name"], " ")
+	}
+	if firstName != "" || lastName != "" {
+		set["profile.first_name"] = strings.TrimSpace(firstName)
+		set["profile.last_name"] = strings.TrimSpace(lastName)
+	}
+
+	if role := fields["role"]; role != "" {
+		if !models.IsValidRole(role) {
+			return nil, nil, errors.New("invalid role: " + role)
+		}
+		set["role"] = role
+		delete(setOnInsert, "role")
+	}
+
+	if verified := fields["verified"]; verified != "" {
+		value, err := strconv.ParseBool(verified)
+		if err != nil {
+			return nil, nil, errors.New("verified must be true or false")
+		}
+		set["verified"] = value
+		delete(setOnInsert, "verified")
+	}
+
+	if expires := fields["membership_expires"]; expires != "" {
+		date, err := time.ParseInLocation("2006-01-02", expires, time.Local)
+		if err != nil {
+			return nil, nil, errors.New("membership_expires must be a date (2006-01-02)")
+		}
+		set["membership_expires"] = date.AddDate(0, 0, 1)
+	}
+
+	if password := fields["password"]; password != "" {
+		// bcrypt не принимает пароли длиннее 72 байт — проверяем это и при пробном запуске
+		if len(password) > 72 {
+			return nil, nil, errors.New("password must be at most 72 bytes")
+		}
+		if !dryRun {
+			hashedPassword, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+			if err != nil {
+				return nil, nil, errors.New("invalid password")
+			}
+			set["password"] = string(hashedPassword)
+		}
+	}
+
+	update := bson.M{"$setOnInsert": setOnInsert}
+	if len(set) > 0 {
+		update["$set"] = set
+	}
+	return bson.M{"email": email}, update, nil
+}
+
+// importProductRow: name (ключ), description, price, category, image_url
+func importProductRow(row importRow, dryRun bool) (bson.M, bson.M, error) {
+	fields := row.Fields
+	name := fields["name"]
+	if name == "" {
+		return nil, nil, errors.New("name is required")
+	}
+
+	set := bson.M{}
+	if price := fields["price"]; price != "" {
+		value, err := strconv.ParseFloat(price, 64)
+		if err != nil || value < 0 {
+			return nil, nil, errors.New("price must be a non-negative number")
+		}
+		set["price"] = value
+	}
+	for _, field := range []string{"description", "category", "image_url"} {
+		if value, ok := fields[field]; ok {
+			set[field] = value
+		}
+	}
+
+	update := bson.M{"$setOnInsert": bson.M{"name": name}}
+	if len(set) > 0 {
+		update["$set"] = set
+	}
+	return bson.M{"name": name}, update, nil
+}
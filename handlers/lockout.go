@@ -0,0 +1,172 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"math"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+
+	"fitnesshub/middleware"
+	"fitnesshub/models"
+	"fitnesshub/utils"
+)
+
+const (
+	// Неудачные попытки считаются в пределах окна; после его окончания счётчик начинается заново
+	LoginFailureWindow = 15 * time.Minute
+	LockoutDuration    = 15 * time.Minute
+	// Учётная запись блокируется после AccountLockThreshold неудач, IP — после IPLockThreshold
+	AccountLockThreshold = 10
+	IPLockThreshold      = 50
+	// Первые попытки проходят без задержки, затем она удваивается до maxLoginDelay
+	freeLoginAttempts = 3
+	maxLoginDelay     = 30 * time.Second
+)
+
+func accountAttemptsKey(email string) string {
+	return "account:" + normalizeEmail(email)
+}
+
+func ipAttemptsKey(r *http.Request) string {
+	return "ip:" + middleware.ClientIP(r)
+}
+
+// loginDelay — пауза перед следующей попыткой после failures неудач подряд
+func loginDelay(failures int) time.Duration {
+	if failures <= freeLoginAttempts {
+		return 0
+	}
+	delay := time.Second << uint(failures-freeLoginAttempts-1)
+	if delay > maxLoginDelay || delay <= 0 {
+		return maxLoginDelay
+	}
+	return delay
+}
+
+// loginRetryAfter возвращает, сколько ждать до следующей попытки входа по любому из ключей
+func loginRetryAfter(collection *mongo.Collection, now time.Time, keys ...string) time.Duration {
+	var wait time.Duration
+	cursor, err := collection.Find(context.TODO(), bson.M{"_id": bson.M{"$in": keys}})
+	if err != nil {
+		return 0
+	}
+	defer cursor.Close(context.TODO())
+
+	for cursor.Next(context.TODO()) {
+		var attempts models.LoginAttempts
+		if err := cursor.Decode(&attempts); err != nil {
+			continue
+		}
+		until := attempts.NextAttemptAt
+		if attempts.LockedUntil != nil && attempts.LockedUntil.After(until) {
+			until = *attempts.LockedUntil
+		}
+		if until.Sub(now) > wait {
+			wait = until.Sub(now)
+		}
+	}
+	return wait
+}
+
+// recordLoginFailure увеличивает счётчик неудач по ключу и назначает задержку.
+// Возвращает true, если этой неудачей ключ был заблокирован.
+func recordLoginFailure(collection *mongo.Collection, key string, threshold int, now time.Time) (models.LoginAttempts, bool) {
+	windowExpired := bson.M{"$lt": bson.A{bson.M{"$ifNull": bson.A{"$window_start", now}}, now.Add(-LoginFailureWindow)}}
+	pipeline := mongo.Pipeline{
+		{{Key: "$set", Value: bson.M{
+			"failures":     bson.M{"$cond": bson.A{windowExpired, 1, bson.M{"$add": bson.A{bson.M{"$ifNull": bson.A{"$failures", 0}}, 1}}}},
+			"window_start": bson.M{"$cond": bson.A{windowExpired, now, bson.M{"$ifNull": bson.A{"$window_start", now}}}},
+			"expires_at":   now.Add(LoginFailureWindow + LockoutDuration),
+		}}},
+	}
+
+	var attempts models.LoginAttempts
+	err := collection.FindOneAndUpdate(context.TODO(), bson.M{"_id": key}, pipeline,
+		options.FindOneAndUpdate().SetUpsert(true).SetReturnDocument(options.After)).Decode(&attempts)
+	if err != nil {
+		log.Println("Error recording login failure:", err)
+		return attempts, false
+	}
+
+	set := bson.M{"next_attempt_at": now.Add(loginDelay(attempts.Failures))}
+	locked := attempts.Failures >= threshold && (attempts.LockedUntil == nil || !attempts.LockedUntil.After(now))
+	if locked {
+		lockedUntil := now.Add(LockoutDuration)
+		attempts.LockedUntil = &lockedUntil
+		set["locked_until"] = lockedUntil
+	}
+	collection.UpdateOne(context.TODO(), bson.M{"_id": key}, bson.M{"$set": set})
+	return attempts, locked
+}
+
+// clearLoginFailures сбрасывает счётчик после успешного входа, разблокировки или смены пароля
+func clearLoginFailures(collection *mongo.Collection, key string) {
+	collection.DeleteOne(context.TODO(), bson.M{"_id": key})
+}
+
+// sendUnlockEmail выдаёт одноразовую ссылку, снимающую блокировку учётной записи досрочно
+func sendUnlockEmail(collection *mongo.Collection, key, email string) {
+	token, err := utils.GenerateVerificationToken()
+	if err != nil {
+		return
+	}
+	_, err = collection.UpdateOne(context.TODO(), bson.M{"_id": key}, bson.M{"$set": bson.M{"unlock_token": utils.HashToken(token)}})
+	if err != nil {
+		return
+	}
+
+	link := "http://localhost:8081/login/unlock?token=" + url.QueryEscape(token)
+	err = utils.SendEmail(email, "Your account has been locked",
+		"We locked your account for "+strconv.Itoa(int(LockoutDuration.Minutes()))+" minutes after too many failed sign-in attempts. "+
+			"If it was you, unlock it now by clicking the following link: "+link+
+			". If it was not you, consider changing your password.")
+	if err != nil {
+		log.Println("Error sending unlock email:", err)
+	}
+}
+
+// UnlockAccountHandler снимает блокировку по токену из письма
+func UnlockAccountHandler(w http.ResponseWriter, r *http.Request, attemptCollection, auditCollection *mongo.Collection) {
+	token := r.URL.Query().Get("token")
+	if token == "" {
+		http.Error(w, "Unlock token is required", http.StatusBadRequest)
+		return
+	}
+
+	var attempts models.LoginAttempts
+	err := attemptCollection.FindOneAndDelete(context.TODO(), bson.M{"unlock_token": utils.HashToken(token)}).Decode(&attempts)
+	if err != nil {
+		http.Error(w, "Invalid or expired unlock token", http.StatusBadRequest)
+		return
+	}
+
+	event := auditEvent(r, "auth.unlock", "users", strings.TrimPrefix(attempts.ID, "account:"))
+	recordAudit(auditCollection, event)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{"status": "success", "message": "Account unlocked. You can sign in again."})
+}
+
+// writeTooManyAttempts отвечает 429 с заголовком Retry-After
+func writeTooManyAttempts(w http.ResponseWriter, wait time.Duration) {
+	w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(wait.Seconds()))))
+	http.Error(w, "Too many failed login attempts. Try again later.", http.StatusTooManyRequests)
+}
+
+// EnsureLoginAttemptIndexes создаёт TTL-индекс, удаляющий устаревшие счётчики
+func EnsureLoginAttemptIndexes(collection *mongo.Collection) error {
+	_, err := collection.Indexes().CreateMany(context.TODO(), []mongo.IndexModel{
+		{Keys: bson.D{{Key: "expires_at", Value: 1}}, Options: options.Index().SetExpireAfterSeconds(0)},
+		{Keys: bson.D{{Key: "unlock_token", Value: 1}}, Options: options.Index().SetSparse(true)},
+	})
+	return err
+}
@@ -0,0 +1,460 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+
+	"fitnesshub/middleware"
+	"fitnesshub/models"
+	"fitnesshub/utils"
+)
+
+const (
+	// Каталог, в котором хранятся фотографии прогресса
+	ProgressPhotoDir     = "uploads/progress"
+	MaxProgressPhotoSize = 10 << 20
+)
+
+var progressPhotoTypes = map[string]string{
+	"image/jpeg": ".jpg",
+	"image/png":  ".png",
+}
+
+// Периоды, за которые считается изменение метрики
+var trendPeriods = map[string]int{"7d": 7, "30d": 30, "90d": 90}
+
+// CreateMeasurementHandler сохраняет замер текущего пользователя, переводя значения из его единиц в метрические
+func CreateMeasurementHandler(w http.ResponseWriter, r *http.Request, measurementCollection, userCollection *mongo.Collection) {
+	var measurement models.Measurement
+	err := json.NewDecoder(r.Body).Decode(&measurement)
+	if err != nil {
+		http.Error(w, "Invalid JSON message", http.StatusBadRequest)
+		return
+	}
+
+	viewer, err := currentUser(r, userCollection)
+	if err != nil {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	if measurement.Weight < 0 || measurement.BodyFatPercent < 0 || measurement.BodyFatPercent > 100 {
+		http.Error(w, "Invalid weight or body fat percentage", http.StatusBadRequest)
+		return
+	}
+	if measurement.Weight == 0 && measurement.BodyFatPercent == 0 && len(measurement.Circumferences) == 0 {
+		http.Error(w, "At least one measurement is required", http.StatusBadRequest)
+		return
+	}
+
+	units := viewer.PreferredUnits()
+	measurement.Weight = utils.ToKilograms(measurement.Weight, units.Weight)
+	for name, value := range measurement.Circumferences {
+		if value <= 0 {
+			http.Error(w, "Invalid circumference: "+name, http.StatusBadRequest)
+			return
+		}
+		measurement.Circumferences[name] = utils.ToCentimeters(value, units.Length)
+	}
+
+	measurement.ID = primitive.NilObjectID
+	measurement.UserID = viewer.ID
+	measurement.Photos = nil
+	if measurement.MeasuredAt.IsZero() {
+		measurement.MeasuredAt = time.Now()
+	}
+
+	result, err := measurementCollection.InsertOne(context.TODO(), measurement)
+	if err != nil {
+		http.Error(w, "Error saving measurement", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{"status": "success", "message": "Measurement saved successfully", "id": result.InsertedID})
+}
+
+// GetMeasurementsHandler возвращает замеры пользователя в единицах того, кто их запрашивает.
+// Тренер может передать ?user_id= своего клиента.
+func GetMeasurementsHandler(w http.ResponseWriter, r *http.Request, measurementCollection, userCollection *mongo.Collection) {
+	viewer, ownerID, status := resolveMeasurementOwner(r, userCollection)
+	if status != http.StatusOK {
+		http.Error(w, http.StatusText(status), status)
+		return
+	}
+
+	measurements, err := findMeasurements(measurementCollection, ownerID, -1)
+	if err != nil {
+		http.Error(w, "Error fetching measurements", http.StatusInternalServerError)
+		return
+	}
+
+	units := viewer.PreferredUnits()
+	for i := range measurements {
+		measurements[i].Weight = utils.FromKilograms(measurements[i].Weight, units.Weight)
+		for name, value := range measurements[i].Circumferences {
+			measurements[i].Circumferences[name] = utils.FromCentimeters(value, units.Length)
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{"units": units, "measurements": measurements})
+}
+
+func DeleteMeasurementByIDHandler(w http.ResponseWriter, r *http.Request, collection *mongo.Collection) {
+	objID, err := primitive.ObjectIDFromHex(routeParam(r, "id"))
+	if err != nil {
+		http.Error(w, "Invalid measurement ID", http.StatusBadRequest)
+		return
+	}
+
+	userID, err := primitive.ObjectIDFromHex(middleware.GetUserID(r))
+	if err != nil {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	var measurement models.Measurement
+	err = collection.FindOneAndDelete(context.TODO(), bson.M{"_id": objID, "user_id": userID}).Decode(&measurement)
+	if err != nil {
+		http.Error(w, "Measurement not found", http.StatusNotFound)
+		return
+	}
+
+	for _, photo := range measurement.Photos {
+		os.Remove(filepath.Join(ProgressPhotoDir, photo))
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{"status": "success", "message": "Measurement deleted successfully"})
+}
+
+// GetMeasurementTrendHandler возвращает динамику метрики (?metric=weight, body_fat или название обхвата)
+// со скользящим средним за 7 дней и изменением за 7, 30 и 90 дней
+func GetMeasurementTrendHandler(w http.ResponseWriter, r *http.Request, measurementCollection, userCollection *mongo.Collection) {
+	viewer, ownerID, status := resolveMeasurementOwner(r, userCollection)
+	if status != http.StatusOK {
+		http.Error(w, http.StatusText(status), status)
+		return
+	}
+
+	metric := r.URL.Query().Get("metric")
+	if metric == "" {
+		metric = "weight"
+	}
+
+	measurements, err := findMeasurements(measurementCollection, ownerID, 1)
+	if err != nil {
+		http.Error(w, "Error fetching measurements", http.StatusInternalServerError)
+		return
+	}
+
+	units := viewer.PreferredUnits()
+	trend := models.Trend{Metric: metric, Points: []models.TrendPoint{}, Deltas: map[string]*float64{}}
+	for _, measurement := range measurements {
+		var value float64
+		switch metric {
+		case "weight":
+			trend.Unit = units.Weight
+			value = utils.FromKilograms(measurement.Weight, units.Weight)
+		case "body_fat":
+			trend.Unit = "%"
+			value = measurement.BodyFatPercent
+		default:
+			trend.Unit = units.Length
+			value = utils.FromCentimeters(measurement.Circumferences[metric], units.Length)
+		}
+		if value == 0 {
+			continue
+		}
+		trend.Points = append(trend.Points, models.TrendPoint{MeasuredAt: measurement.MeasuredAt, Value: value})
+	}
+
+	for i := range trend.Points {
+		sum, count := 0.0, 0
+		for j := i; j >= 0 && trend.Points[i].MeasuredAt.Sub(trend.Points[j].MeasuredAt) < 7*24*time.Hour; j-- {
+			sum += trend.Points[j].Value
+			count++
+		}
+		trend.Points[i].MovingAverage = sum / float64(count)
+	}
+
+	if len(trend.Points) > 0 {
+		latest := trend.Points[len(trend.Points)-1]
+		for name, days := range trendPeriods {
+			since := latest.MeasuredAt.AddDate(0, 0, -days)
+			// Берём последний замер, сделанный не позже начала периода
+			index := sort.Search(len(trend.Points), func(i int) bool { return trend.Points[i].MeasuredAt.After(since) }) - 1
+			if index < 0 {
+				trend.Deltas[name] = nil
+				continue
+			}
+			delta := latest.Value - trend.Points[index].Value
+			trend.Deltas[name] = &delta
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(trend)
+}
+
+// UploadProgressPhotoHandler прикрепляет фотографию прогресса (поле формы photo) к замеру ?id=
+func UploadProgressPhotoHandler(w http.ResponseWriter, r *http.Request, collection *mongo.Collection) {
+	objID, err := primitive.ObjectIDFromHex(routeParam(r, "id"))
+	if err != nil {
+		http.Error(w, "Invalid measurement ID", http.StatusBadRequest)
+		return
+	}
+
+	userID, err := primitive.ObjectIDFromHex(middleware.GetUserID(r))
+	if err != nil {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	r.Body = http.MaxBytesReader(w, r.Body, MaxProgressPhotoSize)
+	file, _, err := r.FormFile("photo")
+	if err != nil {
+		http.Error(w, "Photo is required (max 10 MB)", http.StatusBadRequest)
+		return
+	}
+	defer file.Close()
+
+	header := make([]byte, 512)
+	n, _ := io.ReadFull(file, header)
+	extension, ok := progressPhotoTypes[http.DetectContentType(header[:n])]
+	if !ok {
+		http.Error(w, "Only JPEG and PNG photos are supported", http.StatusBadRequest)
+		return
+	}
+
+	count, err := collection.CountDocuments(context.TODO(), bson.M{"_id": objID, "user_id": userID})
+	if err != nil || count == 0 {
+		http.Error(w, "Measurement not found", http.StatusNotFound)
+		return
+	}
+
+	suffix, err := utils.GenerateVerificationToken()
+	if err != nil {
+		http.Error(w, "Error saving photo", http.StatusInternalServerError)
+		return
+	}
+	name := objID.Hex() + "_" + suffix + extension
+
+	if err = os.MkdirAll(ProgressPhotoDir, 0o750); err != nil {
+		http.Error(w, "Error saving photo", http.StatusInternalServerError)
+		return
+	}
+	out, err := os.Create(filepath.Join(ProgressPhotoDir, name))
+	if err != nil {
+		http.Error(w, "Error saving photo", http.StatusInternalServerError)
+		return
+	}
+	defer out.Close()
+
+	if _, err = io.Copy(out, io.MultiReader(bytes.NewReader(header[:n]), file)); err != nil {
+		os.Remove(out.Name())
+		http.Error(w, "Error saving photo", http.StatusInternalServerError)
+		return
+	}
+
+	_, err = collection.UpdateOne(context.TODO(), bson.M{"_id": objID}, bson.M{"$push": bson.M{"photos": name}})
+	if err != nil {
+		os.Remove(out.Name())
+		http.Error(w, "Error saving photo", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{"status": "success", "message": "Photo uploaded successfully", "photo": name})
+}
+
+// GetProgressPhotoHandler отдаёт фотографию ?name= из замера ?id= владельцу или его тренеру
+func GetProgressPhotoHandler(w http.ResponseWriter, r *http.Request, measurementCollection, userCollection *mongo.Collection) {
+	objID, err := primitive.ObjectIDFromHex(routeParam(r, "id"))
+	if err != nil {
+		http.Error(w, "Invalid measurement ID", http.StatusBadRequest)
+		return
+	}
+	name := r.URL.Query().Get("name")
+
+	var measurement models.Measurement
+	err = measurementCollection.FindOne(context.TODO(), bson.M{"_id": objID, "photos": name}).Decode(&measurement)
+	if err != nil {
+		http.Error(w, "Photo not found", http.StatusNotFound)
+		return
+	}
+
+	query := r.URL.Query()
+	query.Set("user_id", measurement.UserID.Hex())
+	r.URL.RawQuery = query.Encode()
+	if _, _, status := resolveMeasurementOwner(r, userCollection); status != http.StatusOK {
+		http.Error(w, http.StatusText(status), status)
+		return
+	}
+
+	w.Header().Set("Cache-Control", "private, max-age=3600")
+	http.ServeFile(w, r, filepath.Join(ProgressPhotoDir, filepath.Base(name)))
+}
+
+// UpdateUnitPreferencesHandler сохраняет единицы измерения текущего пользователя
+func UpdateUnitPreferencesHandler(w http.ResponseWriter, r *http.Request, collection *mongo.Collection) {
+	var units models.UnitPreferences
+	err := json.NewDecoder(r.Body).Decode(&units)
+	if err != nil {
+		http.Error(w, "Invalid JSON message", http.StatusBadRequest)
+		return
+	}
+
+	if (units.Weight != models.WeightUnitKg && units.Weight != models.WeightUnitLb) ||
+		(units.Length != models.LengthUnitCm && units.Length != models.LengthUnitIn) {
+		http.Error(w, "Units must be kg/lb and cm/in", http.StatusBadRequest)
+		return
+	}
+
+	userID, err := primitive.ObjectIDFromHex(middleware.GetUserID(r))
+	if err != nil {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	_, err = collection.UpdateOne(context.TODO(), notDeleted(bson.M{"_id": userID}), bson.M{"$set": bson.M{"units": units}})
+	if err != nil {
+		http.Error(w, "Error updating units", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{"status": "success", "message": "Units updated successfully"})
+}
+
+// AssignTrainerHandler закрепляет участника за тренером; пустой trainer_id снимает закрепление
+func AssignTrainerHandler(w http.ResponseWriter, r *http.Request, collection, auditCollection *mongo.Collection) {
+	var request struct {
+		UserID    primitive.ObjectID `json:"user_id"`
+		TrainerID primitive.ObjectID `json:"trainer_id"`
+	}
+	err := json.NewDecoder(r.Body).Decode(&request)
+	if err != nil {
+		http.Error(w, "Invalid JSON message", http.StatusBadRequest)
+		return
+	}
+
+	update := bson.M{"$unset": bson.M{"trainer_id": ""}}
+	if !request.TrainerID.IsZero() {
+		count, err := collection.CountDocuments(context.TODO(), notDeleted(bson.M{"_id": request.TrainerID, "role": "trainer"}))
+		if err != nil || count == 0 {
+			http.Error(w, "Trainer not found", http.StatusNotFound)
+			return
+		}
+		update = bson.M{"$set": bson.M{"trainer_id": request.TrainerID}}
+	}
+
+	before, after, err := auditedUpdate(collection, notDeleted(bson.M{"_id": request.UserID}), update)
+	if err == mongo.ErrNoDocuments {
+		http.Error(w, "User not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		http.Error(w, "Error assigning trainer", http.StatusInternalServerError)
+		return
+	}
+
+	event := auditEvent(r, "users.assign_trainer", "users", request.UserID.Hex())
+	event.Changes = auditDiff(before, after)
+	recordAudit(auditCollection, event)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{"status": "success", "message": "Trainer assigned successfully"})
+}
+
+// GetTrainerClientsHandler возвращает участников, закреплённых за текущим тренером
+func GetTrainerClientsHandler(w http.ResponseWriter, r *http.Request, collection *mongo.Collection) {
+	trainerID, err := primitive.ObjectIDFromHex(middleware.GetUserID(r))
+	if err != nil {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	clients := []map[string]interface{}{}
+	projection := options.Find().SetProjection(bson.M{"email": 1})
+	cursor, err := collection.Find(context.TODO(), notDeleted(bson.M{"trainer_id": trainerID}), projection)
+	if err != nil {
+		http.Error(w, "Error fetching clients", http.StatusInternalServerError)
+		return
+	}
+	defer cursor.Close(context.TODO())
+
+	for cursor.Next(context.TODO()) {
+		var user models.User
+		cursor.Decode(&user)
+		clients = append(clients, map[string]interface{}{"id": user.ID, "email": user.Email})
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(clients)
+}
+
+func currentUser(r *http.Request, collection *mongo.Collection) (models.User, error) {
+	var user models.User
+	userID, err := primitive.ObjectIDFromHex(middleware.GetUserID(r))
+	if err != nil {
+		return user, err
+	}
+	err = collection.FindOne(context.TODO(), notDeleted(bson.M{"_id": userID})).Decode(&user)
+	return user, err
+}
+
+// resolveMeasurementOwner определяет, чьи замеры запрошены (?user_id=), и проверяет,
+// что это сам пользователь, его закреплённый тренер или администратор
+func resolveMeasurementOwner(r *http.Request, collection *mongo.Collection) (models.User, primitive.ObjectID, int) {
+	viewer, err := currentUser(r, collection)
+	if err != nil {
+		return viewer, primitive.NilObjectID, http.StatusUnauthorized
+	}
+
+	requested := r.URL.Query().Get("user_id")
+	if requested == "" || requested == viewer.ID.Hex() {
+		return viewer, viewer.ID, http.StatusOK
+	}
+
+	ownerID, err := primitive.ObjectIDFromHex(requested)
+	if err != nil {
+		return viewer, ownerID, http.StatusBadRequest
+	}
+
+	var owner models.User
+	err = collection.FindOne(context.TODO(), notDeleted(bson.M{"_id": ownerID})).Decode(&owner)
+	if err != nil {
+		return viewer, ownerID, http.StatusNotFound
+	}
+
+	if viewer.Role == "administrator" || (viewer.Role == "trainer" && owner.TrainerID == viewer.ID) {
+		return viewer, ownerID, http.StatusOK
+	}
+	return viewer, ownerID, http.StatusForbidden
+}
+
+func findMeasurements(collection *mongo.Collection, userID primitive.ObjectID, order int) ([]models.Measurement, error) {
+	measurements := []models.Measurement{}
+	cursor, err := collection.Find(context.TODO(), bson.M{"user_id": userID}, options.Find().SetSort(bson.D{{Key: "measured_at", Value: order}}))
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(context.TODO())
+
+	err = cursor.All(context.TODO(), &measurements)
+	return measurements, err
+}
@@ -0,0 +1,595 @@
+package handlers
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"io"
+	"math"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+
+	"fitnesshub/middleware"
+	"fitnesshub/models"
+	"fitnesshub/utils"
+)
+
+var mealTypes = map[string]bool{"breakfast": true, "lunch": true, "dinner": true, "snack": true}
+
+func CreateFoodItemHandler(w http.ResponseWriter, r *http.Request, collection, auditCollection *mongo.Collection) {
+	var food models.FoodItem
+	err := json.NewDecoder(r.Body).Decode(&food)
+	if err != nil {
+		http.Error(w, "Invalid JSON message", http.StatusBadRequest)
+		return
+	}
+
+	if msg := validateFoodItem(&food); msg != "" {
+		http.Error(w, msg, http.StatusBadRequest)
+		return
+	}
+
+	result, err := collection.InsertOne(context.TODO(), food)
+	if err != nil {
+		http.Error(w, "Error adding food item", http.StatusInternalServerError)
+		return
+	}
+	food.ID = result.InsertedID.(primitive.ObjectID)
+
+	event := auditEvent(r, "foods.create", "foods", food.ID.Hex())
+	event.Changes = auditDiff(nil, toAuditDoc(food))
+	recordAudit(auditCollection, event)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{"status": "success", "message": "Food item added successfully"})
+}
+
+// GetAllFoodItemsHandler ищет продукты по названию (?q=), возвращая не более ?limit= записей
+func GetAllFoodItemsHandler(w http.ResponseWriter, r *http.Request, collection *mongo.Collection) {
+	filter := bson.M{}
+	if q := r.URL.Query().Get("q"); q != "" {
+		filter["name"] = bson.M{"$regex": regexp.QuoteMeta(q), "$options": "i"}
+	}
+	limit, err := strconv.Atoi(r.URL.Query().Get("limit"))
+	if err != nil || limit <= 0 || limit > 100 {
+		limit = 50
+	}
+
+	var foods []models.FoodItem
+	cursor, err := collection.Find(context.TODO(), filter, options.Find().SetSort(bson.D{{Key: "name", Value: 1}}).SetLimit(int64(limit)))
+	if err != nil {
+		http.Error(w, "Error fetching food items", http.StatusInternalServerError)
+		return
+	}
+	defer cursor.Close(context.TODO())
+
+	for cursor.Next(context.TODO()) {
+		var food models.FoodItem
+		cursor.Decode(&food)
+		foods = append(foods, food)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(foods)
+}
+
+func UpdateFoodItemByIDHandler(w http.ResponseWriter, r *http.Request, collection, auditCollection *mongo.Collection) {
+	var food models.FoodItem
+	err := json.NewDecoder(r.Body).Decode(&food)
+	if err != nil {
+		http.Error(w, "Invalid JSON message", http.StatusBadRequest)
+		return
+	}
+
+	pathObjectID(r, &food.ID)
+	if food.ID.IsZero() {
+		http.Error(w, "Food item ID is required", http.StatusBadRequest)
+		return
+	}
+	if msg := validateFoodItem(&food); msg != "" {
+		http.Error(w, msg, http.StatusBadRequest)
+		return
+	}
+
+	before, after, err := auditedUpdate(collection, bson.M{"_id": food.ID}, bson.M{"$set": food})
+	if err == mongo.ErrNoDocuments {
+		http.Error(w, "Food item not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		http.Error(w, "Error updating food item", http.StatusInternalServerError)
+		return
+	}
+
+	event := auditEvent(r, "foods.update", "foods", food.ID.Hex())
+	event.Changes = auditDiff(before, after)
+	recordAudit(auditCollection, event)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{"status": "success", "message": "Food item updated successfully"})
+}
+
+func DeleteFoodItemByIDHandler(w http.ResponseWriter, r *http.Request, collection, auditCollection *mongo.Collection) {
+	objID, err := primitive.ObjectIDFromHex(routeParam(r, "id"))
+	if err != nil {
+		http.Error(w, "Invalid food item ID", http.StatusBadRequest)
+		return
+	}
+
+	before, err := auditedDelete(collection, bson.M{"_id": objID})
+	if err == mongo.ErrNoDocuments {
+		http.Error(w, "Food item not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		http.Error(w, "Error deleting food item", http.StatusInternalServerError)
+		return
+	}
+
+	event := auditEvent(r, "foods.delete", "foods", objID.Hex())
+	event.Changes = auditDiff(before, nil)
+	recordAudit(auditCollection, event)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{"status": "success", "message": "Food item deleted successfully"})
+}
+
+// ImportFoodItemsHandler загружает продукты из CSV (тело запроса или поле формы file).
+// Первая строка — заголовок с колонками name, brand, calories, protein, carbs, fat, serving_grams;
+// brand и serving_grams необязательны. Продукты с тем же названием и брендом обновляются.
+func ImportFoodItemsHandler(w http.ResponseWriter, r *http.Request, collection, auditCollection *mongo.Collection) {
+	var source io.Reader = r.Body
+	if strings.HasPrefix(r.Header.Get("Content-Type"), "multipart/form-data") {
+		file, _, err := r.FormFile("file")
+		if err != nil {
+			http.Error(w, "CSV file is required", http.StatusBadRequest)
+			return
+		}
+		defer file.Close()
+		source = file
+	}
+
+	reader := csv.NewReader(source)
+	reader.TrimLeadingSpace = true
+	header, err := reader.Read()
+	if err != nil {
+		http.Error(w, "Invalid CSV header", http.StatusBadRequest)
+		return
+	}
+
+	columns := map[string]int{}
+	for i, name := range header {
+		columns[strings.ToLower(strings.TrimSpace(name))] = i
+	}
+	for _, required := range []string{"name", "calories", "protein", "carbs", "fat"} {
+		if _, ok := columns[required]; !ok {
+			http.Error(w, "Missing CSV column: "+required, http.StatusBadRequest)
+			return
+		}
+	}
+
+	imported, line := 0, 1
+	rowErrors := []string{}
+	for {
+		record, err := reader.Read()
+		line++
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			rowErrors = append(rowErrors, err.Error())
+			continue
+		}
+
+		field := func(name string) string {
+			if i, ok := columns[name]; ok && i < len(record) {
+				return strings.TrimSpace(record[i])
+			}
+			return ""
+		}
+		number := func(name string) float64 {
+			value, err := strconv.ParseFloat(field(name), 64)
+			if err != nil {
+				return math.NaN()
+			}
+			return value
+		}
+
+		food := models.FoodItem{
+			Name:     field("name"),
+			Brand:    field("brand"),
+			Calories: number("calories"),
+			Protein:  number("protein"),
+			Carbs:    number("carbs"),
+			Fat:      number("fat"),
+		}
+		if field("serving_grams") != "" {
+			food.ServingGrams = number("serving_grams")
+		}
+		if msg := validateFoodItem(&food); msg != "" {
+			rowErrors = append(rowErrors, "line "+strconv.Itoa(line)+": "+msg)
+			continue
+		}
+
+		_, err = collection.UpdateOne(context.TODO(),
+			bson.M{"name": food.Name, "brand": food.Brand},
+			bson.M{"$set": food},
+			options.Update().SetUpsert(true))
+		if err != nil {
+			rowErrors = append(rowErrors, "line "+strconv.Itoa(line)+": error saving food item")
+			continue
+		}
+		imported++
+	}
+
+	event := auditEvent(r, "foods.import", "foods", "")
+	event.Details = strconv.Itoa(imported) + " imported, " + strconv.Itoa(len(rowErrors)) + " errors"
+	recordAudit(auditCollection, event)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{"status": "success", "imported": imported, "errors": rowErrors})
+}
+
+// LogMealHandler сохраняет приём пищи текущего пользователя
+func LogMealHandler(w http.ResponseWriter, r *http.Request, mealCollection, foodCollection *mongo.Collection) {
+	var meal models.MealLog
+	err := json.NewDecoder(r.Body).Decode(&meal)
+	if err != nil {
+		http.Error(w, "Invalid JSON message", http.StatusBadRequest)
+		return
+	}
+
+	userID, err := primitive.ObjectIDFromHex(middleware.GetUserID(r))
+	if err != nil {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	if meal.Date == "" {
+		meal.Date = time.Now().Format("2006-01-02")
+	}
+	if _, err = time.Parse("2006-01-02", meal.Date); err != nil {
+		http.Error(w, "Invalid date", http.StatusBadRequest)
+		return
+	}
+	if !mealTypes[meal.Meal] {
+		http.Error(w, "Meal must be breakfast, lunch, dinner or snack", http.StatusBadRequest)
+		return
+	}
+	if len(meal.Items) == 0 {
+		http.Error(w, "At least one item is required", http.StatusBadRequest)
+		return
+	}
+
+	for i, item := range meal.Items {
+		if item.Servings <= 0 {
+			http.Error(w, "Servings must be positive", http.StatusBadRequest)
+			return
+		}
+
+		var food models.FoodItem
+		err = foodCollection.FindOne(context.TODO(), bson.M{"_id": item.FoodID}).Decode(&food)
+		if err != nil {
+			http.Error(w, "Food item not found: "+item.FoodID.Hex(), http.StatusBadRequest)
+			return
+		}
+
+		grams := item.Servings * food.ServingGrams
+		factor := grams / 100
+		meal.Items[i] = models.MealItem{
+			FoodID:   food.ID,
+			Name:     food.Name,
+			Servings: item.Servings,
+			Grams:    grams,
+			Macros: models.Macros{
+				Calories: food.Calories * factor,
+				Protein:  food.Protein * factor,
+				Carbs:    food.Carbs * factor,
+				Fat:      food.Fat * factor,
+			},
+		}
+	}
+
+	meal.ID = primitive.NilObjectID
+	meal.UserID = userID
+	meal.LoggedAt = time.Now()
+
+	result, err := mealCollection.InsertOne(context.TODO(), meal)
+	if err != nil {
+		http.Error(w, "Error saving meal", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{"status": "success", "message": "Meal logged successfully", "id": result.InsertedID})
+}
+
+// GetMealsHandler возвращает приёмы пищи текущего пользователя за день ?date= (по умолчанию сегодня)
+func GetMealsHandler(w http.ResponseWriter, r *http.Request, collection *mongo.Collection) {
+	userID, err := primitive.ObjectIDFromHex(middleware.GetUserID(r))
+	if err != nil {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	date := r.URL.Query().Get("date")
+	if date == "" {
+		date = time.Now().Format("2006-01-02")
+	}
+
+	meals, err := findMeals(collection, bson.M{"user_id": userID, "date": date})
+	if err != nil {
+		http.Error(w, "Error fetching meals", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(meals)
+}
+
+func DeleteMealByIDHandler(w http.ResponseWriter, r *http.Request, collection *mongo.Collection) {
+	objID, err := primitive.ObjectIDFromHex(routeParam(r, "id"))
+	if err != nil {
+		http.Error(w, "Invalid meal ID", http.StatusBadRequest)
+		return
+	}
+
+	userID, err := primitive.ObjectIDFromHex(middleware.GetUserID(r))
+	if err != nil {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	result, err := collection.DeleteOne(context.TODO(), bson.M{"_id": objID, "user_id": userID})
+	if err != nil {
+		http.Error(w, "Error deleting meal", http.StatusInternalServerError)
+		return
+	}
+	if result.DeletedCount == 0 {
+		http.Error(w, "Meal not found", http.StatusNotFound)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{"status": "success", "message": "Meal deleted successfully"})
+}
+
+// UpdateNutritionSettingsHandler сохраняет данные для расчёта норм питания текущего пользователя
+func UpdateNutritionSettingsHandler(w http.ResponseWriter, r *http.Request, collection *mongo.Collection) {
+	var settings models.NutritionSettings
+	err := json.NewDecoder(r.Body).Decode(&settings)
+	if err != nil {
+		http.Error(w, "Invalid JSON message", http.StatusBadRequest)
+		return
+	}
+
+	if settings.ActivityLevel != "" && !utils.IsValidActivityLevel(settings.ActivityLevel) {
+		http.Error(w, "Invalid activity level", http.StatusBadRequest)
+		return
+	}
+	if settings.Goal != "" && !utils.IsValidGoal(settings.Goal) {
+		http.Error(w, "Goal must be lose, maintain or gain", http.StatusBadRequest)
+		return
+	}
+	if settings.HeightCm < 0 {
+		http.Error(w, "Height cannot be negative", http.StatusBadRequest)
+		return
+	}
+
+	userID, err := primitive.ObjectIDFromHex(middleware.GetUserID(r))
+	if err != nil {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	_, err = collection.UpdateOne(context.TODO(), notDeleted(bson.M{"_id": userID}), bson.M{"$set": bson.M{"nutrition": settings}})
+	if err != nil {
+		http.Error(w, "Error updating nutrition settings", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{"status": "success", "message": "Nutrition settings updated successfully"})
+}
+
+// GetNutritionTargetsHandler возвращает суточные нормы текущего пользователя
+func GetNutritionTargetsHandler(w http.ResponseWriter, r *http.Request, userCollection, measurementCollection *mongo.Collection) {
+	user, err := currentUser(r, userCollection)
+	if err != nil {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	target, err := nutritionTarget(user, measurementCollection)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(target)
+}
+
+// GetDailyNutritionSummaryHandler возвращает итог по БЖУ за день ?date= в сравнении с нормой
+func GetDailyNutritionSummaryHandler(w http.ResponseWriter, r *http.Request, mealCollection, userCollection, measurementCollection *mongo.Collection) {
+	user, err := currentUser(r, userCollection)
+	if err != nil {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	date := r.URL.Query().Get("date")
+	if date == "" {
+		date = time.Now().Format("2006-01-02")
+	}
+
+	summaries, err := nutritionSummaries(user, []string{date}, mealCollection, measurementCollection)
+	if err != nil {
+		http.Error(w, "Error fetching meals", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(summaries[0])
+}
+
+// GetWeeklyNutritionSummaryHandler возвращает итоги по дням недели ?week= и среднее за неделю
+func GetWeeklyNutritionSummaryHandler(w http.ResponseWriter, r *http.Request, mealCollection, userCollection, measurementCollection *mongo.Collection) {
+	user, err := currentUser(r, userCollection)
+	if err != nil {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	day := time.Now()
+	if week := r.URL.Query().Get("week"); week != "" {
+		day, err = time.ParseInLocation("2006-01-02", week, time.Local)
+		if err != nil {
+			http.Error(w, "Invalid week date", http.StatusBadRequest)
+			return
+		}
+	}
+
+	weekStart := utils.StartOfWeek(day)
+	dates := make([]string, 7)
+	for i := range dates {
+		dates[i] = weekStart.AddDate(0, 0, i).Format("2006-01-02")
+	}
+
+	summaries, err := nutritionSummaries(user, dates, mealCollection, measurementCollection)
+	if err != nil {
+		http.Error(w, "Error fetching meals", http.StatusInternalServerError)
+		return
+	}
+
+	var average models.Macros
+	loggedDays := 0
+	for _, summary := range summaries {
+		if summary.Totals.Calories > 0 {
+			average.Add(summary.Totals)
+			loggedDays++
+		}
+	}
+	if loggedDays > 0 {
+		average = models.Macros{
+			Calories: average.Calories / float64(loggedDays),
+			Protein:  average.Protein / float64(loggedDays),
+			Carbs:    average.Carbs / float64(loggedDays),
+			Fat:      average.Fat / float64(loggedDays),
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"week_start":    weekStart.Format("2006-01-02"),
+		"days":          summaries,
+		"logged_days":   loggedDays,
+		"daily_average": average,
+	})
+}
+
+// nutritionTarget возвращает заданную пользователем норму или рассчитывает её по профилю
+// (пол, дата рождения), настройкам питания и последнему замеру веса
+func nutritionTarget(user models.User, measurementCollection *mongo.Collection) (*models.Macros, error) {
+	settings := user.Nutrition
+	if settings.Target != nil {
+		return settings.Target, nil
+	}
+
+	var latest models.Measurement
+	err := measurementCollection.FindOne(context.TODO(),
+		bson.M{"user_id": user.ID, "weight": bson.M{"$gt": 0}},
+		options.FindOne().SetSort(bson.D{{Key: "measured_at", Value: -1}})).Decode(&latest)
+	if err != nil && err != mongo.ErrNoDocuments {
+		return nil, err
+	}
+
+	var birthDate time.Time
+	if user.Profile.DateOfBirth != nil {
+		birthDate = *user.Profile.DateOfBirth
+	}
+	goal := settings.Goal
+	if goal == "" {
+		goal = models.GoalMaintain
+	}
+
+	targets, err := utils.CalculateMacroTargets(user.Profile.Gender, birthDate, settings.HeightCm, latest.Weight, settings.ActivityLevel, goal, time.Now())
+	if err != nil {
+		return nil, err
+	}
+	return &models.Macros{Calories: targets.Calories, Protein: targets.Protein, Carbs: targets.Carbs, Fat: targets.Fat}, nil
+}
+
+func nutritionSummaries(user models.User, dates []string, mealCollection, measurementCollection *mongo.Collection) ([]models.NutritionSummary, error) {
+	meals, err := findMeals(mealCollection, bson.M{"user_id": user.ID, "date": bson.M{"$in": dates}})
+	if err != nil {
+		return nil, err
+	}
+
+	totals := map[string]*models.Macros{}
+	for _, date := range dates {
+		totals[date] = &models.Macros{}
+	}
+	for _, meal := range meals {
+		for _, item := range meal.Items {
+			totals[meal.Date].Add(item.Macros)
+		}
+	}
+
+	// Если норму рассчитать не удалось (не заполнен профиль), итоги возвращаются без неё
+	target, _ := nutritionTarget(user, measurementCollection)
+
+	summaries := make([]models.NutritionSummary, 0, len(dates))
+	for _, date := range dates {
+		summary := models.NutritionSummary{Date: date, Totals: *totals[date], Target: target}
+		if target != nil {
+			summary.Remaining = &models.Macros{
+				Calories: target.Calories - summary.Totals.Calories,
+				Protein:  target.Protein - summary.Totals.Protein,
+				Carbs:    target.Carbs - summary.Totals.Carbs,
+				Fat:      target.Fat - summary.Totals.Fat,
+			}
+		}
+		summaries = append(summaries, summary)
+	}
+	return summaries, nil
+}
+
+func findMeals(collection *mongo.Collection, filter bson.M) ([]models.MealLog, error) {
+	meals := []models.MealLog{}
+	cursor, err := collection.Find(context.TODO(), filter, options.Find().SetSort(bson.D{{Key: "logged_at", Value: 1}}))
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(context.TODO())
+
+	err = cursor.All(context.TODO(), &meals)
+	return meals, err
+}
+
+// validateFoodItem проверяет пищевую ценность и подставляет порцию 100 г по умолчанию
+func validateFoodItem(food *models.FoodItem) string {
+	if food.Name == "" {
+		return "Name is required"
+	}
+	for _, value := range []float64{food.Calories, food.Protein, food.Carbs, food.Fat, food.ServingGrams} {
+		if math.IsNaN(value) || value < 0 {
+			return "Nutrition values must be non-negative numbers"
+		}
+	}
+	if food.Protein+food.Carbs+food.Fat > 100 {
+		return "Macros cannot exceed 100 g per 100 g"
+	}
+	if food.ServingGrams == 0 {
+		food.ServingGrams = 100
+	}
+	return ""
+}
@@ -0,0 +1,666 @@
+package handlers
+
+import (
+	"context"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+
+	"fitnesshub/middleware"
+	"fitnesshub/models"
+	"fitnesshub/utils"
+)
+
+const (
+	AuthorizationCodeTTL = 10 * time.Minute
+	AccessTokenTTL       = time.Hour
+)
+
+// oauthScopeDescriptions — пояснения к областям доступа для экрана согласия
+var oauthScopeDescriptions = map[string]string{
+	models.ScopeProfileRead:  "View your profile: name, contact details and fitness goals",
+	models.ScopeWorkoutsRead: "View your workout history",
+	models.ScopeVisitsRead:   "View your gym visits",
+}
+
+var errInvalidClient = errors.New("invalid client")
+
+// writeOAuthError отвечает ошибкой в формате RFC 6749 (раздел 5.2)
+func writeOAuthError(w http.ResponseWriter, status int, code, description string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(map[string]string{"error": code, "error_description": description})
+}
+
+func containsString(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+// findOAuthClient возвращает действующего (не отозванного) клиента по client_id
+func findOAuthClient(collection *mongo.Collection, clientID string) (models.OAuthClient, error) {
+	var client models.OAuthClient
+	objID, err := primitive.ObjectIDFromHex(clientID)
+	if err != nil {
+		return client, errInvalidClient
+	}
+	err = collection.FindOne(context.TODO(), bson.M{"_id": objID, "revoked_at": nil}).Decode(&client)
+	if err != nil {
+		return client, errInvalidClient
+	}
+	return client, nil
+}
+
+// authenticateClient проверяет клиента по HTTP Basic или полям client_id/client_secret;
+// публичным клиентам секрет не нужен, их защищает PKCE
+func authenticateClient(r *http.Request, collection *mongo.Collection) (models.OAuthClient, error) {
+	clientID, secret, ok := r.BasicAuth()
+	if ok {
+		clientID, _ = url.QueryUnescape(clientID)
+		secret, _ = url.QueryUnescape(secret)
+	} else {
+		clientID, secret = r.PostFormValue("client_id"), r.PostFormValue("client_secret")
+	}
+
+	client, err := findOAuthClient(collection, clientID)
+	if err != nil {
+		return client, err
+	}
+	if client.Confidential && subtle.ConstantTimeCompare([]byte(utils.HashToken(secret)), []byte(client.SecretHash)) != 1 {
+		return client, errInvalidClient
+	}
+	return client, nil
+}
+
+// authorizeRequest — параметры запроса авторизации из строки запроса или формы согласия
+type authorizeRequest struct {
+	ClientID            string
+	RedirectURI         string
+	ResponseType        string
+	Scopes              []string
+	State               string
+	CodeChallenge       string
+	CodeChallengeMethod string
+}
+
+func parseAuthorizeRequest(r *http.Request) authorizeRequest {
+	return authorizeRequest{
+		ClientID:            r.FormValue("client_id"),
+		RedirectURI:         r.FormValue("redirect_uri"),
+		ResponseType:        r.FormValue("response_type"),
+		Scopes:              strings.Fields(r.FormValue("scope")),
+		State:               r.FormValue("state"),
+		CodeChallenge:       r.FormValue("code_challenge"),
+		CodeChallengeMethod: r.FormValue("code_challenge_method"),
+	}
+}
+
+// validateAuthorizeRequest проверяет клиента и redirect_uri (при ошибке в них на адрес клиента
+// не перенаправляем) и возвращает код ошибки OAuth для остальных параметров
+func validateAuthorizeRequest(collection *mongo.Collection, request authorizeRequest) (models.OAuthClient, string, error) {
+	client, err := findOAuthClient(collection, request.ClientID)
+	if err != nil {
+		return client, "", errors.New("Unknown client")
+	}
+	if !containsString(client.RedirectURIs, request.RedirectURI) {
+		return client, "", errors.New("Redirect URI is not registered for this client")
+	}
+
+	if request.ResponseType != "code" || !containsString(client.GrantTypes, models.GrantAuthorizationCode) {
+		return client, "unsupported_response_type", nil
+	}
+	if request.CodeChallenge == "" || request.CodeChallengeMethod != "S256" {
+		return client, "invalid_request", nil
+	}
+	if len(request.Scopes) == 0 {
+		return client, "invalid_scope", nil
+	}
+	for _, scope := range request.Scopes {
+		if !containsString(client.Scopes, scope) {
+			return client, "invalid_scope", nil
+		}
+	}
+	return client, "", nil
+}
+
+// redirectToClient возвращает браузер на redirect_uri клиента с параметрами ответа и state
+func redirectToClient(w http.ResponseWriter, r *http.Request, request authorizeRequest, params url.Values) {
+	target, err := url.Parse(request.RedirectURI)
+	if err != nil {
+		http.Error(w, "Invalid redirect URI", http.StatusBadRequest)
+		return
+	}
+	query := target.Query()
+	for key, values := range params {
+		query[key] = values
+	}
+	if request.State != "" {
+		query.Set("state", request.State)
+	}
+	target.RawQuery = query.Encode()
+	http.Redirect(w, r, target.String(), http.StatusFound)
+}
+
+// issueAuthorizationCode сохраняет хеш одноразового кода и возвращает браузер к клиенту
+func issueAuthorizationCode(w http.ResponseWriter, r *http.Request, codeCollection *mongo.Collection, client models.OAuthClient, userID primitive.ObjectID, request authorizeRequest) {
+	code, err := utils.GenerateVerificationToken()
+	if err != nil {
+		redirectToClient(w, r, request, url.Values{"error": {"server_error"}})
+		return
+	}
+	_, err = codeCollection.InsertOne(context.TODO(), models.OAuthCode{
+		ID:            utils.HashToken(code),
+		ClientID:      client.ID,
+		UserID:        userID,
+		RedirectURI:   request.RedirectURI,
+		Scopes:        request.Scopes,
+		CodeChallenge: request.CodeChallenge,
+		ExpiresAt:     time.Now().Add(AuthorizationCodeTTL),
+	})
+	if err != nil {
+		redirectToClient(w, r, request, url.Values{"error": {"server_error"}})
+		return
+	}
+	redirectToClient(w, r, request, url.Values{"code": {code}})
+}
+
+// AuthorizeHandler показывает участнику экран согласия; если нужные области уже
+// разрешены этому приложению, код выдаётся сразу
+func AuthorizeHandler(w http.ResponseWriter, r *http.Request, clientCollection, codeCollection, consentCollection *mongo.Collection) {
+	request := parseAuthorizeRequest(r)
+	client, errorCode, err := validateAuthorizeRequest(clientCollection, request)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if errorCode != "" {
+		redirectToClient(w, r, request, url.Values{"error": {errorCode}})
+		return
+	}
+
+	userID, err := primitive.ObjectIDFromHex(middleware.GetUserID(r))
+	if err != nil {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	var consent models.OAuthConsent
+	err = consentCollection.FindOne(context.TODO(), bson.M{"user_id": userID, "client_id": client.ID}).Decode(&consent)
+	if err == nil {
+		granted := true
+		for _, scope := range request.Scopes {
+			granted = granted && containsString(consent.Scopes, scope)
+		}
+		if granted {
+			issueAuthorizationCode(w, r, codeCollection, client, userID, request)
+			return
+		}
+	}
+
+	scopes := make([]map[string]string, 0, len(request.Scopes))
+	for _, scope := range request.Scopes {
+		scopes = append(scopes, map[string]string{"Name": scope, "Description": oauthScopeDescriptions[scope]})
+	}
+	renderAdminPage(w, r, "templates/oauth_consent.html", map[string]interface{}{
+		"Client":  client,
+		"Scopes":  scopes,
+		"Request": request,
+		"Scope":   strings.Join(request.Scopes, " "),
+	})
+}
+
+// AuthorizeDecisionHandler принимает решение участника на экране согласия
+func AuthorizeDecisionHandler(w http.ResponseWriter, r *http.Request, clientCollection, codeCollection, consentCollection, auditCollection *mongo.Collection) {
+	request := parseAuthorizeRequest(r)
+	client, errorCode, err := validateAuthorizeRequest(clientCollection, request)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if errorCode != "" {
+		redirectToClient(w, r, request, url.Values{"error": {errorCode}})
+		return
+	}
+	if r.PostFormValue("decision") != "approve" {
+		redirectToClient(w, r, request, url.Values{"error": {"access_denied"}})
+		return
+	}
+
+	userID, err := primitive.ObjectIDFromHex(middleware.GetUserID(r))
+	if err != nil {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	_, err = consentCollection.UpdateOne(context.TODO(),
+		bson.M{"user_id": userID, "client_id": client.ID},
+		bson.M{"$addToSet": bson.M{"scopes": bson.M{"$each": request.Scopes}}, "$set": bson.M{"granted_at": time.Now()}},
+		options.Update().SetUpsert(true))
+	if err != nil {
+		redirectToClient(w, r, request, url.Values{"error": {"server_error"}})
+		return
+	}
+
+	event := auditEvent(r, "oauth.consent_granted", "oauth_clients", client.ID.Hex())
+	event.Details = strings.Join(request.Scopes, " ")
+	recordAudit(auditCollection, event)
+
+	issueAuthorizationCode(w, r, codeCollection, client, userID, request)
+}
+
+// TokenHandler — конечная точка /oauth/token: обмен кода авторизации с проверкой PKCE
+// и выдача токена приложению по client_credentials
+func TokenHandler(w http.ResponseWriter, r *http.Request, clientCollection, codeCollection, tokenCollection *mongo.Collection) {
+	if err := r.ParseForm(); err != nil {
+		writeOAuthError(w, http.StatusBadRequest, "invalid_request", "Invalid form body")
+		return
+	}
+	client, err := authenticateClient(r, clientCollection)
+	if err != nil {
+		w.Header().Set("WWW-Authenticate", `Basic realm="fitnesshub"`)
+		writeOAuthError(w, http.StatusUnauthorized, "invalid_client", "Client authentication failed")
+		return
+	}
+
+	grantType := r.PostFormValue("grant_type")
+	if !containsString(client.GrantTypes, grantType) {
+		writeOAuthError(w, http.StatusBadRequest, "unauthorized_client", "Grant type is not allowed for this client")
+		return
+	}
+
+	var userID primitive.ObjectID
+	var scopes []string
+	switch grantType {
+	case models.GrantAuthorizationCode:
+		var code models.OAuthCode
+		err := codeCollection.FindOneAndDelete(context.TODO(), bson.M{"_id": utils.HashToken(r.PostFormValue("code"))}).Decode(&code)
+		if err != nil || time.Now().After(code.ExpiresAt) || code.ClientID != client.ID || code.RedirectURI != r.PostFormValue("redirect_uri") {
+			writeOAuthError(w, http.StatusBadRequest, "invalid_grant", "Invalid or expired authorization code")
+			return
+		}
+		sum := sha256.Sum256([]byte(r.PostFormValue("code_verifier")))
+		if subtle.ConstantTimeCompare([]byte(base64.RawURLEncoding.EncodeToString(sum[:])), []byte(code.CodeChallenge)) != 1 {
+			writeOAuthError(w, http.StatusBadRequest, "invalid_grant", "PKCE verification failed")
+			return
+		}
+		userID, scopes = code.UserID, code.Scopes
+
+	case models.GrantClientCredentials:
+		if !client.Confidential {
+			writeOAuthError(w, http.StatusBadRequest, "unauthorized_client", "Public clients cannot use client credentials")
+			return
+		}
+		scopes = strings.Fields(r.PostFormValue("scope"))
+		if len(scopes) == 0 {
+			scopes = client.Scopes
+		}
+		for _, scope := range scopes {
+			if !containsString(client.Scopes, scope) {
+				writeOAuthError(w, http.StatusBadRequest, "invalid_scope", "Scope is not allowed for this client: "+scope)
+				return
+			}
+		}
+
+	default:
+		writeOAuthError(w, http.StatusBadRequest, "unsupported_grant_type", "Unsupported grant type")
+		return
+	}
+
+	accessToken, err := utils.GenerateVerificationToken()
+	if err != nil {
+		writeOAuthError(w, http.StatusInternalServerError, "server_error", "Error generating token")
+		return
+	}
+	now := time.Now()
+	_, err = tokenCollection.InsertOne(context.TODO(), models.OAuthToken{
+		ID:        utils.HashToken(accessToken),
+		ClientID:  client.ID,
+		UserID:    userID,
+		Scopes:    scopes,
+		IssuedAt:  now,
+		ExpiresAt: now.Add(AccessTokenTTL),
+	})
+	if err != nil {
+		writeOAuthError(w, http.StatusInternalServerError, "server_error", "Error storing token")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"access_token": accessToken,
+		"token_type":   "Bearer",
+		"expires_in":   int(AccessTokenTTL.Seconds()),
+		"scope":        strings.Join(scopes, " "),
+	})
+}
+
+// findActiveToken возвращает неотозванный и неистёкший токен доступа
+func findActiveToken(collection *mongo.Collection, accessToken string) (models.OAuthToken, error) {
+	var token models.OAuthToken
+	err := collection.FindOne(context.TODO(), bson.M{"_id": utils.HashToken(accessToken), "revoked_at": nil}).Decode(&token)
+	if err != nil {
+		return token, err
+	}
+	if time.Now().After(token.ExpiresAt) {
+		return token, errors.New("token expired")
+	}
+	return token, nil
+}
+
+// ValidateAccessToken проверяет токены доступа партнёрских приложений для middleware.RequireScope
+func ValidateAccessToken(collection *mongo.Collection) middleware.AccessTokenValidator {
+	return func(accessToken string) (middleware.AccessToken, error) {
+		token, err := findActiveToken(collection, accessToken)
+		if err != nil {
+			return middleware.AccessToken{}, err
+		}
+		userID := ""
+		if !token.UserID.IsZero() {
+			userID = token.UserID.Hex()
+		}
+		return middleware.AccessToken{UserID: userID, ClientID: token.ClientID.Hex(), Scopes: token.Scopes}, nil
+	}
+}
+
+// IntrospectHandler — интроспекция токена по RFC 7662 для аутентифицированных клиентов
+func IntrospectHandler(w http.ResponseWriter, r *http.Request, clientCollection, tokenCollection *mongo.Collection) {
+	if err := r.ParseForm(); err != nil {
+		writeOAuthError(w, http.StatusBadRequest, "invalid_request", "Invalid form body")
+		return
+	}
+	client, err := authenticateClient(r, clientCollection)
+	if err != nil || !client.Confidential {
+		w.Header().Set("WWW-Authenticate", `Basic realm="fitnesshub"`)
+		writeOAuthError(w, http.StatusUnauthorized, "invalid_client", "Client authentication failed")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	token, err := findActiveToken(tokenCollection, r.PostFormValue("token"))
+	if err != nil {
+		json.NewEncoder(w).Encode(map[string]bool{"active": false})
+		return
+	}
+
+	response := map[string]interface{}{
+		"active":     true,
+		"scope":      strings.Join(token.Scopes, " "),
+		"client_id":  token.ClientID.Hex(),
+		"token_type": "Bearer",
+		"iat":        token.IssuedAt.Unix(),
+		"exp":        token.ExpiresAt.Unix(),
+	}
+	if !token.UserID.IsZero() {
+		response["sub"] = token.UserID.Hex()
+	}
+	json.NewEncoder(w).Encode(response)
+}
+
+// RevokeTokenHandler отзывает токен по RFC 7009; клиент может отозвать только свои токены,
+// а на неизвестный токен отвечаем так же, как на успешный отзыв
+func RevokeTokenHandler(w http.ResponseWriter, r *http.Request, clientCollection, tokenCollection *mongo.Collection) {
+	if err := r.ParseForm(); err != nil {
+		writeOAuthError(w, http.StatusBadRequest, "invalid_request", "Invalid form body")
+		return
+	}
+	client, err := authenticateClient(r, clientCollection)
+	if err != nil {
+		w.Header().Set("WWW-Authenticate", `Basic realm="fitnesshub"`)
+		writeOAuthError(w, http.StatusUnauthorized, "invalid_client", "Client authentication failed")
+		return
+	}
+
+	_, err = tokenCollection.UpdateOne(context.TODO(),
+		bson.M{"_id": utils.HashToken(r.PostFormValue("token")), "client_id": client.ID, "revoked_at": nil},
+		bson.M{"$set": bson.M{"revoked_at": time.Now()}})
+	if err != nil {
+		writeOAuthError(w, http.StatusServiceUnavailable, "server_error", "Error revoking token")
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+}
+
+// GetOAuthClientsHandler возвращает зарегистрированные партнёрские приложения
+func GetOAuthClientsHandler(w http.ResponseWriter, r *http.Request, clientCollection *mongo.Collection) {
+	clients := []models.OAuthClient{}
+	cursor, err := clientCollection.Find(context.TODO(), bson.M{}, options.Find().SetSort(bson.M{"created_at": -1}))
+	if err != nil {
+		http.Error(w, "Error fetching clients", http.StatusInternalServerError)
+		return
+	}
+	if err := cursor.All(context.TODO(), &clients); err != nil {
+		http.Error(w, "Error fetching clients", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(clients)
+}
+
+// oauthClientRequest — параметры регистрируемого OAuth-клиента
+type oauthClientRequest struct {
+	Name         string   `json:"name"`
+	Confidential bool     `json:"confidential"`
+	RedirectURIs []string `json:"redirect_uris"`
+	Scopes       []string `json:"scopes"`
+	GrantTypes   []string `json:"grant_types"`
+}
+
+// CreateOAuthClientHandler регистрирует приложение; секрет конфиденциального клиента
+// показывается только в этом ответе
+func CreateOAuthClientHandler(w http.ResponseWriter, r *http.Request, clientCollection, auditCollection *mongo.Collection) {
+	var request oauthClientRequest
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		http.Error(w, "Invalid JSON message", http.StatusBadRequest)
+		return
+	}
+
+	request.Name = strings.TrimSpace(request.Name)
+	if request.Name == "" {
+		http.Error(w, "Name is required", http.StatusBadRequest)
+		return
+	}
+	if len(request.GrantTypes) == 0 {
+		request.GrantTypes = []string{models.GrantAuthorizationCode}
+	}
+	for _, grantType := range request.GrantTypes {
+		if grantType != models.GrantAuthorizationCode && grantType != models.GrantClientCredentials {
+			http.Error(w, "Invalid grant type: "+grantType, http.StatusBadRequest)
+			return
+		}
+	}
+	if containsString(request.GrantTypes, models.GrantClientCredentials) && !request.Confidential {
+		http.Error(w, "Client credentials grant requires a confidential client", http.StatusBadRequest)
+		return
+	}
+	if containsString(request.GrantTypes, models.GrantAuthorizationCode) && len(request.RedirectURIs) == 0 {
+		http.Error(w, "At least one redirect URI is required", http.StatusBadRequest)
+		return
+	}
+	for _, redirectURI := range request.RedirectURIs {
+		parsed, err := url.Parse(redirectURI)
+		if err != nil || !parsed.IsAbs() || parsed.Fragment != "" {
+			http.Error(w, "Invalid redirect URI: "+redirectURI, http.StatusBadRequest)
+			return
+		}
+	}
+	if len(request.Scopes) == 0 {
+		http.Error(w, "At least one scope is required", http.StatusBadRequest)
+		return
+	}
+	for _, scope := range request.Scopes {
+		if !models.IsValidOAuthScope(scope) {
+			http.Error(w, "Invalid scope: "+scope, http.StatusBadRequest)
+			return
+		}
+	}
+
+	client := models.OAuthClient{
+		Name:         request.Name,
+		Confidential: request.Confidential,
+		RedirectURIs: request.RedirectURIs,
+		Scopes:       request.Scopes,
+		GrantTypes:   request.GrantTypes,
+		CreatedAt:    time.Now(),
+	}
+	client.CreatedBy, _ = primitive.ObjectIDFromHex(middleware.GetUserID(r))
+
+	secret := ""
+	if client.Confidential {
+		var err error
+		if secret, err = utils.GenerateVerificationToken(); err != nil {
+			http.Error(w, "Error generating client secret", http.StatusInternalServerError)
+			return
+		}
+		client.SecretHash = utils.HashToken(secret)
+	}
+
+	result, err := clientCollection.InsertOne(context.TODO(), client)
+	if err != nil {
+		http.Error(w, "Error registering client", http.StatusInternalServerError)
+		return
+	}
+	client.ID = result.InsertedID.(primitive.ObjectID)
+
+	event := auditEvent(r, "oauth_clients.create", "oauth_clients", client.ID.Hex())
+	event.Changes = auditDiff(nil, toAuditDoc(client))
+	recordAudit(auditCollection, event)
+
+	response := map[string]interface{}{"client": client}
+	if secret != "" {
+		response["client_secret"] = secret
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
+
+// RevokeOAuthClientHandler отключает приложение ?id= и отзывает все выданные ему токены
+func RevokeOAuthClientHandler(w http.ResponseWriter, r *http.Request, clientCollection, tokenCollection, auditCollection *mongo.Collection) {
+	objID, err := primitive.ObjectIDFromHex(requestID(r))
+	if err != nil {
+		http.Error(w, "Invalid client ID", http.StatusBadRequest)
+		return
+	}
+
+	now := time.Now()
+	before, after, err := auditedUpdate(clientCollection, bson.M{"_id": objID, "revoked_at": nil}, bson.M{"$set": bson.M{"revoked_at": now}})
+	if err == mongo.ErrNoDocuments {
+		http.Error(w, "Client not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		http.Error(w, "Error revoking client", http.StatusInternalServerError)
+		return
+	}
+	_, err = tokenCollection.UpdateMany(context.TODO(), bson.M{"client_id": objID, "revoked_at": nil}, bson.M{"$set": bson.M{"revoked_at": now}})
+	if err != nil {
+		http.Error(w, "Error revoking client tokens", http.StatusInternalServerError)
+		return
+	}
+
+	event := auditEvent(r, "oauth_clients.revoke", "oauth_clients", objID.Hex())
+	event.Changes = auditDiff(before, after)
+	recordAudit(auditCollection, event)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{"status": "success", "message": "Client revoked"})
+}
+
+// GetOAuthConsentsHandler возвращает приложения, которым текущий пользователь дал доступ
+func GetOAuthConsentsHandler(w http.ResponseWriter, r *http.Request, consentCollection *mongo.Collection) {
+	userID, err := primitive.ObjectIDFromHex(middleware.GetUserID(r))
+	if err != nil {
+		http.Error(w, "Invalid user ID", http.StatusBadRequest)
+		return
+	}
+
+	consents := []models.OAuthConsent{}
+	cursor, err := consentCollection.Find(context.TODO(), bson.M{"user_id": userID}, options.Find().SetSort(bson.M{"granted_at": -1}))
+	if err != nil {
+		http.Error(w, "Error fetching consents", http.StatusInternalServerError)
+		return
+	}
+	if err := cursor.All(context.TODO(), &consents); err != nil {
+		http.Error(w, "Error fetching consents", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(consents)
+}
+
+// RevokeOAuthConsentHandler отзывает согласие для приложения ?client_id= и его токены
+// доступа к данным текущего пользователя
+func RevokeOAuthConsentHandler(w http.ResponseWriter, r *http.Request, consentCollection, tokenCollection, auditCollection *mongo.Collection) {
+	userID, err := primitive.ObjectIDFromHex(middleware.GetUserID(r))
+	if err != nil {
+		http.Error(w, "Invalid user ID", http.StatusBadRequest)
+		return
+	}
+	clientID, err := primitive.ObjectIDFromHex(routeParam(r, "client_id"))
+	if err != nil {
+		http.Error(w, "Invalid client ID", http.StatusBadRequest)
+		return
+	}
+
+	result, err := consentCollection.DeleteOne(context.TODO(), bson.M{"user_id": userID, "client_id": clientID})
+	if err != nil {
+		http.Error(w, "Error revoking consent", http.StatusInternalServerError)
+		return
+	}
+	if result.DeletedCount == 0 {
+		http.Error(w, "Consent not found", http.StatusNotFound)
+		return
+	}
+	_, err = tokenCollection.UpdateMany(context.TODO(), bson.M{"user_id": userID, "client_id": clientID, "revoked_at": nil}, bson.M{"$set": bson.M{"revoked_at": time.Now()}})
+	if err != nil {
+		http.Error(w, "Error revoking tokens", http.StatusInternalServerError)
+		return
+	}
+
+	recordAudit(auditCollection, auditEvent(r, "oauth.consent_revoked", "oauth_clients", clientID.Hex()))
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{"status": "success", "message": "Access revoked"})
+}
+
+// EnsureOAuthIndexes создаёт TTL-индексы кодов и токенов и уникальный индекс согласий
+func EnsureOAuthIndexes(codeCollection, tokenCollection, consentCollection *mongo.Collection) error {
+	_, err := codeCollection.Indexes().CreateOne(context.TODO(), mongo.IndexModel{
+		Keys:    bson.D{{Key: "expires_at", Value: 1}},
+		Options: options.Index().SetExpireAfterSeconds(0),
+	})
+	if err != nil {
+		return err
+	}
+	_, err = tokenCollection.Indexes().CreateMany(context.TODO(), []mongo.IndexModel{
+		{Keys: bson.D{{Key: "expires_at", Value: 1}}, Options: options.Index().SetExpireAfterSeconds(0)},
+		{Keys: bson.D{{Key: "client_id", Value: 1}, {Key: "user_id", Value: 1}}},
+	})
+	if err != nil {
+		return err
+	}
+	_, err = consentCollection.Indexes().CreateOne(context.TODO(), mongo.IndexModel{
+		Keys:    bson.D{{Key: "user_id", Value: 1}, {Key: "client_id", Value: 1}},
+		Options: options.Index().SetUnique(true),
+	})
+	return err
+}
@@ -0,0 +1,537 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/coreos/go-oidc/v3/oidc"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"golang.org/x/oauth2"
+
+	"fitnesshub/middleware"
+	"fitnesshub/models"
+	"fitnesshub/utils"
+)
+
+const (
+	OIDCStateTTL    = 10 * time.Minute
+	oidcStateCookie = "oidc_state"
+)
+
+// oidcProvider — настроенный клиент одного провайдера; метаданные берутся из discovery-документа
+type oidcProvider struct {
+	provider *oidc.Provider
+	config   oauth2.Config
+	verifier *oidc.IDTokenVerifier
+}
+
+// oidcClaims — нужные нам поля ID-токена; email_verified некоторые провайдеры передают строкой
+type oidcClaims struct {
+	Subject       string      `json:"sub"`
+	Email         string      `json:"email"`
+	EmailVerified interface{} `json:"email_verified"`
+	Name          string      `json:"name"`
+	GivenName     string      `json:"given_name"`
+	FamilyName    string      `json:"family_name"`
+	Nonce         string      `json:"nonce"`
+}
+
+func (c oidcClaims) emailVerified() bool {
+	switch value := c.EmailVerified.(type) {
+	case bool:
+		return value
+	case string:
+		return value == "true"
+	}
+	return false
+}
+
+var oidcProviders = struct {
+	sync.Mutex
+	clients map[string]*oidcProvider
+}{clients: map[string]*oidcProvider{}}
+
+var (
+	errOIDCExchange      = errors.New("authorization code exchange failed")
+	errOIDCNoIDToken     = errors.New("identity provider did not return an ID token")
+	errOIDCInvalidToken  = errors.New("invalid ID token")
+	errOIDCEmailConflict = errors.New("An account with this email already exists. Sign in with your password and link the provider from your profile")
+)
+
+// resetOIDCProviders забывает настроенных провайдеров: следующий вход снова выполнит discovery
+// по текущим переменным окружения
+func resetOIDCProviders() {
+	oidcProviders.Lock()
+	defer oidcProviders.Unlock()
+	oidcProviders.clients = map[string]*oidcProvider{}
+}
+
+// identify обменивает код авторизации на токены с PKCE-верификатором, проверяет подпись
+// ID-токена и nonce и возвращает его заявления; email без ID-токена берётся из userinfo
+func (c *oidcProvider) identify(ctx context.Context, code, verifier, nonce string) (oidcClaims, error) {
+	var claims oidcClaims
+	token, err := c.config.Exchange(ctx, code, oauth2.VerifierOption(verifier))
+	if err != nil {
+		return claims, errOIDCExchange
+	}
+	rawIDToken, ok := token.Extra("id_token").(string)
+	if !ok {
+		return claims, errOIDCNoIDToken
+	}
+	idToken, err := c.verifier.Verify(ctx, rawIDToken)
+	if err != nil {
+		return claims, errOIDCInvalidToken
+	}
+	if err := idToken.Claims(&claims); err != nil || claims.Nonce != nonce {
+		return claims, errOIDCInvalidToken
+	}
+	claims.Subject = idToken.Subject
+
+	// Часть провайдеров не кладёт email в ID-токен — тогда спрашиваем userinfo
+	if claims.Email == "" {
+		if info, err := c.provider.UserInfo(ctx, oauth2.StaticTokenSource(token)); err == nil && info.Subject == claims.Subject {
+			claims.Email, claims.EmailVerified = info.Email, info.EmailVerified
+		}
+	}
+	return claims, nil
+}
+
+// OIDCProviderNames возвращает провайдеров из OIDC_PROVIDERS (через запятую, например "google,microsoft")
+func OIDCProviderNames() []string {
+	names := []string{}
+	for _, name := range strings.Split(os.Getenv("OIDC_PROVIDERS"), ",") {
+		if name = strings.ToLower(strings.TrimSpace(name)); name != "" {
+			names = append(names, name)
+		}
+	}
+	return names
+}
+
+// loadOIDCProvider настраивает провайдера по переменным OIDC_<ИМЯ>_ISSUER, _CLIENT_ID,
+// _CLIENT_SECRET и необязательной _SCOPES; discovery выполняется один раз и кешируется
+func loadOIDCProvider(name string) (*oidcProvider, error) {
+	known := false
+	for _, configured := range OIDCProviderNames() {
+		known = known || configured == name
+	}
+	if !known {
+		return nil, errors.New("unknown identity provider")
+	}
+
+	oidcProviders.Lock()
+	defer oidcProviders.Unlock()
+	if client, ok := oidcProviders.clients[name]; ok {
+		return client, nil
+	}
+
+	prefix := "OIDC_" + strings.ToUpper(name) + "_"
+	issuer, clientID := os.Getenv(prefix+"ISSUER"), os.Getenv(prefix+"CLIENT_ID")
+	if issuer == "" || clientID == "" {
+		return nil, errors.New("identity provider is not configured")
+	}
+	scopes := []string{oidc.ScopeOpenID, "email", "profile"}
+	if value := os.Getenv(prefix + "SCOPES"); value != "" {
+		scopes = strings.Fields(value)
+	}
+	redirectURL := os.Getenv("OIDC_REDIRECT_URL")
+	if redirectURL == "" {
+		redirectURL = "http://localhost:8081/login/oidc/callback"
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	provider, err := oidc.NewProvider(ctx, issuer)
+	if err != nil {
+		return nil, err
+	}
+
+	client := &oidcProvider{
+		provider: provider,
+		config: oauth2.Config{
+			ClientID:     clientID,
+			ClientSecret: os.Getenv(prefix + "CLIENT_SECRET"),
+			Endpoint:     provider.Endpoint(),
+			RedirectURL:  redirectURL,
+			Scopes:       scopes,
+		},
+		verifier: provider.Verifier(&oidc.Config{ClientID: clientID}),
+	}
+	oidcProviders.clients[name] = client
+	return client, nil
+}
+
+// redirectToProvider сохраняет state, nonce и PKCE-верификатор и отправляет браузер к провайдеру.
+// Тот же state кладётся в cookie, чтобы ответ провайдера принимался только в этом браузере.
+func redirectToProvider(w http.ResponseWriter, r *http.Request, stateCollection *mongo.Collection, name, linkUserID string) {
+	client, err := loadOIDCProvider(name)
+	if err != nil {
+		http.Error(w, "Identity provider unavailable: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	state, err := utils.GenerateVerificationToken()
+	if err != nil {
+		http.Error(w, "Error starting sign-in", http.StatusInternalServerError)
+		return
+	}
+	nonce, err := utils.GenerateVerificationToken()
+	if err != nil {
+		http.Error(w, "Error starting sign-in", http.StatusInternalServerError)
+		return
+	}
+	verifier := oauth2.GenerateVerifier()
+
+	_, err = stateCollection.InsertOne(context.TODO(), models.OIDCState{
+		ID:         utils.HashToken(state),
+		Provider:   name,
+		Nonce:      nonce,
+		Verifier:   verifier,
+		LinkUserID: linkUserID,
+		ExpiresAt:  time.Now().Add(OIDCStateTTL),
+	})
+	if err != nil {
+		http.Error(w, "Error starting sign-in", http.StatusInternalServerError)
+		return
+	}
+
+	// Возврат от провайдера — межсайтовый переход верхнего уровня, поэтому SameSite=Lax
+	http.SetCookie(w, &http.Cookie{
+		Name:     oidcStateCookie,
+		Value:    state,
+		Path:     "/",
+		MaxAge:   int(OIDCStateTTL.Seconds()),
+		HttpOnly: true,
+		Secure:   utils.SecureCookies(),
+		SameSite: http.SameSiteLaxMode,
+	})
+	http.Redirect(w, r, client.config.AuthCodeURL(state, oidc.Nonce(nonce), oauth2.S256ChallengeOption(verifier)), http.StatusFound)
+}
+
+// GetOIDCProvidersHandler возвращает список настроенных внешних провайдеров входа
+func GetOIDCProvidersHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string][]string{"providers": OIDCProviderNames()})
+}
+
+// BeginOIDCLoginHandler начинает вход или регистрацию через провайдера ?provider=
+func BeginOIDCLoginHandler(w http.ResponseWriter, r *http.Request, stateCollection *mongo.Collection) {
+	redirectToProvider(w, r, stateCollection, r.URL.Query().Get("provider"), "")
+}
+
+// LinkOIDCIdentityHandler начинает привязку провайдера ?provider= к текущему пользователю
+func LinkOIDCIdentityHandler(w http.ResponseWriter, r *http.Request, stateCollection *mongo.Collection) {
+	if !requireSession(w, r) {
+		return
+	}
+	redirectToProvider(w, r, stateCollection, r.URL.Query().Get("provider"), middleware.GetUserID(r))
+}
+
+// OIDCCallbackHandler принимает код авторизации, обменивает его с PKCE-верификатором,
+// проверяет ID-токен и nonce, находит или создаёт пользователя и выдаёт сессию.
+// Существующая учётная запись привязывается по email, только если провайдер его подтвердил.
+func OIDCCallbackHandler(w http.ResponseWriter, r *http.Request, collection, stateCollection, attemptCollection, auditCollection *mongo.Collection) {
+	query := r.URL.Query()
+	if providerError := query.Get("error"); providerError != "" {
+		http.Error(w, "Sign-in was cancelled: "+providerError, http.StatusBadRequest)
+		return
+	}
+
+	cookie, err := r.Cookie(oidcStateCookie)
+	if err != nil || cookie.Value != query.Get("state") {
+		http.Error(w, "Invalid sign-in state", http.StatusBadRequest)
+		return
+	}
+	http.SetCookie(w, &http.Cookie{Name: oidcStateCookie, Path: "/", MaxAge: -1, HttpOnly: true, Secure: utils.SecureCookies()})
+
+	var state models.OIDCState
+	err = stateCollection.FindOneAndDelete(context.TODO(), bson.M{"_id": utils.HashToken(cookie.Value)}).Decode(&state)
+	if err != nil || time.Now().After(state.ExpiresAt) {
+		http.Error(w, "Sign-in expired, please try again", http.StatusBadRequest)
+		return
+	}
+
+	client, err := loadOIDCProvider(state.Provider)
+	if err != nil {
+		http.Error(w, "Identity provider unavailable: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	claims, err := client.identify(ctx, query.Get("code"), state.Verifier, state.Nonce)
+	switch err {
+	case nil:
+	case errOIDCExchange:
+		http.Error(w, "Error exchanging authorization code", http.StatusBadGateway)
+		return
+	case errOIDCNoIDToken:
+		http.Error(w, "Identity provider did not return an ID token", http.StatusBadGateway)
+		return
+	default:
+		http.Error(w, "Invalid ID token", http.StatusUnauthorized)
+		return
+	}
+
+	identity := models.ExternalIdentity{
+		Provider: state.Provider,
+		Subject:  claims.Subject,
+		Email:    normalizeEmail(claims.Email),
+		LinkedAt: time.Now(),
+	}
+
+	if state.LinkUserID != "" {
+		linkIdentity(w, r, collection, auditCollection, state.LinkUserID, identity)
+		return
+	}
+
+	user, status, err := userForIdentity(r, collection, auditCollection, identity, claims)
+	if err != nil {
+		http.Error(w, err.Error(), status)
+		return
+	}
+	if !user.Verified {
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]string{"status": "success", "message": "User registered successfully. Check your email for verification."})
+		return
+	}
+
+	// Вход через провайдера заменяет только пароль, второй фактор по-прежнему обязателен.
+	// Это переход браузера, а не fetch, поэтому шаг 2FA продолжает страница входа.
+	step, preAuthToken, err := secondFactorStep(user)
+	if err != nil {
+		http.Error(w, "Error generating token", http.StatusInternalServerError)
+		return
+	}
+	if step != "" {
+		setPreAuthCookie(w, preAuthToken)
+		http.Redirect(w, r, "/login?step="+step, http.StatusSeeOther)
+		return
+	}
+	if _, err := startSession(w, r, user, false, "oidc:"+state.Provider, attemptCollection, auditCollection); err != nil {
+		http.Error(w, "Error generating token", http.StatusInternalServerError)
+		return
+	}
+	http.Redirect(w, r, "/", http.StatusSeeOther)
+}
+
+// userForIdentity находит пользователя по привязанной внешней учётной записи, привязывает её
+// к пользователю с тем же подтверждённым email или регистрирует нового пользователя.
+// Неподтверждённая запись с этим email переходит к владельцу адреса без прежних способов входа.
+func userForIdentity(r *http.Request, collection, auditCollection *mongo.Collection, identity models.ExternalIdentity, claims oidcClaims) (models.User, int, error) {
+	var user models.User
+	err := collection.FindOne(context.TODO(), notDeleted(bson.M{
+		"identities": bson.M{"$elemMatch": bson.M{"provider": identity.Provider, "subject": identity.Subject}},
+	})).Decode(&user)
+	if err == nil {
+		return user, http.StatusOK, nil
+	}
+	if err != mongo.ErrNoDocuments {
+		return user, http.StatusInternalServerError, errors.New("Error fetching user")
+	}
+	if identity.Email == "" {
+		return user, http.StatusBadRequest, errors.New("Identity provider did not share an email address")
+	}
+
+	err = collection.FindOne(context.TODO(), notDeleted(bson.M{"email": identity.Email})).Decode(&user)
+	if err == nil {
+		update, err := claimByEmail(user, identity, claims)
+		if err != nil {
+			return user, http.StatusConflict, err
+		}
+		before, after, err := auditedUpdate(collection, bson.M{"_id": user.ID, "verified": user.Verified}, update)
+		if err != nil {
+			return user, http.StatusInternalServerError, errors.New("Error linking account")
+		}
+		event := auditEvent(r, "auth.identity_linked", "users", user.ID.Hex())
+		event.ActorID, event.ActorRole = user.ID.Hex(), user.Role
+		event.Details = identity.Provider
+		event.Changes = auditDiff(before, after)
+		recordAudit(auditCollection, event)
+
+		user.Verified, user.Password, user.VerificationToken = true, "", ""
+		return user, http.StatusOK, nil
+	}
+	if err != mongo.ErrNoDocuments {
+		return user, http.StatusInternalServerError, errors.New("Error fetching user")
+	}
+
+	firstName, lastName := claims.GivenName, claims.FamilyName
+	if firstName == "" && lastName == "" {
+		firstName, lastName, _ = strings.Cut(strings.TrimSpace(claims.Name), " ")
+	}
+	user = models.User{
+		Email:      identity.Email,
+		Verified:   claims.emailVerified(),
+		Role:       "user",
+		Profile:    models.Profile{FirstName: strings.TrimSpace(firstName), LastName: strings.TrimSpace(lastName)},
+		Identities: []models.ExternalIdentity{identity},
+	}
+	// Адрес, не подтверждённый провайдером, подтверждаем письмом, как при обычной регистрации
+	if !user.Verified {
+		user.VerificationToken, err = utils.GenerateVerificationToken()
+		if err != nil {
+			return user, http.StatusInternalServerError, errors.New("Error generating verification token")
+		}
+	}
+
+	result, err := collection.InsertOne(context.TODO(), user)
+	if err != nil {
+		return user, http.StatusInternalServerError, errors.New("Error adding user")
+	}
+	user.ID = result.InsertedID.(primitive.ObjectID)
+
+	event := auditEvent(r, "auth.signup", "users", user.ID.Hex())
+	event.ActorID = user.ID.Hex()
+	event.Details = identity.Provider
+	event.Changes = auditDiff(nil, toAuditDoc(user))
+	recordAudit(auditCollection, event)
+
+	if !user.Verified {
+		verificationLink := "http://localhost:8081/verify?token=" + url.QueryEscape(user.VerificationToken)
+		err = utils.SendEmail(user.Email, "Verify your email", "Please verify your email by clicking the following link: "+verificationLink)
+		if err != nil {
+			log.Println("Error sending verification email:", err)
+		}
+	}
+	return user, http.StatusOK, nil
+}
+
+// claimByEmail решает, можно ли привязать внешнюю учётную запись к пользователю с тем же email,
+// и возвращает изменение записи. Email должен быть подтверждён провайдером. Неподтверждённую запись
+// мог заранее создать кто угодно, указав чужой адрес, поэтому владелец адреса забирает её целиком:
+// пароль, токен подтверждения и чужие привязки сбрасываются.
+func claimByEmail(user models.User, identity models.ExternalIdentity, claims oidcClaims) (bson.M, error) {
+	if !claims.emailVerified() {
+		return nil, errOIDCEmailConflict
+	}
+	if !user.Verified {
+		return bson.M{
+			"$set":   bson.M{"verified": true, "identities": []models.ExternalIdentity{identity}},
+			"$unset": bson.M{"password": "", "verification_token": ""},
+		}, nil
+	}
+	return bson.M{
+		"$push": bson.M{"identities": identity},
+		"$set":  bson.M{"verified": true},
+	}, nil
+}
+
+// linkIdentity привязывает внешнюю учётную запись к вошедшему пользователю
+func linkIdentity(w http.ResponseWriter, r *http.Request, collection, auditCollection *mongo.Collection, userID string, identity models.ExternalIdentity) {
+	objID, err := primitive.ObjectIDFromHex(userID)
+	if err != nil {
+		http.Error(w, "Invalid user ID", http.StatusBadRequest)
+		return
+	}
+
+	count, err := collection.CountDocuments(context.TODO(), bson.M{
+		"identities": bson.M{"$elemMatch": bson.M{"provider": identity.Provider, "subject": identity.Subject}},
+	})
+	if err != nil || count > 0 {
+		http.Error(w, "This account is already linked to a user", http.StatusConflict)
+		return
+	}
+
+	filter := notDeleted(bson.M{"_id": objID, "identities.provider": bson.M{"$ne": identity.Provider}})
+	before, after, err := auditedUpdate(collection, filter, bson.M{"$push": bson.M{"identities": identity}})
+	if err == mongo.ErrNoDocuments {
+		http.Error(w, "This provider is already linked to your account", http.StatusConflict)
+		return
+	}
+	if err != nil {
+		http.Error(w, "Error linking account", http.StatusInternalServerError)
+		return
+	}
+
+	event := auditEvent(r, "auth.identity_linked", "users", objID.Hex())
+	event.ActorID = objID.Hex()
+	event.Details = identity.Provider
+	event.Changes = auditDiff(before, after)
+	recordAudit(auditCollection, event)
+
+	http.Redirect(w, r, "/profile", http.StatusSeeOther)
+}
+
+// GetIdentitiesHandler возвращает внешние учётные записи, привязанные к текущему пользователю
+func GetIdentitiesHandler(w http.ResponseWriter, r *http.Request, collection *mongo.Collection) {
+	user, err := currentUser(r, collection)
+	if err != nil {
+		http.Error(w, "User not found", http.StatusNotFound)
+		return
+	}
+
+	identities := user.Identities
+	if identities == nil {
+		identities = []models.ExternalIdentity{}
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{"identities": identities, "providers": OIDCProviderNames()})
+}
+
+// UnlinkIdentityHandler отвязывает провайдера ?provider=; последний способ входа отвязать нельзя
+func UnlinkIdentityHandler(w http.ResponseWriter, r *http.Request, collection, auditCollection *mongo.Collection) {
+	if !requireSession(w, r) {
+		return
+	}
+	user, err := currentUser(r, collection)
+	if err != nil {
+		http.Error(w, "User not found", http.StatusNotFound)
+		return
+	}
+
+	provider := routeParam(r, "provider")
+	linked := false
+	for _, identity := range user.Identities {
+		linked = linked || identity.Provider == provider
+	}
+	if !linked {
+		http.Error(w, "Provider is not linked", http.StatusNotFound)
+		return
+	}
+	if user.Password == "" && len(user.Identities) == 1 {
+		http.Error(w, "Set a password before unlinking your only sign-in method", http.StatusConflict)
+		return
+	}
+
+	before, after, err := auditedUpdate(collection, bson.M{"_id": user.ID}, bson.M{"$pull": bson.M{"identities": bson.M{"provider": provider}}})
+	if err != nil {
+		http.Error(w, "Error unlinking account", http.StatusInternalServerError)
+		return
+	}
+
+	event := auditEvent(r, "auth.identity_unlinked", "users", user.ID.Hex())
+	event.Details = provider
+	event.Changes = auditDiff(before, after)
+	recordAudit(auditCollection, event)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{"status": "success", "message": "Provider unlinked"})
+}
+
+// EnsureOIDCIndexes создаёт уникальный индекс внешних учётных записей и TTL-индекс для state
+func EnsureOIDCIndexes(collection, stateCollection *mongo.Collection) error {
+	_, err := collection.Indexes().CreateOne(context.TODO(), mongo.IndexModel{
+		Keys: bson.D{{Key: "identities.provider", Value: 1}, {Key: "identities.subject", Value: 1}},
+		Options: options.Index().SetUnique(true).
+			SetPartialFilterExpression(bson.M{"identities.subject": bson.M{"$exists": true}}),
+	})
+	if err != nil {
+		return err
+	}
+	_, err = stateCollection.Indexes().CreateOne(context.TODO(), mongo.IndexModel{
+		Keys:    bson.D{{Key: "expires_at", Value: 1}},
+		Options: options.Index().SetExpireAfterSeconds(0),
+	})
+	return err
+}
@@ -0,0 +1,271 @@
+package handlers
+
+import (
+	"context"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/coreos/go-oidc/v3/oidc"
+	"go.mongodb.org/mongo-driver/bson"
+	"golang.org/x/oauth2"
+
+	"fitnesshub/models"
+)
+
+// mockIdP — провайдер OpenID Connect на httptest: discovery, JWKS и token endpoint с PKCE
+type mockIdP struct {
+	*httptest.Server
+	key      *rsa.PrivateKey
+	clientID string
+
+	mu    sync.Mutex
+	codes map[string]mockGrant
+}
+
+// mockGrant — выданный код авторизации: PKCE-вызов из запроса и заявления будущего ID-токена
+type mockGrant struct {
+	challenge string
+	claims    map[string]interface{}
+}
+
+func newMockIdP(t *testing.T) *mockIdP {
+	t.Helper()
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	idp := &mockIdP{key: key, clientID: "fitnesshub", codes: map[string]mockGrant{}}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /.well-known/openid-configuration", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"issuer":                                idp.URL,
+			"authorization_endpoint":                idp.URL + "/authorize",
+			"token_endpoint":                        idp.URL + "/token",
+			"jwks_uri":                              idp.URL + "/jwks",
+			"id_token_signing_alg_values_supported": []string{"RS256"},
+		})
+	})
+	mux.HandleFunc("GET /jwks", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]interface{}{"keys": []map[string]string{{
+			"kty": "RSA", "kid": "test", "alg": "RS256", "use": "sig",
+			"n": base64.RawURLEncoding.EncodeToString(key.PublicKey.N.Bytes()),
+			"e": base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.PublicKey.E)).Bytes()),
+		}}})
+	})
+	mux.HandleFunc("POST /token", func(w http.ResponseWriter, r *http.Request) {
+		idp.mu.Lock()
+		grant, ok := idp.codes[r.PostFormValue("code")]
+		delete(idp.codes, r.PostFormValue("code"))
+		idp.mu.Unlock()
+
+		verifier := sha256.Sum256([]byte(r.PostFormValue("code_verifier")))
+		if !ok || base64.RawURLEncoding.EncodeToString(verifier[:]) != grant.challenge {
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(map[string]string{"error": "invalid_grant"})
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"access_token": "access-token",
+			"token_type":   "Bearer",
+			"expires_in":   3600,
+			"id_token":     idp.sign(t, grant.claims),
+		})
+	})
+	idp.Server = httptest.NewServer(mux)
+	t.Cleanup(idp.Close)
+	return idp
+}
+
+// sign выпускает ID-токен RS256 с обязательными заявлениями и переданными claims
+func (idp *mockIdP) sign(t *testing.T, claims map[string]interface{}) string {
+	t.Helper()
+	payload := map[string]interface{}{
+		"iss": idp.URL,
+		"aud": idp.clientID,
+		"iat": time.Now().Unix(),
+		"exp": time.Now().Add(time.Hour).Unix(),
+	}
+	for name, value := range claims {
+		payload[name] = value
+	}
+	header, _ := json.Marshal(map[string]string{"alg": "RS256", "kid": "test", "typ": "JWT"})
+	body, err := json.Marshal(payload)
+	if err != nil {
+		t.Fatal(err)
+	}
+	signed := base64.RawURLEncoding.EncodeToString(header) + "." + base64.RawURLEncoding.EncodeToString(body)
+	digest := sha256.Sum256([]byte(signed))
+	signature, err := rsa.SignPKCS1v15(rand.Reader, idp.key, crypto.SHA256, digest[:])
+	if err != nil {
+		t.Fatal(err)
+	}
+	return signed + "." + base64.RawURLEncoding.EncodeToString(signature)
+}
+
+// authorize имитирует вход пользователя у провайдера: запоминает PKCE-вызов из адреса
+// авторизации и выдаёт код. Без nonce в claims в токен попадает nonce из адреса.
+func (idp *mockIdP) authorize(t *testing.T, authURL string, claims map[string]interface{}) string {
+	t.Helper()
+	parsed, err := url.Parse(authURL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	query := parsed.Query()
+	if query.Get("code_challenge_method") != "S256" || query.Get("code_challenge") == "" {
+		t.Fatalf("authorization URL without PKCE: %s", authURL)
+	}
+	if _, ok := claims["nonce"]; !ok {
+		claims["nonce"] = query.Get("nonce")
+	}
+
+	code := "code-" + query.Get("state")
+	idp.mu.Lock()
+	idp.codes[code] = mockGrant{challenge: query.Get("code_challenge"), claims: claims}
+	idp.mu.Unlock()
+	return code
+}
+
+// useMockIdP настраивает провайдера "mock" на idp и сбрасывает кеш провайдеров
+func useMockIdP(t *testing.T, idp *mockIdP) *oidcProvider {
+	t.Helper()
+	t.Setenv("OIDC_PROVIDERS", "mock")
+	t.Setenv("OIDC_MOCK_ISSUER", idp.URL)
+	t.Setenv("OIDC_MOCK_CLIENT_ID", idp.clientID)
+	resetOIDCProviders()
+	t.Cleanup(resetOIDCProviders)
+
+	client, err := loadOIDCProvider("mock")
+	if err != nil {
+		t.Fatalf("loadOIDCProvider: %v", err)
+	}
+	return client
+}
+
+func TestOIDCIdentifyWithPKCE(t *testing.T) {
+	idp := newMockIdP(t)
+	client := useMockIdP(t, idp)
+
+	verifier := oauth2.GenerateVerifier()
+	authURL := client.config.AuthCodeURL("state", oidc.Nonce("nonce"), oauth2.S256ChallengeOption(verifier))
+	code := idp.authorize(t, authURL, map[string]interface{}{"sub": "subject-1", "email": "member@example.com", "email_verified": true})
+
+	claims, err := client.identify(context.Background(), code, verifier, "nonce")
+	if err != nil {
+		t.Fatalf("identify: %v", err)
+	}
+	if claims.Subject != "subject-1" || claims.Email != "member@example.com" || !claims.emailVerified() {
+		t.Errorf("claims = %+v", claims)
+	}
+}
+
+func TestOIDCIdentifyRejectsWrongVerifier(t *testing.T) {
+	idp := newMockIdP(t)
+	client := useMockIdP(t, idp)
+
+	authURL := client.config.AuthCodeURL("state", oidc.Nonce("nonce"), oauth2.S256ChallengeOption(oauth2.GenerateVerifier()))
+	code := idp.authorize(t, authURL, map[string]interface{}{"sub": "subject-1"})
+
+	if _, err := client.identify(context.Background(), code, oauth2.GenerateVerifier(), "nonce"); err != errOIDCExchange {
+		t.Fatalf("err = %v, want errOIDCExchange", err)
+	}
+}
+
+func TestOIDCIdentifyRejectsNonceMismatch(t *testing.T) {
+	idp := newMockIdP(t)
+	client := useMockIdP(t, idp)
+
+	verifier := oauth2.GenerateVerifier()
+	authURL := client.config.AuthCodeURL("state", oidc.Nonce("nonce"), oauth2.S256ChallengeOption(verifier))
+	code := idp.authorize(t, authURL, map[string]interface{}{"sub": "subject-1", "nonce": "replayed"})
+
+	if _, err := client.identify(context.Background(), code, verifier, "nonce"); err != errOIDCInvalidToken {
+		t.Fatalf("err = %v, want errOIDCInvalidToken", err)
+	}
+}
+
+func TestOIDCIdentifyRejectsForeignSignature(t *testing.T) {
+	idp := newMockIdP(t)
+	other := newMockIdP(t)
+	client := useMockIdP(t, idp)
+
+	verifier := oauth2.GenerateVerifier()
+	authURL := client.config.AuthCodeURL("state", oidc.Nonce("nonce"), oauth2.S256ChallengeOption(verifier))
+	claims := map[string]interface{}{"sub": "subject-1", "nonce": "nonce"}
+	code := idp.authorize(t, authURL, claims)
+	// Токен подписан ключом другого провайдера
+	idp.key = other.key
+
+	if _, err := client.identify(context.Background(), code, verifier, "nonce"); err != errOIDCInvalidToken {
+		t.Fatalf("err = %v, want errOIDCInvalidToken", err)
+	}
+}
+
+func TestResetOIDCProvidersSwitchesIssuer(t *testing.T) {
+	first := newMockIdP(t)
+	second := newMockIdP(t)
+
+	client := useMockIdP(t, first)
+	if cached, _ := loadOIDCProvider("mock"); cached != client {
+		t.Fatal("provider is not cached")
+	}
+
+	client = useMockIdP(t, second)
+	if client.config.Endpoint.TokenURL != second.URL+"/token" {
+		t.Errorf("token URL = %s, want the second issuer", client.config.Endpoint.TokenURL)
+	}
+}
+
+func TestClaimByEmail(t *testing.T) {
+	identity := models.ExternalIdentity{Provider: "mock", Subject: "subject-1", Email: "member@example.com"}
+	verified := oidcClaims{Subject: "subject-1", Email: "member@example.com", EmailVerified: true}
+
+	t.Run("unverified email conflicts", func(t *testing.T) {
+		unverified := verified
+		unverified.EmailVerified = "false"
+		if _, err := claimByEmail(models.User{Verified: true}, identity, unverified); err != errOIDCEmailConflict {
+			t.Fatalf("err = %v, want errOIDCEmailConflict", err)
+		}
+	})
+
+	t.Run("links verified account", func(t *testing.T) {
+		update, err := claimByEmail(models.User{Verified: true, Password: "hash"}, identity, verified)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if update["$push"] == nil || update["$unset"] != nil {
+			t.Errorf("update = %v, want identity pushed and credentials kept", update)
+		}
+	})
+
+	t.Run("claims unverified account", func(t *testing.T) {
+		update, err := claimByEmail(models.User{Password: "hash", VerificationToken: "token"}, identity, verified)
+		if err != nil {
+			t.Fatal(err)
+		}
+		unset, _ := update["$unset"].(bson.M)
+		if _, ok := unset["password"]; !ok {
+			t.Errorf("update = %v, want password cleared", update)
+		}
+		if _, ok := unset["verification_token"]; !ok {
+			t.Errorf("update = %v, want verification token cleared", update)
+		}
+		set, _ := update["$set"].(bson.M)
+		if identities, _ := set["identities"].([]models.ExternalIdentity); len(identities) != 1 || identities[0] != identity {
+			t.Errorf("identities = %v, want only the claiming identity", set["identities"])
+		}
+	})
+}
@@ -0,0 +1,672 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"reflect"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+
+	"fitnesshub/models"
+)
+
+// APISpec собирает маршруты /api/v1, входа, OAuth2 и GraphQL по мере регистрации и строит по ним
+// документ OpenAPI 3.1. Методы и пути берутся из шаблонов ServeMux, описания — из apiOperations,
+// схемы тел — из типов Go.
+type APISpec struct {
+	routes []apiSpecRoute
+}
+
+type apiSpecRoute struct {
+	pattern string
+	roles   []string
+	scope   string
+}
+
+// apiOperation описывает маршрут: Request и Response — значения типов тела запроса и ответа,
+// по которым схемы строятся через reflect; nil означает, что тела нет. Form — тело
+// application/x-www-form-urlencoded, Redirect — маршрут отвечает перенаправлением 302.
+type apiOperation struct {
+	Summary      string
+	Query        []string
+	Request      interface{}
+	Form         interface{}
+	Upload       string
+	Response     interface{}
+	ResponseType string
+	Redirect     bool
+}
+
+func NewAPISpec() *APISpec {
+	return &APISpec{}
+}
+
+// Add добавляет маршрут в спецификацию; roles — роли, которым он доступен (пусто — без входа)
+func (s *APISpec) Add(pattern string, roles ...string) {
+	s.routes = append(s.routes, apiSpecRoute{pattern: pattern, roles: roles})
+}
+
+// AddScoped добавляет маршрут партнёрского API, доступный по токену OAuth2 с областью scope
+func (s *APISpec) AddScoped(pattern, scope string) {
+	s.routes = append(s.routes, apiSpecRoute{pattern: pattern, scope: scope})
+}
+
+// Drift возвращает расхождения между зарегистрированными маршрутами и их описаниями
+func (s *APISpec) Drift() []string {
+	var problems []string
+	registered := map[string]bool{}
+	for _, route := range s.routes {
+		registered[route.pattern] = true
+		if _, ok := apiOperations[route.pattern]; !ok {
+			problems = append(problems, route.pattern+": route is not described")
+		}
+	}
+	for pattern := range apiOperations {
+		if !registered[pattern] {
+			problems = append(problems, pattern+": described but not registered")
+		}
+	}
+	sort.Strings(problems)
+	return problems
+}
+
+var pathParamPattern = regexp.MustCompile(`\{(\w+)\}`)
+
+// Document строит документ OpenAPI 3.1 по зарегистрированным маршрутам
+func (s *APISpec) Document() map[string]interface{} {
+	schemas := map[string]interface{}{}
+	paths := map[string]map[string]interface{}{}
+
+	for _, route := range s.routes {
+		method, path, _ := strings.Cut(route.pattern, " ")
+		op := apiOperations[route.pattern]
+
+		var parameters []interface{}
+		for _, match := range pathParamPattern.FindAllStringSubmatch(path, -1) {
+			parameters = append(parameters, map[string]interface{}{
+				"name": match[1], "in": "path", "required": true, "schema": map[string]interface{}{"type": "string"},
+			})
+		}
+		for _, name := range op.Query {
+			parameters = append(parameters, map[string]interface{}{
+				"name": name, "in": "query", "schema": map[string]interface{}{"type": "string"},
+			})
+		}
+
+		success := map[string]interface{}{"description": "Success"}
+		successStatus := "200"
+		switch {
+		case op.Redirect:
+			successStatus = "302"
+			success["description"] = "Redirect"
+		case op.ResponseType != "":
+			success["content"] = map[string]interface{}{
+				op.ResponseType: map[string]interface{}{"schema": map[string]interface{}{"type": "string", "contentMediaType": op.ResponseType}},
+			}
+		case op.Response != nil:
+			success["content"] = map[string]interface{}{
+				"application/json": map[string]interface{}{"schema": schemaFor(reflect.TypeOf(op.Response), schemas)},
+			}
+		}
+		operation := map[string]interface{}{
+			"operationId": operationID(method, path),
+			"summary":     op.Summary,
+			"tags":        []string{apiTag(path)},
+			"responses": map[string]interface{}{
+				successStatus: success,
+				"default": map[string]interface{}{
+					"description": "Error message",
+					"content":     map[string]interface{}{"text/plain": map[string]interface{}{"schema": map[string]interface{}{"type": "string"}}},
+				},
+			},
+		}
+		if len(parameters) > 0 {
+			operation["parameters"] = parameters
+		}
+		if op.Request != nil {
+			operation["requestBody"] = map[string]interface{}{
+				"required": true,
+				"content": map[string]interface{}{
+					"application/json": map[string]interface{}{"schema": schemaFor(reflect.TypeOf(op.Request), schemas)},
+				},
+			}
+		}
+		if op.Form != nil {
+			operation["requestBody"] = map[string]interface{}{
+				"required": true,
+				"content": map[string]interface{}{
+					"application/x-www-form-urlencoded": map[string]interface{}{"schema": schemaFor(reflect.TypeOf(op.Form), schemas)},
+				},
+			}
+		}
+		if op.Upload != "" {
+			operation["requestBody"] = map[string]interface{}{
+				"required": true,
+				"content": map[string]interface{}{
+					"multipart/form-data": map[string]interface{}{"schema": map[string]interface{}{
+						"type":       "object",
+						"required":   []string{op.Upload},
+						"properties": map[string]interface{}{op.Upload: map[string]interface{}{"type": "string", "contentMediaType": "application/octet-stream"}},
+					}},
+				},
+			}
+		}
+		if len(route.roles) > 0 {
+			operation["description"] = "Roles: " + strings.Join(route.roles, ", ")
+			// API-ключи принимаются только на /api/v1, остальные маршруты требуют сессию
+			operation["security"] = []interface{}{map[string]interface{}{"cookieAuth": []string{}}}
+			if strings.HasPrefix(path, "/api/v1/") {
+				operation["security"] = []interface{}{
+					map[string]interface{}{"bearerAuth": []string{}},
+					map[string]interface{}{"cookieAuth": []string{}},
+				}
+			}
+		}
+		if route.scope != "" {
+			operation["description"] = "OAuth2 scope: " + route.scope
+			operation["security"] = []interface{}{
+				map[string]interface{}{"oauth2": []string{route.scope}},
+			}
+		}
+
+		if paths[path] == nil {
+			paths[path] = map[string]interface{}{}
+		}
+		paths[path][strings.ToLower(method)] = operation
+	}
+
+	return map[string]interface{}{
+		"openapi": "3.1.0",
+		"info": map[string]interface{}{
+			"title":       "FitnessHub API",
+			"version":     "v1",
+			"description": "JSON API of FitnessHub with its sign-in, OAuth2 and GraphQL endpoints. Errors are returned as plain text with the matching HTTP status; the OAuth2 endpoints return RFC 6749 error objects.",
+		},
+		"paths": paths,
+		"components": map[string]interface{}{
+			"schemas": schemas,
+			"securitySchemes": map[string]interface{}{
+				"bearerAuth": map[string]interface{}{
+					"type":        "http",
+					"scheme":      "bearer",
+					"description": "API key (fhk_...) created with POST /api/v1/profile/api-keys; the session token from sign-in is not accepted here",
+				},
+				"cookieAuth": map[string]interface{}{
+					"type":        "apiKey",
+					"in":          "cookie",
+					"name":        "token",
+					"description": "Session set by POST /api/v1/auth/login; requests other than GET also need the X-CSRF-Token header with the csrf_token cookie value",
+				},
+				"oauth2": map[string]interface{}{
+					"type":        "oauth2",
+					"description": "Access token issued to a partner application by /oauth/token",
+					"flows": map[string]interface{}{
+						"authorizationCode": map[string]interface{}{
+							"authorizationUrl": "/oauth/authorize",
+							"tokenUrl":         "/oauth/token",
+							"scopes":           oauthScopeDescriptions,
+						},
+					},
+				},
+			},
+		},
+	}
+}
+
+// operationID строит идентификатор операции из метода и пути: GET /api/v1/products/{id} → getProductsById
+func operationID(method, path string) string {
+	id := strings.ToLower(method)
+	for _, segment := range strings.Split(specPath(path), "/") {
+		if strings.HasPrefix(segment, "{") {
+			segment = "by_" + strings.Trim(segment, "{}")
+		}
+		for _, word := range strings.FieldsFunc(segment, func(r rune) bool { return r == '-' || r == '_' || r == '.' }) {
+			id += strings.ToUpper(word[:1]) + word[1:]
+		}
+	}
+	return id
+}
+
+// apiTag группирует операции по первому сегменту пути, для /admin — по двум
+func apiTag(path string) string {
+	segments := strings.Split(specPath(path), "/")
+	if segments[0] == "admin" && len(segments) > 1 {
+		return "admin/" + segments[1]
+	}
+	return segments[0]
+}
+
+// specPath отбрасывает префикс /api/v1 и ведущую косую черту: /oauth/token → oauth/token
+func specPath(path string) string {
+	return strings.TrimPrefix(strings.TrimPrefix(path, "/api/v1"), "/")
+}
+
+var (
+	objectIDType = reflect.TypeOf(primitive.ObjectID{})
+	timeType     = reflect.TypeOf(time.Time{})
+)
+
+// schemaFor строит JSON Schema типа по тем же правилам, что и encoding/json. Именованные
+// структуры попадают в components/schemas и подставляются ссылкой.
+func schemaFor(t reflect.Type, schemas map[string]interface{}) map[string]interface{} {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	switch t {
+	case objectIDType:
+		return map[string]interface{}{"type": "string", "pattern": "^[0-9a-f]{24}$"}
+	case timeType:
+		return map[string]interface{}{"type": "string", "format": "date-time"}
+	}
+
+	switch t.Kind() {
+	case reflect.String:
+		return map[string]interface{}{"type": "string"}
+	case reflect.Bool:
+		return map[string]interface{}{"type": "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return map[string]interface{}{"type": "integer"}
+	case reflect.Float32, reflect.Float64:
+		return map[string]interface{}{"type": "number"}
+	case reflect.Slice, reflect.Array:
+		if t.Elem().Kind() == reflect.Uint8 {
+			return map[string]interface{}{"type": "string", "contentEncoding": "base64"}
+		}
+		return map[string]interface{}{"type": "array", "items": schemaFor(t.Elem(), schemas)}
+	case reflect.Map:
+		return map[string]interface{}{"type": "object", "additionalProperties": schemaFor(t.Elem(), schemas)}
+	case reflect.Struct:
+		if t.Name() == "" {
+			return structSchema(t, schemas)
+		}
+		name := strings.ToUpper(t.Name()[:1]) + t.Name()[1:]
+		if _, ok := schemas[name]; !ok {
+			schemas[name] = map[string]interface{}{}
+			schemas[name] = structSchema(t, schemas)
+		}
+		return map[string]interface{}{"$ref": "#/components/schemas/" + name}
+	}
+	return map[string]interface{}{}
+}
+
+func structSchema(t reflect.Type, schemas map[string]interface{}) map[string]interface{} {
+	properties := map[string]interface{}{}
+	addStructProperties(t, properties, schemas)
+	return map[string]interface{}{"type": "object", "properties": properties}
+}
+
+// addStructProperties добавляет поля структуры; встроенные структуры без тега json раскрываются
+func addStructProperties(t reflect.Type, properties map[string]interface{}, schemas map[string]interface{}) {
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		tag := field.Tag.Get("json")
+		if tag == "-" {
+			continue
+		}
+		name, _, _ := strings.Cut(tag, ",")
+		if field.Anonymous && name == "" && field.Type.Kind() == reflect.Struct {
+			addStructProperties(field.Type, properties, schemas)
+			continue
+		}
+		if !field.IsExported() {
+			continue
+		}
+		if name == "" {
+			name = field.Name
+		}
+		properties[name] = schemaFor(field.Type, schemas)
+	}
+}
+
+// OpenAPIHandler отдаёт спецификацию /api/v1 в формате OpenAPI 3.1
+func OpenAPIHandler(w http.ResponseWriter, r *http.Request, spec *APISpec) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(spec.Document())
+}
+
+// APIDocsHandler отображает просмотрщик спецификации /api/openapi.json
+func APIDocsHandler(w http.ResponseWriter, r *http.Request) {
+	renderPage(w, r, "templates/api_docs.html", map[string]interface{}{})
+}
+
+// Типы ниже описывают ответы, которые обработчики собирают из map
+type (
+	statusResponse struct {
+		Status  string `json:"status"`
+		Message string `json:"message"`
+	}
+	createdResponse struct {
+		Status  string             `json:"status"`
+		Message string             `json:"message"`
+		ID      primitive.ObjectID `json:"id"`
+	}
+	loginResponse struct {
+		Status        string   `json:"status"`
+		Token         string   `json:"token,omitempty"`
+		CSRFToken     string   `json:"csrf_token,omitempty"`
+		PreAuthToken  string   `json:"pre_auth_token,omitempty"`
+		RecoveryCodes []string `json:"recovery_codes,omitempty"`
+	}
+	twoFactorSetupResponse struct {
+		Secret     string `json:"secret"`
+		OTPAuthURI string `json:"otpauth_uri"`
+		QRCode     string `json:"qr_code"`
+	}
+	twoFactorStatusResponse struct {
+		Enabled           bool       `json:"enabled"`
+		EnabledAt         *time.Time `json:"enabled_at"`
+		Required          bool       `json:"required"`
+		RecoveryCodesLeft int        `json:"recovery_codes_left"`
+	}
+	recoveryCodesResponse struct {
+		Status        string   `json:"status"`
+		RecoveryCodes []string `json:"recovery_codes"`
+	}
+	timetableResponse struct {
+		WeekStart string                `json:"week_start"`
+		Sessions  []models.ClassSession `json:"sessions"`
+	}
+	trainerSlotsResponse struct {
+		TrainerID primitive.ObjectID `json:"trainer_id"`
+		Date      string             `json:"date"`
+		Slots     []time.Time        `json:"slots"`
+	}
+	bookingResponse struct {
+		Status        string `json:"status"`
+		BookingStatus string `json:"booking_status"`
+		Message       string `json:"message"`
+	}
+	memberPassResponse struct {
+		Payload   string    `json:"payload"`
+		ExpiresAt time.Time `json:"expires_at"`
+	}
+	checkInResponse struct {
+		Status            string       `json:"status"`
+		Email             string       `json:"email"`
+		MembershipExpires *time.Time   `json:"membership_expires"`
+		Visit             models.Visit `json:"visit"`
+	}
+	occupancyResponse struct {
+		HourStart time.Time `json:"hour_start"`
+		Visits    int64     `json:"visits"`
+	}
+	identitiesResponse struct {
+		Identities []models.ExternalIdentity `json:"identities"`
+		Providers  []string                  `json:"providers"`
+	}
+	measurementsResponse struct {
+		Units        models.UnitPreferences `json:"units"`
+		Measurements []models.Measurement   `json:"measurements"`
+	}
+	clientSummary struct {
+		ID    primitive.ObjectID `json:"id"`
+		Email string             `json:"email"`
+	}
+	clientProfileResponse struct {
+		ID      primitive.ObjectID `json:"id"`
+		Email   string             `json:"email"`
+		Profile models.Profile     `json:"profile"`
+	}
+	apiKeyCreatedResponse struct {
+		APIKey models.APIKey `json:"api_key"`
+		Key    string        `json:"key"`
+	}
+	oauthClientCreatedResponse struct {
+		Client       models.OAuthClient `json:"client"`
+		ClientSecret string             `json:"client_secret,omitempty"`
+	}
+	auditPageResponse struct {
+		Events []models.AuditEvent `json:"events"`
+		Next   string              `json:"next"`
+	}
+	auditVerifyResponse struct {
+		Valid    bool   `json:"valid"`
+		Checked  int64  `json:"checked"`
+		BrokenAt int64  `json:"broken_at,omitempty"`
+		Reason   string `json:"reason,omitempty"`
+	}
+	trashResponse struct {
+		Users         []models.User    `json:"users"`
+		Products      []models.Product `json:"products"`
+		RetentionDays int              `json:"retention_days"`
+	}
+	emailConflictsResponse struct {
+		Conflicts []emailCollision `json:"conflicts"`
+	}
+	foodImportResponse struct {
+		Status   string   `json:"status"`
+		Imported int      `json:"imported"`
+		Errors   []string `json:"errors"`
+	}
+	oauthTokenResponse struct {
+		AccessToken string `json:"access_token"`
+		TokenType   string `json:"token_type"`
+		ExpiresIn   int    `json:"expires_in"`
+		Scope       string `json:"scope"`
+	}
+	oauthIntrospectResponse struct {
+		Active    bool   `json:"active"`
+		Scope     string `json:"scope,omitempty"`
+		ClientID  string `json:"client_id,omitempty"`
+		TokenType string `json:"token_type,omitempty"`
+		IssuedAt  int64  `json:"iat,omitempty"`
+		ExpiresAt int64  `json:"exp,omitempty"`
+		Subject   string `json:"sub,omitempty"`
+	}
+	graphQLResponse struct {
+		Data   map[string]interface{} `json:"data,omitempty"`
+		Errors []struct {
+			Message string        `json:"message"`
+			Path    []interface{} `json:"path,omitempty"`
+		} `json:"errors,omitempty"`
+	}
+)
+
+// Поля форм OAuth2; клиент может вместо client_id и client_secret передать их в HTTP Basic
+type (
+	oauthAuthorizeForm struct {
+		ClientID            string `json:"client_id"`
+		RedirectURI         string `json:"redirect_uri"`
+		ResponseType        string `json:"response_type"`
+		Scope               string `json:"scope"`
+		State               string `json:"state"`
+		CodeChallenge       string `json:"code_challenge"`
+		CodeChallengeMethod string `json:"code_challenge_method"`
+		Decision            string `json:"decision"`
+	}
+	oauthTokenForm struct {
+		GrantType    string `json:"grant_type"`
+		Code         string `json:"code,omitempty"`
+		RedirectURI  string `json:"redirect_uri,omitempty"`
+		CodeVerifier string `json:"code_verifier,omitempty"`
+		Scope        string `json:"scope,omitempty"`
+		ClientID     string `json:"client_id,omitempty"`
+		ClientSecret string `json:"client_secret,omitempty"`
+	}
+	oauthTokenTokenForm struct {
+		Token        string `json:"token"`
+		ClientID     string `json:"client_id,omitempty"`
+		ClientSecret string `json:"client_secret,omitempty"`
+	}
+)
+
+var (
+	auditFilterQuery = []string{"actor", "action", "target_type", "target_id", "from", "to"}
+	userFilterQuery  = []string{"q", "role", "verified", "membership", "sort"}
+	graphQLQuery     = []string{"query", "operationName", "variables", "extensions"}
+)
+
+// apiOperations — описания маршрутов; ключ совпадает с шаблоном маршрута в api_routes.go
+var apiOperations = map[string]apiOperation{
+	// Аутентификация
+	"POST /api/v1/auth/signup":           {Summary: "Register a new member account", Request: signUpRequest{}, Response: statusResponse{}},
+	"POST /api/v1/auth/login":            {Summary: "Sign in with email and password; returns pre_auth_token when a second factor is needed", Request: loginRequest{}, Response: loginResponse{}},
+	"POST /api/v1/auth/login/2fa":        {Summary: "Complete sign-in with a TOTP or recovery code", Request: twoFactorRequest{}, Response: loginResponse{}},
+	"POST /api/v1/auth/login/2fa/setup":  {Summary: "Start mandatory two-factor enrollment during sign-in", Request: twoFactorRequest{}, Response: twoFactorSetupResponse{}},
+	"POST /api/v1/auth/login/2fa/enable": {Summary: "Confirm mandatory two-factor enrollment and sign in", Request: twoFactorRequest{}, Response: loginResponse{}},
+	"POST /api/v1/auth/verify/resend":    {Summary: "Resend the email verification link", Request: emailRequest{}, Response: statusResponse{}},
+	"POST /api/v1/auth/password/forgot":  {Summary: "Send a password reset link", Request: emailRequest{}, Response: statusResponse{}},
+	"POST /api/v1/auth/password/reset":   {Summary: "Set a new password with a reset token", Request: passwordResetRequest{}, Response: statusResponse{}},
+	"GET /api/v1/auth/oidc/providers":    {Summary: "List configured external sign-in providers", Response: []string{}},
+
+	// Каталог и расписание
+	"GET /api/v1/products":                    {Summary: "List products", Query: []string{"page", "limit", "sort", "filter"}, Response: []models.Product{}},
+	"GET /api/v1/products/{id}":               {Summary: "Get a product", Response: models.Product{}},
+	"POST /api/v1/products":                   {Summary: "Create a product", Request: models.Product{}, Response: statusResponse{}},
+	"PUT /api/v1/products/{id}":               {Summary: "Update a product", Request: models.Product{}, Response: statusResponse{}},
+	"DELETE /api/v1/products/{id}":            {Summary: "Move a product to the trash", Response: statusResponse{}},
+	"GET /api/v1/exercises":                   {Summary: "List exercises", Response: []models.Exercise{}},
+	"POST /api/v1/exercises":                  {Summary: "Create an exercise", Request: models.Exercise{}, Response: statusResponse{}},
+	"PUT /api/v1/exercises/{id}":              {Summary: "Update an exercise", Request: models.Exercise{}, Response: statusResponse{}},
+	"DELETE /api/v1/exercises/{id}":           {Summary: "Delete an exercise", Response: statusResponse{}},
+	"GET /api/v1/foods":                       {Summary: "List food items", Response: []models.FoodItem{}},
+	"POST /api/v1/foods":                      {Summary: "Create a food item", Request: models.FoodItem{}, Response: statusResponse{}},
+	"PUT /api/v1/foods/{id}":                  {Summary: "Update a food item", Request: models.FoodItem{}, Response: statusResponse{}},
+	"DELETE /api/v1/foods/{id}":               {Summary: "Delete a food item", Response: statusResponse{}},
+	"GET /api/v1/timetable":                   {Summary: "Class sessions of the week containing ?week=YYYY-MM-DD", Query: []string{"week"}, Response: timetableResponse{}},
+	"GET /api/v1/trainers":                    {Summary: "List trainers", Response: []models.TrainerProfile{}},
+	"GET /api/v1/trainers/{id}":               {Summary: "Get a trainer", Response: models.TrainerProfile{}},
+	"GET /api/v1/trainers/{trainer_id}/slots": {Summary: "Free personal training slots on ?date=YYYY-MM-DD", Query: []string{"date"}, Response: trainerSlotsResponse{}},
+
+	// Профиль текущего пользователя
+	"GET /api/v1/profile":                               {Summary: "Get the current user's profile", Response: profileView{}},
+	"PATCH /api/v1/profile":                             {Summary: "Update profile fields", Request: profileUpdate{}, Response: profileView{}},
+	"POST /api/v1/profile/password":                     {Summary: "Change the password", Request: passwordChangeRequest{}, Response: statusResponse{}},
+	"PUT /api/v1/profile/units":                         {Summary: "Set preferred measurement units", Request: models.UnitPreferences{}, Response: statusResponse{}},
+	"GET /api/v1/profile/pass":                          {Summary: "Get the signed member pass payload", Response: memberPassResponse{}},
+	"GET /api/v1/profile/pass.png":                      {Summary: "Get the member pass as a QR code", ResponseType: "image/png"},
+	"GET /api/v1/profile/2fa":                           {Summary: "Two-factor authentication status", Response: twoFactorStatusResponse{}},
+	"POST /api/v1/profile/2fa/setup":                    {Summary: "Start two-factor enrollment", Response: twoFactorSetupResponse{}},
+	"POST /api/v1/profile/2fa/enable":                   {Summary: "Confirm two-factor enrollment with a TOTP code", Request: twoFactorRequest{}, Response: recoveryCodesResponse{}},
+	"POST /api/v1/profile/2fa/disable":                  {Summary: "Disable two-factor authentication", Request: twoFactorRequest{}, Response: statusResponse{}},
+	"POST /api/v1/profile/2fa/recovery-codes":           {Summary: "Regenerate recovery codes", Request: twoFactorRequest{}, Response: recoveryCodesResponse{}},
+	"GET /api/v1/profile/passkeys":                      {Summary: "List registered passkeys", Response: []models.Passkey{}},
+	"DELETE /api/v1/profile/passkeys/{id}":              {Summary: "Remove a passkey", Response: statusResponse{}},
+	"POST /api/v1/profile/passkeys/register/begin":      {Summary: "Start passkey registration; returns options for navigator.credentials.create()", Response: map[string]interface{}{}},
+	"POST /api/v1/profile/passkeys/register/finish":     {Summary: "Finish passkey registration with the authenticator response; the key name is passed in ?name=", Query: []string{"name"}, Request: map[string]interface{}{}, Response: models.Passkey{}},
+	"GET /api/v1/profile/identities":                    {Summary: "List linked external identities", Response: identitiesResponse{}},
+	"DELETE /api/v1/profile/identities/{provider}":      {Summary: "Unlink an external identity", Response: statusResponse{}},
+	"GET /api/v1/profile/api-keys":                      {Summary: "List personal API keys", Response: []models.APIKey{}},
+	"POST /api/v1/profile/api-keys":                     {Summary: "Create a personal API key; the key is shown only once", Request: apiKeyRequest{}, Response: apiKeyCreatedResponse{}},
+	"DELETE /api/v1/profile/api-keys/{id}":              {Summary: "Revoke a personal API key", Response: statusResponse{}},
+	"GET /api/v1/profile/oauth-consents":                {Summary: "List partner apps with access to the account", Response: []models.OAuthConsent{}},
+	"DELETE /api/v1/profile/oauth-consents/{client_id}": {Summary: "Revoke a partner app's access", Response: statusResponse{}},
+
+	// Записи на занятия, персональные тренировки и посещения
+	"GET /api/v1/bookings":             {Summary: "List the current user's class bookings", Response: []models.Booking{}},
+	"POST /api/v1/bookings":            {Summary: "Book a class session or join its waitlist", Request: bookingRequest{}, Response: bookingResponse{}},
+	"DELETE /api/v1/bookings/{id}":     {Summary: "Cancel a booking", Response: statusResponse{}},
+	"GET /api/v1/appointments":         {Summary: "List the current user's personal training appointments", Response: []models.Appointment{}},
+	"POST /api/v1/appointments":        {Summary: "Book a personal training appointment", Request: models.Appointment{}, Response: createdResponse{}},
+	"DELETE /api/v1/appointments/{id}": {Summary: "Cancel an appointment", Response: statusResponse{}},
+	"GET /api/v1/visits":               {Summary: "List the current user's gym visits", Response: []models.Visit{}},
+	"POST /api/v1/checkins":            {Summary: "Check a member in by the member pass payload", Request: checkInRequest{}, Response: checkInResponse{}},
+	"GET /api/v1/occupancy":            {Summary: "Number of visits in the current hour", Response: occupancyResponse{}},
+
+	// Дневники тренировок, замеров и питания
+	"GET /api/v1/workouts":                 {Summary: "Workout history", Query: []string{"from", "to"}, Response: []models.Workout{}},
+	"POST /api/v1/workouts":                {Summary: "Log a workout", Request: models.Workout{}, Response: createdResponse{}},
+	"DELETE /api/v1/workouts/{id}":         {Summary: "Delete a workout", Response: statusResponse{}},
+	"GET /api/v1/workouts/records":         {Summary: "Personal records per exercise", Response: []models.PersonalRecord{}},
+	"GET /api/v1/workouts/volume":          {Summary: "Weekly training volume", Query: []string{"weeks"}, Response: []models.WeeklyVolume{}},
+	"GET /api/v1/measurements":             {Summary: "Body measurements; trainers pass ?user_id= for a client", Query: []string{"user_id"}, Response: measurementsResponse{}},
+	"POST /api/v1/measurements":            {Summary: "Record a body measurement", Request: models.Measurement{}, Response: createdResponse{}},
+	"DELETE /api/v1/measurements/{id}":     {Summary: "Delete a measurement", Response: statusResponse{}},
+	"GET /api/v1/measurements/trends":      {Summary: "Trend of one metric", Query: []string{"metric", "user_id"}, Response: models.Trend{}},
+	"GET /api/v1/measurements/{id}/photo":  {Summary: "Download a progress photo", Query: []string{"name", "user_id"}, ResponseType: "image/*"},
+	"POST /api/v1/measurements/{id}/photo": {Summary: "Upload a progress photo", Upload: "photo", Response: statusResponse{}},
+	"GET /api/v1/meals":                    {Summary: "Meals logged on ?date=YYYY-MM-DD", Query: []string{"date"}, Response: []models.MealLog{}},
+	"POST /api/v1/meals":                   {Summary: "Log a meal", Request: models.MealLog{}, Response: createdResponse{}},
+	"DELETE /api/v1/meals/{id}":            {Summary: "Delete a meal", Response: statusResponse{}},
+	"PUT /api/v1/nutrition/settings":       {Summary: "Update nutrition goal settings", Request: models.NutritionSettings{}, Response: statusResponse{}},
+	"GET /api/v1/nutrition/targets":        {Summary: "Daily calorie and macro targets", Response: models.Macros{}},
+	"GET /api/v1/nutrition/summary/daily":  {Summary: "Nutrition totals for ?date=YYYY-MM-DD", Query: []string{"date"}, Response: models.NutritionSummary{}},
+	"GET /api/v1/nutrition/summary/weekly": {Summary: "Nutrition totals for the week containing ?week=YYYY-MM-DD", Query: []string{"week"}, Response: map[string]interface{}{}},
+
+	// Программы тренировок
+	"GET /api/v1/programs":                                       {Summary: "List the trainer's programs", Response: []models.Program{}},
+	"POST /api/v1/programs":                                      {Summary: "Create a program", Request: models.Program{}, Response: createdResponse{}},
+	"PUT /api/v1/programs/{id}":                                  {Summary: "Update a program", Request: models.Program{}, Response: statusResponse{}},
+	"DELETE /api/v1/programs/{id}":                               {Summary: "Delete a program", Response: statusResponse{}},
+	"POST /api/v1/programs/assignments":                          {Summary: "Assign a program to a member", Request: models.ProgramAssignment{}, Response: createdResponse{}},
+	"GET /api/v1/programs/assignments":                           {Summary: "List the current user's program assignments", Response: []models.ProgramAssignment{}},
+	"GET /api/v1/programs/assignments/{assignment_id}/adherence": {Summary: "Adherence to an assigned program by day", Response: map[string]interface{}{}},
+	"GET /api/v1/programs/today":                                 {Summary: "Today's prescribed workout", Response: map[string]interface{}{}},
+
+	// Кабинет тренера
+	"PUT /api/v1/trainer/profile":              {Summary: "Update the trainer's public profile and availability", Request: models.TrainerProfile{}, Response: statusResponse{}},
+	"GET /api/v1/trainer/appointments":         {Summary: "List the trainer's appointments", Response: []models.Appointment{}},
+	"DELETE /api/v1/trainer/appointments/{id}": {Summary: "Cancel an appointment", Response: statusResponse{}},
+	"GET /api/v1/trainer/clients":              {Summary: "List the trainer's clients", Response: []clientSummary{}},
+	"GET /api/v1/trainer/clients/{user_id}":    {Summary: "Get a client's profile as allowed by their privacy settings", Response: clientProfileResponse{}},
+
+	// Администрирование
+	"POST /api/v1/admin/users":                         {Summary: "Create a user", Request: adminUserRequest{}, Response: statusResponse{}},
+	"PUT /api/v1/admin/users/{id}":                     {Summary: "Update a user", Request: adminUserRequest{}, Response: statusResponse{}},
+	"DELETE /api/v1/admin/users/{id}":                  {Summary: "Move a user to the trash", Response: statusResponse{}},
+	"POST /api/v1/admin/users/{id}/2fa/reset":          {Summary: "Reset a user's two-factor authentication", Response: statusResponse{}},
+	"GET /api/v1/admin/sessions/{session_id}/bookings": {Summary: "List bookings of a class session", Response: []models.Booking{}},
+	"POST /api/v1/admin/bookings/attendance":           {Summary: "Mark attendance for a booking", Request: attendanceRequest{}, Response: statusResponse{}},
+	"GET /api/v1/admin/class-types":                    {Summary: "List class types", Response: []models.ClassType{}},
+	"POST /api/v1/admin/class-types":                   {Summary: "Create a class type", Request: models.ClassType{}, Response: statusResponse{}},
+	"PUT /api/v1/admin/class-types/{id}":               {Summary: "Update a class type", Request: models.ClassType{}, Response: statusResponse{}},
+	"DELETE /api/v1/admin/class-types/{id}":            {Summary: "Delete a class type", Response: statusResponse{}},
+	"GET /api/v1/admin/rooms":                          {Summary: "List rooms", Response: []models.Room{}},
+	"POST /api/v1/admin/rooms":                         {Summary: "Create a room", Request: models.Room{}, Response: statusResponse{}},
+	"PUT /api/v1/admin/rooms/{id}":                     {Summary: "Update a room", Request: models.Room{}, Response: statusResponse{}},
+	"DELETE /api/v1/admin/rooms/{id}":                  {Summary: "Delete a room", Response: statusResponse{}},
+	"GET /api/v1/admin/instructors":                    {Summary: "List instructors", Response: []models.Instructor{}},
+	"POST /api/v1/admin/instructors":                   {Summary: "Create an instructor", Request: models.Instructor{}, Response: statusResponse{}},
+	"PUT /api/v1/admin/instructors/{id}":               {Summary: "Update an instructor", Request: models.Instructor{}, Response: statusResponse{}},
+	"DELETE /api/v1/admin/instructors/{id}":            {Summary: "Delete an instructor", Response: statusResponse{}},
+	"GET /api/v1/admin/schedules":                      {Summary: "List recurring class schedules", Response: []models.ClassSchedule{}},
+	"POST /api/v1/admin/schedules":                     {Summary: "Create a recurring class schedule", Request: models.ClassSchedule{}, Response: createdResponse{}},
+	"PUT /api/v1/admin/schedules/{id}":                 {Summary: "Update a recurring class schedule", Request: models.ClassSchedule{}, Response: statusResponse{}},
+	"DELETE /api/v1/admin/schedules/{id}":              {Summary: "Delete a schedule and its future sessions", Response: statusResponse{}},
+	"POST /api/v1/admin/schedules/materialize":         {Summary: "Create class sessions for the next ?weeks= weeks", Query: []string{"weeks"}, Response: map[string]interface{}{}},
+	"GET /api/v1/admin/imports/{id}":                   {Summary: "Get an import job and its report", Response: models.ImportJob{}},
+	"POST /api/v1/admin/users/import":                  {Summary: "Import users from CSV or JSON Lines; large files run in the background and return 202 with job_id", Query: []string{"dry_run"}, Upload: "file", Response: models.ImportReport{}},
+	"GET /api/v1/admin/users/export":                   {Summary: "Export users as CSV or JSON Lines (?format=jsonl)", Query: append([]string{"format"}, userFilterQuery...), ResponseType: "text/csv"},
+	"GET /api/v1/admin/users/email-conflicts":          {Summary: "List active users whose emails collide after normalization", Response: emailConflictsResponse{}},
+	"POST /api/v1/admin/products/import":               {Summary: "Import products from CSV or JSON Lines; large files run in the background and return 202 with job_id", Query: []string{"dry_run"}, Upload: "file", Response: models.ImportReport{}},
+	"GET /api/v1/admin/products/export":                {Summary: "Export products as CSV or JSON Lines (?format=jsonl)", Query: []string{"format", "filter", "category"}, ResponseType: "text/csv"},
+	"POST /api/v1/admin/foods/import":                  {Summary: "Import food items from CSV", Upload: "file", Response: foodImportResponse{}},
+	"GET /api/v1/admin/trash":                          {Summary: "List users and products in the trash", Response: trashResponse{}},
+	"POST /api/v1/admin/trash/restore":                 {Summary: "Restore a user or product from the trash", Request: trashRestoreRequest{}, Response: statusResponse{}},
+	"GET /api/v1/admin/audit":                          {Summary: "List audit events, newest first; the next page is requested with ?before=<seq>", Query: append([]string{"before", "limit"}, auditFilterQuery...), Response: auditPageResponse{}},
+	"GET /api/v1/admin/audit/export":                   {Summary: "Export audit events as CSV or JSON Lines (?format=jsonl)", Query: append([]string{"format"}, auditFilterQuery...), ResponseType: "text/csv"},
+	"GET /api/v1/admin/audit/verify":                   {Summary: "Verify the sequence numbers and hash chain of the audit log", Response: auditVerifyResponse{}},
+	"GET /api/v1/admin/api-keys":                       {Summary: "List API keys", Query: []string{"user_id", "service"}, Response: []models.APIKey{}},
+	"POST /api/v1/admin/api-keys":                      {Summary: "Create a service API key; the key is shown only once", Request: apiKeyRequest{}, Response: apiKeyCreatedResponse{}},
+	"DELETE /api/v1/admin/api-keys/{id}":               {Summary: "Revoke an API key", Response: statusResponse{}},
+	"GET /api/v1/admin/oauth/clients":                  {Summary: "List OAuth clients", Response: []models.OAuthClient{}},
+	"POST /api/v1/admin/oauth/clients":                 {Summary: "Register an OAuth client; the secret is shown only once", Request: oauthClientRequest{}, Response: oauthClientCreatedResponse{}},
+	"DELETE /api/v1/admin/oauth/clients/{id}":          {Summary: "Revoke an OAuth client and its tokens", Response: statusResponse{}},
+
+	// Вход по ключу доступа и через внешних провайдеров
+	"POST /login/passkey/begin":    {Summary: "Start passkey sign-in; returns options for navigator.credentials.get()", Response: map[string]interface{}{}},
+	"POST /login/passkey/finish":   {Summary: "Finish passkey sign-in with the authenticator response", Request: map[string]interface{}{}, Response: loginResponse{}},
+	"GET /login/oidc/providers":    {Summary: "List configured external sign-in providers", Response: []string{}},
+	"GET /login/oidc":              {Summary: "Redirect to the external provider ?provider= to sign in", Query: []string{"provider"}, Redirect: true},
+	"GET /login/oidc/callback":     {Summary: "Provider callback; starts the session or continues with the second factor on /login", Query: []string{"code", "state"}, Redirect: true},
+	"GET /profile/identities/link": {Summary: "Redirect to the external provider ?provider= to link it to the account", Query: []string{"provider"}, Redirect: true},
+
+	// OAuth2 для партнёрских приложений
+	"GET /oauth/authorize":    {Summary: "Show the consent screen, or redirect with a code when consent was already given", Query: []string{"client_id", "redirect_uri", "response_type", "scope", "state", "code_challenge", "code_challenge_method"}, ResponseType: "text/html"},
+	"POST /oauth/authorize":   {Summary: "Approve or deny the consent request and redirect back to the application", Form: oauthAuthorizeForm{}, Redirect: true},
+	"POST /oauth/token":       {Summary: "Exchange an authorization code (PKCE) or client credentials for an access token", Form: oauthTokenForm{}, Response: oauthTokenResponse{}},
+	"POST /oauth/introspect":  {Summary: "Check whether an access token is active (RFC 7662)", Form: oauthTokenTokenForm{}, Response: oauthIntrospectResponse{}},
+	"POST /oauth/revoke":      {Summary: "Revoke an access token (RFC 7009)", Form: oauthTokenTokenForm{}},
+	"GET /oauth/api/profile":  {Summary: "Get the profile of the member who granted access", Response: profileView{}},
+	"GET /oauth/api/workouts": {Summary: "List workouts of the member who granted access", Query: []string{"from", "to"}, Response: []models.Workout{}},
+	"GET /oauth/api/visits":   {Summary: "List gym visits of the member who granted access", Response: []models.Visit{}},
+
+	// GraphQL: анонимно доступен каталог, вход открывает поля участника
+	"GET /graphql":  {Summary: "Run a GraphQL query passed in the query string", Query: graphQLQuery, Response: graphQLResponse{}},
+	"POST /graphql": {Summary: "Run a GraphQL query or mutation", Request: graphQLRequest{}, Response: graphQLResponse{}},
+}
@@ -0,0 +1,517 @@
+package handlers
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/go-webauthn/webauthn/protocol"
+	"github.com/go-webauthn/webauthn/webauthn"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+
+	"fitnesshub/middleware"
+	"fitnesshub/models"
+	"fitnesshub/utils"
+)
+
+// Назначения WebAuthn-сессий: добавление ключа из профиля и вход по ключу
+const (
+	WebAuthnRegister = "register"
+	WebAuthnLogin    = "login"
+)
+
+const (
+	WebAuthnSessionTTL    = 5 * time.Minute
+	webauthnSessionCookie = "webauthn_session"
+)
+
+var (
+	errWebAuthnSession = errors.New("invalid or expired WebAuthn session")
+	errPasskeyOwner    = errors.New("user handle does not match passkey owner")
+	errPasskeyCloned   = errors.New("passkey sign count did not increase")
+)
+
+// webauthnUser связывает пользователя и его ключи с интерфейсом webauthn.User;
+// дескриптором пользователя (user handle) служат байты его ObjectID
+type webauthnUser struct {
+	user     models.User
+	passkeys []models.Passkey
+}
+
+func (u webauthnUser) WebAuthnID() []byte {
+	return u.user.ID[:]
+}
+
+func (u webauthnUser) WebAuthnName() string {
+	return u.user.Email
+}
+
+func (u webauthnUser) WebAuthnDisplayName() string {
+	if name := strings.TrimSpace(u.user.Profile.FirstName + " " + u.user.Profile.LastName); name != "" {
+		return name
+	}
+	return u.user.Email
+}
+
+func (u webauthnUser) WebAuthnCredentials() []webauthn.Credential {
+	credentials := make([]webauthn.Credential, 0, len(u.passkeys))
+	for _, passkey := range u.passkeys {
+		credentials = append(credentials, passkeyCredential(passkey))
+	}
+	return credentials
+}
+
+// passkeyCredential восстанавливает запись WebAuthn-ключа для проверки подписи
+func passkeyCredential(passkey models.Passkey) webauthn.Credential {
+	id, _ := base64.RawURLEncoding.DecodeString(passkey.CredentialID)
+	transports := make([]protocol.AuthenticatorTransport, 0, len(passkey.Transports))
+	for _, transport := range passkey.Transports {
+		transports = append(transports, protocol.AuthenticatorTransport(transport))
+	}
+	return webauthn.Credential{
+		ID:              id,
+		PublicKey:       passkey.PublicKey,
+		AttestationType: passkey.AttestationType,
+		Transport:       transports,
+		Flags: webauthn.CredentialFlags{
+			BackupEligible: passkey.BackupEligible,
+			BackupState:    passkey.BackupState,
+		},
+		Authenticator: webauthn.Authenticator{AAGUID: passkey.AAGUID, SignCount: passkey.SignCount},
+	}
+}
+
+// relyingParty настраивает проверяющую сторону WebAuthn: WEBAUTHN_RP_ID — домен сайта,
+// WEBAUTHN_ORIGINS — разрешённые адреса страниц через запятую
+func relyingParty() (*webauthn.WebAuthn, error) {
+	rpID := os.Getenv("WEBAUTHN_RP_ID")
+	if rpID == "" {
+		rpID = "localhost"
+	}
+	origins := []string{"http://localhost:8081"}
+	if value := os.Getenv("WEBAUTHN_ORIGINS"); value != "" {
+		origins = strings.Split(value, ",")
+	}
+	return webauthn.New(&webauthn.Config{
+		RPID:          rpID,
+		RPDisplayName: "FitnessHub",
+		RPOrigins:     origins,
+	})
+}
+
+func userPasskeys(collection *mongo.Collection, userID primitive.ObjectID) ([]models.Passkey, error) {
+	passkeys := []models.Passkey{}
+	cursor, err := collection.Find(context.TODO(), bson.M{"user_id": userID}, options.Find().SetSort(bson.M{"created_at": 1}))
+	if err != nil {
+		return passkeys, err
+	}
+	err = cursor.All(context.TODO(), &passkeys)
+	return passkeys, err
+}
+
+// saveWebAuthnSession сохраняет вызов церемонии на сервере, а браузеру отдаёт только
+// случайный идентификатор в HttpOnly cookie
+func saveWebAuthnSession(w http.ResponseWriter, collection *mongo.Collection, purpose string, userID primitive.ObjectID, data *webauthn.SessionData) error {
+	id, err := utils.GenerateVerificationToken()
+	if err != nil {
+		return err
+	}
+	encoded, err := json.Marshal(data)
+	if err != nil {
+		return err
+	}
+
+	session := models.WebAuthnSession{
+		ID:        utils.HashToken(id),
+		Purpose:   purpose,
+		UserID:    userID,
+		Data:      string(encoded),
+		ExpiresAt: time.Now().Add(WebAuthnSessionTTL),
+	}
+	if _, err := collection.InsertOne(context.TODO(), session); err != nil {
+		return err
+	}
+
+	http.SetCookie(w, &http.Cookie{
+		Name:     webauthnSessionCookie,
+		Value:    id,
+		Path:     "/",
+		MaxAge:   int(WebAuthnSessionTTL.Seconds()),
+		HttpOnly: true,
+		Secure:   utils.SecureCookies(),
+		SameSite: http.SameSiteStrictMode,
+	})
+	return nil
+}
+
+// takeWebAuthnSession забирает сессию церемонии из базы; каждый вызов используется один раз
+func takeWebAuthnSession(w http.ResponseWriter, r *http.Request, collection *mongo.Collection, purpose string) (models.WebAuthnSession, webauthn.SessionData, error) {
+	var session models.WebAuthnSession
+	var data webauthn.SessionData
+
+	cookie, err := r.Cookie(webauthnSessionCookie)
+	if err != nil {
+		return session, data, errWebAuthnSession
+	}
+	http.SetCookie(w, &http.Cookie{Name: webauthnSessionCookie, Path: "/", MaxAge: -1, HttpOnly: true, Secure: utils.SecureCookies()})
+
+	err = collection.FindOneAndDelete(context.TODO(), bson.M{"_id": utils.HashToken(cookie.Value), "purpose": purpose}).Decode(&session)
+	if err != nil || time.Now().After(session.ExpiresAt) {
+		return session, data, errWebAuthnSession
+	}
+	if err := json.Unmarshal([]byte(session.Data), &data); err != nil {
+		return session, data, errWebAuthnSession
+	}
+	return session, data, nil
+}
+
+// beginPasskeyRegistration готовит параметры создания ключа. Ключ должен храниться на устройстве
+// (discoverable), чтобы входить без email, и подтверждать пользователя (PIN, биометрия);
+// уже добавленные ключи исключаются
+func beginPasskeyRegistration(rp *webauthn.WebAuthn, account webauthnUser) (*protocol.CredentialCreation, *webauthn.SessionData, error) {
+	exclusions := make([]protocol.CredentialDescriptor, 0, len(account.passkeys))
+	for _, credential := range account.WebAuthnCredentials() {
+		exclusions = append(exclusions, credential.Descriptor())
+	}
+	return rp.BeginRegistration(account,
+		webauthn.WithAuthenticatorSelection(protocol.AuthenticatorSelection{
+			ResidentKey:        protocol.ResidentKeyRequirementRequired,
+			RequireResidentKey: protocol.ResidentKeyRequired(),
+			UserVerification:   protocol.VerificationRequired,
+		}),
+		webauthn.WithExclusions(exclusions),
+	)
+}
+
+// finishPasskeyRegistration проверяет ответ аутентификатора из тела запроса и собирает запись ключа
+func finishPasskeyRegistration(rp *webauthn.WebAuthn, user models.User, data webauthn.SessionData, r *http.Request) (models.Passkey, error) {
+	credential, err := rp.FinishRegistration(webauthnUser{user: user}, data, r)
+	if err != nil {
+		return models.Passkey{}, err
+	}
+
+	transports := make([]string, 0, len(credential.Transport))
+	for _, transport := range credential.Transport {
+		transports = append(transports, string(transport))
+	}
+	return models.Passkey{
+		UserID:          user.ID,
+		CredentialID:    base64.RawURLEncoding.EncodeToString(credential.ID),
+		PublicKey:       credential.PublicKey,
+		AttestationType: credential.AttestationType,
+		Transports:      transports,
+		AAGUID:          credential.Authenticator.AAGUID,
+		SignCount:       credential.Authenticator.SignCount,
+		BackupEligible:  credential.Flags.BackupEligible,
+		BackupState:     credential.Flags.BackupState,
+		CreatedAt:       time.Now(),
+	}, nil
+}
+
+// BeginPasskeyRegistrationHandler выдаёт параметры для navigator.credentials.create()
+func BeginPasskeyRegistrationHandler(w http.ResponseWriter, r *http.Request, collection, passkeyCollection, sessionCollection *mongo.Collection) {
+	if !requireSession(w, r) {
+		return
+	}
+	user, err := currentUser(r, collection)
+	if err != nil {
+		http.Error(w, "User not found", http.StatusNotFound)
+		return
+	}
+	passkeys, err := userPasskeys(passkeyCollection, user.ID)
+	if err != nil {
+		http.Error(w, "Error fetching passkeys", http.StatusInternalServerError)
+		return
+	}
+
+	rp, err := relyingParty()
+	if err != nil {
+		http.Error(w, "WebAuthn is not configured: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	creation, data, err := beginPasskeyRegistration(rp, webauthnUser{user: user, passkeys: passkeys})
+	if err != nil {
+		http.Error(w, "Error starting passkey registration", http.StatusInternalServerError)
+		return
+	}
+	if err := saveWebAuthnSession(w, sessionCollection, WebAuthnRegister, user.ID, data); err != nil {
+		http.Error(w, "Error starting passkey registration", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(creation)
+}
+
+// FinishPasskeyRegistrationHandler проверяет ответ аутентификатора и сохраняет ключ;
+// название ключа передаётся в ?name=
+func FinishPasskeyRegistrationHandler(w http.ResponseWriter, r *http.Request, collection, passkeyCollection, sessionCollection, auditCollection *mongo.Collection) {
+	if !requireSession(w, r) {
+		return
+	}
+	user, err := currentUser(r, collection)
+	if err != nil {
+		http.Error(w, "User not found", http.StatusNotFound)
+		return
+	}
+
+	session, data, err := takeWebAuthnSession(w, r, sessionCollection, WebAuthnRegister)
+	if err != nil || session.UserID != user.ID {
+		http.Error(w, "Invalid or expired WebAuthn session", http.StatusBadRequest)
+		return
+	}
+
+	rp, err := relyingParty()
+	if err != nil {
+		http.Error(w, "WebAuthn is not configured: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	passkey, err := finishPasskeyRegistration(rp, user, data, r)
+	if err != nil {
+		http.Error(w, "Passkey registration failed", http.StatusBadRequest)
+		return
+	}
+
+	passkey.Name = strings.TrimSpace(r.URL.Query().Get("name"))
+	if passkey.Name == "" {
+		passkey.Name = "Passkey"
+	}
+	result, err := passkeyCollection.InsertOne(context.TODO(), passkey)
+	if mongo.IsDuplicateKeyError(err) {
+		http.Error(w, "This passkey is already registered", http.StatusConflict)
+		return
+	}
+	if err != nil {
+		http.Error(w, "Error saving passkey", http.StatusInternalServerError)
+		return
+	}
+	passkey.ID = result.InsertedID.(primitive.ObjectID)
+
+	event := auditEvent(r, "auth.passkey_added", "users", user.ID.Hex())
+	event.Details = passkey.Name
+	recordAudit(auditCollection, event)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(passkey)
+}
+
+// GetPasskeysHandler возвращает ключи текущего пользователя
+func GetPasskeysHandler(w http.ResponseWriter, r *http.Request, passkeyCollection *mongo.Collection) {
+	userID, err := primitive.ObjectIDFromHex(middleware.GetUserID(r))
+	if err != nil {
+		http.Error(w, "Invalid user ID", http.StatusBadRequest)
+		return
+	}
+
+	passkeys, err := userPasskeys(passkeyCollection, userID)
+	if err != nil {
+		http.Error(w, "Error fetching passkeys", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(passkeys)
+}
+
+// DeletePasskeyHandler удаляет ключ ?id= текущего пользователя
+func DeletePasskeyHandler(w http.ResponseWriter, r *http.Request, passkeyCollection, auditCollection *mongo.Collection) {
+	if !requireSession(w, r) {
+		return
+	}
+	userID, err := primitive.ObjectIDFromHex(middleware.GetUserID(r))
+	if err != nil {
+		http.Error(w, "Invalid user ID", http.StatusBadRequest)
+		return
+	}
+	objID, err := primitive.ObjectIDFromHex(requestID(r))
+	if err != nil {
+		http.Error(w, "Invalid passkey ID", http.StatusBadRequest)
+		return
+	}
+
+	var passkey models.Passkey
+	err = passkeyCollection.FindOneAndDelete(context.TODO(), bson.M{"_id": objID, "user_id": userID}).Decode(&passkey)
+	if err == mongo.ErrNoDocuments {
+		http.Error(w, "Passkey not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		http.Error(w, "Error deleting passkey", http.StatusInternalServerError)
+		return
+	}
+
+	event := auditEvent(r, "auth.passkey_removed", "users", userID.Hex())
+	event.Details = passkey.Name
+	recordAudit(auditCollection, event)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{"status": "success", "message": "Passkey removed"})
+}
+
+// beginPasskeyLogin готовит параметры входа по ключу на устройстве с подтверждением пользователя
+func beginPasskeyLogin(rp *webauthn.WebAuthn) (*protocol.CredentialAssertion, *webauthn.SessionData, error) {
+	return rp.BeginDiscoverableLogin(webauthn.WithUserVerification(protocol.VerificationRequired))
+}
+
+// BeginPasskeyLoginHandler выдаёт параметры для navigator.credentials.get(); ключ
+// выбирается на устройстве, поэтому email не нужен
+func BeginPasskeyLoginHandler(w http.ResponseWriter, r *http.Request, sessionCollection *mongo.Collection) {
+	rp, err := relyingParty()
+	if err != nil {
+		http.Error(w, "WebAuthn is not configured: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	assertion, data, err := beginPasskeyLogin(rp)
+	if err != nil {
+		http.Error(w, "Error starting passkey sign-in", http.StatusInternalServerError)
+		return
+	}
+	if err := saveWebAuthnSession(w, sessionCollection, WebAuthnLogin, primitive.NilObjectID, data); err != nil {
+		http.Error(w, "Error starting passkey sign-in", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(assertion)
+}
+
+// validatePasskeyLogin проверяет подпись аутентификатора ключом, который lookup находит по
+// идентификатору из ответа. Дескриптор пользователя из ответа должен совпадать с владельцем ключа.
+// Найденные ключ и пользователь возвращаются и при ошибке, чтобы неудачу можно было учесть.
+func validatePasskeyLogin(rp *webauthn.WebAuthn, data webauthn.SessionData, parsed *protocol.ParsedCredentialAssertionData, lookup func(credentialID string) (models.Passkey, models.User, error)) (models.Passkey, models.User, *webauthn.Credential, error) {
+	var passkey models.Passkey
+	var user models.User
+	findUser := func(rawID, userHandle []byte) (webauthn.User, error) {
+		var err error
+		passkey, user, err = lookup(base64.RawURLEncoding.EncodeToString(rawID))
+		if err != nil {
+			return nil, err
+		}
+		// Чужой дескриптор считается неизвестным ключом и не засчитывается владельцу
+		if string(userHandle) != string(passkey.UserID[:]) {
+			user = models.User{}
+			return nil, errPasskeyOwner
+		}
+		return webauthnUser{user: user, passkeys: []models.Passkey{passkey}}, nil
+	}
+
+	_, credential, err := rp.ValidatePasskeyLogin(findUser, data, parsed)
+	if err != nil {
+		return passkey, user, nil, err
+	}
+	if credential.Authenticator.CloneWarning {
+		return passkey, user, credential, errPasskeyCloned
+	}
+	return passkey, user, credential, nil
+}
+
+// FinishPasskeyLoginHandler проверяет подпись аутентификатора и выдаёт сессию. Ключ
+// с подтверждением пользователя сам является многофакторным, поэтому код 2FA не запрашивается.
+// Счётчик подписей, не выросший с прошлого входа, означает возможную копию ключа — такой вход отклоняется.
+func FinishPasskeyLoginHandler(w http.ResponseWriter, r *http.Request, collection, passkeyCollection, sessionCollection, attemptCollection, auditCollection *mongo.Collection) {
+	now := time.Now()
+	if wait := loginRetryAfter(attemptCollection, now, ipAttemptsKey(r)); wait > 0 {
+		writeTooManyAttempts(w, wait)
+		return
+	}
+
+	_, data, err := takeWebAuthnSession(w, r, sessionCollection, WebAuthnLogin)
+	if err != nil {
+		http.Error(w, "Invalid or expired WebAuthn session", http.StatusBadRequest)
+		return
+	}
+	parsed, err := protocol.ParseCredentialRequestResponse(r)
+	if err != nil {
+		http.Error(w, "Invalid passkey response", http.StatusBadRequest)
+		return
+	}
+	rp, err := relyingParty()
+	if err != nil {
+		http.Error(w, "WebAuthn is not configured: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	passkey, user, credential, err := validatePasskeyLogin(rp, data, parsed, func(credentialID string) (models.Passkey, models.User, error) {
+		var passkey models.Passkey
+		var user models.User
+		err := passkeyCollection.FindOne(context.TODO(), bson.M{"credential_id": credentialID}).Decode(&passkey)
+		if err != nil {
+			return passkey, user, err
+		}
+		err = collection.FindOne(context.TODO(), notDeleted(bson.M{"_id": passkey.UserID})).Decode(&user)
+		return passkey, user, err
+	})
+	if errors.Is(err, errPasskeyCloned) {
+		registerLoginFailure(r, attemptCollection, auditCollection, user.Email, &user, err.Error(), now)
+		http.Error(w, "Passkey sign-in failed: possible cloned authenticator", http.StatusUnauthorized)
+		return
+	}
+	if err != nil {
+		if user.ID.IsZero() {
+			failed := auditEvent(r, "auth.login_failed", "passkeys", base64.RawURLEncoding.EncodeToString(parsed.RawID))
+			failed.Details = "unknown passkey"
+			recordAudit(auditCollection, failed)
+			if _, locked := recordLoginFailure(attemptCollection, ipAttemptsKey(r), IPLockThreshold, now); locked {
+				recordAudit(auditCollection, auditEvent(r, "auth.lockout", "ip", middleware.ClientIP(r)))
+			}
+		} else {
+			registerLoginFailure(r, attemptCollection, auditCollection, user.Email, &user, "invalid passkey assertion", now)
+		}
+		http.Error(w, "Passkey sign-in failed", http.StatusUnauthorized)
+		return
+	}
+
+	if wait := loginRetryAfter(attemptCollection, now, accountAttemptsKey(user.Email)); wait > 0 {
+		writeTooManyAttempts(w, wait)
+		return
+	}
+	if !user.Verified {
+		http.Error(w, "Email not verified", http.StatusUnauthorized)
+		return
+	}
+
+	_, err = passkeyCollection.UpdateOne(context.TODO(), bson.M{"_id": passkey.ID}, bson.M{"$set": bson.M{
+		"sign_count":   credential.Authenticator.SignCount,
+		"backup_state": credential.Flags.BackupState,
+		"last_used_at": now,
+	}})
+	if err != nil {
+		http.Error(w, "Error updating passkey", http.StatusInternalServerError)
+		return
+	}
+
+	token, err := startSession(w, r, user, true, "passkey", attemptCollection, auditCollection)
+	if err != nil {
+		http.Error(w, "Error generating token", http.StatusInternalServerError)
+		return
+	}
+
+	writeLoginSuccess(w, r, token, nil)
+}
+
+// EnsurePasskeyIndexes создаёт уникальный индекс идентификаторов ключей и TTL-индекс
+// для незавершённых церемоний
+func EnsurePasskeyIndexes(passkeyCollection, sessionCollection *mongo.Collection) error {
+	_, err := passkeyCollection.Indexes().CreateMany(context.TODO(), []mongo.IndexModel{
+		{Keys: bson.D{{Key: "credential_id", Value: 1}}, Options: options.Index().SetUnique(true)},
+		{Keys: bson.D{{Key: "user_id", Value: 1}}},
+	})
+	if err != nil {
+		return err
+	}
+	_, err = sessionCollection.Indexes().CreateOne(context.TODO(), mongo.IndexModel{
+		Keys:    bson.D{{Key: "expires_at", Value: 1}},
+		Options: options.Index().SetExpireAfterSeconds(0),
+	})
+	return err
+}
@@ -0,0 +1,303 @@
+package handlers
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/go-webauthn/webauthn/protocol"
+	"github.com/go-webauthn/webauthn/protocol/webauthncbor"
+	"github.com/go-webauthn/webauthn/protocol/webauthncose"
+	"github.com/go-webauthn/webauthn/webauthn"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+
+	"fitnesshub/models"
+)
+
+const testOrigin = "http://localhost:8081"
+
+// Флаги данных аутентификатора: присутствие и подтверждение пользователя, данные ключа
+const (
+	flagUserPresent  = 0x01
+	flagUserVerified = 0x04
+	flagAttested     = 0x40
+)
+
+// softAuthenticator — программный аутентификатор с ключом P-256, отвечающий так же, как браузер
+type softAuthenticator struct {
+	key          *ecdsa.PrivateKey
+	credentialID []byte
+	userHandle   []byte
+	signCount    uint32
+	flags        byte
+}
+
+func newSoftAuthenticator(t *testing.T) *softAuthenticator {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	credentialID := make([]byte, 16)
+	rand.Read(credentialID)
+	return &softAuthenticator{key: key, credentialID: credentialID, flags: flagUserPresent | flagUserVerified}
+}
+
+func (a *softAuthenticator) authenticatorData(rpID string, attested []byte) []byte {
+	rpIDHash := sha256.Sum256([]byte(rpID))
+	data := append([]byte{}, rpIDHash[:]...)
+	flags := a.flags
+	if attested != nil {
+		flags |= flagAttested
+	}
+	data = append(data, flags)
+	data = binary.BigEndian.AppendUint32(data, a.signCount)
+	return append(data, attested...)
+}
+
+func clientData(t *testing.T, ceremony string, challenge []byte) []byte {
+	t.Helper()
+	data, err := json.Marshal(map[string]string{
+		"type":      ceremony,
+		"challenge": base64.RawURLEncoding.EncodeToString(challenge),
+		"origin":    testOrigin,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return data
+}
+
+// create отвечает на navigator.credentials.create() аттестацией "none"
+func (a *softAuthenticator) create(t *testing.T, creation *protocol.CredentialCreation) *http.Request {
+	t.Helper()
+	a.userHandle = creation.Response.User.ID.(protocol.URLEncodedBase64)
+
+	publicKey, err := webauthncbor.Marshal(webauthncose.EC2PublicKeyData{
+		PublicKeyData: webauthncose.PublicKeyData{KeyType: int64(webauthncose.EllipticKey), Algorithm: int64(webauthncose.AlgES256)},
+		Curve:         1,
+		XCoord:        a.key.PublicKey.X.FillBytes(make([]byte, 32)),
+		YCoord:        a.key.PublicKey.Y.FillBytes(make([]byte, 32)),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	attested := make([]byte, 16) // AAGUID
+	attested = binary.BigEndian.AppendUint16(attested, uint16(len(a.credentialID)))
+	attested = append(attested, a.credentialID...)
+	attested = append(attested, publicKey...)
+
+	attestation, err := webauthncbor.Marshal(struct {
+		Format    string         `cbor:"fmt"`
+		Statement map[string]any `cbor:"attStmt"`
+		AuthData  []byte         `cbor:"authData"`
+	}{"none", map[string]any{}, a.authenticatorData(creation.Response.RelyingParty.ID, attested)})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return credentialRequest(t, a.credentialID, map[string]interface{}{
+		"clientDataJSON":    clientData(t, "webauthn.create", creation.Response.Challenge),
+		"attestationObject": attestation,
+		"transports":        []string{"internal"},
+	})
+}
+
+// get отвечает на navigator.credentials.get() подписью с очередным значением счётчика
+func (a *softAuthenticator) get(t *testing.T, assertion *protocol.CredentialAssertion) *protocol.ParsedCredentialAssertionData {
+	t.Helper()
+	authData := a.authenticatorData(assertion.Response.RelyingPartyID, nil)
+	client := clientData(t, "webauthn.get", assertion.Response.Challenge)
+	clientHash := sha256.Sum256(client)
+	digest := sha256.Sum256(append(append([]byte{}, authData...), clientHash[:]...))
+	signature, err := ecdsa.SignASN1(rand.Reader, a.key, digest[:])
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	r := credentialRequest(t, a.credentialID, map[string]interface{}{
+		"clientDataJSON":    client,
+		"authenticatorData": authData,
+		"signature":         signature,
+		"userHandle":        a.userHandle,
+	})
+	parsed, err := protocol.ParseCredentialRequestResponse(r)
+	if err != nil {
+		t.Fatalf("ParseCredentialRequestResponse: %v", err)
+	}
+	return parsed
+}
+
+// credentialRequest кодирует ответ аутентификатора в JSON, как его отправляет браузер
+func credentialRequest(t *testing.T, credentialID []byte, response map[string]interface{}) *http.Request {
+	t.Helper()
+	encoded := map[string]interface{}{}
+	for name, value := range response {
+		if raw, ok := value.([]byte); ok {
+			value = base64.RawURLEncoding.EncodeToString(raw)
+		}
+		encoded[name] = value
+	}
+	body, err := json.Marshal(map[string]interface{}{
+		"id":       base64.RawURLEncoding.EncodeToString(credentialID),
+		"rawId":    base64.RawURLEncoding.EncodeToString(credentialID),
+		"type":     "public-key",
+		"response": encoded,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return httptest.NewRequest(http.MethodPost, "/", bytes.NewReader(body))
+}
+
+func testRelyingParty(t *testing.T) *webauthn.WebAuthn {
+	t.Helper()
+	t.Setenv("WEBAUTHN_RP_ID", "localhost")
+	t.Setenv("WEBAUTHN_ORIGINS", testOrigin)
+	rp, err := relyingParty()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return rp
+}
+
+// registerPasskey проводит регистрацию ключа authenticator для user
+func registerPasskey(t *testing.T, rp *webauthn.WebAuthn, user models.User, authenticator *softAuthenticator) (models.Passkey, error) {
+	t.Helper()
+	creation, data, err := beginPasskeyRegistration(rp, webauthnUser{user: user})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return finishPasskeyRegistration(rp, user, *data, authenticator.create(t, creation))
+}
+
+// loginWithPasskey проводит вход; lookup находит только ключ stored и его владельца owner
+func loginWithPasskey(t *testing.T, rp *webauthn.WebAuthn, authenticator *softAuthenticator, stored models.Passkey, owner models.User) (models.Passkey, models.User, *webauthn.Credential, error) {
+	t.Helper()
+	assertion, data, err := beginPasskeyLogin(rp)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return validatePasskeyLogin(rp, *data, authenticator.get(t, assertion), func(credentialID string) (models.Passkey, models.User, error) {
+		if credentialID != stored.CredentialID {
+			return models.Passkey{}, models.User{}, errors.New("passkey not found")
+		}
+		return stored, owner, nil
+	})
+}
+
+func testPasskeyUser() models.User {
+	return models.User{ID: primitive.NewObjectID(), Email: "member@example.com", Verified: true}
+}
+
+func TestPasskeyRegistrationAndLogin(t *testing.T) {
+	rp := testRelyingParty(t)
+	user := testPasskeyUser()
+	authenticator := newSoftAuthenticator(t)
+	authenticator.signCount = 1
+
+	passkey, err := registerPasskey(t, rp, user, authenticator)
+	if err != nil {
+		t.Fatalf("registration failed: %v", err)
+	}
+	if passkey.UserID != user.ID || passkey.CredentialID != base64.RawURLEncoding.EncodeToString(authenticator.credentialID) {
+		t.Fatalf("passkey = %+v, want credential of user %s", passkey, user.ID.Hex())
+	}
+	if passkey.SignCount != 1 || len(passkey.Transports) != 1 || passkey.Transports[0] != "internal" {
+		t.Errorf("sign count = %d, transports = %v", passkey.SignCount, passkey.Transports)
+	}
+
+	authenticator.signCount = 2
+	found, owner, credential, err := loginWithPasskey(t, rp, authenticator, passkey, user)
+	if err != nil {
+		t.Fatalf("login failed: %v", err)
+	}
+	if owner.ID != user.ID || found.CredentialID != passkey.CredentialID {
+		t.Errorf("login resolved user %s, passkey %s", owner.ID.Hex(), found.CredentialID)
+	}
+	if credential.Authenticator.SignCount != 2 {
+		t.Errorf("sign count = %d, want 2", credential.Authenticator.SignCount)
+	}
+}
+
+func TestPasskeyRegistrationExcludesExistingKeys(t *testing.T) {
+	rp := testRelyingParty(t)
+	user := testPasskeyUser()
+	existing := models.Passkey{UserID: user.ID, CredentialID: base64.RawURLEncoding.EncodeToString([]byte("existing-key"))}
+
+	creation, _, err := beginPasskeyRegistration(rp, webauthnUser{user: user, passkeys: []models.Passkey{existing}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	excluded := creation.Response.CredentialExcludeList
+	if len(excluded) != 1 || string(excluded[0].CredentialID) != "existing-key" {
+		t.Errorf("exclude list = %v, want the existing key", excluded)
+	}
+	if creation.Response.AuthenticatorSelection.UserVerification != protocol.VerificationRequired {
+		t.Errorf("user verification = %q, want required", creation.Response.AuthenticatorSelection.UserVerification)
+	}
+}
+
+func TestPasskeyRegistrationRequiresUserVerification(t *testing.T) {
+	rp := testRelyingParty(t)
+	authenticator := newSoftAuthenticator(t)
+	authenticator.flags = flagUserPresent
+
+	if _, err := registerPasskey(t, rp, testPasskeyUser(), authenticator); err == nil {
+		t.Fatal("registration without user verification succeeded")
+	}
+}
+
+func TestPasskeyLoginRejectsSignCountRegression(t *testing.T) {
+	rp := testRelyingParty(t)
+	user := testPasskeyUser()
+	authenticator := newSoftAuthenticator(t)
+	authenticator.signCount = 5
+	passkey, err := registerPasskey(t, rp, user, authenticator)
+	if err != nil {
+		t.Fatalf("registration failed: %v", err)
+	}
+
+	// Копия ключа отвечает счётчиком меньше сохранённого
+	authenticator.signCount = 3
+	_, owner, credential, err := loginWithPasskey(t, rp, authenticator, passkey, user)
+	if !errors.Is(err, errPasskeyCloned) {
+		t.Fatalf("err = %v, want errPasskeyCloned", err)
+	}
+	if !credential.Authenticator.CloneWarning {
+		t.Error("CloneWarning is not set")
+	}
+	if owner.ID != user.ID {
+		t.Error("owner is not returned, the failure cannot be counted against the account")
+	}
+}
+
+func TestPasskeyLoginRejectsUserHandleMismatch(t *testing.T) {
+	rp := testRelyingParty(t)
+	user := testPasskeyUser()
+	authenticator := newSoftAuthenticator(t)
+	passkey, err := registerPasskey(t, rp, user, authenticator)
+	if err != nil {
+		t.Fatalf("registration failed: %v", err)
+	}
+
+	other := primitive.NewObjectID()
+	authenticator.userHandle = other[:]
+	authenticator.signCount = 1
+	_, owner, _, err := loginWithPasskey(t, rp, authenticator, passkey, user)
+	if !errors.Is(err, errPasskeyOwner) {
+		t.Fatalf("err = %v, want errPasskeyOwner", err)
+	}
+	if !owner.ID.IsZero() {
+		t.Error("failure with a foreign user handle is counted against the passkey owner")
+	}
+}
@@ -0,0 +1,161 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"net/http"
+	"net/url"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"golang.org/x/crypto/bcrypt"
+
+	"fitnesshub/models"
+	"fitnesshub/utils"
+)
+
+// Срок действия ссылки для сброса пароля
+const PasswordResetTTL = time.Hour
+
+// Ответ не зависит от того, есть ли такой email, чтобы по нему нельзя было проверять адреса
+const emailRequestAccepted = "If this email is registered, we have sent you a message."
+
+// emailRequest — тело запросов, в которых передаётся только email
+type emailRequest struct {
+	Email string `json:"email"`
+}
+
+func decodeEmailRequest(r *http.Request) (string, error) {
+	var request emailRequest
+	err := json.NewDecoder(r.Body).Decode(&request)
+	return normalizeEmail(request.Email), err
+}
+
+// ResendVerificationHandler повторно отправляет письмо для подтверждения email с новым токеном
+func ResendVerificationHandler(w http.ResponseWriter, r *http.Request, collection *mongo.Collection) {
+	email, err := decodeEmailRequest(r)
+	if err != nil || email == "" {
+		http.Error(w, "Invalid JSON message", http.StatusBadRequest)
+		return
+	}
+
+	token, err := utils.GenerateVerificationToken()
+	if err != nil {
+		http.Error(w, "Error generating verification token", http.StatusInternalServerError)
+		return
+	}
+
+	result, err := collection.UpdateOne(context.TODO(),
+		notDeleted(bson.M{"email": email, "verified": false}),
+		bson.M{"$set": bson.M{"verification_token": token}})
+	if err != nil {
+		http.Error(w, "Error updating verification token", http.StatusInternalServerError)
+		return
+	}
+	if result.MatchedCount > 0 {
+		verificationLink := "http://localhost:8081/verify?token=" + url.QueryEscape(token)
+		err = utils.SendEmail(email, "Verify your email", "Please verify your email by clicking the following link: "+verificationLink)
+		if err != nil {
+			log.Println("Error sending verification email:", err)
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{"status": "success", "message": emailRequestAccepted})
+}
+
+// ForgotPasswordHandler отправляет ссылку для сброса пароля. В базе хранится только хеш токена.
+func ForgotPasswordHandler(w http.ResponseWriter, r *http.Request, collection, auditCollection *mongo.Collection) {
+	email, err := decodeEmailRequest(r)
+	if err != nil || email == "" {
+		http.Error(w, "Invalid JSON message", http.StatusBadRequest)
+		return
+	}
+
+	token, err := utils.GenerateVerificationToken()
+	if err != nil {
+		http.Error(w, "Error generating reset token", http.StatusInternalServerError)
+		return
+	}
+
+	var user models.User
+	err = collection.FindOneAndUpdate(context.TODO(), notDeleted(bson.M{"email": email}), bson.M{"$set": bson.M{
+		"password_reset_token":   utils.HashToken(token),
+		"password_reset_expires": time.Now().Add(PasswordResetTTL),
+	}}).Decode(&user)
+	if err == nil {
+		recordAudit(auditCollection, auditEvent(r, "auth.password_reset_requested", "users", user.ID.Hex()))
+
+		resetLink := "http://localhost:8081/password/reset?token=" + url.QueryEscape(token)
+		err = utils.SendEmail(email, "Reset your password",
+			"To choose a new password, click the following link within an hour: "+resetLink+
+				". If you did not request a password reset, ignore this email.")
+		if err != nil {
+			log.Println("Error sending password reset email:", err)
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{"status": "success", "message": emailRequestAccepted})
+}
+
+// ResetPasswordPageHandler отображает форму нового пароля с токеном из ссылки в письме
+func ResetPasswordPageHandler(w http.ResponseWriter, r *http.Request) {
+	renderPage(w, r, "templates/reset_password.html", map[string]interface{}{
+		"Token": r.URL.Query().Get("token"),
+	})
+}
+
+// passwordResetRequest — токен из письма и новый пароль
+type passwordResetRequest struct {
+	Token    string `json:"token"`
+	Password string `json:"password"`
+}
+
+// ResetPasswordHandler задаёт новый пароль по токену из письма (JSON или HTML-форма)
+// и снимает блокировку входа для этой учётной записи
+func ResetPasswordHandler(w http.ResponseWriter, r *http.Request, collection, attemptCollection, auditCollection *mongo.Collection) {
+	var request passwordResetRequest
+	if isFormRequest(r) {
+		request.Token = r.PostFormValue("token")
+		request.Password = r.PostFormValue("password")
+	} else if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		http.Error(w, "Invalid JSON message", http.StatusBadRequest)
+		return
+	}
+	if request.Token == "" || request.Password == "" {
+		writeResult(w, r, "/password/reset?token="+url.QueryEscape(request.Token), http.StatusBadRequest, "Token and password are required")
+		return
+	}
+
+	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(request.Password), bcrypt.DefaultCost)
+	if err != nil {
+		writeResult(w, r, "/login", http.StatusInternalServerError, "Error hashing password")
+		return
+	}
+
+	var user models.User
+	err = collection.FindOneAndUpdate(context.TODO(),
+		notDeleted(bson.M{
+			"password_reset_token":   utils.HashToken(request.Token),
+			"password_reset_expires": bson.M{"$gt": time.Now()},
+		}),
+		bson.M{
+			"$set":   bson.M{"password": string(hashedPassword)},
+			"$unset": bson.M{"password_reset_token": "", "password_reset_expires": ""},
+		}).Decode(&user)
+	if err != nil {
+		writeResult(w, r, "/login", http.StatusBadRequest, "Invalid or expired reset token")
+		return
+	}
+	clearLoginFailures(attemptCollection, accountAttemptsKey(user.Email))
+
+	event := auditEvent(r, "auth.password_reset", "users", user.ID.Hex())
+	event.ActorID = user.ID.Hex()
+	event.Changes = map[string]models.AuditChange{"password": {Before: redactAuditValue("-"), After: redactAuditValue("-")}}
+	recordAudit(auditCollection, event)
+
+	writeResult(w, r, "/login", http.StatusOK, "Password changed. You can sign in with your new password.")
+}
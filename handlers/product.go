@@ -5,6 +5,7 @@ import (
 	"encoding/json"
 	"net/http"
 	"strconv"
+	"strings"
 
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/bson/primitive"
@@ -14,26 +15,62 @@ import (
 	"fitnesshub/models"
 )
 
-func CreateProductHandler(w http.ResponseWriter, r *http.Request, collection *mongo.Collection) {
+// decodeProduct читает товар из JSON или HTML-формы администратора
+func decodeProduct(r *http.Request) (models.Product, error) {
 	var product models.Product
-	err := json.NewDecoder(r.Body).Decode(&product)
+	if !isFormRequest(r) {
+		err := json.NewDecoder(r.Body).Decode(&product)
+		return product, err
+	}
+
+	if err := r.ParseForm(); err != nil {
+		return product, err
+	}
+	price, err := strconv.ParseFloat(r.PostForm.Get("price"), 64)
+	if err != nil {
+		return product, err
+	}
+	if id := r.PostForm.Get("id"); id != "" {
+		if product.ID, err = primitive.ObjectIDFromHex(id); err != nil {
+			return product, err
+		}
+	}
+	product.Name = strings.TrimSpace(r.PostForm.Get("name"))
+	product.Description = r.PostForm.Get("description")
+	product.Price = price
+	product.Category = r.PostForm.Get("category")
+	product.ImageURL = r.PostForm.Get("image_url")
+	return product, nil
+}
+
+func CreateProductHandler(w http.ResponseWriter, r *http.Request, collection, auditCollection *mongo.Collection) {
+	product, err := decodeProduct(r)
 	if err != nil {
-		http.Error(w, "Invalid JSON message", http.StatusBadRequest)
+		writeResult(w, r, "/admin/products", http.StatusBadRequest, "Invalid request")
+		return
+	}
+
+	if product.Name == "" || product.Price < 0 {
+		writeResult(w, r, "/admin/products", http.StatusBadRequest, "Name and a non-negative price are required")
 		return
 	}
 
-	_, err = collection.InsertOne(context.TODO(), product)
+	result, err := collection.InsertOne(context.TODO(), product)
 	if err != nil {
-		http.Error(w, "Error adding product", http.StatusInternalServerError)
+		writeResult(w, r, "/admin/products", http.StatusInternalServerError, "Error adding product")
 		return
 	}
+	product.ID = result.InsertedID.(primitive.ObjectID)
 
-	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(map[string]string{"status": "success", "message": "Product added successfully"})
+	event := auditEvent(r, "products.create", "products", product.ID.Hex())
+	event.Changes = auditDiff(nil, toAuditDoc(product))
+	recordAudit(auditCollection, event)
+
+	writeResult(w, r, "/admin/products", http.StatusOK, "Product added successfully")
 }
 
 func GetProductByIDHandler(w http.ResponseWriter, r *http.Request, collection *mongo.Collection) {
-	id := r.URL.Query().Get("id")
+	id := routeParam(r, "id")
 	objID, err := primitive.ObjectIDFromHex(id)
 	if err != nil {
 		http.Error(w, "Invalid product ID", http.StatusBadRequest)
@@ -41,7 +78,7 @@ func GetProductByIDHandler(w http.ResponseWriter, r *http.Request, collection *m
 	}
 
 	var product models.Product
-	err = collection.FindOne(context.TODO(), bson.M{"_id": objID}).Decode(&product)
+	err = collection.FindOne(context.TODO(), notDeleted(bson.M{"_id": objID})).Decode(&product)
 	if err != nil {
 		http.Error(w, "Product not found", http.StatusNotFound)
 		return
@@ -51,48 +88,65 @@ func GetProductByIDHandler(w http.ResponseWriter, r *http.Request, collection *m
 	json.NewEncoder(w).Encode(product)
 }
 
-func UpdateProductByIDHandler(w http.ResponseWriter, r *http.Request, collection *mongo.Collection) {
-	var product models.Product
-	err := json.NewDecoder(r.Body).Decode(&product)
+func UpdateProductByIDHandler(w http.ResponseWriter, r *http.Request, collection, auditCollection *mongo.Collection) {
+	product, err := decodeProduct(r)
 	if err != nil {
-		http.Error(w, "Invalid JSON message", http.StatusBadRequest)
+		writeResult(w, r, "/admin/products", http.StatusBadRequest, "Invalid request")
 		return
 	}
 
+	pathObjectID(r, &product.ID)
 	if product.ID.IsZero() {
-		http.Error(w, "Product ID is required", http.StatusBadRequest)
+		writeResult(w, r, "/admin/products", http.StatusBadRequest, "Product ID is required")
+		return
+	}
+	if product.Name == "" || product.Price < 0 {
+		writeResult(w, r, "/admin/products/edit?id="+product.ID.Hex(), http.StatusBadRequest, "Name and a non-negative price are required")
 		return
 	}
 
-	filter := bson.M{"_id": product.ID}
+	filter := notDeleted(bson.M{"_id": product.ID})
 	update := bson.M{"$set": product}
 
-	_, err = collection.UpdateOne(context.TODO(), filter, update)
+	before, after, err := auditedUpdate(collection, filter, update)
+	if err == mongo.ErrNoDocuments {
+		writeResult(w, r, "/admin/products", http.StatusNotFound, "Product not found")
+		return
+	}
 	if err != nil {
-		http.Error(w, "Error updating product", http.StatusInternalServerError)
+		writeResult(w, r, "/admin/products", http.StatusInternalServerError, "Error updating product")
 		return
 	}
 
-	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(map[string]string{"status": "success", "message": "Product updated successfully"})
+	event := auditEvent(r, "products.update", "products", product.ID.Hex())
+	event.Changes = auditDiff(before, after)
+	recordAudit(auditCollection, event)
+
+	writeResult(w, r, "/admin/products", http.StatusOK, "Product updated successfully")
 }
 
-func DeleteProductByIDHandler(w http.ResponseWriter, r *http.Request, collection *mongo.Collection) {
-	id := r.URL.Query().Get("id")
-	objID, err := primitive.ObjectIDFromHex(id)
+func DeleteProductByIDHandler(w http.ResponseWriter, r *http.Request, collection, auditCollection *mongo.Collection) {
+	objID, err := primitive.ObjectIDFromHex(requestID(r))
 	if err != nil {
-		http.Error(w, "Invalid product ID", http.StatusBadRequest)
+		writeResult(w, r, "/admin/products", http.StatusBadRequest, "Invalid product ID")
 		return
 	}
 
-	_, err = collection.DeleteOne(context.TODO(), bson.M{"_id": objID})
+	before, after, err := softDelete(r, collection, objID)
+	if err == mongo.ErrNoDocuments {
+		writeResult(w, r, "/admin/products", http.StatusNotFound, "Product not found")
+		return
+	}
 	if err != nil {
-		http.Error(w, "Error deleting product", http.StatusInternalServerError)
+		writeResult(w, r, "/admin/products", http.StatusInternalServerError, "Error deleting product")
 		return
 	}
 
-	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(map[string]string{"status": "success", "message": "Product deleted successfully"})
+	event := auditEvent(r, "products.delete", "products", objID.Hex())
+	event.Changes = auditDiff(before, after)
+	recordAudit(auditCollection, event)
+
+	writeResult(w, r, "/admin/products", http.StatusOK, "Product moved to trash")
 }
 
 func GetAllProductsHandler(w http.ResponseWriter, r *http.Request, collection *mongo.Collection) {
@@ -108,9 +162,9 @@ func GetAllProductsHandler(w http.ResponseWriter, r *http.Request, collection *m
 
 	var filterBson bson.M
 	if filter != "" {
-		filterBson = bson.M{"name": bson.M{"$regex": filter, "$options": "i"}}
+		filterBson = notDeleted(bson.M{"name": bson.M{"$regex": filter, "$options": "i"}})
 	} else {
-		filterBson = bson.M{}
+		filterBson = notDeleted(bson.M{})
 	}
 
 	var products []models.Product
@@ -133,7 +187,7 @@ func GetAllProductsHandler(w http.ResponseWriter, r *http.Request, collection *m
 
 func GetAllProducts(collection *mongo.Collection) []models.Product {
 	var products []models.Product
-	cursor, err := collection.Find(context.TODO(), bson.M{})
+	cursor, err := collection.Find(context.TODO(), notDeleted(bson.M{}))
 	if err != nil {
 		return products
 	}
@@ -147,3 +201,52 @@ func GetAllProducts(collection *mongo.Collection) []models.Product {
 
 	return products
 }
+
+// AdminProductsPageHandler отображает страницу управления товарами
+func AdminProductsPageHandler(w http.ResponseWriter, r *http.Request, collection *mongo.Collection) {
+	renderAdminPage(w, r, "templates/admin_products.html", map[string]interface{}{
+		"Products": GetAllProducts(collection),
+	})
+}
+
+// AdminProductEditPageHandler отображает форму редактирования товара ?id=
+func AdminProductEditPageHandler(w http.ResponseWriter, r *http.Request, collection *mongo.Collection) {
+	objID, err := primitive.ObjectIDFromHex(routeParam(r, "id"))
+	if err != nil {
+		http.Error(w, "Invalid product ID", http.StatusBadRequest)
+		return
+	}
+
+	var product models.Product
+	err = collection.FindOne(context.TODO(), notDeleted(bson.M{"_id": objID})).Decode(&product)
+	if err != nil {
+		http.Error(w, "Product not found", http.StatusNotFound)
+		return
+	}
+
+	renderAdminPage(w, r, "templates/admin_product_edit.html", map[string]interface{}{"Product": product})
+}
+
+// AdminProductDeletePageHandler запрашивает подтверждение удаления товара ?id=
+func AdminProductDeletePageHandler(w http.ResponseWriter, r *http.Request, collection *mongo.Collection) {
+	objID, err := primitive.ObjectIDFromHex(routeParam(r, "id"))
+	if err != nil {
+		http.Error(w, "Invalid product ID", http.StatusBadRequest)
+		return
+	}
+
+	var product models.Product
+	err = collection.FindOne(context.TODO(), notDeleted(bson.M{"_id": objID})).Decode(&product)
+	if err != nil {
+		http.Error(w, "Product not found", http.StatusNotFound)
+		return
+	}
+
+	renderAdminPage(w, r, "templates/admin_confirm_delete.html", map[string]interface{}{
+		"Kind":   "product",
+		"Name":   product.Name,
+		"ID":     product.ID.Hex(),
+		"Action": "/admin/products/delete",
+		"Back":   "/admin/products",
+	})
+}
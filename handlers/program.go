@@ -0,0 +1,443 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+
+	"fitnesshub/middleware"
+	"fitnesshub/models"
+)
+
+func CreateProgramHandler(w http.ResponseWriter, r *http.Request, collection, auditCollection *mongo.Collection) {
+	var program models.Program
+	err := json.NewDecoder(r.Body).Decode(&program)
+	if err != nil {
+		http.Error(w, "Invalid JSON message", http.StatusBadRequest)
+		return
+	}
+
+	trainerID, err := primitive.ObjectIDFromHex(middleware.GetUserID(r))
+	if err != nil {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	if msg := validateProgram(program); msg != "" {
+		http.Error(w, msg, http.StatusBadRequest)
+		return
+	}
+
+	program.ID = primitive.NilObjectID
+	program.TrainerID = trainerID
+	program.CreatedAt = time.Now()
+
+	result, err := collection.InsertOne(context.TODO(), program)
+	if err != nil {
+		http.Error(w, "Error adding program", http.StatusInternalServerError)
+		return
+	}
+	program.ID = result.InsertedID.(primitive.ObjectID)
+
+	event := auditEvent(r, "programs.create", "programs", program.ID.Hex())
+	event.Changes = auditDiff(nil, toAuditDoc(program))
+	recordAudit(auditCollection, event)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{"status": "success", "message": "Program added successfully", "id": result.InsertedID})
+}
+
+// GetTrainerProgramsHandler возвращает программы текущего тренера (администратору — все программы)
+func GetTrainerProgramsHandler(w http.ResponseWriter, r *http.Request, collection *mongo.Collection) {
+	filter := bson.M{}
+	if middleware.GetUserRole(r) != "administrator" {
+		trainerID, err := primitive.ObjectIDFromHex(middleware.GetUserID(r))
+		if err != nil {
+			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+		filter["trainer_id"] = trainerID
+	}
+
+	var programs []models.Program
+	cursor, err := collection.Find(context.TODO(), filter, options.Find().SetSort(bson.D{{Key: "created_at", Value: -1}}))
+	if err != nil {
+		http.Error(w, "Error fetching programs", http.StatusInternalServerError)
+		return
+	}
+	defer cursor.Close(context.TODO())
+
+	for cursor.Next(context.TODO()) {
+		var program models.Program
+		cursor.Decode(&program)
+		programs = append(programs, program)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(programs)
+}
+
+func UpdateProgramByIDHandler(w http.ResponseWriter, r *http.Request, collection, auditCollection *mongo.Collection) {
+	var program models.Program
+	err := json.NewDecoder(r.Body).Decode(&program)
+	if err != nil {
+		http.Error(w, "Invalid JSON message", http.StatusBadRequest)
+		return
+	}
+
+	pathObjectID(r, &program.ID)
+	if program.ID.IsZero() {
+		http.Error(w, "Program ID is required", http.StatusBadRequest)
+		return
+	}
+	if msg := validateProgram(program); msg != "" {
+		http.Error(w, msg, http.StatusBadRequest)
+		return
+	}
+
+	update := bson.M{"$set": bson.M{"name": program.Name, "description": program.Description, "weeks": program.Weeks}}
+	before, after, err := auditedUpdate(collection, programOwnerFilter(r, program.ID), update)
+	if err == mongo.ErrNoDocuments {
+		http.Error(w, "Program not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		http.Error(w, "Error updating program", http.StatusInternalServerError)
+		return
+	}
+
+	event := auditEvent(r, "programs.update", "programs", program.ID.Hex())
+	event.Changes = auditDiff(before, after)
+	recordAudit(auditCollection, event)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{"status": "success", "message": "Program updated successfully"})
+}
+
+func DeleteProgramByIDHandler(w http.ResponseWriter, r *http.Request, collection, auditCollection *mongo.Collection) {
+	objID, err := primitive.ObjectIDFromHex(routeParam(r, "id"))
+	if err != nil {
+		http.Error(w, "Invalid program ID", http.StatusBadRequest)
+		return
+	}
+
+	before, err := auditedDelete(collection, programOwnerFilter(r, objID))
+	if err == mongo.ErrNoDocuments {
+		http.Error(w, "Program not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		http.Error(w, "Error deleting program", http.StatusInternalServerError)
+		return
+	}
+
+	event := auditEvent(r, "programs.delete", "programs", objID.Hex())
+	event.Changes = auditDiff(before, nil)
+	recordAudit(auditCollection, event)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{"status": "success", "message": "Program deleted successfully"})
+}
+
+// AssignProgramHandler назначает программу участнику, закреплённому за тренером, с даты start_date
+func AssignProgramHandler(w http.ResponseWriter, r *http.Request, programCollection, assignmentCollection, userCollection, auditCollection *mongo.Collection) {
+	var assignment models.ProgramAssignment
+	err := json.NewDecoder(r.Body).Decode(&assignment)
+	if err != nil {
+		http.Error(w, "Invalid JSON message", http.StatusBadRequest)
+		return
+	}
+
+	if _, err = time.ParseInLocation("2006-01-02", assignment.StartDate, time.Local); err != nil {
+		http.Error(w, "Invalid start_date", http.StatusBadRequest)
+		return
+	}
+
+	var program models.Program
+	err = programCollection.FindOne(context.TODO(), programOwnerFilter(r, assignment.ProgramID)).Decode(&program)
+	if err != nil {
+		http.Error(w, "Program not found", http.StatusNotFound)
+		return
+	}
+
+	var member models.User
+	err = userCollection.FindOne(context.TODO(), notDeleted(bson.M{"_id": assignment.MemberID})).Decode(&member)
+	if err != nil {
+		http.Error(w, "Member not found", http.StatusNotFound)
+		return
+	}
+	if middleware.GetUserRole(r) != "administrator" && member.TrainerID.Hex() != middleware.GetUserID(r) {
+		http.Error(w, "Member is not assigned to you", http.StatusForbidden)
+		return
+	}
+
+	// У участника может быть только одна активная программа
+	cancelled, err := assignmentCollection.UpdateMany(context.TODO(),
+		bson.M{"member_id": member.ID, "status": models.AssignmentStatusActive},
+		bson.M{"$set": bson.M{"status": models.AssignmentStatusCancelled}})
+	if err != nil {
+		http.Error(w, "Error assigning program", http.StatusInternalServerError)
+		return
+	}
+
+	assignment.ID = primitive.NilObjectID
+	assignment.TrainerID = program.TrainerID
+	assignment.Status = models.AssignmentStatusActive
+	assignment.CreatedAt = time.Now()
+
+	result, err := assignmentCollection.InsertOne(context.TODO(), assignment)
+	if err != nil {
+		http.Error(w, "Error assigning program", http.StatusInternalServerError)
+		return
+	}
+	assignment.ID = result.InsertedID.(primitive.ObjectID)
+
+	event := auditEvent(r, "program_assignments.create", "program_assignments", assignment.ID.Hex())
+	event.Changes = auditDiff(nil, toAuditDoc(assignment))
+	event.Details = strconv.FormatInt(cancelled.ModifiedCount, 10) + " active assignments cancelled"
+	recordAudit(auditCollection, event)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{"status": "success", "message": "Program assigned successfully", "id": result.InsertedID})
+}
+
+// GetMyProgramAssignmentsHandler возвращает программы, назначенные текущему пользователю
+func GetMyProgramAssignmentsHandler(w http.ResponseWriter, r *http.Request, collection *mongo.Collection) {
+	memberID, err := primitive.ObjectIDFromHex(middleware.GetUserID(r))
+	if err != nil {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	var assignments []models.ProgramAssignment
+	cursor, err := collection.Find(context.TODO(), bson.M{"member_id": memberID}, options.Find().SetSort(bson.D{{Key: "created_at", Value: -1}}))
+	if err != nil {
+		http.Error(w, "Error fetching assignments", http.StatusInternalServerError)
+		return
+	}
+	defer cursor.Close(context.TODO())
+
+	for cursor.Next(context.TODO()) {
+		var assignment models.ProgramAssignment
+		cursor.Decode(&assignment)
+		assignments = append(assignments, assignment)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(assignments)
+}
+
+// GetTodaysWorkoutHandler возвращает тренировку на сегодня по активной программе текущего пользователя
+// с рабочими весами, рассчитанными от его расчётного максимума
+func GetTodaysWorkoutHandler(w http.ResponseWriter, r *http.Request, programCollection, assignmentCollection, workoutCollection *mongo.Collection) {
+	memberID, err := primitive.ObjectIDFromHex(middleware.GetUserID(r))
+	if err != nil {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	var assignment models.ProgramAssignment
+	err = assignmentCollection.FindOne(context.TODO(), bson.M{"member_id": memberID, "status": models.AssignmentStatusActive}).Decode(&assignment)
+	if err != nil {
+		http.Error(w, "No active program", http.StatusNotFound)
+		return
+	}
+
+	var program models.Program
+	err = programCollection.FindOne(context.TODO(), bson.M{"_id": assignment.ProgramID}).Decode(&program)
+	if err != nil {
+		http.Error(w, "Program not found", http.StatusNotFound)
+		return
+	}
+
+	today := time.Now()
+	week, day, programDay := programDayFor(program, assignment, today)
+	response := map[string]interface{}{
+		"date":          today.Format("2006-01-02"),
+		"program_id":    program.ID,
+		"program_name":  program.Name,
+		"assignment_id": assignment.ID,
+		"week":          week + 1,
+		"day":           day,
+		"rest_day":      programDay == nil,
+	}
+
+	if programDay != nil {
+		workouts, err := findWorkouts(workoutCollection, bson.M{"user_id": memberID})
+		if err != nil {
+			http.Error(w, "Error fetching workouts", http.StatusInternalServerError)
+			return
+		}
+		records := personalRecords(workouts)
+
+		for i, exercise := range programDay.Exercises {
+			if record, ok := records[exercise.ExerciseID]; ok && exercise.PercentOneRM > 0 {
+				programDay.Exercises[i].TargetWeight = record.EstimatedOneRM * exercise.PercentOneRM / 100
+			}
+		}
+		response["workout"] = programDay
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
+
+// GetProgramAdherenceHandler сравнивает назначенные и выполненные подходы по назначению ?assignment_id=
+// до сегодняшнего дня включительно. Доступно участнику, его тренеру и администратору.
+func GetProgramAdherenceHandler(w http.ResponseWriter, r *http.Request, programCollection, assignmentCollection, workoutCollection *mongo.Collection) {
+	assignmentID, err := primitive.ObjectIDFromHex(routeParam(r, "assignment_id"))
+	if err != nil {
+		http.Error(w, "Invalid assignment ID", http.StatusBadRequest)
+		return
+	}
+
+	var assignment models.ProgramAssignment
+	err = assignmentCollection.FindOne(context.TODO(), bson.M{"_id": assignmentID}).Decode(&assignment)
+	if err != nil {
+		http.Error(w, "Assignment not found", http.StatusNotFound)
+		return
+	}
+
+	userID := middleware.GetUserID(r)
+	if userID != assignment.MemberID.Hex() && userID != assignment.TrainerID.Hex() && middleware.GetUserRole(r) != "administrator" {
+		http.Error(w, "Forbidden", http.StatusForbidden)
+		return
+	}
+
+	var program models.Program
+	err = programCollection.FindOne(context.TODO(), bson.M{"_id": assignment.ProgramID}).Decode(&program)
+	if err != nil {
+		http.Error(w, "Program not found", http.StatusNotFound)
+		return
+	}
+
+	start, _ := time.ParseInLocation("2006-01-02", assignment.StartDate, time.Local)
+	end := start.AddDate(0, 0, 7*len(program.Weeks))
+	now := time.Now()
+	if end.After(now) {
+		end = now
+	}
+
+	workouts, err := findWorkouts(workoutCollection, bson.M{
+		"user_id":      assignment.MemberID,
+		"performed_at": bson.M{"$gte": start, "$lt": end.AddDate(0, 0, 1)},
+	})
+	if err != nil {
+		http.Error(w, "Error fetching workouts", http.StatusInternalServerError)
+		return
+	}
+
+	// Выполненные подходы по дате и упражнению
+	logged := map[string]map[primitive.ObjectID]int{}
+	for _, workout := range workouts {
+		date := workout.PerformedAt.In(time.Local).Format("2006-01-02")
+		if logged[date] == nil {
+			logged[date] = map[primitive.ObjectID]int{}
+		}
+		for _, entry := range workout.Entries {
+			logged[date][entry.ExerciseID] += len(entry.Sets)
+		}
+	}
+
+	days := []models.DayAdherence{}
+	prescribedSets, completedSets, completedDays := 0, 0, 0
+	for date := start; !date.After(end); date = date.AddDate(0, 0, 1) {
+		week, day, programDay := programDayFor(program, assignment, date)
+		if programDay == nil {
+			continue
+		}
+
+		key := date.Format("2006-01-02")
+		adherence := models.DayAdherence{Date: key, Week: week + 1, Day: day}
+		for _, exercise := range programDay.Exercises {
+			adherence.PrescribedSets += exercise.Sets
+			adherence.CompletedSets += min(logged[key][exercise.ExerciseID], exercise.Sets)
+		}
+		adherence.Completed = adherence.PrescribedSets > 0 && adherence.CompletedSets >= adherence.PrescribedSets
+
+		prescribedSets += adherence.PrescribedSets
+		completedSets += adherence.CompletedSets
+		if adherence.Completed {
+			completedDays++
+		}
+		days = append(days, adherence)
+	}
+
+	percent := 0.0
+	if prescribedSets > 0 {
+		percent = float64(completedSets) / float64(prescribedSets) * 100
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"assignment_id":     assignment.ID,
+		"prescribed_days":   len(days),
+		"completed_days":    completedDays,
+		"prescribed_sets":   prescribedSets,
+		"completed_sets":    completedSets,
+		"adherence_percent": percent,
+		"days":              days,
+	})
+}
+
+// programDayFor возвращает неделю и день программы для даты date и тренировку на этот день
+// (nil, если это день отдыха или дата вне программы)
+func programDayFor(program models.Program, assignment models.ProgramAssignment, date time.Time) (int, int, *models.ProgramDay) {
+	start, err := time.ParseInLocation("2006-01-02", assignment.StartDate, time.Local)
+	if err != nil {
+		return 0, 0, nil
+	}
+
+	day := time.Date(date.Year(), date.Month(), date.Day(), 0, 0, 0, 0, time.Local)
+	offset := int(day.Sub(start).Hours()+12) / 24
+	if offset < 0 || offset >= 7*len(program.Weeks) {
+		return 0, 0, nil
+	}
+
+	week := offset / 7
+	for _, programDay := range program.Weeks[week].Days {
+		if programDay.Day == offset%7 {
+			return week, offset % 7, &programDay
+		}
+	}
+	return week, offset % 7, nil
+}
+
+// programOwnerFilter ограничивает доступ к программе её автором; администратор видит все программы
+func programOwnerFilter(r *http.Request, programID primitive.ObjectID) bson.M {
+	filter := bson.M{"_id": programID}
+	if middleware.GetUserRole(r) != "administrator" {
+		trainerID, _ := primitive.ObjectIDFromHex(middleware.GetUserID(r))
+		filter["trainer_id"] = trainerID
+	}
+	return filter
+}
+
+func validateProgram(program models.Program) string {
+	if program.Name == "" {
+		return "Name is required"
+	}
+	if len(program.Weeks) == 0 {
+		return "At least one week is required"
+	}
+	for _, week := range program.Weeks {
+		for _, day := range week.Days {
+			if day.Day < 0 || day.Day > 6 {
+				return "Day must be between 0 and 6"
+			}
+			for _, exercise := range day.Exercises {
+				if exercise.Sets <= 0 || exercise.Reps < 0 || exercise.PercentOneRM < 0 || exercise.PercentOneRM > 110 {
+					return "Invalid exercise prescription"
+				}
+			}
+		}
+	}
+	return ""
+}
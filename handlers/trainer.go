@@ -0,0 +1,418 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+
+	"fitnesshub/middleware"
+	"fitnesshub/models"
+)
+
+// Длительность персональной тренировки по умолчанию, если тренер не указал свою
+const DefaultSlotMinutes = 60
+
+// EnsureAppointmentIndexes не даёт записать двух клиентов к одному тренеру на одно и то же время
+func EnsureAppointmentIndexes(collection *mongo.Collection) error {
+	_, err := collection.Indexes().CreateOne(context.TODO(), mongo.IndexModel{
+		Keys: bson.D{{Key: "trainer_id", Value: 1}, {Key: "starts_at", Value: 1}},
+		Options: options.Index().SetUnique(true).
+			SetPartialFilterExpression(bson.M{"status": models.AppointmentStatusBooked}),
+	})
+	return err
+}
+
+func GetAllTrainersHandler(w http.ResponseWriter, r *http.Request, collection *mongo.Collection) {
+	var trainers []models.TrainerProfile
+	cursor, err := collection.Find(context.TODO(), bson.M{})
+	if err != nil {
+		http.Error(w, "Error fetching trainers", http.StatusInternalServerError)
+		return
+	}
+	defer cursor.Close(context.TODO())
+
+	for cursor.Next(context.TODO()) {
+		var trainer models.TrainerProfile
+		cursor.Decode(&trainer)
+		trainers = append(trainers, trainer)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(trainers)
+}
+
+// GetTrainerByIDHandler возвращает профиль тренера по ID пользователя (?id=)
+func GetTrainerByIDHandler(w http.ResponseWriter, r *http.Request, collection *mongo.Collection) {
+	userID, err := primitive.ObjectIDFromHex(routeParam(r, "id"))
+	if err != nil {
+		http.Error(w, "Invalid trainer ID", http.StatusBadRequest)
+		return
+	}
+
+	var trainer models.TrainerProfile
+	err = collection.FindOne(context.TODO(), bson.M{"user_id": userID}).Decode(&trainer)
+	if err != nil {
+		http.Error(w, "Trainer not found", http.StatusNotFound)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(trainer)
+}
+
+// UpdateTrainerProfileHandler создаёт или обновляет профиль и расписание текущего тренера
+func UpdateTrainerProfileHandler(w http.ResponseWriter, r *http.Request, collection, auditCollection *mongo.Collection) {
+	var trainer models.TrainerProfile
+	err := json.NewDecoder(r.Body).Decode(&trainer)
+	if err != nil {
+		http.Error(w, "Invalid JSON message", http.StatusBadRequest)
+		return
+	}
+
+	userID, err := primitive.ObjectIDFromHex(middleware.GetUserID(r))
+	if err != nil {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	for _, window := range trainer.Availability {
+		start, errStart := time.Parse("15:04", window.Start)
+		end, errEnd := time.Parse("15:04", window.End)
+		if window.Weekday < 0 || window.Weekday > 6 || errStart != nil || errEnd != nil || !start.Before(end) {
+			http.Error(w, "Invalid availability window", http.StatusBadRequest)
+			return
+		}
+	}
+	if trainer.SlotMinutes <= 0 {
+		trainer.SlotMinutes = DefaultSlotMinutes
+	}
+	if trainer.BufferMinutes < 0 {
+		http.Error(w, "buffer_minutes cannot be negative", http.StatusBadRequest)
+		return
+	}
+
+	trainer.ID = primitive.NilObjectID
+	trainer.UserID = userID
+	before, after, err := auditedUpsert(collection, bson.M{"user_id": userID}, bson.M{"$set": trainer})
+	if err != nil {
+		http.Error(w, "Error updating trainer profile", http.StatusInternalServerError)
+		return
+	}
+
+	event := auditEvent(r, "trainers.update", "trainers", userID.Hex())
+	event.Changes = auditDiff(before, after)
+	recordAudit(auditCollection, event)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{"status": "success", "message": "Trainer profile updated successfully"})
+}
+
+// GetTrainerSlotsHandler возвращает свободные слоты тренера (?trainer_id=) на дату (?date=2006-01-02)
+func GetTrainerSlotsHandler(w http.ResponseWriter, r *http.Request, trainerCollection, appointmentCollection *mongo.Collection) {
+	trainerID, err := primitive.ObjectIDFromHex(routeParam(r, "trainer_id"))
+	if err != nil {
+		http.Error(w, "Invalid trainer ID", http.StatusBadRequest)
+		return
+	}
+	date, err := time.ParseInLocation("2006-01-02", r.URL.Query().Get("date"), time.Local)
+	if err != nil {
+		http.Error(w, "Invalid date", http.StatusBadRequest)
+		return
+	}
+
+	var trainer models.TrainerProfile
+	err = trainerCollection.FindOne(context.TODO(), bson.M{"user_id": trainerID}).Decode(&trainer)
+	if err != nil {
+		http.Error(w, "Trainer not found", http.StatusNotFound)
+		return
+	}
+
+	slots, err := freeTrainerSlots(trainer, date, appointmentCollection)
+	if err != nil {
+		http.Error(w, "Error fetching appointments", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{"trainer_id": trainerID, "date": date.Format("2006-01-02"), "slots": slots})
+}
+
+// BookAppointmentHandler записывает текущего пользователя на персональную тренировку
+func BookAppointmentHandler(w http.ResponseWriter, r *http.Request, trainerCollection, appointmentCollection, sessionCollection, instructorCollection *mongo.Collection) {
+	var appointment models.Appointment
+	err := json.NewDecoder(r.Body).Decode(&appointment)
+	if err != nil {
+		http.Error(w, "Invalid JSON message", http.StatusBadRequest)
+		return
+	}
+
+	memberID, err := primitive.ObjectIDFromHex(middleware.GetUserID(r))
+	if err != nil {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	var trainer models.TrainerProfile
+	err = trainerCollection.FindOne(context.TODO(), bson.M{"user_id": appointment.TrainerID}).Decode(&trainer)
+	if err != nil {
+		http.Error(w, "Trainer not found", http.StatusNotFound)
+		return
+	}
+
+	startsAt := appointment.StartsAt.In(time.Local)
+	slots, err := freeTrainerSlots(trainer, startsAt, appointmentCollection)
+	if err != nil {
+		http.Error(w, "Error fetching appointments", http.StatusInternalServerError)
+		return
+	}
+	available := false
+	for _, slot := range slots {
+		if slot.Equal(startsAt) {
+			available = true
+			break
+		}
+	}
+	if !available {
+		http.Error(w, "Selected time is not available", http.StatusConflict)
+		return
+	}
+
+	appointment.ID = primitive.NilObjectID
+	appointment.MemberID = memberID
+	appointment.StartsAt = startsAt
+	appointment.EndsAt = startsAt.Add(time.Duration(trainer.SlotMinutes) * time.Minute)
+	appointment.Status = models.AppointmentStatusBooked
+	appointment.CreatedAt = time.Now()
+
+	conflict, err := findAppointmentConflict(appointment, trainer, appointmentCollection, sessionCollection, instructorCollection)
+	if err != nil {
+		http.Error(w, "Error checking schedule conflicts", http.StatusInternalServerError)
+		return
+	}
+	if conflict != "" {
+		http.Error(w, conflict, http.StatusConflict)
+		return
+	}
+
+	result, err := appointmentCollection.InsertOne(context.TODO(), appointment)
+	if mongo.IsDuplicateKeyError(err) {
+		http.Error(w, "Selected time is not available", http.StatusConflict)
+		return
+	}
+	if err != nil {
+		http.Error(w, "Error booking appointment", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{"status": "success", "message": "Appointment booked successfully", "id": result.InsertedID})
+}
+
+// GetMyAppointmentsHandler возвращает персональные тренировки текущего пользователя
+func GetMyAppointmentsHandler(w http.ResponseWriter, r *http.Request, collection *mongo.Collection) {
+	memberID, err := primitive.ObjectIDFromHex(middleware.GetUserID(r))
+	if err != nil {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	writeAppointments(w, collection, bson.M{"member_id": memberID})
+}
+
+// GetTrainerAppointmentsHandler возвращает предстоящие тренировки текущего тренера
+func GetTrainerAppointmentsHandler(w http.ResponseWriter, r *http.Request, collection *mongo.Collection) {
+	trainerID, err := primitive.ObjectIDFromHex(middleware.GetUserID(r))
+	if err != nil {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	writeAppointments(w, collection, bson.M{
+		"trainer_id": trainerID,
+		"status":     models.AppointmentStatusBooked,
+		"starts_at":  bson.M{"$gte": time.Now()},
+	})
+}
+
+// CancelAppointmentHandler отменяет тренировку; отменить её может как клиент, так и тренер
+func CancelAppointmentHandler(w http.ResponseWriter, r *http.Request, collection *mongo.Collection) {
+	objID, err := primitive.ObjectIDFromHex(routeParam(r, "id"))
+	if err != nil {
+		http.Error(w, "Invalid appointment ID", http.StatusBadRequest)
+		return
+	}
+
+	userID, err := primitive.ObjectIDFromHex(middleware.GetUserID(r))
+	if err != nil {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	filter := bson.M{
+		"_id":    objID,
+		"status": models.AppointmentStatusBooked,
+		"$or":    bson.A{bson.M{"member_id": userID}, bson.M{"trainer_id": userID}},
+	}
+	result, err := collection.UpdateOne(context.TODO(), filter, bson.M{"$set": bson.M{"status": models.AppointmentStatusCancelled}})
+	if err != nil {
+		http.Error(w, "Error cancelling appointment", http.StatusInternalServerError)
+		return
+	}
+	if result.MatchedCount == 0 {
+		http.Error(w, "Appointment not found", http.StatusNotFound)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{"status": "success", "message": "Appointment cancelled successfully"})
+}
+
+func writeAppointments(w http.ResponseWriter, collection *mongo.Collection, filter bson.M) {
+	var appointments []models.Appointment
+	cursor, err := collection.Find(context.TODO(), filter, options.Find().SetSort(bson.D{{Key: "starts_at", Value: 1}}))
+	if err != nil {
+		http.Error(w, "Error fetching appointments", http.StatusInternalServerError)
+		return
+	}
+	defer cursor.Close(context.TODO())
+
+	for cursor.Next(context.TODO()) {
+		var appointment models.Appointment
+		cursor.Decode(&appointment)
+		appointments = append(appointments, appointment)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(appointments)
+}
+
+// freeTrainerSlots строит слоты по окнам доступности тренера на дату day и убирает прошедшие
+// и пересекающиеся (с учётом буфера) с уже забронированными тренировками
+func freeTrainerSlots(trainer models.TrainerProfile, day time.Time, appointmentCollection *mongo.Collection) ([]time.Time, error) {
+	dayStart := time.Date(day.Year(), day.Month(), day.Day(), 0, 0, 0, 0, time.Local)
+	slotLength := time.Duration(trainer.SlotMinutes) * time.Minute
+	buffer := time.Duration(trainer.BufferMinutes) * time.Minute
+
+	var booked []models.Appointment
+	cursor, err := appointmentCollection.Find(context.TODO(), bson.M{
+		"trainer_id": trainer.UserID,
+		"status":     models.AppointmentStatusBooked,
+		"starts_at":  bson.M{"$lt": dayStart.AddDate(0, 0, 1).Add(buffer)},
+		"ends_at":    bson.M{"$gt": dayStart.Add(-buffer)},
+	})
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(context.TODO())
+	if err = cursor.All(context.TODO(), &booked); err != nil {
+		return nil, err
+	}
+
+	slots := []time.Time{}
+	for _, window := range trainer.Availability {
+		if time.Weekday(window.Weekday) != dayStart.Weekday() {
+			continue
+		}
+		start, _ := time.Parse("15:04", window.Start)
+		end, _ := time.Parse("15:04", window.End)
+		windowStart := dayStart.Add(time.Duration(start.Hour())*time.Hour + time.Duration(start.Minute())*time.Minute)
+		windowEnd := dayStart.Add(time.Duration(end.Hour())*time.Hour + time.Duration(end.Minute())*time.Minute)
+
+		for slot := windowStart; !slot.Add(slotLength).After(windowEnd); slot = slot.Add(slotLength + buffer) {
+			if slot.Before(time.Now()) {
+				continue
+			}
+			free := true
+			for _, appointment := range booked {
+				if slot.Before(appointment.EndsAt.Add(buffer)) && slot.Add(slotLength).After(appointment.StartsAt.Add(-buffer)) {
+					free = false
+					break
+				}
+			}
+			if free {
+				slots = append(slots, slot)
+			}
+		}
+	}
+	return slots, nil
+}
+
+// findAppointmentConflict проверяет пересечения с групповыми занятиями тренера,
+// другими записями клиента и занятостью зала. Возвращает описание конфликта или пустую строку.
+func findAppointmentConflict(appointment models.Appointment, trainer models.TrainerProfile, appointmentCollection, sessionCollection, instructorCollection *mongo.Collection) (string, error) {
+	buffer := time.Duration(trainer.BufferMinutes) * time.Minute
+	overlap := func(buffer time.Duration) bson.M {
+		return bson.M{
+			"starts_at": bson.M{"$lt": appointment.EndsAt.Add(buffer)},
+			"ends_at":   bson.M{"$gt": appointment.StartsAt.Add(-buffer)},
+		}
+	}
+
+	var instructors []models.Instructor
+	cursor, err := instructorCollection.Find(context.TODO(), bson.M{"user_id": trainer.UserID})
+	if err != nil {
+		return "", err
+	}
+	if err = cursor.All(context.TODO(), &instructors); err != nil {
+		return "", err
+	}
+	if len(instructors) > 0 {
+		instructorIDs := bson.A{}
+		for _, instructor := range instructors {
+			instructorIDs = append(instructorIDs, instructor.ID)
+		}
+		filter := overlap(buffer)
+		filter["instructor_id"] = bson.M{"$in": instructorIDs}
+		filter["cancelled"] = false
+		count, err := sessionCollection.CountDocuments(context.TODO(), filter)
+		if err != nil {
+			return "", err
+		}
+		if count > 0 {
+			return "Trainer is teaching a class at this time", nil
+		}
+	}
+
+	filter := overlap(0)
+	filter["member_id"] = appointment.MemberID
+	filter["status"] = models.AppointmentStatusBooked
+	count, err := appointmentCollection.CountDocuments(context.TODO(), filter)
+	if err != nil {
+		return "", err
+	}
+	if count > 0 {
+		return "You already have an appointment at this time", nil
+	}
+
+	if !appointment.RoomID.IsZero() {
+		filter = overlap(0)
+		filter["room_id"] = appointment.RoomID
+		filter["status"] = models.AppointmentStatusBooked
+		count, err = appointmentCollection.CountDocuments(context.TODO(), filter)
+		if err != nil {
+			return "", err
+		}
+		if count > 0 {
+			return "Room is already booked at this time", nil
+		}
+
+		filter = overlap(0)
+		filter["room_id"] = appointment.RoomID
+		filter["cancelled"] = false
+		count, err = sessionCollection.CountDocuments(context.TODO(), filter)
+		if err != nil {
+			return "", err
+		}
+		if count > 0 {
+			return "Room is used by a class at this time", nil
+		}
+	}
+
+	return "", nil
+}
@@ -0,0 +1,201 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"net/http"
+	"os"
+	"strconv"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+
+	"fitnesshub/middleware"
+	"fitnesshub/models"
+)
+
+// Срок хранения удалённых записей по умолчанию, если не задан TRASH_RETENTION_DAYS
+const DefaultTrashRetentionDays = 30
+
+// notDeleted дополняет фильтр условием, исключающим записи в корзине
+func notDeleted(filter bson.M) bson.M {
+	filter["deleted_at"] = nil
+	return filter
+}
+
+// softDelete помечает запись удалённой от имени текущего пользователя и возвращает
+// её состояние до и после; mongo.ErrNoDocuments — записи нет или она уже в корзине
+func softDelete(r *http.Request, collection *mongo.Collection, id primitive.ObjectID) (bson.M, bson.M, error) {
+	deletedBy, _ := primitive.ObjectIDFromHex(middleware.GetUserID(r))
+	return auditedUpdate(collection, notDeleted(bson.M{"_id": id}), bson.M{"$set": bson.M{
+		"deleted_at": time.Now(),
+		"deleted_by": deletedBy,
+	}})
+}
+
+// TrashRetention возвращает срок хранения удалённых записей из TRASH_RETENTION_DAYS
+func TrashRetention() time.Duration {
+	days, err := strconv.Atoi(os.Getenv("TRASH_RETENTION_DAYS"))
+	if err != nil || days <= 0 {
+		days = DefaultTrashRetentionDays
+	}
+	return time.Duration(days) * 24 * time.Hour
+}
+
+// AdminTrashHandler показывает удалённых пользователей и товары (JSON при ?format=json)
+func AdminTrashHandler(w http.ResponseWriter, r *http.Request, userCollection, productCollection *mongo.Collection) {
+	findOptions := options.Find().SetSort(bson.D{{Key: "deleted_at", Value: -1}})
+	deleted := bson.M{"deleted_at": bson.M{"$ne": nil}}
+
+	users := []models.User{}
+	cursor, err := userCollection.Find(context.TODO(), deleted, findOptions.SetProjection(bson.M{"password": 0, "verification_token": 0}))
+	if err != nil {
+		http.Error(w, "Error fetching users", http.StatusInternalServerError)
+		return
+	}
+	defer cursor.Close(context.TODO())
+	if err = cursor.All(context.TODO(), &users); err != nil {
+		http.Error(w, "Error decoding users", http.StatusInternalServerError)
+		return
+	}
+
+	products := []models.Product{}
+	cursor, err = productCollection.Find(context.TODO(), deleted, options.Find().SetSort(bson.D{{Key: "deleted_at", Value: -1}}))
+	if err != nil {
+		http.Error(w, "Error fetching products", http.StatusInternalServerError)
+		return
+	}
+	defer cursor.Close(context.TODO())
+	if err = cursor.All(context.TODO(), &products); err != nil {
+		http.Error(w, "Error decoding products", http.StatusInternalServerError)
+		return
+	}
+
+	retention := TrashRetention()
+	if wantsJSON(r) {
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"users":          users,
+			"products":       products,
+			"retention_days": int(retention.Hours() / 24),
+		})
+		return
+	}
+
+	renderAdminPage(w, r, "templates/admin_trash.html", map[string]interface{}{
+		"Users":         users,
+		"Products":      products,
+		"RetentionDays": int(retention.Hours() / 24),
+	})
+}
+
+// trashRestoreRequest — запись для восстановления: kind=users|products, id
+type trashRestoreRequest struct {
+	Kind string `json:"kind"`
+	ID   string `json:"id"`
+}
+
+// RestoreFromTrashHandler возвращает из корзины пользователя или товар: kind=users|products, id
+func RestoreFromTrashHandler(w http.ResponseWriter, r *http.Request, userCollection, productCollection, auditCollection *mongo.Collection) {
+	var request trashRestoreRequest
+	if isFormRequest(r) {
+		request.Kind = r.PostFormValue("kind")
+		request.ID = r.PostFormValue("id")
+	} else if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		writeResult(w, r, "/admin/trash", http.StatusBadRequest, "Invalid request")
+		return
+	}
+
+	var collection *mongo.Collection
+	switch request.Kind {
+	case "users":
+		collection = userCollection
+	case "products":
+		collection = productCollection
+	default:
+		writeResult(w, r, "/admin/trash", http.StatusBadRequest, "Kind must be users or products")
+		return
+	}
+
+	objID, err := primitive.ObjectIDFromHex(request.ID)
+	if err != nil {
+		writeResult(w, r, "/admin/trash", http.StatusBadRequest, "Invalid ID")
+		return
+	}
+
+	before, after, err := auditedUpdate(collection,
+		bson.M{"_id": objID, "deleted_at": bson.M{"$ne": nil}},
+		bson.M{"$unset": bson.M{"deleted_at": "", "deleted_by": ""}})
+	if err == mongo.ErrNoDocuments {
+		writeResult(w, r, "/admin/trash", http.StatusNotFound, "Record not found in trash")
+		return
+	}
+	if mongo.IsDuplicateKeyError(err) {
+		writeResult(w, r, "/admin/trash", http.StatusConflict, "An active user with this email already exists")
+		return
+	}
+	if err != nil {
+		writeResult(w, r, "/admin/trash", http.StatusInternalServerError, "Error restoring record")
+		return
+	}
+
+	event := auditEvent(r, request.Kind+".restore", request.Kind, objID.Hex())
+	event.Changes = auditDiff(before, after)
+	recordAudit(auditCollection, event)
+
+	writeResult(w, r, "/admin/trash", http.StatusOK, "Record restored successfully")
+}
+
+// PurgeTrash окончательно удаляет записи, пролежавшие в корзине дольше retention,
+// и записывает в журнал аудита, какие записи были удалены
+func PurgeTrash(auditCollection *mongo.Collection, retention time.Duration, collections ...*mongo.Collection) error {
+	cutoff := time.Now().Add(-retention)
+	filter := bson.M{"deleted_at": bson.M{"$lt": cutoff}}
+	for _, collection := range collections {
+		var expired []struct {
+			ID primitive.ObjectID `bson:"_id"`
+		}
+		cursor, err := collection.Find(context.TODO(), filter, options.Find().SetProjection(bson.M{"_id": 1}))
+		if err != nil {
+			return err
+		}
+		if err = cursor.All(context.TODO(), &expired); err != nil {
+			return err
+		}
+
+		for _, record := range expired {
+			result, err := collection.DeleteOne(context.TODO(), bson.M{"_id": record.ID, "deleted_at": bson.M{"$lt": cutoff}})
+			if err != nil {
+				return err
+			}
+			if result.DeletedCount > 0 {
+				recordAudit(auditCollection, models.AuditEvent{
+					ActorRole:  "system",
+					Action:     collection.Name() + ".purge",
+					TargetType: collection.Name(),
+					TargetID:   record.ID.Hex(),
+				})
+			}
+		}
+		if len(expired) > 0 {
+			log.Printf("Purged %d records from %s trash", len(expired), collection.Name())
+		}
+	}
+	return nil
+}
+
+// RunTrashPurge запускает PurgeTrash сразу и затем с периодом interval
+func RunTrashPurge(auditCollection *mongo.Collection, interval, retention time.Duration, collections ...*mongo.Collection) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		if err := PurgeTrash(auditCollection, retention, collections...); err != nil {
+			log.Println("Error purging trash:", err)
+		}
+		<-ticker.C
+	}
+}
@@ -0,0 +1,464 @@
+package handlers
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/skip2/go-qrcode"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"golang.org/x/crypto/bcrypt"
+
+	"fitnesshub/middleware"
+	"fitnesshub/models"
+	"fitnesshub/utils"
+)
+
+// Назначения токена промежуточного шага входа: ввод кода или обязательное подключение 2FA
+const (
+	PreAuthTwoFactor       = "2fa"
+	PreAuthTwoFactorEnroll = "2fa_enroll"
+)
+
+const RecoveryCodeCount = 10
+
+// PreAuthCookie — cookie с токеном шага 2FA после входа через внешнего провайдера
+const PreAuthCookie = "pre_auth_token"
+
+var errInvalidTwoFactorCode = errors.New("invalid two-factor code")
+
+// twoFactorRequest — тело запросов 2FA; pre_auth_token нужен только на шаге входа
+// и берётся из cookie PreAuthCookie, если в теле его нет
+type twoFactorRequest struct {
+	PreAuthToken string `json:"pre_auth_token"`
+	Code         string `json:"code"`
+	RecoveryCode string `json:"recovery_code"`
+	Password     string `json:"password"`
+}
+
+func decodeTwoFactorRequest(r *http.Request) (twoFactorRequest, error) {
+	var request twoFactorRequest
+	err := json.NewDecoder(r.Body).Decode(&request)
+	if cookie, cookieErr := r.Cookie(PreAuthCookie); request.PreAuthToken == "" && cookieErr == nil {
+		request.PreAuthToken = cookie.Value
+	}
+	return request, err
+}
+
+// preAuthUser возвращает пользователя по токену промежуточного шага входа с нужным назначением
+func preAuthUser(collection *mongo.Collection, token, purpose string) (models.User, error) {
+	var user models.User
+	userID, tokenPurpose, err := utils.ParsePreAuthToken(token)
+	if err != nil || tokenPurpose != purpose {
+		return user, errors.New("invalid or expired pre-auth token")
+	}
+	objID, err := primitive.ObjectIDFromHex(userID)
+	if err != nil {
+		return user, err
+	}
+	err = collection.FindOne(context.TODO(), notDeleted(bson.M{"_id": objID})).Decode(&user)
+	return user, err
+}
+
+// writeTwoFactorSetup создаёт новый секрет, сохраняет его как ожидающий подтверждения
+// и возвращает секрет, otpauth://-ссылку и QR-код для приложения-аутентификатора
+func writeTwoFactorSetup(w http.ResponseWriter, collection *mongo.Collection, user models.User) {
+	if user.TwoFactor.Enabled {
+		http.Error(w, "Two-factor authentication is already enabled", http.StatusConflict)
+		return
+	}
+
+	secret, err := utils.GenerateTOTPSecret()
+	if err != nil {
+		http.Error(w, "Error generating secret", http.StatusInternalServerError)
+		return
+	}
+	encrypted, err := utils.EncryptSecret(secret)
+	if err != nil {
+		http.Error(w, "Error storing secret", http.StatusInternalServerError)
+		return
+	}
+	_, err = collection.UpdateOne(context.TODO(), bson.M{"_id": user.ID}, bson.M{"$set": bson.M{"two_factor.pending_secret": encrypted}})
+	if err != nil {
+		http.Error(w, "Error storing secret", http.StatusInternalServerError)
+		return
+	}
+
+	uri := utils.TOTPProvisioningURI(secret, user.Email)
+	png, err := qrcode.Encode(uri, qrcode.Medium, 256)
+	if err != nil {
+		http.Error(w, "Error generating QR code", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	json.NewEncoder(w).Encode(map[string]string{
+		"secret":      secret,
+		"otpauth_uri": uri,
+		"qr_code":     "data:image/png;base64," + base64.StdEncoding.EncodeToString(png),
+	})
+}
+
+// newRecoveryCodes возвращает коды восстановления и их хеши для хранения
+func newRecoveryCodes() ([]string, []string, error) {
+	codes, err := utils.GenerateRecoveryCodes(RecoveryCodeCount)
+	if err != nil {
+		return nil, nil, err
+	}
+	hashes := make([]string, len(codes))
+	for i, code := range codes {
+		hashes[i] = utils.HashToken(code)
+	}
+	return codes, hashes, nil
+}
+
+// enableTwoFactor подтверждает ожидающий секрет кодом из приложения и включает 2FA.
+// Возвращает коды восстановления, которые показываются пользователю один раз.
+func enableTwoFactor(collection *mongo.Collection, user models.User, code string) ([]string, error) {
+	if user.TwoFactor.PendingSecret == "" {
+		return nil, errors.New("start two-factor setup first")
+	}
+	secret, err := utils.DecryptSecret(user.TwoFactor.PendingSecret)
+	if err != nil {
+		return nil, err
+	}
+	step, ok := utils.ValidateTOTP(secret, code, time.Now())
+	if !ok {
+		return nil, errInvalidTwoFactorCode
+	}
+
+	codes, hashes, err := newRecoveryCodes()
+	if err != nil {
+		return nil, err
+	}
+	_, err = collection.UpdateOne(context.TODO(),
+		bson.M{"_id": user.ID, "two_factor.pending_secret": user.TwoFactor.PendingSecret},
+		bson.M{"$set": bson.M{"two_factor": models.TwoFactorSettings{
+			Enabled:       true,
+			Secret:        user.TwoFactor.PendingSecret,
+			RecoveryCodes: hashes,
+			LastStep:      step,
+			EnabledAt:     timePtr(time.Now()),
+		}}})
+	return codes, err
+}
+
+func timePtr(t time.Time) *time.Time {
+	return &t
+}
+
+// verifySecondFactor проверяет код из приложения или код восстановления и возвращает способ
+// подтверждения. Каждый код принимается один раз: для TOTP запоминается шаг времени,
+// использованный код восстановления удаляется.
+func verifySecondFactor(collection *mongo.Collection, user models.User, request twoFactorRequest) (string, error) {
+	if request.RecoveryCode != "" {
+		hash := utils.HashToken(utils.NormalizeRecoveryCode(request.RecoveryCode))
+		result, err := collection.UpdateOne(context.TODO(),
+			bson.M{"_id": user.ID, "two_factor.recovery_codes": hash},
+			bson.M{"$pull": bson.M{"two_factor.recovery_codes": hash}})
+		if err != nil {
+			return "", err
+		}
+		if result.ModifiedCount == 0 {
+			return "", errInvalidTwoFactorCode
+		}
+		return "recovery_code", nil
+	}
+
+	secret, err := utils.DecryptSecret(user.TwoFactor.Secret)
+	if err != nil {
+		return "", err
+	}
+	step, ok := utils.ValidateTOTP(secret, request.Code, time.Now())
+	if !ok {
+		return "", errInvalidTwoFactorCode
+	}
+	result, err := collection.UpdateOne(context.TODO(),
+		bson.M{"_id": user.ID, "two_factor.last_step": bson.M{"$lt": step}},
+		bson.M{"$set": bson.M{"two_factor.last_step": step}})
+	if err != nil {
+		return "", err
+	}
+	if result.ModifiedCount == 0 {
+		return "", errInvalidTwoFactorCode
+	}
+	return "totp", nil
+}
+
+// LoginTwoFactorHandler завершает вход кодом второго фактора и выдаёт сессию
+func LoginTwoFactorHandler(w http.ResponseWriter, r *http.Request, collection, attemptCollection, auditCollection *mongo.Collection) {
+	request, err := decodeTwoFactorRequest(r)
+	if err != nil {
+		http.Error(w, "Invalid JSON message", http.StatusBadRequest)
+		return
+	}
+
+	user, err := preAuthUser(collection, request.PreAuthToken, PreAuthTwoFactor)
+	if err != nil || !user.TwoFactor.Enabled {
+		http.Error(w, "Invalid or expired pre-auth token", http.StatusUnauthorized)
+		return
+	}
+
+	// Подбор кода ограничивается теми же счётчиками, что и подбор пароля
+	now := time.Now()
+	if wait := loginRetryAfter(attemptCollection, now, accountAttemptsKey(user.Email), ipAttemptsKey(r)); wait > 0 {
+		writeTooManyAttempts(w, wait)
+		return
+	}
+
+	method, err := verifySecondFactor(collection, user, request)
+	if err == errInvalidTwoFactorCode {
+		registerLoginFailure(r, attemptCollection, auditCollection, user.Email, &user, "wrong two-factor code", now)
+		http.Error(w, "Invalid two-factor code", http.StatusUnauthorized)
+		return
+	}
+	if err != nil {
+		http.Error(w, "Error verifying two-factor code", http.StatusInternalServerError)
+		return
+	}
+
+	token, err := startSession(w, r, user, true, method, attemptCollection, auditCollection)
+	if err != nil {
+		http.Error(w, "Error generating token", http.StatusInternalServerError)
+		return
+	}
+
+	writeLoginSuccess(w, r, token, nil)
+}
+
+// LoginTwoFactorSetupHandler начинает обязательное подключение 2FA на шаге входа
+func LoginTwoFactorSetupHandler(w http.ResponseWriter, r *http.Request, collection *mongo.Collection) {
+	request, err := decodeTwoFactorRequest(r)
+	if err != nil {
+		http.Error(w, "Invalid JSON message", http.StatusBadRequest)
+		return
+	}
+
+	user, err := preAuthUser(collection, request.PreAuthToken, PreAuthTwoFactorEnroll)
+	if err != nil {
+		http.Error(w, "Invalid or expired pre-auth token", http.StatusUnauthorized)
+		return
+	}
+	writeTwoFactorSetup(w, collection, user)
+}
+
+// LoginTwoFactorEnableHandler подтверждает подключение 2FA на шаге входа, выдаёт сессию
+// и возвращает коды восстановления
+func LoginTwoFactorEnableHandler(w http.ResponseWriter, r *http.Request, collection, attemptCollection, auditCollection *mongo.Collection) {
+	request, err := decodeTwoFactorRequest(r)
+	if err != nil {
+		http.Error(w, "Invalid JSON message", http.StatusBadRequest)
+		return
+	}
+
+	user, err := preAuthUser(collection, request.PreAuthToken, PreAuthTwoFactorEnroll)
+	if err != nil {
+		http.Error(w, "Invalid or expired pre-auth token", http.StatusUnauthorized)
+		return
+	}
+
+	codes, err := enableTwoFactor(collection, user, request.Code)
+	if err != nil {
+		http.Error(w, "Error enabling two-factor authentication: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	event := auditEvent(r, "auth.2fa_enabled", "users", user.ID.Hex())
+	event.ActorID, event.ActorRole = user.ID.Hex(), user.Role
+	recordAudit(auditCollection, event)
+
+	token, err := startSession(w, r, user, true, "totp", attemptCollection, auditCollection)
+	if err != nil {
+		http.Error(w, "Error generating token", http.StatusInternalServerError)
+		return
+	}
+
+	writeLoginSuccess(w, r, token, map[string]interface{}{"recovery_codes": codes})
+}
+
+// GetTwoFactorStatusHandler возвращает состояние 2FA текущего пользователя
+func GetTwoFactorStatusHandler(w http.ResponseWriter, r *http.Request, collection *mongo.Collection) {
+	user, err := currentUser(r, collection)
+	if err != nil {
+		http.Error(w, "User not found", http.StatusNotFound)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"enabled":             user.TwoFactor.Enabled,
+		"enabled_at":          user.TwoFactor.EnabledAt,
+		"required":            utils.TwoFactorRequired(user.Role),
+		"recovery_codes_left": len(user.TwoFactor.RecoveryCodes),
+	})
+}
+
+// TwoFactorSetupHandler начинает подключение 2FA из профиля
+func TwoFactorSetupHandler(w http.ResponseWriter, r *http.Request, collection *mongo.Collection) {
+	if !requireSession(w, r) {
+		return
+	}
+	user, err := currentUser(r, collection)
+	if err != nil {
+		http.Error(w, "User not found", http.StatusNotFound)
+		return
+	}
+	writeTwoFactorSetup(w, collection, user)
+}
+
+// TwoFactorEnableHandler подтверждает подключение 2FA кодом и возвращает коды восстановления
+func TwoFactorEnableHandler(w http.ResponseWriter, r *http.Request, collection, auditCollection *mongo.Collection) {
+	if !requireSession(w, r) {
+		return
+	}
+	request, err := decodeTwoFactorRequest(r)
+	if err != nil {
+		http.Error(w, "Invalid JSON message", http.StatusBadRequest)
+		return
+	}
+	user, err := currentUser(r, collection)
+	if err != nil {
+		http.Error(w, "User not found", http.StatusNotFound)
+		return
+	}
+
+	codes, err := enableTwoFactor(collection, user, request.Code)
+	if err != nil {
+		http.Error(w, "Error enabling two-factor authentication: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	recordAudit(auditCollection, auditEvent(r, "auth.2fa_enabled", "users", user.ID.Hex()))
+
+	// Текущая сессия тоже считается подтверждённой вторым фактором
+	if _, err := setSessionCookie(w, user, true); err != nil {
+		http.Error(w, "Error generating token", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{"status": "success", "recovery_codes": codes})
+}
+
+// TwoFactorDisableHandler отключает 2FA после проверки пароля и кода.
+// Для ролей, где второй фактор обязателен, отключение запрещено.
+func TwoFactorDisableHandler(w http.ResponseWriter, r *http.Request, collection, auditCollection *mongo.Collection) {
+	if !requireSession(w, r) {
+		return
+	}
+	request, err := decodeTwoFactorRequest(r)
+	if err != nil {
+		http.Error(w, "Invalid JSON message", http.StatusBadRequest)
+		return
+	}
+	user, err := currentUser(r, collection)
+	if err != nil {
+		http.Error(w, "User not found", http.StatusNotFound)
+		return
+	}
+	if !user.TwoFactor.Enabled {
+		http.Error(w, "Two-factor authentication is not enabled", http.StatusBadRequest)
+		return
+	}
+	if utils.TwoFactorRequired(user.Role) {
+		http.Error(w, "Two-factor authentication is required for your role", http.StatusForbidden)
+		return
+	}
+	if bcrypt.CompareHashAndPassword([]byte(user.Password), []byte(request.Password)) != nil {
+		http.Error(w, "Invalid password", http.StatusUnauthorized)
+		return
+	}
+	if _, err := verifySecondFactor(collection, user, request); err != nil {
+		http.Error(w, "Invalid two-factor code", http.StatusUnauthorized)
+		return
+	}
+
+	_, err = collection.UpdateOne(context.TODO(), bson.M{"_id": user.ID}, bson.M{"$unset": bson.M{"two_factor": ""}})
+	if err != nil {
+		http.Error(w, "Error disabling two-factor authentication", http.StatusInternalServerError)
+		return
+	}
+	recordAudit(auditCollection, auditEvent(r, "auth.2fa_disabled", "users", user.ID.Hex()))
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{"status": "success", "message": "Two-factor authentication disabled"})
+}
+
+// RegenerateRecoveryCodesHandler заменяет все коды восстановления новыми после проверки кода из приложения
+func RegenerateRecoveryCodesHandler(w http.ResponseWriter, r *http.Request, collection, auditCollection *mongo.Collection) {
+	if !requireSession(w, r) {
+		return
+	}
+	request, err := decodeTwoFactorRequest(r)
+	if err != nil {
+		http.Error(w, "Invalid JSON message", http.StatusBadRequest)
+		return
+	}
+	user, err := currentUser(r, collection)
+	if err != nil {
+		http.Error(w, "User not found", http.StatusNotFound)
+		return
+	}
+	if !user.TwoFactor.Enabled {
+		http.Error(w, "Two-factor authentication is not enabled", http.StatusBadRequest)
+		return
+	}
+	request.RecoveryCode = ""
+	if _, err := verifySecondFactor(collection, user, request); err != nil {
+		http.Error(w, "Invalid two-factor code", http.StatusUnauthorized)
+		return
+	}
+
+	codes, hashes, err := newRecoveryCodes()
+	if err != nil {
+		http.Error(w, "Error generating recovery codes", http.StatusInternalServerError)
+		return
+	}
+	_, err = collection.UpdateOne(context.TODO(), bson.M{"_id": user.ID}, bson.M{"$set": bson.M{"two_factor.recovery_codes": hashes}})
+	if err != nil {
+		http.Error(w, "Error saving recovery codes", http.StatusInternalServerError)
+		return
+	}
+	recordAudit(auditCollection, auditEvent(r, "auth.2fa_recovery_codes_regenerated", "users", user.ID.Hex()))
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{"status": "success", "recovery_codes": codes})
+}
+
+// AdminResetTwoFactorHandler сбрасывает 2FA пользователя, потерявшего устройство и коды
+// восстановления. При следующем входе пользователь подключит 2FA заново, если она обязательна.
+func AdminResetTwoFactorHandler(w http.ResponseWriter, r *http.Request, collection, auditCollection *mongo.Collection) {
+	if !requireSession(w, r) {
+		return
+	}
+	objID, err := primitive.ObjectIDFromHex(requestID(r))
+	if err != nil {
+		writeResult(w, r, "/admin/users", http.StatusBadRequest, "Invalid user ID")
+		return
+	}
+	editPage := "/admin/users/edit?id=" + objID.Hex()
+	if objID.Hex() == middleware.GetUserID(r) {
+		writeResult(w, r, editPage, http.StatusBadRequest, "You cannot reset your own two-factor authentication")
+		return
+	}
+
+	before, after, err := auditedUpdate(collection, notDeleted(bson.M{"_id": objID}), bson.M{"$unset": bson.M{"two_factor": ""}})
+	if err == mongo.ErrNoDocuments {
+		writeResult(w, r, "/admin/users", http.StatusNotFound, "User not found")
+		return
+	}
+	if err != nil {
+		writeResult(w, r, editPage, http.StatusInternalServerError, "Error resetting two-factor authentication")
+		return
+	}
+
+	event := auditEvent(r, "users.2fa_reset", "users", objID.Hex())
+	event.Changes = auditDiff(before, after)
+	recordAudit(auditCollection, event)
+
+	writeResult(w, r, editPage, http.StatusOK, "Two-factor authentication reset")
+}
@@ -4,55 +4,150 @@ import (
 	"context"
 	"encoding/json"
 	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+	"time"
 
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/bson/primitive"
 	"go.mongodb.org/mongo-driver/mongo"
 	"golang.org/x/crypto/bcrypt"
 
+	"fitnesshub/middleware"
 	"fitnesshub/models"
 )
 
-func UpdateUserProfileHandler(w http.ResponseWriter, r *http.Request, collection *mongo.Collection) {
-	var user models.User
-	err := json.NewDecoder(r.Body).Decode(&user)
+var (
+	phonePattern  = regexp.MustCompile(`^\+?[0-9 ()-]{7,20}$`)
+	localePattern = regexp.MustCompile(`^[a-z]{2}(-[A-Z]{2})?$`)
+	genders       = map[string]bool{"male": true, "female": true, "other": true, "prefer_not_to_say": true}
+)
+
+// profileUpdate описывает поля профиля, которые пользователь может изменить;
+// поля, не переданные в запросе, остаются без изменений
+type profileUpdate struct {
+	FirstName        *string                  `json:"first_name"`
+	LastName         *string                  `json:"last_name"`
+	Phone            *string                  `json:"phone"`
+	DateOfBirth      *string                  `json:"date_of_birth"`
+	Gender           *string                  `json:"gender"`
+	EmergencyContact *models.EmergencyContact `json:"emergency_contact"`
+	FitnessGoals     *[]string                `json:"fitness_goals"`
+	AvatarURL        *string                  `json:"avatar_url"`
+	Locale           *string                  `json:"locale"`
+	Timezone         *string                  `json:"timezone"`
+	Privacy          *models.ProfilePrivacy   `json:"privacy"`
+}
+
+// ProfilePageHandler отображает страницу профиля; данные она загружает сама через /profile
+func ProfilePageHandler(w http.ResponseWriter, r *http.Request) {
+	renderPage(w, r, "templates/profile.html", map[string]interface{}{})
+}
+
+// GetUserProfileHandler возвращает профиль текущего пользователя без служебных полей
+func GetUserProfileHandler(w http.ResponseWriter, r *http.Request, collection *mongo.Collection) {
+	user, err := currentUser(r, collection)
+	if err != nil {
+		http.Error(w, "User not found", http.StatusNotFound)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(profileResponse(user))
+}
+
+// UpdateUserProfileHandler частично обновляет профиль текущего пользователя.
+// Email, роль, пароль и прочие служебные поля этим запросом не меняются.
+func UpdateUserProfileHandler(w http.ResponseWriter, r *http.Request, collection, auditCollection *mongo.Collection) {
+	var update profileUpdate
+	decoder := json.NewDecoder(r.Body)
+	decoder.DisallowUnknownFields()
+	err := decoder.Decode(&update)
 	if err != nil {
 		http.Error(w, "Invalid JSON message", http.StatusBadRequest)
 		return
 	}
 
-	if user.ID.IsZero() {
-		http.Error(w, "User ID is required", http.StatusBadRequest)
+	userID, err := primitive.ObjectIDFromHex(middleware.GetUserID(r))
+	if err != nil {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
 		return
 	}
 
-	filter := bson.M{"_id": user.ID}
-	update := bson.M{"$set": user}
+	fields, msg := update.fields()
+	if msg != "" {
+		http.Error(w, msg, http.StatusBadRequest)
+		return
+	}
+	if len(fields) == 0 {
+		http.Error(w, "No profile fields to update", http.StatusBadRequest)
+		return
+	}
 
-	_, err = collection.UpdateOne(context.TODO(), filter, update)
+	before, after, err := auditedUpdate(collection, notDeleted(bson.M{"_id": userID}), bson.M{"$set": fields})
+	if err == mongo.ErrNoDocuments {
+		http.Error(w, "User not found", http.StatusNotFound)
+		return
+	}
 	if err != nil {
 		http.Error(w, "Error updating user profile", http.StatusInternalServerError)
 		return
 	}
 
+	event := auditEvent(r, "users.profile_update", "users", userID.Hex())
+	event.Changes = auditDiff(before, after)
+	recordAudit(auditCollection, event)
+
+	var user models.User
+	data, _ := bson.Marshal(after)
+	bson.Unmarshal(data, &user)
+
 	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(map[string]string{"status": "success", "message": "User profile updated successfully"})
+	json.NewEncoder(w).Encode(profileResponse(user))
 }
 
-func ChangeUserPasswordHandler(w http.ResponseWriter, r *http.Request, collection *mongo.Collection) {
-	var credentials struct {
-		UserID      primitive.ObjectID `json:"user_id"`
-		OldPassword string             `json:"old_password"`
-		NewPassword string             `json:"new_password"`
+// GetClientProfileHandler возвращает тренеру профиль закреплённого за ним участника (?user_id=)
+// с учётом настроек приватности
+func GetClientProfileHandler(w http.ResponseWriter, r *http.Request, collection *mongo.Collection) {
+	clientID, err := primitive.ObjectIDFromHex(routeParam(r, "user_id"))
+	if err != nil {
+		http.Error(w, "Invalid user ID", http.StatusBadRequest)
+		return
+	}
+
+	trainerID, err := primitive.ObjectIDFromHex(middleware.GetUserID(r))
+	if err != nil {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	var user models.User
+	err = collection.FindOne(context.TODO(), notDeleted(bson.M{"_id": clientID, "trainer_id": trainerID})).Decode(&user)
+	if err != nil {
+		http.Error(w, "Client not found", http.StatusNotFound)
+		return
 	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{"id": user.ID, "email": user.Email, "profile": user.Profile.ForTrainer()})
+}
+
+// passwordChangeRequest — текущий и новый пароль для смены пароля
+type passwordChangeRequest struct {
+	OldPassword string `json:"old_password"`
+	NewPassword string `json:"new_password"`
+}
+
+func ChangeUserPasswordHandler(w http.ResponseWriter, r *http.Request, collection, auditCollection *mongo.Collection) {
+	var credentials passwordChangeRequest
 	err := json.NewDecoder(r.Body).Decode(&credentials)
 	if err != nil {
 		http.Error(w, "Invalid JSON message", http.StatusBadRequest)
 		return
 	}
 
-	var user models.User
-	err = collection.FindOne(context.TODO(), bson.M{"_id": credentials.UserID}).Decode(&user)
+	user, err := currentUser(r, collection)
 	if err != nil {
 		http.Error(w, "User not found", http.StatusNotFound)
 		return
@@ -70,7 +165,7 @@ func ChangeUserPasswordHandler(w http.ResponseWriter, r *http.Request, collectio
 		return
 	}
 
-	filter := bson.M{"_id": credentials.UserID}
+	filter := bson.M{"_id": user.ID}
 	update := bson.M{"$set": bson.M{"password": string(hashedPassword)}}
 
 	_, err = collection.UpdateOne(context.TODO(), filter, update)
@@ -79,6 +174,132 @@ func ChangeUserPasswordHandler(w http.ResponseWriter, r *http.Request, collectio
 		return
 	}
 
+	event := auditEvent(r, "auth.password_change", "users", user.ID.Hex())
+	event.Changes = map[string]models.AuditChange{"password": {Before: redactAuditValue("-"), After: redactAuditValue("-")}}
+	recordAudit(auditCollection, event)
+
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(map[string]string{"status": "success", "message": "Password changed successfully"})
 }
+
+// profileView — поля профиля, которые безопасно показывать владельцу
+type profileView struct {
+	ID                primitive.ObjectID       `json:"id"`
+	Email             string                   `json:"email"`
+	Verified          bool                     `json:"verified"`
+	Role              string                   `json:"role"`
+	MembershipExpires *time.Time               `json:"membership_expires"`
+	TrainerID         primitive.ObjectID       `json:"trainer_id"`
+	Units             models.UnitPreferences   `json:"units"`
+	Nutrition         models.NutritionSettings `json:"nutrition"`
+	Profile           models.Profile           `json:"profile"`
+}
+
+// profileResponse оставляет в ответе только поля, которые безопасно показывать владельцу
+func profileResponse(user models.User) profileView {
+	return profileView{
+		ID:                user.ID,
+		Email:             user.Email,
+		Verified:          user.Verified,
+		Role:              user.Role,
+		MembershipExpires: user.MembershipExpires,
+		TrainerID:         user.TrainerID,
+		Units:             user.PreferredUnits(),
+		Nutrition:         user.Nutrition,
+		Profile:           user.Profile,
+	}
+}
+
+// fields проверяет изменения и возвращает их в виде полей для $set
+func (u profileUpdate) fields() (bson.M, string) {
+	fields := bson.M{}
+
+	for name, value := range map[string]*string{"first_name": u.FirstName, "last_name": u.LastName} {
+		if value == nil {
+			continue
+		}
+		trimmed := strings.TrimSpace(*value)
+		if len(trimmed) > 100 {
+			return nil, "Name must be at most 100 characters"
+		}
+		fields["profile."+name] = trimmed
+	}
+
+	if u.Phone != nil {
+		if *u.Phone != "" && !phonePattern.MatchString(*u.Phone) {
+			return nil, "Invalid phone number"
+		}
+		fields["profile.phone"] = *u.Phone
+	}
+
+	if u.DateOfBirth != nil {
+		if *u.DateOfBirth == "" {
+			fields["profile.date_of_birth"] = nil
+		} else {
+			date, err := time.Parse("2006-01-02", *u.DateOfBirth)
+			if err != nil || date.After(time.Now()) || date.Year() < 1900 {
+				return nil, "Invalid date_of_birth (2006-01-02)"
+			}
+			fields["profile.date_of_birth"] = date
+		}
+	}
+
+	if u.Gender != nil {
+		if *u.Gender != "" && !genders[*u.Gender] {
+			return nil, "Gender must be male, female, other or prefer_not_to_say"
+		}
+		fields["profile.gender"] = *u.Gender
+	}
+
+	if u.EmergencyContact != nil {
+		if u.EmergencyContact.Phone != "" && !phonePattern.MatchString(u.EmergencyContact.Phone) {
+			return nil, "Invalid emergency contact phone number"
+		}
+		if len(u.EmergencyContact.Name) > 100 || len(u.EmergencyContact.Relationship) > 50 {
+			return nil, "Emergency contact fields are too long"
+		}
+		fields["profile.emergency_contact"] = u.EmergencyContact
+	}
+
+	if u.FitnessGoals != nil {
+		if len(*u.FitnessGoals) > 10 {
+			return nil, "At most 10 fitness goals are allowed"
+		}
+		for _, goal := range *u.FitnessGoals {
+			if goal == "" || len(goal) > 100 {
+				return nil, "Fitness goals must be 1 to 100 characters"
+			}
+		}
+		fields["profile.fitness_goals"] = *u.FitnessGoals
+	}
+
+	if u.AvatarURL != nil {
+		if *u.AvatarURL != "" {
+			parsed, err := url.Parse(*u.AvatarURL)
+			if err != nil || (parsed.Scheme != "https" && parsed.Scheme != "http") || parsed.Host == "" {
+				return nil, "Avatar URL must be an http(s) URL"
+			}
+		}
+		fields["profile.avatar_url"] = *u.AvatarURL
+	}
+
+	if u.Locale != nil {
+		if *u.Locale != "" && !localePattern.MatchString(*u.Locale) {
+			return nil, "Locale must look like en or en-US"
+		}
+		fields["profile.locale"] = *u.Locale
+	}
+
+	if u.Timezone != nil {
+		if _, err := time.LoadLocation(*u.Timezone); err != nil || *u.Timezone == "Local" {
+			return nil, "Invalid timezone"
+		}
+		fields["profile.timezone"] = *u.Timezone
+	}
+
+	if u.Privacy != nil {
+		fields["profile.privacy"] = u.Privacy
+	}
+
+	return fields, ""
+}
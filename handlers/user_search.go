@@ -0,0 +1,310 @@
+package handlers
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+
+	"fitnesshub/models"
+)
+
+const (
+	DefaultUserPageSize = 25
+	MaxUserPageSize     = 100
+)
+
+// Варианты сортировки списка пользователей: поле и направление
+var userSortOptions = map[string]struct {
+	Field     string
+	Direction int
+}{
+	"newest":     {"_id", -1},
+	"oldest":     {"_id", 1},
+	"email":      {"email", 1},
+	"-email":     {"email", -1},
+	"last_name":  {"profile.last_name", 1},
+	"-last_name": {"profile.last_name", -1},
+}
+
+// UserQuery — параметры поиска пользователей в административной панели
+type UserQuery struct {
+	Search     string
+	Role       string
+	Verified   string
+	Membership string
+	Sort       string
+	Cursor     string
+	Limit      int
+}
+
+// errInvalidCursor — параметр cursor повреждён или получен не от SearchUsers
+var errInvalidCursor = errors.New("invalid cursor")
+
+// userCursor указывает на последнего пользователя предыдущей страницы
+type userCursor struct {
+	Value string             `json:"v,omitempty"`
+	ID    primitive.ObjectID `json:"id"`
+}
+
+// ParseUserQuery читает параметры q, role, verified, membership, sort, cursor и limit
+func ParseUserQuery(values url.Values) UserQuery {
+	query := UserQuery{
+		Search:     strings.TrimSpace(values.Get("q")),
+		Role:       values.Get("role"),
+		Verified:   values.Get("verified"),
+		Membership: values.Get("membership"),
+		Sort:       values.Get("sort"),
+		Cursor:     values.Get("cursor"),
+	}
+	if _, ok := userSortOptions[query.Sort]; !ok {
+		query.Sort = "newest"
+	}
+	query.Limit, _ = strconv.Atoi(values.Get("limit"))
+	if query.Limit <= 0 || query.Limit > MaxUserPageSize {
+		query.Limit = DefaultUserPageSize
+	}
+	return query
+}
+
+// Values возвращает параметры запроса без курсора — для ссылок на страницы
+func (q UserQuery) Values() url.Values {
+	values := url.Values{}
+	for key, value := range map[string]string{
+		"q": q.Search, "role": q.Role, "verified": q.Verified, "membership": q.Membership, "sort": q.Sort,
+	} {
+		if value != "" {
+			values.Set(key, value)
+		}
+	}
+	if q.Limit != DefaultUserPageSize {
+		values.Set("limit", strconv.Itoa(q.Limit))
+	}
+	return values
+}
+
+// Filter строит фильтр MongoDB по условиям поиска без учёта курсора
+func (q UserQuery) Filter() bson.M {
+	filter := notDeleted(bson.M{})
+	if q.Search != "" {
+		pattern := bson.M{"$regex": regexp.QuoteMeta(q.Search), "$options": "i"}
+		filter["$or"] = bson.A{
+			bson.M{"email": pattern},
+			bson.M{"profile.first_name": pattern},
+			bson.M{"profile.last_name": pattern},
+		}
+	}
+	if q.Role != "" {
+		filter["role"] = q.Role
+	}
+	switch q.Verified {
+	case "true":
+		filter["verified"] = true
+	case "false":
+		filter["verified"] = false
+	}
+	switch q.Membership {
+	case "active":
+		filter["membership_expires"] = bson.M{"$gt": time.Now()}
+	case "expired":
+		filter["membership_expires"] = bson.M{"$lte": time.Now()}
+	case "none":
+		filter["membership_expires"] = bson.M{"$exists": false}
+	}
+	return filter
+}
+
+// SearchUsers возвращает страницу пользователей и курсор следующей страницы (пустой, если это последняя)
+func SearchUsers(collection *mongo.Collection, query UserQuery) ([]models.User, string, error) {
+	sortOption := userSortOptions[query.Sort]
+	filter := query.Filter()
+
+	if query.Cursor != "" {
+		cursor, err := decodeUserCursor(query.Cursor)
+		if err != nil {
+			return nil, "", err
+		}
+		op := "$gt"
+		if sortOption.Direction < 0 {
+			op = "$lt"
+		}
+		var after bson.M
+		if sortOption.Field == "_id" {
+			after = bson.M{"_id": bson.M{op: cursor.ID}}
+		} else {
+			after = bson.M{"$or": bson.A{
+				bson.M{sortOption.Field: bson.M{op: cursor.Value}},
+				bson.M{sortOption.Field: cursor.Value, "_id": bson.M{op: cursor.ID}},
+			}}
+		}
+		filter = bson.M{"$and": bson.A{filter, after}}
+	}
+
+	sort := bson.D{{Key: sortOption.Field, Value: sortOption.Direction}}
+	if sortOption.Field != "_id" {
+		sort = append(sort, bson.E{Key: "_id", Value: sortOption.Direction})
+	}
+	findOptions := options.Find().
+		SetSort(sort).
+		SetLimit(int64(query.Limit + 1)).
+		SetProjection(bson.M{"password": 0, "verification_token": 0})
+
+	users := []models.User{}
+	cursor, err := collection.Find(context.TODO(), filter, findOptions)
+	if err != nil {
+		return nil, "", err
+	}
+	defer cursor.Close(context.TODO())
+	if err = cursor.All(context.TODO(), &users); err != nil {
+		return nil, "", err
+	}
+
+	next := ""
+	if len(users) > query.Limit {
+		users = users[:query.Limit]
+		last := users[len(users)-1]
+		value := ""
+		switch sortOption.Field {
+		case "email":
+			value = last.Email
+		case "profile.last_name":
+			value = last.Profile.LastName
+		}
+		next = encodeUserCursor(userCursor{Value: value, ID: last.ID})
+	}
+	return users, next, nil
+}
+
+func encodeUserCursor(cursor userCursor) string {
+	data, _ := json.Marshal(cursor)
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+func decodeUserCursor(value string) (userCursor, error) {
+	var cursor userCursor
+	data, err := base64.RawURLEncoding.DecodeString(value)
+	if err != nil {
+		return cursor, errInvalidCursor
+	}
+	if err = json.Unmarshal(data, &cursor); err != nil || cursor.ID.IsZero() {
+		return cursor, errInvalidCursor
+	}
+	return cursor, nil
+}
+
+// wantsJSON сообщает, что клиент запросил JSON вместо HTML-страницы; /api/v1 всегда отвечает JSON
+func wantsJSON(r *http.Request) bool {
+	return strings.HasPrefix(r.URL.Path, "/api/v1/") || strings.Contains(r.Header.Get("Accept"), "application/json") || r.URL.Query().Get("format") == "json"
+}
+
+// emailCollision — активные учётные записи, email которых совпадает после normalizeEmail
+type emailCollision struct {
+	Email  string               `bson:"_id" json:"email"`
+	IDs    []primitive.ObjectID `bson:"ids" json:"ids"`
+	Emails []string             `bson:"emails" json:"emails"`
+}
+
+// normalizedEmailExpr — normalizeEmail в виде выражения агрегации
+var normalizedEmailExpr = bson.M{"$toLower": bson.M{"$trim": bson.M{"input": "$email"}}}
+
+// findEmailCollisions находит активные учётные записи, которые после нормализации email
+// получили бы одинаковый адрес
+func findEmailCollisions(collection *mongo.Collection) ([]emailCollision, error) {
+	cursor, err := collection.Aggregate(context.TODO(), mongo.Pipeline{
+		{{Key: "$match", Value: notDeleted(bson.M{})}},
+		{{Key: "$group", Value: bson.M{
+			"_id":    normalizedEmailExpr,
+			"ids":    bson.M{"$push": "$_id"},
+			"emails": bson.M{"$push": "$email"},
+		}}},
+		{{Key: "$match", Value: bson.M{"ids.1": bson.M{"$exists": true}}}},
+		{{Key: "$sort", Value: bson.M{"_id": 1}}},
+	})
+	if err != nil {
+		return nil, err
+	}
+	collisions := []emailCollision{}
+	err = cursor.All(context.TODO(), &collisions)
+	return collisions, err
+}
+
+// EnsureUserIndexes создаёт индексы для поиска и сортировки пользователей и уникальный индекс
+// по email активных учётных записей. Адреса, сохранённые до нормализации, сначала приводятся
+// к виду normalizeEmail. Если после этого адреса совпали бы, такие записи не трогаются,
+// уникальный индекс не создаётся, а конфликты выводятся в журнал: администратор устраняет их,
+// изменив email или переместив лишнюю запись в корзину (список — GET /admin/users/email-conflicts),
+// и индекс создаётся при следующем запуске.
+func EnsureUserIndexes(collection *mongo.Collection) error {
+	collisions, err := findEmailCollisions(collection)
+	if err != nil {
+		return err
+	}
+	conflicting := bson.A{}
+	for _, collision := range collisions {
+		conflicting = append(conflicting, collision.Email)
+		log.Printf("Email conflict: accounts %v share %q (%s)", collision.IDs, collision.Email, strings.Join(collision.Emails, ", "))
+	}
+
+	_, err = collection.UpdateMany(context.TODO(),
+		bson.M{"$expr": bson.M{"$and": bson.A{
+			bson.M{"$ne": bson.A{"$email", normalizedEmailExpr}},
+			bson.M{"$not": bson.A{bson.M{"$in": bson.A{normalizedEmailExpr, conflicting}}}},
+		}}},
+		mongo.Pipeline{{{Key: "$set", Value: bson.M{"email": normalizedEmailExpr}}}})
+	if err != nil {
+		return err
+	}
+
+	// У записей, созданных до появления профиля, нет profile.last_name: курсор сортировки
+	// по фамилии такие записи не находит, поэтому заполняем поле пустой строкой
+	_, err = collection.UpdateMany(context.TODO(),
+		bson.M{"profile.last_name": bson.M{"$exists": false}},
+		mongo.Pipeline{{{Key: "$set", Value: bson.M{"profile.last_name": ""}}}})
+	if err != nil {
+		return err
+	}
+
+	indexes := []mongo.IndexModel{
+		{Keys: bson.D{{Key: "email", Value: 1}, {Key: "_id", Value: 1}}},
+		{Keys: bson.D{{Key: "profile.last_name", Value: 1}, {Key: "_id", Value: 1}}},
+		{Keys: bson.D{{Key: "role", Value: 1}}},
+		{Keys: bson.D{{Key: "membership_expires", Value: 1}}},
+		{Keys: bson.D{{Key: "deleted_at", Value: 1}}},
+	}
+	if len(collisions) == 0 {
+		// Email из корзины не мешает новой регистрации с тем же адресом
+		indexes = append(indexes, mongo.IndexModel{
+			Keys:    bson.D{{Key: "email", Value: 1}},
+			Options: options.Index().SetUnique(true).SetPartialFilterExpression(bson.M{"deleted_at": nil}),
+		})
+	} else {
+		log.Printf("Unique email index not created: resolve %d email conflicts and restart", len(collisions))
+	}
+	_, err = collection.Indexes().CreateMany(context.TODO(), indexes)
+	return err
+}
+
+// AdminEmailConflictsHandler возвращает активные учётные записи, email которых совпадают
+// без учёта регистра и мешают созданию уникального индекса
+func AdminEmailConflictsHandler(w http.ResponseWriter, r *http.Request, collection *mongo.Collection) {
+	collisions, err := findEmailCollisions(collection)
+	if err != nil {
+		http.Error(w, "Error fetching users", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{"conflicts": collisions})
+}
@@ -0,0 +1,151 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"github.com/skip2/go-qrcode"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+
+	"fitnesshub/middleware"
+	"fitnesshub/models"
+	"fitnesshub/utils"
+)
+
+// Повторное сканирование в течение CheckInDedupWindow не создаёт новый визит
+const CheckInDedupWindow = 5 * time.Minute
+
+// GetMemberPassHandler возвращает текущую строку пропуска для QR-кода
+func GetMemberPassHandler(w http.ResponseWriter, r *http.Request) {
+	payload, expiresAt := utils.GenerateMemberPass(middleware.GetUserID(r), time.Now())
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	json.NewEncoder(w).Encode(map[string]interface{}{"payload": payload, "expires_at": expiresAt})
+}
+
+// GetMemberPassQRHandler возвращает QR-код текущего пропуска в формате PNG
+func GetMemberPassQRHandler(w http.ResponseWriter, r *http.Request) {
+	payload, _ := utils.GenerateMemberPass(middleware.GetUserID(r), time.Now())
+
+	png, err := qrcode.Encode(payload, qrcode.Medium, 256)
+	if err != nil {
+		http.Error(w, "Error generating QR code", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "image/png")
+	w.Header().Set("Cache-Control", "no-store")
+	w.Write(png)
+}
+
+// checkInRequest — содержимое QR-кода пропуска участника
+type checkInRequest struct {
+	Payload string `json:"payload"`
+}
+
+// CheckInHandler проверяет отсканированный пропуск и абонемент участника и регистрирует визит
+func CheckInHandler(w http.ResponseWriter, r *http.Request, userCollection, visitCollection *mongo.Collection) {
+	var request checkInRequest
+	err := json.NewDecoder(r.Body).Decode(&request)
+	if err != nil {
+		http.Error(w, "Invalid JSON message", http.StatusBadRequest)
+		return
+	}
+
+	userID, err := utils.VerifyMemberPass(request.Payload, time.Now())
+	if err != nil {
+		http.Error(w, "Invalid pass: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	objID, err := primitive.ObjectIDFromHex(userID)
+	if err != nil {
+		http.Error(w, "Invalid pass", http.StatusBadRequest)
+		return
+	}
+
+	var user models.User
+	err = userCollection.FindOne(context.TODO(), notDeleted(bson.M{"_id": objID})).Decode(&user)
+	if err != nil {
+		http.Error(w, "Member not found", http.StatusNotFound)
+		return
+	}
+
+	if !user.HasActiveMembership(time.Now()) {
+		http.Error(w, "Membership is not active", http.StatusForbidden)
+		return
+	}
+
+	staffID, _ := primitive.ObjectIDFromHex(middleware.GetUserID(r))
+	now := time.Now()
+	visit := models.Visit{UserID: objID, CheckedInAt: now, CheckedInBy: staffID}
+
+	// Если участник уже отмечен несколько минут назад, возвращаем существующий визит
+	err = visitCollection.FindOne(context.TODO(),
+		bson.M{"user_id": objID, "checked_in_at": bson.M{"$gte": now.Add(-CheckInDedupWindow)}}).Decode(&visit)
+	if err == mongo.ErrNoDocuments {
+		result, err := visitCollection.InsertOne(context.TODO(), visit)
+		if err != nil {
+			http.Error(w, "Error recording visit", http.StatusInternalServerError)
+			return
+		}
+		visit.ID = result.InsertedID.(primitive.ObjectID)
+	} else if err != nil {
+		http.Error(w, "Error recording visit", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"status":             "success",
+		"email":              user.Email,
+		"membership_expires": user.MembershipExpires,
+		"visit":              visit,
+	})
+}
+
+// OccupancyHandler возвращает количество визитов за текущий час
+func OccupancyHandler(w http.ResponseWriter, r *http.Request, collection *mongo.Collection) {
+	hourStart := time.Now().Truncate(time.Hour)
+
+	count, err := collection.CountDocuments(context.TODO(), bson.M{"checked_in_at": bson.M{"$gte": hourStart}})
+	if err != nil {
+		http.Error(w, "Error counting visits", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{"hour_start": hourStart, "visits": count})
+}
+
+// GetMyVisitsHandler возвращает историю посещений текущего пользователя
+func GetMyVisitsHandler(w http.ResponseWriter, r *http.Request, collection *mongo.Collection) {
+	userID, err := primitive.ObjectIDFromHex(middleware.GetUserID(r))
+	if err != nil {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	var visits []models.Visit
+	cursor, err := collection.Find(context.TODO(), bson.M{"user_id": userID}, options.Find().SetSort(bson.D{{Key: "checked_in_at", Value: -1}}))
+	if err != nil {
+		http.Error(w, "Error fetching visits", http.StatusInternalServerError)
+		return
+	}
+	defer cursor.Close(context.TODO())
+
+	for cursor.Next(context.TODO()) {
+		var visit models.Visit
+		cursor.Decode(&visit)
+		visits = append(visits, visit)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(visits)
+}
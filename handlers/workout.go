@@ -0,0 +1,388 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"regexp"
+	"strconv"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+
+	"fitnesshub/middleware"
+	"fitnesshub/models"
+	"fitnesshub/utils"
+)
+
+func CreateExerciseHandler(w http.ResponseWriter, r *http.Request, collection, auditCollection *mongo.Collection) {
+	var exercise models.Exercise
+	err := json.NewDecoder(r.Body).Decode(&exercise)
+	if err != nil {
+		http.Error(w, "Invalid JSON message", http.StatusBadRequest)
+		return
+	}
+
+	if exercise.Name == "" {
+		http.Error(w, "Name is required", http.StatusBadRequest)
+		return
+	}
+	exercise.CreatedBy, _ = primitive.ObjectIDFromHex(middleware.GetUserID(r))
+
+	result, err := collection.InsertOne(context.TODO(), exercise)
+	if err != nil {
+		http.Error(w, "Error adding exercise", http.StatusInternalServerError)
+		return
+	}
+	exercise.ID = result.InsertedID.(primitive.ObjectID)
+
+	event := auditEvent(r, "exercises.create", "exercises", exercise.ID.Hex())
+	event.Changes = auditDiff(nil, toAuditDoc(exercise))
+	recordAudit(auditCollection, event)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{"status": "success", "message": "Exercise added successfully"})
+}
+
+// GetAllExercisesHandler возвращает библиотеку упражнений с фильтрами ?q=, ?muscle= и ?equipment=
+func GetAllExercisesHandler(w http.ResponseWriter, r *http.Request, collection *mongo.Collection) {
+	filter := bson.M{}
+	if q := r.URL.Query().Get("q"); q != "" {
+		filter["name"] = bson.M{"$regex": regexp.QuoteMeta(q), "$options": "i"}
+	}
+	if muscle := r.URL.Query().Get("muscle"); muscle != "" {
+		filter["muscle_groups"] = muscle
+	}
+	if equipment := r.URL.Query().Get("equipment"); equipment != "" {
+		filter["equipment"] = equipment
+	}
+
+	var exercises []models.Exercise
+	cursor, err := collection.Find(context.TODO(), filter, options.Find().SetSort(bson.D{{Key: "name", Value: 1}}))
+	if err != nil {
+		http.Error(w, "Error fetching exercises", http.StatusInternalServerError)
+		return
+	}
+	defer cursor.Close(context.TODO())
+
+	for cursor.Next(context.TODO()) {
+		var exercise models.Exercise
+		cursor.Decode(&exercise)
+		exercises = append(exercises, exercise)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(exercises)
+}
+
+func UpdateExerciseByIDHandler(w http.ResponseWriter, r *http.Request, collection, auditCollection *mongo.Collection) {
+	var exercise models.Exercise
+	err := json.NewDecoder(r.Body).Decode(&exercise)
+	if err != nil {
+		http.Error(w, "Invalid JSON message", http.StatusBadRequest)
+		return
+	}
+
+	pathObjectID(r, &exercise.ID)
+	if exercise.ID.IsZero() {
+		http.Error(w, "Exercise ID is required", http.StatusBadRequest)
+		return
+	}
+
+	update := bson.M{"$set": bson.M{
+		"name":          exercise.Name,
+		"muscle_groups": exercise.MuscleGroups,
+		"equipment":     exercise.Equipment,
+		"instructions":  exercise.Instructions,
+	}}
+	before, after, err := auditedUpdate(collection, bson.M{"_id": exercise.ID}, update)
+	if err == mongo.ErrNoDocuments {
+		http.Error(w, "Exercise not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		http.Error(w, "Error updating exercise", http.StatusInternalServerError)
+		return
+	}
+
+	event := auditEvent(r, "exercises.update", "exercises", exercise.ID.Hex())
+	event.Changes = auditDiff(before, after)
+	recordAudit(auditCollection, event)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{"status": "success", "message": "Exercise updated successfully"})
+}
+
+func DeleteExerciseByIDHandler(w http.ResponseWriter, r *http.Request, collection, auditCollection *mongo.Collection) {
+	objID, err := primitive.ObjectIDFromHex(routeParam(r, "id"))
+	if err != nil {
+		http.Error(w, "Invalid exercise ID", http.StatusBadRequest)
+		return
+	}
+
+	before, err := auditedDelete(collection, bson.M{"_id": objID})
+	if err == mongo.ErrNoDocuments {
+		http.Error(w, "Exercise not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		http.Error(w, "Error deleting exercise", http.StatusInternalServerError)
+		return
+	}
+
+	event := auditEvent(r, "exercises.delete", "exercises", objID.Hex())
+	event.Changes = auditDiff(before, nil)
+	recordAudit(auditCollection, event)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{"status": "success", "message": "Exercise deleted successfully"})
+}
+
+// LogWorkoutHandler сохраняет тренировку текущего пользователя
+func LogWorkoutHandler(w http.ResponseWriter, r *http.Request, workoutCollection, exerciseCollection *mongo.Collection) {
+	var workout models.Workout
+	err := json.NewDecoder(r.Body).Decode(&workout)
+	if err != nil {
+		http.Error(w, "Invalid JSON message", http.StatusBadRequest)
+		return
+	}
+
+	userID, err := primitive.ObjectIDFromHex(middleware.GetUserID(r))
+	if err != nil {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	if len(workout.Entries) == 0 {
+		http.Error(w, "At least one exercise entry is required", http.StatusBadRequest)
+		return
+	}
+	if workout.DurationMinutes < 0 {
+		http.Error(w, "duration_minutes cannot be negative", http.StatusBadRequest)
+		return
+	}
+
+	exerciseIDs := bson.A{}
+	for _, entry := range workout.Entries {
+		exerciseIDs = append(exerciseIDs, entry.ExerciseID)
+		for _, set := range entry.Sets {
+			if set.Reps < 0 || set.Weight < 0 || set.DurationSeconds < 0 {
+				http.Error(w, "Reps, weight and duration cannot be negative", http.StatusBadRequest)
+				return
+			}
+			if set.RPE != 0 && (set.RPE < 1 || set.RPE > 10) {
+				http.Error(w, "RPE must be between 1 and 10", http.StatusBadRequest)
+				return
+			}
+		}
+	}
+
+	known, err := exerciseCollection.CountDocuments(context.TODO(), bson.M{"_id": bson.M{"$in": exerciseIDs}})
+	if err != nil {
+		http.Error(w, "Error checking exercises", http.StatusInternalServerError)
+		return
+	}
+	if int(known) != len(uniqueObjectIDs(exerciseIDs)) {
+		http.Error(w, "Unknown exercise in workout", http.StatusBadRequest)
+		return
+	}
+
+	workout.ID = primitive.NilObjectID
+	workout.UserID = userID
+	if workout.PerformedAt.IsZero() {
+		workout.PerformedAt = time.Now()
+	}
+
+	result, err := workoutCollection.InsertOne(context.TODO(), workout)
+	if err != nil {
+		http.Error(w, "Error saving workout", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{"status": "success", "message": "Workout logged successfully", "id": result.InsertedID})
+}
+
+// GetWorkoutHistoryHandler возвращает тренировки текущего пользователя за период ?from= и ?to= (2006-01-02)
+func GetWorkoutHistoryHandler(w http.ResponseWriter, r *http.Request, collection *mongo.Collection) {
+	userID, err := primitive.ObjectIDFromHex(middleware.GetUserID(r))
+	if err != nil {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	filter := bson.M{"user_id": userID}
+	performedAt := bson.M{}
+	if from := r.URL.Query().Get("from"); from != "" {
+		date, err := time.ParseInLocation("2006-01-02", from, time.Local)
+		if err != nil {
+			http.Error(w, "Invalid from date", http.StatusBadRequest)
+			return
+		}
+		performedAt["$gte"] = date
+	}
+	if to := r.URL.Query().Get("to"); to != "" {
+		date, err := time.ParseInLocation("2006-01-02", to, time.Local)
+		if err != nil {
+			http.Error(w, "Invalid to date", http.StatusBadRequest)
+			return
+		}
+		performedAt["$lt"] = date.AddDate(0, 0, 1)
+	}
+	if len(performedAt) > 0 {
+		filter["performed_at"] = performedAt
+	}
+
+	workouts, err := findWorkouts(collection, filter)
+	if err != nil {
+		http.Error(w, "Error fetching workouts", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(workouts)
+}
+
+func DeleteWorkoutByIDHandler(w http.ResponseWriter, r *http.Request, collection *mongo.Collection) {
+	objID, err := primitive.ObjectIDFromHex(routeParam(r, "id"))
+	if err != nil {
+		http.Error(w, "Invalid workout ID", http.StatusBadRequest)
+		return
+	}
+
+	userID, err := primitive.ObjectIDFromHex(middleware.GetUserID(r))
+	if err != nil {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	result, err := collection.DeleteOne(context.TODO(), bson.M{"_id": objID, "user_id": userID})
+	if err != nil {
+		http.Error(w, "Error deleting workout", http.StatusInternalServerError)
+		return
+	}
+	if result.DeletedCount == 0 {
+		http.Error(w, "Workout not found", http.StatusNotFound)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{"status": "success", "message": "Workout deleted successfully"})
+}
+
+// GetPersonalRecordsHandler возвращает личные рекорды текущего пользователя по каждому упражнению
+func GetPersonalRecordsHandler(w http.ResponseWriter, r *http.Request, collection *mongo.Collection) {
+	userID, err := primitive.ObjectIDFromHex(middleware.GetUserID(r))
+	if err != nil {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	workouts, err := findWorkouts(collection, bson.M{"user_id": userID})
+	if err != nil {
+		http.Error(w, "Error fetching workouts", http.StatusInternalServerError)
+		return
+	}
+
+	records := personalRecords(workouts)
+	result := make([]models.PersonalRecord, 0, len(records))
+	for _, record := range records {
+		result = append(result, *record)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(result)
+}
+
+// GetWeeklyVolumeHandler возвращает недельную нагрузку за последние ?weeks= недель (по умолчанию 8)
+func GetWeeklyVolumeHandler(w http.ResponseWriter, r *http.Request, collection *mongo.Collection) {
+	userID, err := primitive.ObjectIDFromHex(middleware.GetUserID(r))
+	if err != nil {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	weeks, err := strconv.Atoi(r.URL.Query().Get("weeks"))
+	if err != nil || weeks <= 0 {
+		weeks = 8
+	}
+
+	firstWeek := utils.StartOfWeek(time.Now()).AddDate(0, 0, -7*(weeks-1))
+	workouts, err := findWorkouts(collection, bson.M{"user_id": userID, "performed_at": bson.M{"$gte": firstWeek}})
+	if err != nil {
+		http.Error(w, "Error fetching workouts", http.StatusInternalServerError)
+		return
+	}
+
+	summary := make([]models.WeeklyVolume, weeks)
+	for i := range summary {
+		summary[i].WeekStart = firstWeek.AddDate(0, 0, 7*i).Format("2006-01-02")
+	}
+	for _, workout := range workouts {
+		week := int(utils.StartOfWeek(workout.PerformedAt.In(time.Local)).Sub(firstWeek).Hours()+12) / (24 * 7)
+		if week < 0 || week >= weeks {
+			continue
+		}
+		summary[week].Workouts++
+		summary[week].DurationMinutes += workout.DurationMinutes
+		for _, entry := range workout.Entries {
+			for _, set := range entry.Sets {
+				summary[week].Sets++
+				summary[week].Reps += set.Reps
+				summary[week].Volume += float64(set.Reps) * set.Weight
+			}
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(summary)
+}
+
+// personalRecords вычисляет лучшие результаты по каждому упражнению
+func personalRecords(workouts []models.Workout) map[primitive.ObjectID]*models.PersonalRecord {
+	records := map[primitive.ObjectID]*models.PersonalRecord{}
+	for _, workout := range workouts {
+		for _, entry := range workout.Entries {
+			record, ok := records[entry.ExerciseID]
+			if !ok {
+				record = &models.PersonalRecord{ExerciseID: entry.ExerciseID}
+				records[entry.ExerciseID] = record
+			}
+			for _, set := range entry.Sets {
+				if set.Weight > record.MaxWeight {
+					record.MaxWeight, record.MaxWeightAt = set.Weight, workout.PerformedAt
+				}
+				if set.Reps > record.MaxReps {
+					record.MaxReps, record.MaxRepsAt = set.Reps, workout.PerformedAt
+				}
+				if oneRM := utils.EstimateOneRepMax(set.Weight, set.Reps); oneRM > record.EstimatedOneRM {
+					record.EstimatedOneRM, record.EstimatedOneRMAt = oneRM, workout.PerformedAt
+				}
+			}
+		}
+	}
+	return records
+}
+
+func findWorkouts(collection *mongo.Collection, filter bson.M) ([]models.Workout, error) {
+	workouts := []models.Workout{}
+	cursor, err := collection.Find(context.TODO(), filter, options.Find().SetSort(bson.D{{Key: "performed_at", Value: -1}}))
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(context.TODO())
+
+	err = cursor.All(context.TODO(), &workouts)
+	return workouts, err
+}
+
+func uniqueObjectIDs(ids bson.A) map[interface{}]bool {
+	unique := make(map[interface{}]bool, len(ids))
+	for _, id := range ids {
+		unique[id] = true
+	}
+	return unique
+}
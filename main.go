@@ -2,16 +2,24 @@ package main
 
 import (
 	"context"
-	"html/template"
 	"log"
 	"net/http"
+	"os"
+	"strings"
+	"time"
 
 	"fitnesshub/db"
 	"fitnesshub/handlers"
 	"fitnesshub/middleware"
+	"fitnesshub/utils"
 )
 
 func main() {
+	// Без заданных секретов пропуска и зашифрованные данные были бы защищены общеизвестным ключом
+	if err := utils.CheckSecrets(); err != nil {
+		log.Fatal(err)
+	}
+
 	// Подключение к MongoDB
 	client, err := db.ConnectToMongoDB()
 	if err != nil {
@@ -22,108 +30,1101 @@ func main() {
 	// Инициализация коллекций
 	userCollection := client.Database("fitnesshub").Collection("users")
 	productCollection := client.Database("fitnesshub").Collection("products")
+	classTypeCollection := client.Database("fitnesshub").Collection("class_types")
+	roomCollection := client.Database("fitnesshub").Collection("rooms")
+	instructorCollection := client.Database("fitnesshub").Collection("instructors")
+	scheduleCollection := client.Database("fitnesshub").Collection("class_schedules")
+	sessionCollection := client.Database("fitnesshub").Collection("class_sessions")
+	bookingCollection := client.Database("fitnesshub").Collection("bookings")
+	trainerCollection := client.Database("fitnesshub").Collection("trainers")
+	appointmentCollection := client.Database("fitnesshub").Collection("appointments")
+	visitCollection := client.Database("fitnesshub").Collection("visits")
+	exerciseCollection := client.Database("fitnesshub").Collection("exercises")
+	workoutCollection := client.Database("fitnesshub").Collection("workouts")
+	measurementCollection := client.Database("fitnesshub").Collection("measurements")
+	foodCollection := client.Database("fitnesshub").Collection("foods")
+	mealCollection := client.Database("fitnesshub").Collection("meals")
+	programCollection := client.Database("fitnesshub").Collection("programs")
+	assignmentCollection := client.Database("fitnesshub").Collection("program_assignments")
+	importJobCollection := client.Database("fitnesshub").Collection("import_jobs")
+	auditCollection := client.Database("fitnesshub").Collection("audit_log")
+	loginAttemptCollection := client.Database("fitnesshub").Collection("login_attempts")
+	passkeyCollection := client.Database("fitnesshub").Collection("passkeys")
+	webauthnSessionCollection := client.Database("fitnesshub").Collection("webauthn_sessions")
+	oidcStateCollection := client.Database("fitnesshub").Collection("oidc_states")
+	oauthClientCollection := client.Database("fitnesshub").Collection("oauth_clients")
+	oauthCodeCollection := client.Database("fitnesshub").Collection("oauth_codes")
+	oauthTokenCollection := client.Database("fitnesshub").Collection("oauth_tokens")
+	oauthConsentCollection := client.Database("fitnesshub").Collection("oauth_consents")
+	apiKeyCollection := client.Database("fitnesshub").Collection("api_keys")
+	persistedQueryCollection := client.Database("fitnesshub").Collection("graphql_queries")
+
+	if err := handlers.EnsureUserIndexes(userCollection); err != nil {
+		log.Fatal(err)
+	}
+	if err := handlers.EnsureBookingIndexes(bookingCollection); err != nil {
+		log.Fatal(err)
+	}
+	if err := handlers.EnsureAppointmentIndexes(appointmentCollection); err != nil {
+		log.Fatal(err)
+	}
+	if err := handlers.EnsureAuditIndexes(auditCollection); err != nil {
+		log.Fatal(err)
+	}
+	if err := handlers.EnsureLoginAttemptIndexes(loginAttemptCollection); err != nil {
+		log.Fatal(err)
+	}
+	if err := handlers.EnsurePasskeyIndexes(passkeyCollection, webauthnSessionCollection); err != nil {
+		log.Fatal(err)
+	}
+	if err := handlers.EnsureOIDCIndexes(userCollection, oidcStateCollection); err != nil {
+		log.Fatal(err)
+	}
+	if err := handlers.EnsureOAuthIndexes(oauthCodeCollection, oauthTokenCollection, oauthConsentCollection); err != nil {
+		log.Fatal(err)
+	}
+	if err := handlers.EnsureAPIKeyIndexes(apiKeyCollection); err != nil {
+		log.Fatal(err)
+	}
+	if err := handlers.EnsureGraphQLIndexes(persistedQueryCollection); err != nil {
+		log.Fatal(err)
+	}
+
+	// За обратным прокси адрес клиента берётся из X-Forwarded-For только от перечисленных прокси
+	if err := middleware.TrustProxies(os.Getenv("TRUSTED_PROXIES")); err != nil {
+		log.Fatal(err)
+	}
+
+	// API-ключи принимаются всеми защищёнными маршрутами наравне с cookie сессии
+	middleware.UseAPIKeys(handlers.ValidateAPIKey(apiKeyCollection, userCollection))
+
+	// Ограничение частоты запросов: в памяти для одного экземпляра,
+	// RATE_LIMIT_STORE=mongo — общее хранилище для нескольких экземпляров
+	var rateLimitStore middleware.RateLimitStore = middleware.NewMemoryRateLimitStore()
+	if os.Getenv("RATE_LIMIT_STORE") == "mongo" {
+		rateLimitStore, err = middleware.NewMongoRateLimitStore(client.Database("fitnesshub").Collection("rate_limits"))
+		if err != nil {
+			log.Fatal(err)
+		}
+	}
+	loginLimit := middleware.RateLimiter{Name: "login", Rate: middleware.PerMinute(30), Burst: 30, Store: rateLimitStore}
+	signupLimit := middleware.RateLimiter{Name: "signup", Rate: middleware.PerHour(10), Burst: 5, Store: rateLimitStore}
+	emailLimit := middleware.RateLimiter{Name: "email", Rate: middleware.PerHour(5), Burst: 3, Store: rateLimitStore}
+	resetLimit := middleware.RateLimiter{Name: "reset", Rate: middleware.PerHour(10), Burst: 5, Store: rateLimitStore}
+	reportLimit := middleware.RateLimiter{Name: "csp_report", Rate: middleware.PerMinute(60), Burst: 60, Store: rateLimitStore}
+	graphqlLimit := middleware.RateLimiter{Name: "graphql", Rate: middleware.PerMinute(120), Burst: 60, Store: rateLimitStore}
+
+	// Заголовки безопасности по группам маршрутов. Форма согласия OAuth перенаправляет
+	// на адрес партнёра, поэтому form-action для неё не ограничивается.
+	consentPolicy := middleware.PagePolicy
+	consentPolicy.CSP = strings.Replace(consentPolicy.CSP, " form-action 'self';", "", 1)
+	securityPolicies := map[string]middleware.SecurityPolicy{
+		"/":                 middleware.PagePolicy,
+		"/oauth/authorize":  consentPolicy,
+		"/oauth/token":      middleware.APIPolicy,
+		"/oauth/introspect": middleware.APIPolicy,
+		"/oauth/revoke":     middleware.APIPolicy,
+		"/oauth/api/":       middleware.APIPolicy,
+		"/csp-report":       middleware.APIPolicy,
+		"/api/v1/":          middleware.APIPolicy,
+		"/api/openapi.json": middleware.APIPolicy,
+		"/graphql":          middleware.APIPolicy,
+	}
+	for prefix, policy := range securityPolicies {
+		policy.ReportURI = "/csp-report"
+		policy.ReportOnly = os.Getenv("CSP_REPORT_ONLY") == "true"
+		securityPolicies[prefix] = policy
+	}
+
+	// Окончательное удаление записей из корзины по истечении срока хранения
+	go handlers.RunTrashPurge(auditCollection, time.Hour, handlers.TrashRetention(), userCollection, productCollection)
+
+	// HTML-страницы. Шаблон с методом точнее шаблона без него, поэтому обработчики
+	// JSON на тех же путях (/login, /signup, /password/reset) получают только остальные методы.
+	http.HandleFunc("GET /signup", func(w http.ResponseWriter, r *http.Request) {
+		http.ServeFile(w, r, "templates/signup.html")
+	})
+	http.HandleFunc("GET /login", func(w http.ResponseWriter, r *http.Request) {
+		http.ServeFile(w, r, "templates/login.html")
+	})
+	http.HandleFunc("GET /password/reset", handlers.ResetPasswordPageHandler)
+	http.HandleFunc("GET /profile.html", handlers.ProfilePageHandler)
+	adminPageHandler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.ServeFile(w, r, "templates/admin.html")
+	})
+	http.Handle("GET /admin", middleware.RoleBasedAccessControl(adminPageHandler, "administrator"))
 
 	// Регистрация обработчиков для аутентификации
 	http.HandleFunc("/signup", func(w http.ResponseWriter, r *http.Request) {
-		if r.Method == "GET" {
-			http.ServeFile(w, r, "templates/signup.html")
-		} else if r.Method == "POST" {
-			handlers.SignUpHandler(w, r, userCollection)
+		if r.Method == "POST" {
+			middleware.RateLimit(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				handlers.SignUpHandler(w, r, userCollection, auditCollection)
+			}), signupLimit).ServeHTTP(w, r)
+		} else {
+			http.Error(w, "Method not supported", http.StatusMethodNotAllowed)
 		}
 	})
 
 	http.HandleFunc("/verify", func(w http.ResponseWriter, r *http.Request) {
-		handlers.VerifyEmailHandler(w, r, userCollection)
+		handlers.VerifyEmailHandler(w, r, userCollection, auditCollection)
 	})
 
-	http.HandleFunc("/login", func(w http.ResponseWriter, r *http.Request) {
-		if r.Method == "GET" {
-			http.ServeFile(w, r, "templates/login.html")
-		} else if r.Method == "POST" {
-			handlers.LoginHandler(w, r, userCollection)
+	verifyResendHandler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == "POST" {
+			handlers.ResendVerificationHandler(w, r, userCollection)
+		} else {
+			http.Error(w, "Method not supported", http.StatusMethodNotAllowed)
 		}
 	})
+	http.Handle("/verify/resend", middleware.RateLimit(verifyResendHandler, emailLimit))
 
-	// Регистрация обработчиков для административной панели
-	adminHandler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+	forgotPasswordHandler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == "POST" {
+			handlers.ForgotPasswordHandler(w, r, userCollection, auditCollection)
+		} else {
+			http.Error(w, "Method not supported", http.StatusMethodNotAllowed)
+		}
+	})
+	http.Handle("/password/forgot", middleware.RateLimit(forgotPasswordHandler, emailLimit))
+
+	http.HandleFunc("/password/reset", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == "POST" {
+			middleware.RateLimit(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				handlers.ResetPasswordHandler(w, r, userCollection, loginAttemptCollection, auditCollection)
+			}), resetLimit).ServeHTTP(w, r)
+		} else {
+			http.Error(w, "Method not supported", http.StatusMethodNotAllowed)
+		}
+	})
+
+	http.HandleFunc("/login/unlock", func(w http.ResponseWriter, r *http.Request) {
 		if r.Method == "GET" {
-			http.ServeFile(w, r, "templates/admin.html")
+			handlers.UnlockAccountHandler(w, r, loginAttemptCollection, auditCollection)
+		} else {
+			http.Error(w, "Method not supported", http.StatusMethodNotAllowed)
+		}
+	})
+
+	http.HandleFunc("/login", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == "POST" {
+			middleware.RateLimit(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				handlers.LoginHandler(w, r, userCollection, loginAttemptCollection, auditCollection)
+			}), loginLimit).ServeHTTP(w, r)
+		} else {
+			http.Error(w, "Method not supported", http.StatusMethodNotAllowed)
+		}
+	})
+
+	// Второй шаг входа: код двухфакторной аутентификации или её обязательное подключение
+	login2FAHandler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == "POST" {
+			handlers.LoginTwoFactorHandler(w, r, userCollection, loginAttemptCollection, auditCollection)
+		} else {
+			http.Error(w, "Method not supported", http.StatusMethodNotAllowed)
+		}
+	})
+	http.Handle("/login/2fa", middleware.RateLimit(login2FAHandler, loginLimit))
+
+	login2FASetupHandler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == "POST" {
+			handlers.LoginTwoFactorSetupHandler(w, r, userCollection)
+		} else {
+			http.Error(w, "Method not supported", http.StatusMethodNotAllowed)
+		}
+	})
+	http.Handle("/login/2fa/setup", middleware.RateLimit(login2FASetupHandler, loginLimit))
+
+	login2FAEnableHandler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == "POST" {
+			handlers.LoginTwoFactorEnableHandler(w, r, userCollection, loginAttemptCollection, auditCollection)
+		} else {
+			http.Error(w, "Method not supported", http.StatusMethodNotAllowed)
 		}
 	})
-	http.Handle("/admin", middleware.RoleBasedAccessControl(adminHandler, "administrator"))
+	http.Handle("/login/2fa/enable", middleware.RateLimit(login2FAEnableHandler, loginLimit))
 
+	// Регистрация обработчиков для административной панели
 	adminUsersHandler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		if r.Method == "GET" {
-			users := handlers.GetAllUsers(userCollection)
-			tmpl, err := template.ParseFiles("templates/admin_users.html")
-			if err != nil {
-				http.Error(w, err.Error(), http.StatusInternalServerError)
-				return
-			}
-			tmpl.Execute(w, users)
-		} else if r.Method == "POST" {
-			handlers.AdminCreateUserHandler(w, r, userCollection)
-		} else if r.Method == "DELETE" {
-			handlers.AdminDeleteUserByIDHandler(w, r, userCollection)
+		switch r.Method {
+		case "GET":
+			handlers.AdminUsersPageHandler(w, r, userCollection)
+		case "POST":
+			handlers.AdminCreateUserHandler(w, r, userCollection, auditCollection)
+		case "PUT":
+			handlers.AdminUpdateUserByIDHandler(w, r, userCollection, auditCollection)
+		case "DELETE":
+			handlers.AdminDeleteUserByIDHandler(w, r, userCollection, auditCollection)
+		default:
+			http.Error(w, "Method not supported", http.StatusMethodNotAllowed)
 		}
 	})
 	http.Handle("/admin/users", middleware.RoleBasedAccessControl(adminUsersHandler, "administrator"))
 
+	adminUserEditHandler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case "GET":
+			handlers.AdminUserEditPageHandler(w, r, userCollection)
+		case "POST":
+			handlers.AdminUpdateUserByIDHandler(w, r, userCollection, auditCollection)
+		default:
+			http.Error(w, "Method not supported", http.StatusMethodNotAllowed)
+		}
+	})
+	http.Handle("/admin/users/edit", middleware.RoleBasedAccessControl(adminUserEditHandler, "administrator"))
+
+	adminUserDeleteHandler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case "GET":
+			handlers.AdminUserDeletePageHandler(w, r, userCollection)
+		case "POST":
+			handlers.AdminDeleteUserByIDHandler(w, r, userCollection, auditCollection)
+		default:
+			http.Error(w, "Method not supported", http.StatusMethodNotAllowed)
+		}
+	})
+	http.Handle("/admin/users/delete", middleware.RoleBasedAccessControl(adminUserDeleteHandler, "administrator"))
+
+	adminReset2FAHandler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == "POST" {
+			handlers.AdminResetTwoFactorHandler(w, r, userCollection, auditCollection)
+		} else {
+			http.Error(w, "Method not supported", http.StatusMethodNotAllowed)
+		}
+	})
+	http.Handle("/admin/users/2fa/reset", middleware.RoleBasedAccessControl(adminReset2FAHandler, "administrator"))
+
 	adminProductsHandler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		if r.Method == "GET" {
-			products := handlers.GetAllProducts(productCollection)
-			tmpl, err := template.ParseFiles("templates/admin_products.html")
-			if err != nil {
-				http.Error(w, err.Error(), http.StatusInternalServerError)
-				return
-			}
-			tmpl.Execute(w, products)
-		} else if r.Method == "POST" {
-			handlers.CreateProductHandler(w, r, productCollection)
-		} else if r.Method == "DELETE" {
-			handlers.DeleteProductByIDHandler(w, r, productCollection)
+		switch r.Method {
+		case "GET":
+			handlers.AdminProductsPageHandler(w, r, productCollection)
+		case "POST":
+			handlers.CreateProductHandler(w, r, productCollection, auditCollection)
+		case "PUT":
+			handlers.UpdateProductByIDHandler(w, r, productCollection, auditCollection)
+		case "DELETE":
+			handlers.DeleteProductByIDHandler(w, r, productCollection, auditCollection)
+		default:
+			http.Error(w, "Method not supported", http.StatusMethodNotAllowed)
 		}
 	})
 	http.Handle("/admin/products", middleware.RoleBasedAccessControl(adminProductsHandler, "administrator"))
 
-	// Регистрация обработчиков для продуктов
-	http.HandleFunc("/products", func(w http.ResponseWriter, r *http.Request) {
+	adminProductEditHandler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case "GET":
+			handlers.AdminProductEditPageHandler(w, r, productCollection)
+		case "POST":
+			handlers.UpdateProductByIDHandler(w, r, productCollection, auditCollection)
+		default:
+			http.Error(w, "Method not supported", http.StatusMethodNotAllowed)
+		}
+	})
+	http.Handle("/admin/products/edit", middleware.RoleBasedAccessControl(adminProductEditHandler, "administrator"))
+
+	adminProductDeleteHandler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case "GET":
+			handlers.AdminProductDeletePageHandler(w, r, productCollection)
+		case "POST":
+			handlers.DeleteProductByIDHandler(w, r, productCollection, auditCollection)
+		default:
+			http.Error(w, "Method not supported", http.StatusMethodNotAllowed)
+		}
+	})
+	http.Handle("/admin/products/delete", middleware.RoleBasedAccessControl(adminProductDeleteHandler, "administrator"))
+
+	// Регистрация обработчиков для корзины
+	adminTrashHandler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == "GET" {
+			handlers.AdminTrashHandler(w, r, userCollection, productCollection)
+		} else {
+			http.Error(w, "Method not supported", http.StatusMethodNotAllowed)
+		}
+	})
+	http.Handle("/admin/trash", middleware.RoleBasedAccessControl(adminTrashHandler, "administrator"))
+
+	adminRestoreHandler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == "POST" {
+			handlers.RestoreFromTrashHandler(w, r, userCollection, productCollection, auditCollection)
+		} else {
+			http.Error(w, "Method not supported", http.StatusMethodNotAllowed)
+		}
+	})
+	http.Handle("/admin/trash/restore", middleware.RoleBasedAccessControl(adminRestoreHandler, "administrator"))
+
+	// Регистрация обработчиков для журнала аудита
+	adminAuditHandler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == "GET" {
+			handlers.AdminAuditHandler(w, r, auditCollection)
+		} else {
+			http.Error(w, "Method not supported", http.StatusMethodNotAllowed)
+		}
+	})
+	http.Handle("/admin/audit", middleware.RoleBasedAccessControl(adminAuditHandler, "administrator"))
+
+	adminAuditExportHandler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == "GET" {
+			handlers.ExportAuditHandler(w, r, auditCollection)
+		} else {
+			http.Error(w, "Method not supported", http.StatusMethodNotAllowed)
+		}
+	})
+	http.Handle("/admin/audit/export", middleware.RoleBasedAccessControl(adminAuditExportHandler, "administrator"))
+
+	adminAuditVerifyHandler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == "GET" {
+			handlers.VerifyAuditChainHandler(w, r, auditCollection)
+		} else {
+			http.Error(w, "Method not supported", http.StatusMethodNotAllowed)
+		}
+	})
+	http.Handle("/admin/audit/verify", middleware.RoleBasedAccessControl(adminAuditVerifyHandler, "administrator"))
+
+	// Регистрация обработчиков для импорта и выгрузки
+	adminUserImportHandler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == "POST" {
+			handlers.ImportUsersHandler(w, r, userCollection, importJobCollection, auditCollection)
+		} else {
+			http.Error(w, "Method not supported", http.StatusMethodNotAllowed)
+		}
+	})
+	http.Handle("/admin/users/import", middleware.RoleBasedAccessControl(adminUserImportHandler, "administrator"))
+
+	adminUserExportHandler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == "GET" {
+			handlers.ExportUsersHandler(w, r, userCollection)
+		} else {
+			http.Error(w, "Method not supported", http.StatusMethodNotAllowed)
+		}
+	})
+	http.Handle("/admin/users/export", middleware.RoleBasedAccessControl(adminUserExportHandler, "administrator"))
+
+	adminEmailConflictsHandler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == "GET" {
+			handlers.AdminEmailConflictsHandler(w, r, userCollection)
+		} else {
+			http.Error(w, "Method not supported", http.StatusMethodNotAllowed)
+		}
+	})
+	http.Handle("/admin/users/email-conflicts", middleware.RoleBasedAccessControl(adminEmailConflictsHandler, "administrator"))
+
+	adminProductImportHandler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == "POST" {
+			handlers.ImportProductsHandler(w, r, productCollection, importJobCollection, auditCollection)
+		} else {
+			http.Error(w, "Method not supported", http.StatusMethodNotAllowed)
+		}
+	})
+	http.Handle("/admin/products/import", middleware.RoleBasedAccessControl(adminProductImportHandler, "administrator"))
+
+	adminProductExportHandler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == "GET" {
+			handlers.ExportProductsHandler(w, r, productCollection)
+		} else {
+			http.Error(w, "Method not supported", http.StatusMethodNotAllowed)
+		}
+	})
+	http.Handle("/admin/products/export", middleware.RoleBasedAccessControl(adminProductExportHandler, "administrator"))
+
+	adminImportJobHandler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == "GET" {
+			handlers.GetImportJobHandler(w, r, importJobCollection)
+		} else {
+			http.Error(w, "Method not supported", http.StatusMethodNotAllowed)
+		}
+	})
+	http.Handle("/admin/imports", middleware.RoleBasedAccessControl(adminImportJobHandler, "administrator"))
+
+	// Регистрация обработчиков для клиентов и согласий OAuth2
+	oauthClientsHandler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case "GET":
+			handlers.GetOAuthClientsHandler(w, r, oauthClientCollection)
+		case "POST":
+			handlers.CreateOAuthClientHandler(w, r, oauthClientCollection, auditCollection)
+		case "DELETE":
+			handlers.RevokeOAuthClientHandler(w, r, oauthClientCollection, oauthTokenCollection, auditCollection)
+		default:
+			http.Error(w, "Method not supported", http.StatusMethodNotAllowed)
+		}
+	})
+	http.Handle("/admin/oauth/clients", middleware.RoleBasedAccessControl(oauthClientsHandler, "administrator"))
+
+	oauthConsentsHandler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case "GET":
+			handlers.GetOAuthConsentsHandler(w, r, oauthConsentCollection)
+		case "DELETE":
+			handlers.RevokeOAuthConsentHandler(w, r, oauthConsentCollection, oauthTokenCollection, auditCollection)
+		default:
+			http.Error(w, "Method not supported", http.StatusMethodNotAllowed)
+		}
+	})
+	http.Handle("/profile/oauth/consents", middleware.RoleBasedAccessControl(oauthConsentsHandler, "user", "trainer", "staff", "administrator"))
+
+	// Регистрация обработчиков для API-ключей
+	myAPIKeysHandler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case "GET":
+			handlers.GetMyAPIKeysHandler(w, r, apiKeyCollection)
+		case "POST":
+			handlers.CreateMyAPIKeyHandler(w, r, apiKeyCollection, auditCollection)
+		case "DELETE":
+			handlers.RevokeMyAPIKeyHandler(w, r, apiKeyCollection, auditCollection)
+		default:
+			http.Error(w, "Method not supported", http.StatusMethodNotAllowed)
+		}
+	})
+	http.Handle("/profile/api-keys", middleware.RoleBasedAccessControl(myAPIKeysHandler, "user", "trainer", "staff", "administrator"))
+
+	adminAPIKeysHandler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case "GET":
+			handlers.AdminGetAPIKeysHandler(w, r, apiKeyCollection)
+		case "POST":
+			handlers.AdminCreateServiceKeyHandler(w, r, apiKeyCollection, auditCollection)
+		case "DELETE":
+			handlers.AdminRevokeAPIKeyHandler(w, r, apiKeyCollection, auditCollection)
+		default:
+			http.Error(w, "Method not supported", http.StatusMethodNotAllowed)
+		}
+	})
+	http.Handle("/admin/api-keys", middleware.RoleBasedAccessControl(adminAPIKeysHandler, "administrator"))
+
+	// Регистрация обработчиков для групповых занятий
+	adminClassTypesHandler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		switch r.Method {
+		case "GET":
+			handlers.GetAllClassTypesHandler(w, r, classTypeCollection)
 		case "POST":
-			handlers.CreateProductHandler(w, r, productCollection)
+			handlers.CreateClassTypeHandler(w, r, classTypeCollection, auditCollection)
+		case "PUT":
+			handlers.UpdateClassTypeByIDHandler(w, r, classTypeCollection, auditCollection)
+		case "DELETE":
+			handlers.DeleteClassTypeByIDHandler(w, r, classTypeCollection, auditCollection)
+		default:
+			http.Error(w, "Method not supported", http.StatusMethodNotAllowed)
+		}
+	})
+	http.Handle("/admin/class-types", middleware.RoleBasedAccessControl(adminClassTypesHandler, "administrator"))
+
+	adminRoomsHandler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
 		case "GET":
-			if r.URL.Query().Get("id") != "" {
-				handlers.GetProductByIDHandler(w, r, productCollection)
-			} else {
-				handlers.GetAllProductsHandler(w, r, productCollection)
-			}
+			handlers.GetAllRoomsHandler(w, r, roomCollection)
+		case "POST":
+			handlers.CreateRoomHandler(w, r, roomCollection, auditCollection)
 		case "PUT":
-			handlers.UpdateProductByIDHandler(w, r, productCollection)
+			handlers.UpdateRoomByIDHandler(w, r, roomCollection, auditCollection)
 		case "DELETE":
-			handlers.DeleteProductByIDHandler(w, r, productCollection)
+			handlers.DeleteRoomByIDHandler(w, r, roomCollection, auditCollection)
 		default:
 			http.Error(w, "Method not supported", http.StatusMethodNotAllowed)
 		}
 	})
+	http.Handle("/admin/rooms", middleware.RoleBasedAccessControl(adminRoomsHandler, "administrator"))
 
-	// Регистрация обработчиков для пользовательского профиля
-	http.HandleFunc("/profile", func(w http.ResponseWriter, r *http.Request) {
+	adminInstructorsHandler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		switch r.Method {
+		case "GET":
+			handlers.GetAllInstructorsHandler(w, r, instructorCollection)
+		case "POST":
+			handlers.CreateInstructorHandler(w, r, instructorCollection, auditCollection)
+		case "PUT":
+			handlers.UpdateInstructorByIDHandler(w, r, instructorCollection, auditCollection)
+		case "DELETE":
+			handlers.DeleteInstructorByIDHandler(w, r, instructorCollection, auditCollection)
+		default:
+			http.Error(w, "Method not supported", http.StatusMethodNotAllowed)
+		}
+	})
+	http.Handle("/admin/instructors", middleware.RoleBasedAccessControl(adminInstructorsHandler, "administrator"))
+
+	adminSchedulesHandler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case "GET":
+			handlers.GetAllSchedulesHandler(w, r, scheduleCollection)
+		case "POST":
+			handlers.CreateScheduleHandler(w, r, scheduleCollection, classTypeCollection, roomCollection, sessionCollection, auditCollection)
 		case "PUT":
-			handlers.UpdateUserProfileHandler(w, r, userCollection)
+			handlers.UpdateScheduleByIDHandler(w, r, scheduleCollection, classTypeCollection, roomCollection, sessionCollection, auditCollection)
+		case "DELETE":
+			handlers.DeleteScheduleByIDHandler(w, r, scheduleCollection, sessionCollection, auditCollection)
+		default:
+			http.Error(w, "Method not supported", http.StatusMethodNotAllowed)
+		}
+	})
+	http.Handle("/admin/schedules", middleware.RoleBasedAccessControl(adminSchedulesHandler, "administrator"))
+
+	adminMaterializeHandler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != "POST" {
+			http.Error(w, "Method not supported", http.StatusMethodNotAllowed)
+			return
+		}
+		handlers.MaterializeAllSchedulesHandler(w, r, scheduleCollection, classTypeCollection, roomCollection, sessionCollection, auditCollection)
+	})
+	http.Handle("/admin/schedules/materialize", middleware.RoleBasedAccessControl(adminMaterializeHandler, "administrator"))
+
+	http.HandleFunc("/timetable", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == "GET" {
+			handlers.TimetableHandler(w, r, sessionCollection)
+		} else {
+			http.Error(w, "Method not supported", http.StatusMethodNotAllowed)
+		}
+	})
+
+	// Регистрация обработчиков для записи на занятия
+	bookingsHandler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case "GET":
+			handlers.GetMyBookingsHandler(w, r, bookingCollection)
 		case "POST":
-			handlers.ChangeUserPasswordHandler(w, r, userCollection)
+			handlers.BookSessionHandler(w, r, bookingCollection, sessionCollection)
+		case "DELETE":
+			handlers.CancelBookingHandler(w, r, bookingCollection, sessionCollection, userCollection)
 		default:
 			http.Error(w, "Method not supported", http.StatusMethodNotAllowed)
 		}
 	})
+	http.Handle("/bookings", middleware.RoleBasedAccessControl(bookingsHandler, "user", "trainer", "administrator"))
+
+	adminBookingsHandler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case "GET":
+			handlers.GetSessionBookingsHandler(w, r, bookingCollection)
+		case "POST":
+			handlers.MarkAttendanceHandler(w, r, bookingCollection, auditCollection)
+		default:
+			http.Error(w, "Method not supported", http.StatusMethodNotAllowed)
+		}
+	})
+	http.Handle("/admin/bookings", middleware.RoleBasedAccessControl(adminBookingsHandler, "administrator"))
+
+	// Регистрация обработчиков для персональных тренировок
+	http.HandleFunc("/trainers", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != "GET" {
+			http.Error(w, "Method not supported", http.StatusMethodNotAllowed)
+			return
+		}
+		if r.URL.Query().Get("id") != "" {
+			handlers.GetTrainerByIDHandler(w, r, trainerCollection)
+		} else {
+			handlers.GetAllTrainersHandler(w, r, trainerCollection)
+		}
+	})
+
+	http.HandleFunc("/trainers/slots", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == "GET" {
+			handlers.GetTrainerSlotsHandler(w, r, trainerCollection, appointmentCollection)
+		} else {
+			http.Error(w, "Method not supported", http.StatusMethodNotAllowed)
+		}
+	})
+
+	trainerProfileHandler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == "PUT" {
+			handlers.UpdateTrainerProfileHandler(w, r, trainerCollection, auditCollection)
+		} else {
+			http.Error(w, "Method not supported", http.StatusMethodNotAllowed)
+		}
+	})
+	http.Handle("/trainer/profile", middleware.RoleBasedAccessControl(trainerProfileHandler, "trainer"))
+
+	trainerAppointmentsHandler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case "GET":
+			handlers.GetTrainerAppointmentsHandler(w, r, appointmentCollection)
+		case "DELETE":
+			handlers.CancelAppointmentHandler(w, r, appointmentCollection)
+		default:
+			http.Error(w, "Method not supported", http.StatusMethodNotAllowed)
+		}
+	})
+	http.Handle("/trainer/appointments", middleware.RoleBasedAccessControl(trainerAppointmentsHandler, "trainer"))
+
+	appointmentsHandler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case "GET":
+			handlers.GetMyAppointmentsHandler(w, r, appointmentCollection)
+		case "POST":
+			handlers.BookAppointmentHandler(w, r, trainerCollection, appointmentCollection, sessionCollection, instructorCollection)
+		case "DELETE":
+			handlers.CancelAppointmentHandler(w, r, appointmentCollection)
+		default:
+			http.Error(w, "Method not supported", http.StatusMethodNotAllowed)
+		}
+	})
+	http.Handle("/appointments", middleware.RoleBasedAccessControl(appointmentsHandler, "user", "trainer", "administrator"))
+
+	// Регистрация обработчиков для продуктов
+	// Каталог читают все, а меняет только администратор
+	manageProductsHandler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case "POST":
+			handlers.CreateProductHandler(w, r, productCollection, auditCollection)
+		case "PUT":
+			handlers.UpdateProductByIDHandler(w, r, productCollection, auditCollection)
+		case "DELETE":
+			handlers.DeleteProductByIDHandler(w, r, productCollection, auditCollection)
+		default:
+			http.Error(w, "Method not supported", http.StatusMethodNotAllowed)
+		}
+	})
+	http.HandleFunc("/products", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != "GET" {
+			middleware.RoleBasedAccessControl(manageProductsHandler, "administrator").ServeHTTP(w, r)
+			return
+		}
+		if r.URL.Query().Get("id") != "" {
+			handlers.GetProductByIDHandler(w, r, productCollection)
+		} else {
+			handlers.GetAllProductsHandler(w, r, productCollection)
+		}
+	})
+
+	// Регистрация обработчиков для пользовательского профиля
+	profileHandler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case "GET":
+			handlers.GetUserProfileHandler(w, r, userCollection)
+		case "PATCH", "PUT":
+			handlers.UpdateUserProfileHandler(w, r, userCollection, auditCollection)
+		case "POST":
+			handlers.ChangeUserPasswordHandler(w, r, userCollection, auditCollection)
+		default:
+			http.Error(w, "Method not supported", http.StatusMethodNotAllowed)
+		}
+	})
+	http.Handle("/profile", middleware.RoleBasedAccessControl(profileHandler, "user", "trainer", "staff", "administrator"))
+
+	twoFactorStatusHandler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == "GET" {
+			handlers.GetTwoFactorStatusHandler(w, r, userCollection)
+		} else {
+			http.Error(w, "Method not supported", http.StatusMethodNotAllowed)
+		}
+	})
+	http.Handle("/profile/2fa", middleware.RoleBasedAccessControl(twoFactorStatusHandler, "user", "trainer", "staff", "administrator"))
+
+	twoFactorSetupHandler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == "POST" {
+			handlers.TwoFactorSetupHandler(w, r, userCollection)
+		} else {
+			http.Error(w, "Method not supported", http.StatusMethodNotAllowed)
+		}
+	})
+	http.Handle("/profile/2fa/setup", middleware.RoleBasedAccessControl(twoFactorSetupHandler, "user", "trainer", "staff", "administrator"))
+
+	twoFactorEnableHandler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == "POST" {
+			handlers.TwoFactorEnableHandler(w, r, userCollection, auditCollection)
+		} else {
+			http.Error(w, "Method not supported", http.StatusMethodNotAllowed)
+		}
+	})
+	http.Handle("/profile/2fa/enable", middleware.RoleBasedAccessControl(twoFactorEnableHandler, "user", "trainer", "staff", "administrator"))
+
+	twoFactorDisableHandler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == "POST" {
+			handlers.TwoFactorDisableHandler(w, r, userCollection, auditCollection)
+		} else {
+			http.Error(w, "Method not supported", http.StatusMethodNotAllowed)
+		}
+	})
+	http.Handle("/profile/2fa/disable", middleware.RoleBasedAccessControl(twoFactorDisableHandler, "user", "trainer", "staff", "administrator"))
+
+	recoveryCodesHandler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == "POST" {
+			handlers.RegenerateRecoveryCodesHandler(w, r, userCollection, auditCollection)
+		} else {
+			http.Error(w, "Method not supported", http.StatusMethodNotAllowed)
+		}
+	})
+	http.Handle("/profile/2fa/recovery-codes", middleware.RoleBasedAccessControl(recoveryCodesHandler, "user", "trainer", "staff", "administrator"))
+
+	passkeysHandler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case "GET":
+			handlers.GetPasskeysHandler(w, r, passkeyCollection)
+		case "DELETE":
+			handlers.DeletePasskeyHandler(w, r, passkeyCollection, auditCollection)
+		default:
+			http.Error(w, "Method not supported", http.StatusMethodNotAllowed)
+		}
+	})
+	http.Handle("/profile/passkeys", middleware.RoleBasedAccessControl(passkeysHandler, "user", "trainer", "staff", "administrator"))
+
+	passkeyRegisterBeginHandler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == "POST" {
+			handlers.BeginPasskeyRegistrationHandler(w, r, userCollection, passkeyCollection, webauthnSessionCollection)
+		} else {
+			http.Error(w, "Method not supported", http.StatusMethodNotAllowed)
+		}
+	})
+	http.Handle("/profile/passkeys/register/begin", middleware.RoleBasedAccessControl(passkeyRegisterBeginHandler, "user", "trainer", "staff", "administrator"))
+
+	passkeyRegisterFinishHandler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == "POST" {
+			handlers.FinishPasskeyRegistrationHandler(w, r, userCollection, passkeyCollection, webauthnSessionCollection, auditCollection)
+		} else {
+			http.Error(w, "Method not supported", http.StatusMethodNotAllowed)
+		}
+	})
+	http.Handle("/profile/passkeys/register/finish", middleware.RoleBasedAccessControl(passkeyRegisterFinishHandler, "user", "trainer", "staff", "administrator"))
+
+	identitiesHandler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case "GET":
+			handlers.GetIdentitiesHandler(w, r, userCollection)
+		case "DELETE":
+			handlers.UnlinkIdentityHandler(w, r, userCollection, auditCollection)
+		default:
+			http.Error(w, "Method not supported", http.StatusMethodNotAllowed)
+		}
+	})
+	http.Handle("/profile/identities", middleware.RoleBasedAccessControl(identitiesHandler, "user", "trainer", "staff", "administrator"))
+
+	clientProfileHandler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == "GET" {
+			handlers.GetClientProfileHandler(w, r, userCollection)
+		} else {
+			http.Error(w, "Method not supported", http.StatusMethodNotAllowed)
+		}
+	})
+	http.Handle("/trainer/clients/profile", middleware.RoleBasedAccessControl(clientProfileHandler, "trainer"))
+
+	// Регистрация обработчиков для пропусков и посещений
+	memberPassHandler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == "GET" {
+			handlers.GetMemberPassHandler(w, r)
+		} else {
+			http.Error(w, "Method not supported", http.StatusMethodNotAllowed)
+		}
+	})
+	http.Handle("/profile/pass", middleware.RoleBasedAccessControl(memberPassHandler, "user", "trainer", "staff", "administrator"))
+
+	memberPassQRHandler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == "GET" {
+			handlers.GetMemberPassQRHandler(w, r)
+		} else {
+			http.Error(w, "Method not supported", http.StatusMethodNotAllowed)
+		}
+	})
+	http.Handle("/profile/pass.png", middleware.RoleBasedAccessControl(memberPassQRHandler, "user", "trainer", "staff", "administrator"))
+
+	visitsHandler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == "GET" {
+			handlers.GetMyVisitsHandler(w, r, visitCollection)
+		} else {
+			http.Error(w, "Method not supported", http.StatusMethodNotAllowed)
+		}
+	})
+	http.Handle("/visits", middleware.RoleBasedAccessControl(visitsHandler, "user", "trainer", "staff", "administrator"))
+
+	checkInHandler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == "POST" {
+			handlers.CheckInHandler(w, r, userCollection, visitCollection)
+		} else {
+			http.Error(w, "Method not supported", http.StatusMethodNotAllowed)
+		}
+	})
+	http.Handle("/checkin", middleware.RoleBasedAccessControl(checkInHandler, "staff", "trainer", "administrator"))
+
+	occupancyHandler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == "GET" {
+			handlers.OccupancyHandler(w, r, visitCollection)
+		} else {
+			http.Error(w, "Method not supported", http.StatusMethodNotAllowed)
+		}
+	})
+	http.Handle("/occupancy", middleware.RoleBasedAccessControl(occupancyHandler, "staff", "trainer", "administrator"))
+
+	// Регистрация обработчиков для библиотеки упражнений и дневника тренировок
+	manageExercisesHandler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case "POST":
+			handlers.CreateExerciseHandler(w, r, exerciseCollection, auditCollection)
+		case "PUT":
+			handlers.UpdateExerciseByIDHandler(w, r, exerciseCollection, auditCollection)
+		case "DELETE":
+			handlers.DeleteExerciseByIDHandler(w, r, exerciseCollection, auditCollection)
+		default:
+			http.Error(w, "Method not supported", http.StatusMethodNotAllowed)
+		}
+	})
+	http.HandleFunc("/exercises", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == "GET" {
+			handlers.GetAllExercisesHandler(w, r, exerciseCollection)
+			return
+		}
+		middleware.RoleBasedAccessControl(manageExercisesHandler, "trainer", "administrator").ServeHTTP(w, r)
+	})
+
+	workoutsHandler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case "GET":
+			handlers.GetWorkoutHistoryHandler(w, r, workoutCollection)
+		case "POST":
+			handlers.LogWorkoutHandler(w, r, workoutCollection, exerciseCollection)
+		case "DELETE":
+			handlers.DeleteWorkoutByIDHandler(w, r, workoutCollection)
+		default:
+			http.Error(w, "Method not supported", http.StatusMethodNotAllowed)
+		}
+	})
+	http.Handle("/workouts", middleware.RoleBasedAccessControl(workoutsHandler, "user", "trainer", "staff", "administrator"))
+
+	personalRecordsHandler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == "GET" {
+			handlers.GetPersonalRecordsHandler(w, r, workoutCollection)
+		} else {
+			http.Error(w, "Method not supported", http.StatusMethodNotAllowed)
+		}
+	})
+	http.Handle("/workouts/records", middleware.RoleBasedAccessControl(personalRecordsHandler, "user", "trainer", "staff", "administrator"))
+
+	weeklyVolumeHandler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == "GET" {
+			handlers.GetWeeklyVolumeHandler(w, r, workoutCollection)
+		} else {
+			http.Error(w, "Method not supported", http.StatusMethodNotAllowed)
+		}
+	})
+	http.Handle("/workouts/volume", middleware.RoleBasedAccessControl(weeklyVolumeHandler, "user", "trainer", "staff", "administrator"))
+
+	// Регистрация обработчиков для замеров тела и прогресса
+	measurementsHandler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case "GET":
+			handlers.GetMeasurementsHandler(w, r, measurementCollection, userCollection)
+		case "POST":
+			handlers.CreateMeasurementHandler(w, r, measurementCollection, userCollection)
+		case "DELETE":
+			handlers.DeleteMeasurementByIDHandler(w, r, measurementCollection)
+		default:
+			http.Error(w, "Method not supported", http.StatusMethodNotAllowed)
+		}
+	})
+	http.Handle("/measurements", middleware.RoleBasedAccessControl(measurementsHandler, "user", "trainer", "staff", "administrator"))
+
+	measurementTrendHandler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == "GET" {
+			handlers.GetMeasurementTrendHandler(w, r, measurementCollection, userCollection)
+		} else {
+			http.Error(w, "Method not supported", http.StatusMethodNotAllowed)
+		}
+	})
+	http.Handle("/measurements/trends", middleware.RoleBasedAccessControl(measurementTrendHandler, "user", "trainer", "staff", "administrator"))
+
+	progressPhotoHandler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case "GET":
+			handlers.GetProgressPhotoHandler(w, r, measurementCollection, userCollection)
+		case "POST":
+			handlers.UploadProgressPhotoHandler(w, r, measurementCollection)
+		default:
+			http.Error(w, "Method not supported", http.StatusMethodNotAllowed)
+		}
+	})
+	http.Handle("/measurements/photos", middleware.RoleBasedAccessControl(progressPhotoHandler, "user", "trainer", "staff", "administrator"))
+
+	unitPreferencesHandler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == "PUT" {
+			handlers.UpdateUnitPreferencesHandler(w, r, userCollection)
+		} else {
+			http.Error(w, "Method not supported", http.StatusMethodNotAllowed)
+		}
+	})
+	http.Handle("/profile/units", middleware.RoleBasedAccessControl(unitPreferencesHandler, "user", "trainer", "staff", "administrator"))
+
+	trainerClientsHandler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == "GET" {
+			handlers.GetTrainerClientsHandler(w, r, userCollection)
+		} else {
+			http.Error(w, "Method not supported", http.StatusMethodNotAllowed)
+		}
+	})
+	http.Handle("/trainer/clients", middleware.RoleBasedAccessControl(trainerClientsHandler, "trainer"))
+
+	adminAssignTrainerHandler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == "POST" {
+			handlers.AssignTrainerHandler(w, r, userCollection, auditCollection)
+		} else {
+			http.Error(w, "Method not supported", http.StatusMethodNotAllowed)
+		}
+	})
+	http.Handle("/admin/users/trainer", middleware.RoleBasedAccessControl(adminAssignTrainerHandler, "administrator"))
+
+	// Регистрация обработчиков для дневника питания
+	manageFoodsHandler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case "POST":
+			handlers.CreateFoodItemHandler(w, r, foodCollection, auditCollection)
+		case "PUT":
+			handlers.UpdateFoodItemByIDHandler(w, r, foodCollection, auditCollection)
+		case "DELETE":
+			handlers.DeleteFoodItemByIDHandler(w, r, foodCollection, auditCollection)
+		default:
+			http.Error(w, "Method not supported", http.StatusMethodNotAllowed)
+		}
+	})
+	http.HandleFunc("/foods", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == "GET" {
+			handlers.GetAllFoodItemsHandler(w, r, foodCollection)
+			return
+		}
+		middleware.RoleBasedAccessControl(manageFoodsHandler, "trainer", "administrator").ServeHTTP(w, r)
+	})
+
+	adminFoodImportHandler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == "POST" {
+			handlers.ImportFoodItemsHandler(w, r, foodCollection, auditCollection)
+		} else {
+			http.Error(w, "Method not supported", http.StatusMethodNotAllowed)
+		}
+	})
+	http.Handle("/admin/foods/import", middleware.RoleBasedAccessControl(adminFoodImportHandler, "administrator"))
+
+	mealsHandler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case "GET":
+			handlers.GetMealsHandler(w, r, mealCollection)
+		case "POST":
+			handlers.LogMealHandler(w, r, mealCollection, foodCollection)
+		case "DELETE":
+			handlers.DeleteMealByIDHandler(w, r, mealCollection)
+		default:
+			http.Error(w, "Method not supported", http.StatusMethodNotAllowed)
+		}
+	})
+	http.Handle("/meals", middleware.RoleBasedAccessControl(mealsHandler, "user", "trainer", "staff", "administrator"))
+
+	nutritionSettingsHandler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == "PUT" {
+			handlers.UpdateNutritionSettingsHandler(w, r, userCollection)
+		} else {
+			http.Error(w, "Method not supported", http.StatusMethodNotAllowed)
+		}
+	})
+	http.Handle("/nutrition/settings", middleware.RoleBasedAccessControl(nutritionSettingsHandler, "user", "trainer", "staff", "administrator"))
+
+	nutritionTargetsHandler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == "GET" {
+			handlers.GetNutritionTargetsHandler(w, r, userCollection, measurementCollection)
+		} else {
+			http.Error(w, "Method not supported", http.StatusMethodNotAllowed)
+		}
+	})
+	http.Handle("/nutrition/targets", middleware.RoleBasedAccessControl(nutritionTargetsHandler, "user", "trainer", "staff", "administrator"))
+
+	dailyNutritionHandler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == "GET" {
+			handlers.GetDailyNutritionSummaryHandler(w, r, mealCollection, userCollection, measurementCollection)
+		} else {
+			http.Error(w, "Method not supported", http.StatusMethodNotAllowed)
+		}
+	})
+	http.Handle("/nutrition/summary/daily", middleware.RoleBasedAccessControl(dailyNutritionHandler, "user", "trainer", "staff", "administrator"))
+
+	weeklyNutritionHandler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == "GET" {
+			handlers.GetWeeklyNutritionSummaryHandler(w, r, mealCollection, userCollection, measurementCollection)
+		} else {
+			http.Error(w, "Method not supported", http.StatusMethodNotAllowed)
+		}
+	})
+	http.Handle("/nutrition/summary/weekly", middleware.RoleBasedAccessControl(weeklyNutritionHandler, "user", "trainer", "staff", "administrator"))
+
+	// Регистрация обработчиков для тренировочных программ
+	trainerProgramsHandler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case "GET":
+			handlers.GetTrainerProgramsHandler(w, r, programCollection)
+		case "POST":
+			handlers.CreateProgramHandler(w, r, programCollection, auditCollection)
+		case "PUT":
+			handlers.UpdateProgramByIDHandler(w, r, programCollection, auditCollection)
+		case "DELETE":
+			handlers.DeleteProgramByIDHandler(w, r, programCollection, auditCollection)
+		default:
+			http.Error(w, "Method not supported", http.StatusMethodNotAllowed)
+		}
+	})
+	http.Handle("/trainer/programs", middleware.RoleBasedAccessControl(trainerProgramsHandler, "trainer", "administrator"))
+
+	assignProgramHandler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == "POST" {
+			handlers.AssignProgramHandler(w, r, programCollection, assignmentCollection, userCollection, auditCollection)
+		} else {
+			http.Error(w, "Method not supported", http.StatusMethodNotAllowed)
+		}
+	})
+	http.Handle("/trainer/programs/assign", middleware.RoleBasedAccessControl(assignProgramHandler, "trainer", "administrator"))
+
+	programAssignmentsHandler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == "GET" {
+			handlers.GetMyProgramAssignmentsHandler(w, r, assignmentCollection)
+		} else {
+			http.Error(w, "Method not supported", http.StatusMethodNotAllowed)
+		}
+	})
+	http.Handle("/programs/assignments", middleware.RoleBasedAccessControl(programAssignmentsHandler, "user", "trainer", "staff", "administrator"))
+
+	todaysWorkoutHandler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == "GET" {
+			handlers.GetTodaysWorkoutHandler(w, r, programCollection, assignmentCollection, workoutCollection)
+		} else {
+			http.Error(w, "Method not supported", http.StatusMethodNotAllowed)
+		}
+	})
+	http.Handle("/programs/today", middleware.RoleBasedAccessControl(todaysWorkoutHandler, "user", "trainer", "staff", "administrator"))
+
+	programAdherenceHandler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == "GET" {
+			handlers.GetProgramAdherenceHandler(w, r, programCollection, assignmentCollection, workoutCollection)
+		} else {
+			http.Error(w, "Method not supported", http.StatusMethodNotAllowed)
+		}
+	})
+	http.Handle("/programs/adherence", middleware.RoleBasedAccessControl(programAdherenceHandler, "user", "trainer", "staff", "administrator"))
+
+	// Версионированный JSON API
+	limits := apiLimits{login: loginLimit, signup: signupLimit, email: emailLimit, reset: resetLimit, graphql: graphqlLimit}
+	api, apiSpec := apiRoutes(client.Database("fitnesshub"), limits)
+	http.Handle("/api/v1/", api)
+
+	// Вход по ключу доступа и через OIDC, OAuth2 и GraphQL.
+	// GRAPHQL_PERSISTED_ONLY=true разрешает в GraphQL только сохранённые запросы
+	err = protocolRoutes(http.DefaultServeMux, apiSpec, client.Database("fitnesshub"), limits, os.Getenv("GRAPHQL_PERSISTED_ONLY") == "true")
+	if err != nil {
+		log.Fatal(err)
+	}
+	for _, problem := range apiSpec.Drift() {
+		log.Println("OpenAPI:", problem)
+	}
+
+	// Спецификация API и её просмотр
+	http.HandleFunc("GET /api/openapi.json", func(w http.ResponseWriter, r *http.Request) {
+		handlers.OpenAPIHandler(w, r, apiSpec)
+	})
+	http.HandleFunc("GET /api/docs", handlers.APIDocsHandler)
+
+	// Отчёты о нарушениях CSP
+	http.Handle("/csp-report", middleware.RateLimit(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == "POST" {
+			handlers.CSPReportHandler(w, r)
+		} else {
+			http.Error(w, "Method not supported", http.StatusMethodNotAllowed)
+		}
+	}), reportLimit))
 
 	// Обслуживание статических файлов
+	http.Handle("/static/", http.StripPrefix("/static/", http.FileServer(http.Dir("./static"))))
 	fs := http.FileServer(http.Dir("./templates"))
 	http.Handle("/", fs)
 
 	// Запуск сервера
 	log.Println("Сервер запущен на порту 8081")
-	log.Fatal(http.ListenAndServe(":8081", nil))
+	log.Fatal(http.ListenAndServe(":8081", middleware.RequestID(middleware.SecurityHeaders(http.DefaultServeMux, securityPolicies))))
 
 }
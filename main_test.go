@@ -0,0 +1,63 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+
+	"fitnesshub/handlers"
+	"fitnesshub/middleware"
+)
+
+// Маршруты собираются без обращения к базе, поэтому клиенту не нужен работающий сервер
+func testAPIRoutes(t *testing.T) (*mongo.Client, apiLimits) {
+	t.Helper()
+	client, err := mongo.Connect(context.TODO(), options.Client().ApplyURI("mongodb://127.0.0.1:1"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { client.Disconnect(context.TODO()) })
+
+	store := middleware.NewMemoryRateLimitStore()
+	limit := middleware.RateLimiter{Name: "test", Rate: middleware.PerMinute(1), Burst: 1, Store: store}
+	return client, apiLimits{login: limit, signup: limit, email: limit, reset: limit, graphql: limit}
+}
+
+// testAPISpec собирает спецификацию так же, как main: /api/v1 и маршруты протоколов
+func testAPISpec(t *testing.T) *handlers.APISpec {
+	t.Helper()
+	client, limits := testAPIRoutes(t)
+	_, spec := apiRoutes(client.Database("fitnesshub"), limits)
+	if err := protocolRoutes(http.NewServeMux(), spec, client.Database("fitnesshub"), limits, false); err != nil {
+		t.Fatal(err)
+	}
+	return spec
+}
+
+func TestAPISpecHasNoDrift(t *testing.T) {
+	spec := testAPISpec(t)
+
+	for _, problem := range spec.Drift() {
+		t.Error(problem)
+	}
+}
+
+func TestAPISpecDocument(t *testing.T) {
+	document := testAPISpec(t).Document()
+	if document["openapi"] != "3.1.0" {
+		t.Errorf("openapi = %v, want 3.1.0", document["openapi"])
+	}
+	paths := document["paths"].(map[string]map[string]interface{})
+	for _, path := range []string{"/graphql", "/oauth/token", "/login/passkey/begin", "/login/oidc/callback", "/api/v1/admin/audit", "/api/v1/admin/trash", "/api/v1/admin/users/import"} {
+		if paths[path] == nil {
+			t.Errorf("path %s is missing from the document", path)
+		}
+	}
+	if _, err := json.Marshal(document); err != nil {
+		t.Fatalf("document is not valid JSON: %v", err)
+	}
+}
@@ -0,0 +1,110 @@
+package middleware
+
+import (
+	"context"
+	"net/http"
+	"strings"
+)
+
+// APIKeyPrefix открывает каждый API-ключ, чтобы его было легко опознать в логах и коде
+const APIKeyPrefix = "fhk_"
+
+const apiKeyIDKey contextKey = "api_key_id"
+
+// APIKeyIdentity — проверенный API-ключ: от чьего имени и с какими разрешениями выполняется запрос
+type APIKeyIdentity struct {
+	KeyID  string
+	Prefix string
+	UserID string
+	Role   string
+	Scopes []string
+}
+
+// APIKeyValidator проверяет ключ и отмечает его использование
+type APIKeyValidator func(key string, r *http.Request) (APIKeyIdentity, error)
+
+var apiKeyValidator APIKeyValidator
+
+// UseAPIKeys включает приём ключей из заголовка Authorization: Bearer fhk_... в RoleBasedAccessControl
+func UseAPIKeys(validate APIKeyValidator) {
+	apiKeyValidator = validate
+}
+
+// bearerAPIKey возвращает API-ключ из заголовка Authorization, если он там есть
+func bearerAPIKey(r *http.Request) (string, bool) {
+	key, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+	if !ok || !strings.HasPrefix(key, APIKeyPrefix) {
+		return "", false
+	}
+	return key, true
+}
+
+// APIKeyPermission — разрешение, нужное запросу: ресурс по первому сегменту пути без
+// префикса /api/v1 (для /admin — "admin.<раздел>") и read для GET/HEAD и /graphql, write для остальных методов
+func APIKeyPermission(r *http.Request) (resource, action string) {
+	path := r.URL.Path
+	if rest, ok := strings.CutPrefix(path, "/api/v1/"); ok {
+		path = rest
+	}
+	segments := strings.Split(strings.Trim(path, "/"), "/")
+	resource = segments[0]
+	if resource == "admin" && len(segments) > 1 {
+		resource = "admin." + segments[1]
+	}
+	action = "write"
+	// Схема GraphQL только читает данные, какой бы метод ни использовал клиент
+	if r.Method == "GET" || r.Method == "HEAD" || resource == "graphql" {
+		action = "read"
+	}
+	return resource, action
+}
+
+// apiKeyAllows проверяет разрешения ключа; write включает read, "*" — любой ресурс
+func apiKeyAllows(scopes []string, resource, action string) bool {
+	for _, scope := range scopes {
+		scopeResource, scopeAction, _ := strings.Cut(scope, ":")
+		if scopeResource != resource && scopeResource != "*" {
+			continue
+		}
+		if scopeAction == action || scopeAction == "write" {
+			return true
+		}
+	}
+	return false
+}
+
+// authenticateAPIKey проверяет ключ, его разрешения и роль для маршрута
+func authenticateAPIKey(w http.ResponseWriter, r *http.Request, next http.Handler, key string, roles []string) {
+	if apiKeyValidator == nil {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+	identity, err := apiKeyValidator(key, r)
+	if err != nil {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	if resource, action := APIKeyPermission(r); !apiKeyAllows(identity.Scopes, resource, action) {
+		http.Error(w, "API key lacks permission "+resource+":"+action, http.StatusForbidden)
+		return
+	}
+
+	for _, role := range roles {
+		if identity.Role == role {
+			ctx := context.WithValue(r.Context(), userIDKey, identity.UserID)
+			ctx = context.WithValue(ctx, userRoleKey, identity.Role)
+			ctx = context.WithValue(ctx, apiKeyIDKey, identity.Prefix)
+			next.ServeHTTP(w, r.WithContext(ctx))
+			return
+		}
+	}
+
+	http.Error(w, "Forbidden", http.StatusForbidden)
+}
+
+// GetAPIKeyPrefix возвращает префикс API-ключа, которым аутентифицирован запрос, или пустую строку
+func GetAPIKeyPrefix(r *http.Request) string {
+	prefix, _ := r.Context().Value(apiKeyIDKey).(string)
+	return prefix
+}
@@ -1,33 +1,57 @@
 package middleware
 
 import (
+	"context"
 	"net/http"
 
-	"github.com/dgrijalva/jwt-go"
+	"fitnesshub/utils"
+)
+
+type contextKey string
+
+const (
+	userIDKey   contextKey = "user_id"
+	userRoleKey contextKey = "role"
 )
 
 func RoleBasedAccessControl(next http.Handler, roles ...string) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// Скрипты и интеграции аутентифицируются API-ключом вместо cookie сессии
+		if key, ok := bearerAPIKey(r); ok {
+			authenticateAPIKey(w, r, next, key, roles)
+			return
+		}
+
 		tokenString, err := r.Cookie("token")
 		if err != nil {
 			http.Error(w, "Unauthorized", http.StatusUnauthorized)
 			return
 		}
 
-		token, err := jwt.Parse(tokenString.Value, func(token *jwt.Token) (interface{}, error) {
-			return []byte("your_secret_key"), nil
-		})
-		if err != nil || !token.Valid {
+		session, err := utils.ParseSessionToken(tokenString.Value)
+		if err != nil {
 			http.Error(w, "Unauthorized", http.StatusUnauthorized)
 			return
 		}
+		userRole, userID := session.Role, session.UserID
 
-		claims := token.Claims.(jwt.MapClaims)
-		userRole := claims["role"].(string)
+		// Для ролей с обязательным вторым фактором сессия должна быть подтверждена кодом
+		if !session.TwoFactor && utils.TwoFactorRequired(userRole) {
+			http.Error(w, "Two-factor authentication required", http.StatusUnauthorized)
+			return
+		}
+
+		// Cookie браузер отправит и с чужого сайта, поэтому меняющие запросы подтверждаются CSRF-токеном
+		if !validCSRFRequest(r) {
+			http.Error(w, "Invalid CSRF token", http.StatusForbidden)
+			return
+		}
 
 		for _, role := range roles {
 			if userRole == role {
-				next.ServeHTTP(w, r)
+				ctx := context.WithValue(r.Context(), userIDKey, userID)
+				ctx = context.WithValue(ctx, userRoleKey, userRole)
+				next.ServeHTTP(w, r.WithContext(ctx))
 				return
 			}
 		}
@@ -35,3 +59,30 @@ func RoleBasedAccessControl(next http.Handler, roles ...string) http.Handler {
 		http.Error(w, "Forbidden", http.StatusForbidden)
 	})
 }
+
+// OptionalAuthentication пропускает анонимные запросы без проверок, а запрос с cookie сессии
+// или API-ключом проверяет так же, как RoleBasedAccessControl с ролями roles
+func OptionalAuthentication(next http.Handler, roles ...string) http.Handler {
+	authenticated := RoleBasedAccessControl(next, roles...)
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if _, ok := bearerAPIKey(r); !ok {
+			if _, err := r.Cookie("token"); err != nil {
+				next.ServeHTTP(w, r)
+				return
+			}
+		}
+		authenticated.ServeHTTP(w, r)
+	})
+}
+
+// GetUserID возвращает ID пользователя из JWT, проверенного RoleBasedAccessControl
+func GetUserID(r *http.Request) string {
+	userID, _ := r.Context().Value(userIDKey).(string)
+	return userID
+}
+
+// GetUserRole возвращает роль пользователя из JWT, проверенного RoleBasedAccessControl
+func GetUserRole(r *http.Request) string {
+	role, _ := r.Context().Value(userRoleKey).(string)
+	return role
+}
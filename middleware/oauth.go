@@ -0,0 +1,61 @@
+package middleware
+
+import (
+	"context"
+	"net/http"
+	"strings"
+)
+
+const clientIDKey contextKey = "client_id"
+
+// AccessToken — сведения о проверенном токене доступа партнёрского приложения
+type AccessToken struct {
+	UserID   string
+	ClientID string
+	Scopes   []string
+}
+
+// AccessTokenValidator проверяет токен из заголовка Authorization: Bearer
+type AccessTokenValidator func(token string) (AccessToken, error)
+
+// RequireScope пропускает запрос с действующим токеном доступа, выданным со всеми
+// перечисленными областями; владелец токена доступен через GetUserID, приложение — через GetClientID
+func RequireScope(next http.Handler, validate AccessTokenValidator, scopes ...string) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		tokenString, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if !ok || tokenString == "" {
+			w.Header().Set("WWW-Authenticate", `Bearer realm="fitnesshub"`)
+			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+
+		token, err := validate(tokenString)
+		if err != nil {
+			w.Header().Set("WWW-Authenticate", `Bearer realm="fitnesshub", error="invalid_token"`)
+			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+
+		for _, scope := range scopes {
+			granted := false
+			for _, tokenScope := range token.Scopes {
+				granted = granted || tokenScope == scope
+			}
+			if !granted {
+				w.Header().Set("WWW-Authenticate", `Bearer realm="fitnesshub", error="insufficient_scope", scope="`+strings.Join(scopes, " ")+`"`)
+				http.Error(w, "Forbidden", http.StatusForbidden)
+				return
+			}
+		}
+
+		ctx := context.WithValue(r.Context(), userIDKey, token.UserID)
+		ctx = context.WithValue(ctx, clientIDKey, token.ClientID)
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+// GetClientID возвращает client_id приложения из токена, проверенного RequireScope
+func GetClientID(r *http.Request) string {
+	clientID, _ := r.Context().Value(clientIDKey).(string)
+	return clientID
+}
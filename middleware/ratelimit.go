@@ -0,0 +1,151 @@
+package middleware
+
+import (
+	"context"
+	"math"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// RateLimitStore хранит корзины токенов. Allow забирает токен из корзины key, пополняемой
+// со скоростью rate токенов в секунду до burst, и при отказе сообщает, через сколько повторить.
+type RateLimitStore interface {
+	Allow(key string, rate float64, burst int, now time.Time) (bool, time.Duration, error)
+}
+
+// RateLimiter описывает ограничение для группы маршрутов
+type RateLimiter struct {
+	Name  string
+	Rate  float64
+	Burst int
+	Key   func(r *http.Request) string
+	Store RateLimitStore
+}
+
+// PerMinute и PerHour переводят число запросов за период в скорость пополнения корзины
+func PerMinute(n int) float64 { return float64(n) / 60 }
+func PerHour(n int) float64   { return float64(n) / 3600 }
+
+// RateLimit отвечает 429 с заголовком Retry-After, когда корзина клиента пуста.
+// По умолчанию клиент определяется по IP-адресу.
+func RateLimit(next http.Handler, limiter RateLimiter) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		key := ClientIP(r)
+		if limiter.Key != nil {
+			key = limiter.Key(r)
+		}
+
+		allowed, retryAfter, err := limiter.Store.Allow(limiter.Name+":"+key, limiter.Rate, limiter.Burst, time.Now())
+		if err != nil {
+			// Недоступность хранилища не должна блокировать вход и регистрацию
+			next.ServeHTTP(w, r)
+			return
+		}
+		if !allowed {
+			w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(retryAfter.Seconds()))))
+			http.Error(w, "Too many requests", http.StatusTooManyRequests)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+type tokenBucket struct {
+	tokens  float64
+	updated time.Time
+}
+
+// MemoryRateLimitStore — хранилище в памяти процесса для одного экземпляра сервера
+type MemoryRateLimitStore struct {
+	mu      sync.Mutex
+	buckets map[string]*tokenBucket
+}
+
+func NewMemoryRateLimitStore() *MemoryRateLimitStore {
+	return &MemoryRateLimitStore{buckets: map[string]*tokenBucket{}}
+}
+
+func (s *MemoryRateLimitStore) Allow(key string, rate float64, burst int, now time.Time) (bool, time.Duration, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	bucket, ok := s.buckets[key]
+	if !ok {
+		if len(s.buckets) >= 10000 {
+			s.sweep(now)
+		}
+		bucket = &tokenBucket{tokens: float64(burst), updated: now}
+		s.buckets[key] = bucket
+	}
+
+	bucket.tokens = math.Min(float64(burst), bucket.tokens+now.Sub(bucket.updated).Seconds()*rate)
+	bucket.updated = now
+	if bucket.tokens < 1 {
+		return false, time.Duration((1 - bucket.tokens) / rate * float64(time.Second)), nil
+	}
+	bucket.tokens--
+	return true, 0, nil
+}
+
+// sweep удаляет корзины, которые не использовались больше часа
+func (s *MemoryRateLimitStore) sweep(now time.Time) {
+	for key, bucket := range s.buckets {
+		if now.Sub(bucket.updated) > time.Hour {
+			delete(s.buckets, key)
+		}
+	}
+}
+
+// MongoRateLimitStore хранит корзины в MongoDB, чтобы ограничение действовало
+// на все экземпляры сервера. Корзина обновляется одним атомарным запросом.
+type MongoRateLimitStore struct {
+	collection *mongo.Collection
+}
+
+func NewMongoRateLimitStore(collection *mongo.Collection) (*MongoRateLimitStore, error) {
+	// Неиспользуемые корзины удаляются по TTL-индексу
+	_, err := collection.Indexes().CreateOne(context.TODO(), mongo.IndexModel{
+		Keys:    bson.D{{Key: "expires_at", Value: 1}},
+		Options: options.Index().SetExpireAfterSeconds(0),
+	})
+	return &MongoRateLimitStore{collection: collection}, err
+}
+
+func (s *MongoRateLimitStore) Allow(key string, rate float64, burst int, now time.Time) (bool, time.Duration, error) {
+	elapsed := bson.M{"$divide": bson.A{bson.M{"$subtract": bson.A{now, bson.M{"$ifNull": bson.A{"$updated_at", now}}}}, 1000}}
+	refilled := bson.M{"$min": bson.A{burst, bson.M{"$add": bson.A{
+		bson.M{"$ifNull": bson.A{"$tokens", burst}},
+		bson.M{"$multiply": bson.A{elapsed, rate}},
+	}}}}
+	idle := time.Duration(float64(burst) / rate * float64(time.Second))
+
+	pipeline := mongo.Pipeline{
+		{{Key: "$set", Value: bson.M{"tokens": refilled}}},
+		{{Key: "$set", Value: bson.M{"allowed": bson.M{"$gte": bson.A{"$tokens", 1}}}}},
+		{{Key: "$set", Value: bson.M{
+			"tokens":     bson.M{"$cond": bson.A{"$allowed", bson.M{"$subtract": bson.A{"$tokens", 1}}, "$tokens"}},
+			"updated_at": now,
+			"expires_at": now.Add(idle),
+		}}},
+	}
+
+	var bucket struct {
+		Tokens  float64 `bson:"tokens"`
+		Allowed bool    `bson:"allowed"`
+	}
+	err := s.collection.FindOneAndUpdate(context.TODO(), bson.M{"_id": key}, pipeline,
+		options.FindOneAndUpdate().SetUpsert(true).SetReturnDocument(options.After)).Decode(&bucket)
+	if err != nil {
+		return false, 0, err
+	}
+	if !bucket.Allowed {
+		return false, time.Duration((1 - bucket.Tokens) / rate * float64(time.Second)), nil
+	}
+	return true, 0, nil
+}
@@ -0,0 +1,41 @@
+package middleware
+
+import (
+	"net/http/httptest"
+	"testing"
+)
+
+func TestClientIP(t *testing.T) {
+	if err := TrustProxies("10.0.0.0/8, 192.168.1.10"); err != nil {
+		t.Fatal(err)
+	}
+	defer TrustProxies("")
+
+	tests := []struct {
+		name, remote, forwarded, want string
+	}{
+		{"direct client", "203.0.113.5:1234", "", "203.0.113.5"},
+		{"untrusted peer cannot spoof", "203.0.113.5:1234", "198.51.100.1", "203.0.113.5"},
+		{"trusted proxy", "10.0.0.2:80", "198.51.100.1", "198.51.100.1"},
+		{"proxy chain", "10.0.0.2:80", "198.51.100.1, 192.168.1.10", "198.51.100.1"},
+		{"spoofed left entry ignored", "10.0.0.2:80", "1.2.3.4, 198.51.100.1", "198.51.100.1"},
+		{"garbage hop", "10.0.0.2:80", "1.2.3.4, bogus", "10.0.0.2"},
+		{"no header", "10.0.0.2:80", "", "10.0.0.2"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			r := httptest.NewRequest("GET", "/", nil)
+			r.RemoteAddr = test.remote
+			if test.forwarded != "" {
+				r.Header.Set("X-Forwarded-For", test.forwarded)
+			}
+			if got := ClientIP(r); got != test.want {
+				t.Fatalf("ClientIP() = %q, want %q", got, test.want)
+			}
+		})
+	}
+
+	if err := TrustProxies("not-an-ip"); err == nil {
+		t.Fatal("invalid proxy accepted")
+	}
+}
@@ -0,0 +1,32 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// APIKey — ключ для скриптов и интеграций. Сам ключ показывается один раз при создании,
+// в базе хранятся только его префикс (для опознания) и хеш. Ключ пользователя действует
+// с ролью владельца, сервисный ключ (UserID пуст) — с ролью, заданной администратором.
+// Scopes — разрешения вида "<ресурс>:<read|write>", например "products:write" или "*:read".
+type APIKey struct {
+	ID         primitive.ObjectID `bson:"_id,omitempty" json:"id"`
+	Name       string             `bson:"name" json:"name"`
+	Prefix     string             `bson:"prefix" json:"prefix"`
+	KeyHash    string             `bson:"key_hash" json:"-"`
+	UserID     primitive.ObjectID `bson:"user_id,omitempty" json:"user_id,omitempty"`
+	Role       string             `bson:"role,omitempty" json:"role,omitempty"`
+	Scopes     []string           `bson:"scopes" json:"scopes"`
+	CreatedBy  primitive.ObjectID `bson:"created_by" json:"created_by"`
+	CreatedAt  time.Time          `bson:"created_at" json:"created_at"`
+	ExpiresAt  time.Time          `bson:"expires_at" json:"expires_at"`
+	LastUsedAt *time.Time         `bson:"last_used_at,omitempty" json:"last_used_at,omitempty"`
+	LastUsedIP string             `bson:"last_used_ip,omitempty" json:"last_used_ip,omitempty"`
+	RevokedAt  *time.Time         `bson:"revoked_at,omitempty" json:"revoked_at,omitempty"`
+}
+
+// IsService сообщает, что ключ не привязан к пользователю
+func (k APIKey) IsService() bool {
+	return k.UserID.IsZero()
+}
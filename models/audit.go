@@ -0,0 +1,32 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// AuditChange — значение поля до и после изменения в виде JSON
+type AuditChange struct {
+	Before string `bson:"before,omitempty" json:"before,omitempty"`
+	After  string `bson:"after,omitempty" json:"after,omitempty"`
+}
+
+// AuditEvent — запись журнала аудита. Записи только добавляются; Hash вычисляется
+// от содержимого записи и PrevHash предыдущей, так что правка любой записи разрывает цепочку.
+type AuditEvent struct {
+	ID         primitive.ObjectID     `bson:"_id,omitempty" json:"-"`
+	Seq        int64                  `bson:"seq" json:"seq"`
+	Time       time.Time              `bson:"time" json:"time"`
+	ActorID    string                 `bson:"actor_id,omitempty" json:"actor_id,omitempty"`
+	ActorRole  string                 `bson:"actor_role,omitempty" json:"actor_role,omitempty"`
+	Action     string                 `bson:"action" json:"action"`
+	TargetType string                 `bson:"target_type,omitempty" json:"target_type,omitempty"`
+	TargetID   string                 `bson:"target_id,omitempty" json:"target_id,omitempty"`
+	Changes    map[string]AuditChange `bson:"changes,omitempty" json:"changes,omitempty"`
+	Details    string                 `bson:"details,omitempty" json:"details,omitempty"`
+	IP         string                 `bson:"ip,omitempty" json:"ip,omitempty"`
+	RequestID  string                 `bson:"request_id,omitempty" json:"request_id,omitempty"`
+	PrevHash   string                 `bson:"prev_hash" json:"prev_hash"`
+	Hash       string                 `bson:"hash" json:"hash"`
+}
@@ -0,0 +1,25 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+const (
+	BookingStatusBooked     = "booked"
+	BookingStatusWaitlisted = "waitlisted"
+	BookingStatusCancelled  = "cancelled"
+	BookingStatusAttended   = "attended"
+	BookingStatusNoShow     = "no_show"
+)
+
+type Booking struct {
+	ID          primitive.ObjectID `bson:"_id,omitempty" json:"id"`
+	SessionID   primitive.ObjectID `bson:"session_id" json:"session_id"`
+	UserID      primitive.ObjectID `bson:"user_id" json:"user_id"`
+	Status      string             `bson:"status" json:"status"`
+	CreatedAt   time.Time          `bson:"created_at" json:"created_at"`
+	UpdatedAt   time.Time          `bson:"updated_at" json:"updated_at"`
+	CancelledAt *time.Time         `bson:"cancelled_at,omitempty" json:"cancelled_at,omitempty"`
+}
@@ -0,0 +1,57 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+type ClassType struct {
+	ID              primitive.ObjectID `bson:"_id,omitempty" json:"id"`
+	Name            string             `bson:"name" json:"name"`
+	Description     string             `bson:"description" json:"description"`
+	DurationMinutes int                `bson:"duration_minutes" json:"duration_minutes"`
+}
+
+type Room struct {
+	ID       primitive.ObjectID `bson:"_id,omitempty" json:"id"`
+	Name     string             `bson:"name" json:"name"`
+	Capacity int                `bson:"capacity" json:"capacity"`
+}
+
+type Instructor struct {
+	ID     primitive.ObjectID `bson:"_id,omitempty" json:"id"`
+	Name   string             `bson:"name" json:"name"`
+	Bio    string             `bson:"bio" json:"bio"`
+	UserID primitive.ObjectID `bson:"user_id,omitempty" json:"user_id,omitempty"`
+}
+
+// ClassSchedule описывает повторяющееся занятие. Recurrence задаётся в формате RRULE
+// (поддерживается FREQ=WEEKLY с BYDAY, INTERVAL, UNTIL и COUNT), Exceptions — даты
+// в формате 2006-01-02, в которые занятие не проводится (например, праздники).
+type ClassSchedule struct {
+	ID           primitive.ObjectID `bson:"_id,omitempty" json:"id"`
+	ClassTypeID  primitive.ObjectID `bson:"class_type_id" json:"class_type_id"`
+	RoomID       primitive.ObjectID `bson:"room_id" json:"room_id"`
+	InstructorID primitive.ObjectID `bson:"instructor_id" json:"instructor_id"`
+	Recurrence   string             `bson:"recurrence" json:"recurrence"`
+	StartDate    string             `bson:"start_date" json:"start_date"`
+	StartTime    string             `bson:"start_time" json:"start_time"`
+	Capacity     int                `bson:"capacity" json:"capacity"`
+	Exceptions   []string           `bson:"exceptions" json:"exceptions"`
+}
+
+// ClassSession — конкретное занятие, полученное из расписания, на которое можно записаться.
+type ClassSession struct {
+	ID           primitive.ObjectID `bson:"_id,omitempty" json:"id"`
+	ScheduleID   primitive.ObjectID `bson:"schedule_id" json:"schedule_id"`
+	ClassTypeID  primitive.ObjectID `bson:"class_type_id" json:"class_type_id"`
+	ClassName    string             `bson:"class_name" json:"class_name"`
+	RoomID       primitive.ObjectID `bson:"room_id" json:"room_id"`
+	InstructorID primitive.ObjectID `bson:"instructor_id" json:"instructor_id"`
+	StartsAt     time.Time          `bson:"starts_at" json:"starts_at"`
+	EndsAt       time.Time          `bson:"ends_at" json:"ends_at"`
+	Capacity     int                `bson:"capacity" json:"capacity"`
+	Booked       int                `bson:"booked" json:"booked"`
+	Cancelled    bool               `bson:"cancelled" json:"cancelled"`
+}
@@ -0,0 +1,16 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// PersistedQuery — сохранённый текст GraphQL-запроса. Клиент отправляет вместо текста
+// его SHA-256 (ID), что сокращает запросы и позволяет разрешить только известные запросы.
+type PersistedQuery struct {
+	ID        string             `bson:"_id" json:"id"`
+	Query     string             `bson:"query" json:"query"`
+	CreatedBy primitive.ObjectID `bson:"created_by,omitempty" json:"created_by,omitempty"`
+	CreatedAt time.Time          `bson:"created_at" json:"created_at"`
+}
@@ -0,0 +1,23 @@
+package models
+
+import "time"
+
+// ExternalIdentity — учётная запись у внешнего OIDC-провайдера, привязанная к пользователю.
+// Пара Provider+Subject уникальна; Email — адрес, сообщённый провайдером при привязке.
+type ExternalIdentity struct {
+	Provider string    `bson:"provider" json:"provider"`
+	Subject  string    `bson:"subject" json:"subject"`
+	Email    string    `bson:"email,omitempty" json:"email,omitempty"`
+	LinkedAt time.Time `bson:"linked_at" json:"linked_at"`
+}
+
+// OIDCState — параметры начатого входа через провайдера: state, nonce и PKCE-верификатор.
+// LinkUserID задан, когда вошедший пользователь привязывает провайдера из профиля.
+type OIDCState struct {
+	ID         string    `bson:"_id" json:"id"`
+	Provider   string    `bson:"provider" json:"provider"`
+	Nonce      string    `bson:"nonce" json:"-"`
+	Verifier   string    `bson:"verifier" json:"-"`
+	LinkUserID string    `bson:"link_user_id,omitempty" json:"link_user_id,omitempty"`
+	ExpiresAt  time.Time `bson:"expires_at" json:"expires_at"`
+}
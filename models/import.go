@@ -0,0 +1,37 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+const (
+	ImportStatusRunning   = "running"
+	ImportStatusCompleted = "completed"
+)
+
+type ImportRowError struct {
+	Line    int    `bson:"line" json:"line"`
+	Message string `bson:"message" json:"message"`
+}
+
+type ImportReport struct {
+	DryRun  bool             `bson:"dry_run" json:"dry_run"`
+	Total   int              `bson:"total" json:"total"`
+	Valid   int              `bson:"valid" json:"valid"`
+	Created int              `bson:"created" json:"created"`
+	Updated int              `bson:"updated" json:"updated"`
+	Errors  []ImportRowError `bson:"errors" json:"errors"`
+}
+
+// ImportJob — импорт большого файла, выполняемый в фоне
+type ImportJob struct {
+	ID         primitive.ObjectID `bson:"_id,omitempty" json:"id"`
+	Kind       string             `bson:"kind" json:"kind"`
+	Status     string             `bson:"status" json:"status"`
+	Report     ImportReport       `bson:"report" json:"report"`
+	CreatedBy  primitive.ObjectID `bson:"created_by" json:"created_by"`
+	CreatedAt  time.Time          `bson:"created_at" json:"created_at"`
+	FinishedAt *time.Time         `bson:"finished_at,omitempty" json:"finished_at,omitempty"`
+}
@@ -0,0 +1,14 @@
+package models
+
+import "time"
+
+// LoginAttempts — счётчик неудачных входов для учётной записи ("account:<email>") или IP ("ip:<адрес>")
+type LoginAttempts struct {
+	ID            string     `bson:"_id" json:"id"`
+	Failures      int        `bson:"failures" json:"failures"`
+	WindowStart   time.Time  `bson:"window_start" json:"window_start"`
+	NextAttemptAt time.Time  `bson:"next_attempt_at" json:"next_attempt_at"`
+	LockedUntil   *time.Time `bson:"locked_until,omitempty" json:"locked_until,omitempty"`
+	UnlockToken   string     `bson:"unlock_token,omitempty" json:"-"`
+	ExpiresAt     time.Time  `bson:"expires_at" json:"-"`
+}
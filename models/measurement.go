@@ -0,0 +1,46 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Measurement хранит замеры в метрических единицах (кг, см); перевод в единицы
+// пользователя выполняется при вводе и выводе
+type Measurement struct {
+	ID             primitive.ObjectID `bson:"_id,omitempty" json:"id"`
+	UserID         primitive.ObjectID `bson:"user_id" json:"user_id"`
+	MeasuredAt     time.Time          `bson:"measured_at" json:"measured_at"`
+	Weight         float64            `bson:"weight,omitempty" json:"weight,omitempty"`
+	BodyFatPercent float64            `bson:"body_fat_percent,omitempty" json:"body_fat_percent,omitempty"`
+	Circumferences map[string]float64 `bson:"circumferences,omitempty" json:"circumferences,omitempty"`
+	Photos         []string           `bson:"photos,omitempty" json:"photos,omitempty"`
+	Notes          string             `bson:"notes" json:"notes"`
+}
+
+const (
+	WeightUnitKg = "kg"
+	WeightUnitLb = "lb"
+	LengthUnitCm = "cm"
+	LengthUnitIn = "in"
+)
+
+type UnitPreferences struct {
+	Weight string `bson:"weight" json:"weight"`
+	Length string `bson:"length" json:"length"`
+}
+
+// TrendPoint — значение метрики на дату и скользящее среднее за 7 дней
+type TrendPoint struct {
+	MeasuredAt    time.Time `json:"measured_at"`
+	Value         float64   `json:"value"`
+	MovingAverage float64   `json:"moving_average_7d"`
+}
+
+type Trend struct {
+	Metric string              `json:"metric"`
+	Unit   string              `json:"unit"`
+	Points []TrendPoint        `json:"points"`
+	Deltas map[string]*float64 `json:"deltas"`
+}
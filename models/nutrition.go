@@ -0,0 +1,74 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// FoodItem — продукт из базы питания; пищевая ценность указана на 100 г
+type FoodItem struct {
+	ID           primitive.ObjectID `bson:"_id,omitempty" json:"id"`
+	Name         string             `bson:"name" json:"name"`
+	Brand        string             `bson:"brand" json:"brand"`
+	Calories     float64            `bson:"calories" json:"calories"`
+	Protein      float64            `bson:"protein" json:"protein"`
+	Carbs        float64            `bson:"carbs" json:"carbs"`
+	Fat          float64            `bson:"fat" json:"fat"`
+	ServingGrams float64            `bson:"serving_grams" json:"serving_grams"`
+}
+
+// MealLog — приём пищи пользователя. Пищевая ценность продуктов копируется
+// в запись, чтобы история не менялась при редактировании базы продуктов.
+type MealLog struct {
+	ID       primitive.ObjectID `bson:"_id,omitempty" json:"id"`
+	UserID   primitive.ObjectID `bson:"user_id" json:"user_id"`
+	Date     string             `bson:"date" json:"date"`
+	Meal     string             `bson:"meal" json:"meal"`
+	Items    []MealItem         `bson:"items" json:"items"`
+	LoggedAt time.Time          `bson:"logged_at" json:"logged_at"`
+}
+
+type MealItem struct {
+	FoodID   primitive.ObjectID `bson:"food_id" json:"food_id"`
+	Name     string             `bson:"name" json:"name"`
+	Servings float64            `bson:"servings" json:"servings"`
+	Grams    float64            `bson:"grams" json:"grams"`
+	Macros   `bson:",inline"`
+}
+
+type Macros struct {
+	Calories float64 `bson:"calories" json:"calories"`
+	Protein  float64 `bson:"protein" json:"protein"`
+	Carbs    float64 `bson:"carbs" json:"carbs"`
+	Fat      float64 `bson:"fat" json:"fat"`
+}
+
+func (m *Macros) Add(other Macros) {
+	m.Calories += other.Calories
+	m.Protein += other.Protein
+	m.Carbs += other.Carbs
+	m.Fat += other.Fat
+}
+
+const (
+	GoalLoseWeight = "lose"
+	GoalMaintain   = "maintain"
+	GoalGainWeight = "gain"
+)
+
+// NutritionSettings — данные для расчёта целевой калорийности; пол и дата рождения
+// берутся из профиля. Если задан Target, он используется вместо расчётного значения.
+type NutritionSettings struct {
+	HeightCm      float64 `bson:"height_cm" json:"height_cm"`
+	ActivityLevel string  `bson:"activity_level" json:"activity_level"`
+	Goal          string  `bson:"goal" json:"goal"`
+	Target        *Macros `bson:"target,omitempty" json:"target,omitempty"`
+}
+
+type NutritionSummary struct {
+	Date      string  `json:"date"`
+	Totals    Macros  `json:"totals"`
+	Target    *Macros `json:"target,omitempty"`
+	Remaining *Macros `json:"remaining,omitempty"`
+}
@@ -0,0 +1,77 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Области доступа, которые партнёрские приложения могут запросить у участника
+const (
+	ScopeProfileRead  = "profile:read"
+	ScopeWorkoutsRead = "workouts:read"
+	ScopeVisitsRead   = "visits:read"
+)
+
+var ValidOAuthScopes = []string{ScopeProfileRead, ScopeWorkoutsRead, ScopeVisitsRead}
+
+func IsValidOAuthScope(scope string) bool {
+	for _, valid := range ValidOAuthScopes {
+		if scope == valid {
+			return true
+		}
+	}
+	return false
+}
+
+// Виды грантов OAuth2, поддерживаемые сервером авторизации
+const (
+	GrantAuthorizationCode = "authorization_code"
+	GrantClientCredentials = "client_credentials"
+)
+
+// OAuthClient — партнёрское приложение, зарегистрированное администратором. ID служит client_id;
+// у конфиденциальных клиентов хранится только хеш секрета.
+type OAuthClient struct {
+	ID           primitive.ObjectID `bson:"_id,omitempty" json:"client_id"`
+	Name         string             `bson:"name" json:"name"`
+	SecretHash   string             `bson:"secret_hash,omitempty" json:"-"`
+	Confidential bool               `bson:"confidential" json:"confidential"`
+	RedirectURIs []string           `bson:"redirect_uris" json:"redirect_uris"`
+	Scopes       []string           `bson:"scopes" json:"scopes"`
+	GrantTypes   []string           `bson:"grant_types" json:"grant_types"`
+	CreatedBy    primitive.ObjectID `bson:"created_by,omitempty" json:"created_by,omitempty"`
+	CreatedAt    time.Time          `bson:"created_at" json:"created_at"`
+	RevokedAt    *time.Time         `bson:"revoked_at,omitempty" json:"revoked_at,omitempty"`
+}
+
+// OAuthCode — одноразовый код авторизации; ID — хеш кода, CodeChallenge — PKCE S256
+type OAuthCode struct {
+	ID            string             `bson:"_id" json:"-"`
+	ClientID      primitive.ObjectID `bson:"client_id" json:"client_id"`
+	UserID        primitive.ObjectID `bson:"user_id" json:"user_id"`
+	RedirectURI   string             `bson:"redirect_uri" json:"redirect_uri"`
+	Scopes        []string           `bson:"scopes" json:"scopes"`
+	CodeChallenge string             `bson:"code_challenge" json:"-"`
+	ExpiresAt     time.Time          `bson:"expires_at" json:"expires_at"`
+}
+
+// OAuthToken — выданный токен доступа; ID — хеш токена. UserID пуст у токенов client_credentials.
+type OAuthToken struct {
+	ID        string             `bson:"_id" json:"-"`
+	ClientID  primitive.ObjectID `bson:"client_id" json:"client_id"`
+	UserID    primitive.ObjectID `bson:"user_id,omitempty" json:"user_id,omitempty"`
+	Scopes    []string           `bson:"scopes" json:"scopes"`
+	IssuedAt  time.Time          `bson:"issued_at" json:"issued_at"`
+	ExpiresAt time.Time          `bson:"expires_at" json:"expires_at"`
+	RevokedAt *time.Time         `bson:"revoked_at,omitempty" json:"revoked_at,omitempty"`
+}
+
+// OAuthConsent — согласие участника на доступ приложения к перечисленным областям
+type OAuthConsent struct {
+	ID        primitive.ObjectID `bson:"_id,omitempty" json:"id"`
+	UserID    primitive.ObjectID `bson:"user_id" json:"user_id"`
+	ClientID  primitive.ObjectID `bson:"client_id" json:"client_id"`
+	Scopes    []string           `bson:"scopes" json:"scopes"`
+	GrantedAt time.Time          `bson:"granted_at" json:"granted_at"`
+}
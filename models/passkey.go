@@ -0,0 +1,35 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Passkey — WebAuthn-ключ пользователя: открытый ключ, счётчик подписей и флаги резервирования.
+// CredentialID хранится в base64url и уникален среди всех пользователей.
+type Passkey struct {
+	ID              primitive.ObjectID `bson:"_id,omitempty" json:"id"`
+	UserID          primitive.ObjectID `bson:"user_id" json:"user_id"`
+	Name            string             `bson:"name" json:"name"`
+	CredentialID    string             `bson:"credential_id" json:"credential_id"`
+	PublicKey       []byte             `bson:"public_key" json:"-"`
+	AttestationType string             `bson:"attestation_type,omitempty" json:"-"`
+	Transports      []string           `bson:"transports,omitempty" json:"transports,omitempty"`
+	AAGUID          []byte             `bson:"aaguid,omitempty" json:"-"`
+	SignCount       uint32             `bson:"sign_count" json:"sign_count"`
+	BackupEligible  bool               `bson:"backup_eligible" json:"backup_eligible"`
+	BackupState     bool               `bson:"backup_state" json:"backup_state"`
+	CreatedAt       time.Time          `bson:"created_at" json:"created_at"`
+	LastUsedAt      *time.Time         `bson:"last_used_at,omitempty" json:"last_used_at,omitempty"`
+}
+
+// WebAuthnSession — серверная часть церемонии WebAuthn: выданный вызов (challenge)
+// и его параметры, которые сверяются при завершении регистрации или входа
+type WebAuthnSession struct {
+	ID        string             `bson:"_id" json:"id"`
+	Purpose   string             `bson:"purpose" json:"purpose"`
+	UserID    primitive.ObjectID `bson:"user_id,omitempty" json:"user_id,omitempty"`
+	Data      string             `bson:"data" json:"-"`
+	ExpiresAt time.Time          `bson:"expires_at" json:"expires_at"`
+}
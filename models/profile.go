@@ -0,0 +1,60 @@
+package models
+
+import "time"
+
+type Profile struct {
+	FirstName        string           `bson:"first_name" json:"first_name"`
+	LastName         string           `bson:"last_name" json:"last_name"`
+	Phone            string           `bson:"phone,omitempty" json:"phone,omitempty"`
+	DateOfBirth      *time.Time       `bson:"date_of_birth,omitempty" json:"date_of_birth,omitempty"`
+	Gender           string           `bson:"gender,omitempty" json:"gender,omitempty"`
+	EmergencyContact EmergencyContact `bson:"emergency_contact" json:"emergency_contact"`
+	FitnessGoals     []string         `bson:"fitness_goals,omitempty" json:"fitness_goals,omitempty"`
+	AvatarURL        string           `bson:"avatar_url,omitempty" json:"avatar_url,omitempty"`
+	Locale           string           `bson:"locale,omitempty" json:"locale,omitempty"`
+	Timezone         string           `bson:"timezone,omitempty" json:"timezone,omitempty"`
+	Privacy          ProfilePrivacy   `bson:"privacy" json:"privacy"`
+}
+
+type EmergencyContact struct {
+	Name         string `bson:"name,omitempty" json:"name,omitempty"`
+	Phone        string `bson:"phone,omitempty" json:"phone,omitempty"`
+	Relationship string `bson:"relationship,omitempty" json:"relationship,omitempty"`
+}
+
+// ProfilePrivacy определяет, какие поля профиля видит закреплённый тренер.
+// Имя и аватар видны тренеру всегда.
+type ProfilePrivacy struct {
+	ShowPhone            bool `bson:"show_phone" json:"show_phone"`
+	ShowDateOfBirth      bool `bson:"show_date_of_birth" json:"show_date_of_birth"`
+	ShowGender           bool `bson:"show_gender" json:"show_gender"`
+	ShowEmergencyContact bool `bson:"show_emergency_contact" json:"show_emergency_contact"`
+	ShowFitnessGoals     bool `bson:"show_fitness_goals" json:"show_fitness_goals"`
+}
+
+// ForTrainer возвращает копию профиля без полей, скрытых настройками приватности
+func (p Profile) ForTrainer() Profile {
+	visible := Profile{
+		FirstName: p.FirstName,
+		LastName:  p.LastName,
+		AvatarURL: p.AvatarURL,
+		Locale:    p.Locale,
+		Timezone:  p.Timezone,
+	}
+	if p.Privacy.ShowPhone {
+		visible.Phone = p.Phone
+	}
+	if p.Privacy.ShowDateOfBirth {
+		visible.DateOfBirth = p.DateOfBirth
+	}
+	if p.Privacy.ShowGender {
+		visible.Gender = p.Gender
+	}
+	if p.Privacy.ShowEmergencyContact {
+		visible.EmergencyContact = p.EmergencyContact
+	}
+	if p.Privacy.ShowFitnessGoals {
+		visible.FitnessGoals = p.FitnessGoals
+	}
+	return visible
+}
@@ -0,0 +1,66 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Program — шаблон многонедельной программы тренировок, составленный тренером
+type Program struct {
+	ID          primitive.ObjectID `bson:"_id,omitempty" json:"id"`
+	TrainerID   primitive.ObjectID `bson:"trainer_id" json:"trainer_id"`
+	Name        string             `bson:"name" json:"name"`
+	Description string             `bson:"description" json:"description"`
+	Weeks       []ProgramWeek      `bson:"weeks" json:"weeks"`
+	CreatedAt   time.Time          `bson:"created_at" json:"created_at"`
+}
+
+type ProgramWeek struct {
+	Days []ProgramDay `bson:"days" json:"days"`
+}
+
+// ProgramDay — тренировочный день; Day — номер дня недели программы от 0 до 6,
+// считая от даты начала назначения
+type ProgramDay struct {
+	Day       int                  `bson:"day" json:"day"`
+	Name      string               `bson:"name" json:"name"`
+	Exercises []PrescribedExercise `bson:"exercises" json:"exercises"`
+}
+
+// PrescribedExercise — назначенная нагрузка. PercentOneRM задаёт рабочий вес
+// в процентах от расчётного максимума участника.
+type PrescribedExercise struct {
+	ExerciseID   primitive.ObjectID `bson:"exercise_id" json:"exercise_id"`
+	Sets         int                `bson:"sets" json:"sets"`
+	Reps         int                `bson:"reps" json:"reps"`
+	PercentOneRM float64            `bson:"percent_one_rm,omitempty" json:"percent_one_rm,omitempty"`
+	RPE          float64            `bson:"rpe,omitempty" json:"rpe,omitempty"`
+	Notes        string             `bson:"notes,omitempty" json:"notes,omitempty"`
+	TargetWeight float64            `bson:"-" json:"target_weight,omitempty"`
+}
+
+const (
+	AssignmentStatusActive    = "active"
+	AssignmentStatusCancelled = "cancelled"
+)
+
+type ProgramAssignment struct {
+	ID        primitive.ObjectID `bson:"_id,omitempty" json:"id"`
+	ProgramID primitive.ObjectID `bson:"program_id" json:"program_id"`
+	MemberID  primitive.ObjectID `bson:"member_id" json:"member_id"`
+	TrainerID primitive.ObjectID `bson:"trainer_id" json:"trainer_id"`
+	StartDate string             `bson:"start_date" json:"start_date"`
+	Status    string             `bson:"status" json:"status"`
+	CreatedAt time.Time          `bson:"created_at" json:"created_at"`
+}
+
+// DayAdherence сравнивает назначенные и выполненные подходы за один день программы
+type DayAdherence struct {
+	Date           string `json:"date"`
+	Week           int    `json:"week"`
+	Day            int    `json:"day"`
+	PrescribedSets int    `json:"prescribed_sets"`
+	CompletedSets  int    `json:"completed_sets"`
+	Completed      bool   `json:"completed"`
+}
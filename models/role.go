@@ -4,3 +4,15 @@ type Role struct {
 	Name        string   `bson:"name"`
 	Permissions []string `bson:"permissions"`
 }
+
+// Роли, которые может назначить администратор
+var ValidRoles = []string{"user", "trainer", "staff", "administrator"}
+
+func IsValidRole(role string) bool {
+	for _, valid := range ValidRoles {
+		if role == valid {
+			return true
+		}
+	}
+	return false
+}
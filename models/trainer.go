@@ -0,0 +1,45 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+type TrainerProfile struct {
+	ID             primitive.ObjectID   `bson:"_id,omitempty" json:"id"`
+	UserID         primitive.ObjectID   `bson:"user_id" json:"user_id"`
+	Name           string               `bson:"name" json:"name"`
+	Bio            string               `bson:"bio" json:"bio"`
+	Specialties    []string             `bson:"specialties" json:"specialties"`
+	Certifications []string             `bson:"certifications" json:"certifications"`
+	PhotoURL       string               `bson:"photo_url" json:"photo_url"`
+	Availability   []AvailabilityWindow `bson:"availability" json:"availability"`
+	SlotMinutes    int                  `bson:"slot_minutes" json:"slot_minutes"`
+	BufferMinutes  int                  `bson:"buffer_minutes" json:"buffer_minutes"`
+}
+
+// AvailabilityWindow — интервал, в который тренер принимает клиентов в указанный день недели
+// (0 — воскресенье, как в time.Weekday). Start и End задаются в формате 15:04.
+type AvailabilityWindow struct {
+	Weekday int    `bson:"weekday" json:"weekday"`
+	Start   string `bson:"start" json:"start"`
+	End     string `bson:"end" json:"end"`
+}
+
+const (
+	AppointmentStatusBooked    = "booked"
+	AppointmentStatusCancelled = "cancelled"
+)
+
+type Appointment struct {
+	ID        primitive.ObjectID `bson:"_id,omitempty" json:"id"`
+	TrainerID primitive.ObjectID `bson:"trainer_id" json:"trainer_id"`
+	MemberID  primitive.ObjectID `bson:"member_id" json:"member_id"`
+	RoomID    primitive.ObjectID `bson:"room_id,omitempty" json:"room_id,omitempty"`
+	StartsAt  time.Time          `bson:"starts_at" json:"starts_at"`
+	EndsAt    time.Time          `bson:"ends_at" json:"ends_at"`
+	Status    string             `bson:"status" json:"status"`
+	Notes     string             `bson:"notes" json:"notes"`
+	CreatedAt time.Time          `bson:"created_at" json:"created_at"`
+}
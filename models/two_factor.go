@@ -0,0 +1,14 @@
+package models
+
+import "time"
+
+// TwoFactorSettings — настройки TOTP пользователя. Секрет хранится зашифрованным,
+// коды восстановления — в виде хешей; наружу отдаётся только статус.
+type TwoFactorSettings struct {
+	Enabled       bool       `bson:"enabled" json:"enabled"`
+	Secret        string     `bson:"secret,omitempty" json:"-"`
+	PendingSecret string     `bson:"pending_secret,omitempty" json:"-"`
+	RecoveryCodes []string   `bson:"recovery_codes,omitempty" json:"-"`
+	LastStep      int64      `bson:"last_step,omitempty" json:"-"`
+	EnabledAt     *time.Time `bson:"enabled_at,omitempty" json:"enabled_at,omitempty"`
+}
@@ -1,12 +1,42 @@
 package models
 
-import "go.mongodb.org/mongo-driver/bson/primitive"
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
 
 type User struct {
 	ID                primitive.ObjectID `bson:"_id,omitempty" json:"id"`
 	Email             string             `bson:"email" json:"email"`
-	Password          string             `bson:"password,omitempty"`
+	Password          string             `bson:"password,omitempty" json:"-"`
 	Verified          bool               `bson:"verified" json:"verified"`
-	VerificationToken string             `bson:"verification_token,omitempty"`
+	VerificationToken string             `bson:"verification_token,omitempty" json:"-"`
 	Role              string             `bson:"role" json:"role"`
+	MembershipExpires *time.Time         `bson:"membership_expires,omitempty" json:"membership_expires,omitempty"`
+	Units             UnitPreferences    `bson:"units" json:"units"`
+	TrainerID         primitive.ObjectID `bson:"trainer_id,omitempty" json:"trainer_id,omitempty"`
+	Nutrition         NutritionSettings  `bson:"nutrition" json:"nutrition"`
+	Profile           Profile            `bson:"profile" json:"profile"`
+	TwoFactor         TwoFactorSettings  `bson:"two_factor" json:"two_factor"`
+	Identities        []ExternalIdentity `bson:"identities,omitempty" json:"identities,omitempty"`
+	DeletedAt         *time.Time         `bson:"deleted_at,omitempty" json:"deleted_at,omitempty"`
+	DeletedBy         primitive.ObjectID `bson:"deleted_by,omitempty" json:"deleted_by,omitempty"`
+}
+
+// PreferredUnits возвращает единицы измерения пользователя, подставляя метрические по умолчанию
+func (u User) PreferredUnits() UnitPreferences {
+	units := u.Units
+	if units.Weight != WeightUnitLb {
+		units.Weight = WeightUnitKg
+	}
+	if units.Length != LengthUnitIn {
+		units.Length = LengthUnitCm
+	}
+	return units
+}
+
+// HasActiveMembership сообщает, действует ли абонемент пользователя в момент now
+func (u User) HasActiveMembership(now time.Time) bool {
+	return u.MembershipExpires != nil && u.MembershipExpires.After(now)
 }
@@ -0,0 +1,14 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+type Visit struct {
+	ID          primitive.ObjectID `bson:"_id,omitempty" json:"id"`
+	UserID      primitive.ObjectID `bson:"user_id" json:"user_id"`
+	CheckedInAt time.Time          `bson:"checked_in_at" json:"checked_in_at"`
+	CheckedInBy primitive.ObjectID `bson:"checked_in_by" json:"checked_in_by"`
+}
@@ -0,0 +1,60 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+type Exercise struct {
+	ID           primitive.ObjectID `bson:"_id,omitempty" json:"id"`
+	Name         string             `bson:"name" json:"name"`
+	MuscleGroups []string           `bson:"muscle_groups" json:"muscle_groups"`
+	Equipment    []string           `bson:"equipment" json:"equipment"`
+	Instructions string             `bson:"instructions" json:"instructions"`
+	CreatedBy    primitive.ObjectID `bson:"created_by,omitempty" json:"created_by,omitempty"`
+}
+
+type Workout struct {
+	ID              primitive.ObjectID `bson:"_id,omitempty" json:"id"`
+	UserID          primitive.ObjectID `bson:"user_id" json:"user_id"`
+	PerformedAt     time.Time          `bson:"performed_at" json:"performed_at"`
+	Name            string             `bson:"name" json:"name"`
+	Notes           string             `bson:"notes" json:"notes"`
+	DurationMinutes int                `bson:"duration_minutes" json:"duration_minutes"`
+	Entries         []WorkoutEntry     `bson:"entries" json:"entries"`
+}
+
+type WorkoutEntry struct {
+	ExerciseID primitive.ObjectID `bson:"exercise_id" json:"exercise_id"`
+	Sets       []WorkoutSet       `bson:"sets" json:"sets"`
+}
+
+// WorkoutSet — один подход. Weight указывается в килограммах, RPE — по шкале от 1 до 10.
+type WorkoutSet struct {
+	Reps            int     `bson:"reps" json:"reps"`
+	Weight          float64 `bson:"weight" json:"weight"`
+	DurationSeconds int     `bson:"duration_seconds" json:"duration_seconds"`
+	RPE             float64 `bson:"rpe" json:"rpe"`
+}
+
+// PersonalRecord — лучшие результаты пользователя в упражнении
+type PersonalRecord struct {
+	ExerciseID       primitive.ObjectID `json:"exercise_id"`
+	MaxWeight        float64            `json:"max_weight"`
+	MaxWeightAt      time.Time          `json:"max_weight_at"`
+	MaxReps          int                `json:"max_reps"`
+	MaxRepsAt        time.Time          `json:"max_reps_at"`
+	EstimatedOneRM   float64            `json:"estimated_one_rm"`
+	EstimatedOneRMAt time.Time          `json:"estimated_one_rm_at"`
+}
+
+// WeeklyVolume — суммарная нагрузка за неделю, начинающуюся с WeekStart (понедельник)
+type WeeklyVolume struct {
+	WeekStart       string  `json:"week_start"`
+	Workouts        int     `json:"workouts"`
+	Sets            int     `json:"sets"`
+	Reps            int     `json:"reps"`
+	Volume          float64 `json:"volume"`
+	DurationMinutes int     `json:"duration_minutes"`
+}
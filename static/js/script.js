@@ -37,21 +37,186 @@ document.addEventListener('DOMContentLoaded', function() {
             });
 
             const result = await response.json();
+            finishLogin(await continueTwoFactor(result.status, result.pre_auth_token, result));
+        });
+
+        // После входа через внешнего провайдера сервер возвращает на эту страницу с шагом 2FA,
+        // а токен шага передаёт в cookie
+        const step = new URLSearchParams(window.location.search).get('step');
+        if (step) {
+            history.replaceState(null, '', window.location.pathname);
+            continueTwoFactor(step, '', { message: 'Login cancelled' }).then(finishLogin);
+        }
+    }
+
+    async function continueTwoFactor(status, preAuthToken, result) {
+        if (status === "2fa_required") {
+            return completeTwoFactor(preAuthToken);
+        } else if (status === "2fa_enrollment_required") {
+            return enrollTwoFactor(preAuthToken);
+        }
+        return result;
+    }
+
+    function finishLogin(result) {
+        if (result.status === "success") {
+            alert('Login successful');
+            window.location.href = '/';
+        } else {
+            alert(result.message);
+        }
+    }
+
+    const passkeyLoginButton = document.getElementById('passkey-login');
+    const passkeyRegisterButton = document.getElementById('passkey-register');
+
+    if (passkeyLoginButton) {
+        passkeyLoginButton.addEventListener('click', async function() {
+            const options = await postJSON('/login/passkey/begin', {});
+            if (!options.publicKey) {
+                alert(options.message);
+                return;
+            }
+            options.publicKey.challenge = fromBase64URL(options.publicKey.challenge);
+            (options.publicKey.allowCredentials || []).forEach(credential => {
+                credential.id = fromBase64URL(credential.id);
+            });
+
+            let assertion;
+            try {
+                assertion = await navigator.credentials.get(options);
+            } catch (error) {
+                alert('Passkey sign-in cancelled');
+                return;
+            }
+            const result = await postJSON('/login/passkey/finish', {
+                id: assertion.id,
+                rawId: toBase64URL(assertion.rawId),
+                type: assertion.type,
+                response: {
+                    clientDataJSON: toBase64URL(assertion.response.clientDataJSON),
+                    authenticatorData: toBase64URL(assertion.response.authenticatorData),
+                    signature: toBase64URL(assertion.response.signature),
+                    userHandle: assertion.response.userHandle ? toBase64URL(assertion.response.userHandle) : null
+                }
+            });
             if (result.status === "success") {
-                localStorage.setItem('token', result.token);
                 alert('Login successful');
-                window.location.href = '/catalog.html';
+                window.location.href = '/';
             } else {
                 alert(result.message);
             }
         });
     }
 
+    if (passkeyRegisterButton) {
+        passkeyRegisterButton.addEventListener('click', async function() {
+            const options = await postJSON('/profile/passkeys/register/begin', {});
+            if (!options.publicKey) {
+                alert(options.message);
+                return;
+            }
+            options.publicKey.challenge = fromBase64URL(options.publicKey.challenge);
+            options.publicKey.user.id = fromBase64URL(options.publicKey.user.id);
+            (options.publicKey.excludeCredentials || []).forEach(credential => {
+                credential.id = fromBase64URL(credential.id);
+            });
+
+            let credential;
+            try {
+                credential = await navigator.credentials.create(options);
+            } catch (error) {
+                alert('Passkey registration cancelled');
+                return;
+            }
+            const name = prompt('Name this passkey', 'My device') || '';
+            const result = await postJSON('/profile/passkeys/register/finish?name=' + encodeURIComponent(name), {
+                id: credential.id,
+                rawId: toBase64URL(credential.rawId),
+                type: credential.type,
+                response: {
+                    clientDataJSON: toBase64URL(credential.response.clientDataJSON),
+                    attestationObject: toBase64URL(credential.response.attestationObject),
+                    transports: credential.response.getTransports ? credential.response.getTransports() : []
+                }
+            });
+            alert(result.credential_id ? 'Passkey added' : result.message);
+        });
+    }
+
+    function toBase64URL(buffer) {
+        const bytes = new Uint8Array(buffer);
+        let binary = '';
+        bytes.forEach(byte => {
+            binary += String.fromCharCode(byte);
+        });
+        return btoa(binary).replace(/\+/g, '-').replace(/\//g, '_').replace(/=+$/, '');
+    }
+
+    function fromBase64URL(value) {
+        const base64 = value.replace(/-/g, '+').replace(/_/g, '/');
+        const binary = atob(base64.padEnd(base64.length + (4 - base64.length % 4) % 4, '='));
+        return Uint8Array.from(binary, char => char.charCodeAt(0));
+    }
+
+    function csrfToken() {
+        const match = document.cookie.match(/(?:^|;\s*)csrf_token=([^;]*)/);
+        return match ? decodeURIComponent(match[1]) : '';
+    }
+
+    async function postJSON(url, body) {
+        const response = await fetch(url, {
+            method: 'POST',
+            headers: {
+                'Content-Type': 'application/json',
+                'X-CSRF-Token': csrfToken()
+            },
+            body: JSON.stringify(body)
+        });
+        if (!response.ok) {
+            return { message: await response.text() };
+        }
+        return response.json();
+    }
+
+    async function completeTwoFactor(preAuthToken) {
+        const code = prompt('Enter the code from your authenticator app, or a recovery code');
+        if (!code) {
+            return { message: 'Login cancelled' };
+        }
+        const body = { pre_auth_token: preAuthToken };
+        if (code.includes('-')) {
+            body.recovery_code = code;
+        } else {
+            body.code = code;
+        }
+        return postJSON('/login/2fa', body);
+    }
+
+    async function enrollTwoFactor(preAuthToken) {
+        const setup = await postJSON('/login/2fa/setup', { pre_auth_token: preAuthToken });
+        if (!setup.secret) {
+            return setup;
+        }
+        const code = prompt('Two-factor authentication is required for your account. Add this key to your authenticator app and enter the code it shows: ' + setup.secret);
+        if (!code) {
+            return { message: 'Login cancelled' };
+        }
+        const result = await postJSON('/login/2fa/enable', { pre_auth_token: preAuthToken, code });
+        if (result.recovery_codes) {
+            alert('Save these recovery codes somewhere safe:\n' + result.recovery_codes.join('\n'));
+        }
+        return result;
+    }
+
     async function fetchProducts() {
+        const productList = document.getElementById('product-list');
+        if (!productList) {
+            return;
+        }
         const response = await fetch('/products?page=1&limit=10&sort=name&filter=');
         const products = await response.json();
 
-        const productList = document.getElementById('product-list');
         productList.innerHTML = '';
 
         products.forEach(product => {
@@ -0,0 +1,51 @@
+package utils
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+)
+
+// Ключ шифрования секретов, которые нужно хранить в базе в восстановимом виде (например, TOTP)
+var secretKey = sha256.Sum256([]byte(secretEnv("SECRET_ENCRYPTION_KEY", "your_secret_encryption_key")))
+
+// EncryptSecret шифрует строку AES-256-GCM и возвращает nonce и шифротекст в base64
+func EncryptSecret(plain string) (string, error) {
+	block, err := aes.NewCipher(secretKey[:])
+	if err != nil {
+		return "", err
+	}
+	gcm, err := cipher.NewGCM(block)
+	if err != nil {
+		return "", err
+	}
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return "", err
+	}
+	return base64.StdEncoding.EncodeToString(gcm.Seal(nonce, nonce, []byte(plain), nil)), nil
+}
+
+// DecryptSecret расшифровывает строку, зашифрованную EncryptSecret
+func DecryptSecret(encrypted string) (string, error) {
+	data, err := base64.StdEncoding.DecodeString(encrypted)
+	if err != nil {
+		return "", err
+	}
+	block, err := aes.NewCipher(secretKey[:])
+	if err != nil {
+		return "", err
+	}
+	gcm, err := cipher.NewGCM(block)
+	if err != nil {
+		return "", err
+	}
+	if len(data) < gcm.NonceSize() {
+		return "", errors.New("malformed secret")
+	}
+	plain, err := gcm.Open(nil, data[:gcm.NonceSize()], data[gcm.NonceSize():], nil)
+	return string(plain), err
+}
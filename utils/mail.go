@@ -0,0 +1,15 @@
+package utils
+
+import "gopkg.in/mail.v2"
+
+// SendEmail отправляет письмо в текстовом формате через SMTP
+func SendEmail(to, subject, body string) error {
+	m := mail.NewMessage()
+	m.SetHeader("From", "no-reply@fitnesshub.com")
+	m.SetHeader("To", to)
+	m.SetHeader("Subject", subject)
+	m.SetBody("text/plain", body)
+
+	d := mail.NewDialer("smtp.mailtrap.io", 2525, "9c521257de733d", "ae506c9d02f243")
+	return d.DialAndSend(m)
+}
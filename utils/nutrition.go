@@ -0,0 +1,85 @@
+package utils
+
+import (
+	"errors"
+	"time"
+)
+
+var activityMultipliers = map[string]float64{
+	"sedentary":   1.2,
+	"light":       1.375,
+	"moderate":    1.55,
+	"active":      1.725,
+	"very_active": 1.9,
+}
+
+// Поправка к суточной калорийности и норма белка (г на кг веса) в зависимости от цели
+var goalAdjustments = map[string]struct {
+	Calories float64
+	Protein  float64
+}{
+	"lose":     {-500, 2.0},
+	"maintain": {0, 1.6},
+	"gain":     {300, 1.8},
+}
+
+// MacroTargets — суточные нормы калорий и БЖУ
+type MacroTargets struct {
+	Calories float64
+	Protein  float64
+	Carbs    float64
+	Fat      float64
+}
+
+// IsValidActivityLevel проверяет уровень активности
+func IsValidActivityLevel(level string) bool {
+	_, ok := activityMultipliers[level]
+	return ok
+}
+
+// IsValidGoal проверяет цель питания
+func IsValidGoal(goal string) bool {
+	_, ok := goalAdjustments[goal]
+	return ok
+}
+
+// CalculateMacroTargets рассчитывает суточные нормы по формуле Миффлина — Сан Жеора.
+// Жиры составляют 25% калорийности, углеводы — остаток после белков и жиров.
+func CalculateMacroTargets(sex string, birthDate time.Time, heightCm, weightKg float64, activityLevel, goal string, now time.Time) (MacroTargets, error) {
+	var targets MacroTargets
+	if weightKg <= 0 || heightCm <= 0 || birthDate.IsZero() {
+		return targets, errors.New("weight, height and birth date are required to calculate targets")
+	}
+	multiplier, ok := activityMultipliers[activityLevel]
+	if !ok {
+		return targets, errors.New("invalid activity level")
+	}
+	adjustment, ok := goalAdjustments[goal]
+	if !ok {
+		return targets, errors.New("invalid goal")
+	}
+
+	age := now.Year() - birthDate.Year()
+	if now.YearDay() < birthDate.YearDay() {
+		age--
+	}
+
+	bmr := 10*weightKg + 6.25*heightCm - 5*float64(age)
+	switch sex {
+	case "male":
+		bmr += 5
+	case "female":
+		bmr -= 161
+	default:
+		bmr -= 78
+	}
+
+	targets.Calories = bmr*multiplier + adjustment.Calories
+	targets.Protein = adjustment.Protein * weightKg
+	targets.Fat = targets.Calories * 0.25 / 9
+	targets.Carbs = (targets.Calories - targets.Protein*4 - targets.Fat*9) / 4
+	if targets.Carbs < 0 {
+		targets.Carbs = 0
+	}
+	return targets, nil
+}
@@ -0,0 +1,89 @@
+package utils
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Период, после которого QR-код пропуска меняется
+const PassRotationPeriod = 30 * time.Second
+
+var passSecret = []byte(secretEnv("PASS_SECRET_KEY", "your_pass_secret_key"))
+
+// DevModeEnv — переменная окружения, при значении true разрешающая запуск без секретов:
+// вместо них берутся известные всем значения, пригодные только для локальной разработки
+const DevModeEnv = "FITNESSHUB_DEV"
+
+// missingSecrets — обязательные переменные окружения, которые не заданы
+var missingSecrets []string
+
+func getEnv(key, fallback string) string {
+	if value := os.Getenv(key); value != "" {
+		return value
+	}
+	return fallback
+}
+
+// secretEnv возвращает секрет из окружения. Если он не задан, в режиме разработки возвращается
+// devFallback, иначе переменная запоминается, и запуск остановит CheckSecrets.
+func secretEnv(key, devFallback string) string {
+	if value := os.Getenv(key); value != "" {
+		return value
+	}
+	if os.Getenv(DevModeEnv) == "true" {
+		return devFallback
+	}
+	missingSecrets = append(missingSecrets, key)
+	return ""
+}
+
+// CheckSecrets возвращает ошибку, если какой-либо обязательный секрет не задан в окружении
+func CheckSecrets() error {
+	if len(missingSecrets) > 0 {
+		return errors.New(strings.Join(missingSecrets, ", ") + " must be set (or " + DevModeEnv + "=true for local development)")
+	}
+	return nil
+}
+
+// GenerateMemberPass возвращает подписанную строку для QR-кода пропуска и время её истечения
+func GenerateMemberPass(userID string, now time.Time) (string, time.Time) {
+	window := now.Unix() / int64(PassRotationPeriod.Seconds())
+	expiresAt := time.Unix((window+1)*int64(PassRotationPeriod.Seconds()), 0)
+	return userID + "." + strconv.FormatInt(window, 10) + "." + signPass(userID, window), expiresAt
+}
+
+// VerifyMemberPass проверяет подпись пропуска и возвращает ID пользователя.
+// Принимается текущий и предыдущий период, чтобы код не устаревал во время сканирования.
+func VerifyMemberPass(payload string, now time.Time) (string, error) {
+	parts := strings.Split(payload, ".")
+	if len(parts) != 3 {
+		return "", errors.New("malformed pass")
+	}
+
+	window, err := strconv.ParseInt(parts[1], 10, 64)
+	if err != nil {
+		return "", errors.New("malformed pass")
+	}
+
+	current := now.Unix() / int64(PassRotationPeriod.Seconds())
+	if window != current && window != current-1 {
+		return "", errors.New("pass expired")
+	}
+
+	if !hmac.Equal([]byte(parts[2]), []byte(signPass(parts[0], window))) {
+		return "", errors.New("invalid pass signature")
+	}
+	return parts[0], nil
+}
+
+func signPass(userID string, window int64) string {
+	mac := hmac.New(sha256.New, passSecret)
+	mac.Write([]byte(userID + "." + strconv.FormatInt(window, 10)))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
@@ -0,0 +1,141 @@
+package utils
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var weekdays = map[string]time.Weekday{
+	"MO": time.Monday,
+	"TU": time.Tuesday,
+	"WE": time.Wednesday,
+	"TH": time.Thursday,
+	"FR": time.Friday,
+	"SA": time.Saturday,
+	"SU": time.Sunday,
+}
+
+// WeeklyRule — разобранное правило повторения FREQ=WEEKLY
+type WeeklyRule struct {
+	Days     []time.Weekday
+	Interval int
+	Until    time.Time
+	Count    int
+}
+
+// ParseWeeklyRule разбирает RRULE вида "FREQ=WEEKLY;BYDAY=MO,WE;INTERVAL=1;UNTIL=20261231"
+func ParseWeeklyRule(rule string) (WeeklyRule, error) {
+	parsed := WeeklyRule{Interval: 1}
+	rule = strings.TrimPrefix(strings.TrimSpace(rule), "RRULE:")
+	if rule == "" {
+		return parsed, errors.New("empty recurrence rule")
+	}
+
+	for _, part := range strings.Split(rule, ";") {
+		key, value, ok := strings.Cut(part, "=")
+		if !ok {
+			return parsed, errors.New("invalid recurrence rule part: " + part)
+		}
+		switch strings.ToUpper(key) {
+		case "FREQ":
+			if strings.ToUpper(value) != "WEEKLY" {
+				return parsed, errors.New("only FREQ=WEEKLY is supported")
+			}
+		case "BYDAY":
+			for _, day := range strings.Split(value, ",") {
+				weekday, ok := weekdays[strings.ToUpper(day)]
+				if !ok {
+					return parsed, errors.New("invalid BYDAY value: " + day)
+				}
+				parsed.Days = append(parsed.Days, weekday)
+			}
+		case "INTERVAL":
+			interval, err := strconv.Atoi(value)
+			if err != nil || interval < 1 {
+				return parsed, errors.New("invalid INTERVAL value: " + value)
+			}
+			parsed.Interval = interval
+		case "UNTIL":
+			until, err := time.ParseInLocation("20060102", value[:min(len(value), 8)], time.Local)
+			if err != nil {
+				return parsed, errors.New("invalid UNTIL value: " + value)
+			}
+			parsed.Until = until.AddDate(0, 0, 1)
+		case "COUNT":
+			count, err := strconv.Atoi(value)
+			if err != nil || count < 1 {
+				return parsed, errors.New("invalid COUNT value: " + value)
+			}
+			parsed.Count = count
+		default:
+			return parsed, errors.New("unsupported recurrence rule part: " + key)
+		}
+	}
+
+	if len(parsed.Days) == 0 {
+		return parsed, errors.New("BYDAY is required")
+	}
+	return parsed, nil
+}
+
+// Occurrences возвращает моменты начала занятий в интервале [from, to).
+// start задаёт первую дату и время начала; даты из exceptions (2006-01-02) пропускаются.
+func (rule WeeklyRule) Occurrences(start, from, to time.Time, exceptions []string) []time.Time {
+	skip := make(map[string]bool, len(exceptions))
+	for _, date := range exceptions {
+		skip[date] = true
+	}
+
+	// Неделя, с которой начинается отсчёт интервала, начинается с понедельника
+	firstWeek := StartOfWeek(start)
+
+	var result []time.Time
+	count := 0
+	for week := 0; ; week += rule.Interval {
+		weekStart := firstWeek.AddDate(0, 0, 7*week)
+		if !weekStart.Before(to) || (!rule.Until.IsZero() && !weekStart.Before(rule.Until)) {
+			break
+		}
+		for offset := 0; offset < 7; offset++ {
+			day := weekStart.AddDate(0, 0, offset)
+			if !containsWeekday(rule.Days, day.Weekday()) {
+				continue
+			}
+			occurrence := time.Date(day.Year(), day.Month(), day.Day(), start.Hour(), start.Minute(), 0, 0, start.Location())
+			if occurrence.Before(start) {
+				continue
+			}
+			if !rule.Until.IsZero() && !occurrence.Before(rule.Until) {
+				return result
+			}
+			count++
+			if rule.Count > 0 && count > rule.Count {
+				return result
+			}
+			if skip[occurrence.Format("2006-01-02")] {
+				continue
+			}
+			if !occurrence.Before(from) && occurrence.Before(to) {
+				result = append(result, occurrence)
+			}
+		}
+	}
+	return result
+}
+
+func containsWeekday(days []time.Weekday, day time.Weekday) bool {
+	for _, d := range days {
+		if d == day {
+			return true
+		}
+	}
+	return false
+}
+
+// StartOfWeek возвращает полночь понедельника недели, в которую попадает t
+func StartOfWeek(t time.Time) time.Time {
+	monday := t.AddDate(0, 0, -((int(t.Weekday()) + 6) % 7))
+	return time.Date(monday.Year(), monday.Month(), monday.Day(), 0, 0, 0, 0, t.Location())
+}
@@ -0,0 +1,107 @@
+package utils
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha1"
+	"crypto/subtle"
+	"encoding/base32"
+	"encoding/binary"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// Параметры TOTP по RFC 6238, которые понимают все приложения-аутентификаторы
+const (
+	TOTPPeriod  = 30
+	TOTPDigits  = 6
+	TOTPIssuer  = "FitnessHub"
+	totpSkew    = 1
+	totpKeySize = 20
+)
+
+var base32NoPadding = base32.StdEncoding.WithPadding(base32.NoPadding)
+
+// GenerateTOTPSecret возвращает случайный секрет в base32
+func GenerateTOTPSecret() (string, error) {
+	key := make([]byte, totpKeySize)
+	if _, err := rand.Read(key); err != nil {
+		return "", err
+	}
+	return base32NoPadding.EncodeToString(key), nil
+}
+
+// TOTPProvisioningURI возвращает otpauth://-ссылку для QR-кода в приложении-аутентификаторе
+func TOTPProvisioningURI(secret, account string) string {
+	label := url.PathEscape(TOTPIssuer + ":" + account)
+	values := url.Values{}
+	values.Set("secret", secret)
+	values.Set("issuer", TOTPIssuer)
+	values.Set("period", fmt.Sprint(TOTPPeriod))
+	values.Set("digits", fmt.Sprint(TOTPDigits))
+	return "otpauth://totp/" + label + "?" + values.Encode()
+}
+
+// TOTPCode вычисляет код для шага времени step
+func TOTPCode(secret string, step int64) (string, error) {
+	key, err := base32NoPadding.DecodeString(strings.ToUpper(strings.TrimRight(secret, "=")))
+	if err != nil {
+		return "", err
+	}
+
+	var counter [8]byte
+	binary.BigEndian.PutUint64(counter[:], uint64(step))
+	mac := hmac.New(sha1.New, key)
+	mac.Write(counter[:])
+	sum := mac.Sum(nil)
+
+	offset := sum[len(sum)-1] & 0x0f
+	value := binary.BigEndian.Uint32(sum[offset:offset+4]) & 0x7fffffff
+	return fmt.Sprintf("%0*d", TOTPDigits, value%1000000), nil
+}
+
+// ValidateTOTP проверяет код с допуском в один шаг в обе стороны и возвращает шаг,
+// которому он соответствует, — по нему отсекается повторное использование кода
+func ValidateTOTP(secret, code string, now time.Time) (int64, bool) {
+	code = strings.ReplaceAll(strings.TrimSpace(code), " ", "")
+	if len(code) != TOTPDigits {
+		return 0, false
+	}
+
+	current := now.Unix() / TOTPPeriod
+	for step := current - totpSkew; step <= current+totpSkew; step++ {
+		expected, err := TOTPCode(secret, step)
+		if err != nil {
+			return 0, false
+		}
+		if subtle.ConstantTimeCompare([]byte(expected), []byte(code)) == 1 {
+			return step, true
+		}
+	}
+	return 0, false
+}
+
+// GenerateRecoveryCodes возвращает n одноразовых кодов вида xxxxx-xxxxx
+func GenerateRecoveryCodes(n int) ([]string, error) {
+	codes := make([]string, n)
+	for i := range codes {
+		raw := make([]byte, 7)
+		if _, err := rand.Read(raw); err != nil {
+			return nil, err
+		}
+		code := strings.ToLower(base32NoPadding.EncodeToString(raw))[:10]
+		codes[i] = code[:5] + "-" + code[5:]
+	}
+	return codes, nil
+}
+
+// NormalizeRecoveryCode приводит введённый код восстановления к виду, в котором он хешировался
+func NormalizeRecoveryCode(code string) string {
+	code = strings.ToLower(strings.ReplaceAll(strings.TrimSpace(code), " ", ""))
+	if len(code) == 10 {
+		code = code[:5] + "-" + code[5:]
+	}
+	return code
+}
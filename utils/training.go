@@ -0,0 +1,49 @@
+package utils
+
+// EstimateOneRepMax оценивает максимум на одно повторение по формуле Эпли
+func EstimateOneRepMax(weight float64, reps int) float64 {
+	if weight <= 0 || reps <= 0 {
+		return 0
+	}
+	if reps == 1 {
+		return weight
+	}
+	return weight * (1 + float64(reps)/30)
+}
+
+const (
+	poundsPerKilogram  = 2.20462262
+	centimetersPerInch = 2.54
+)
+
+// ToKilograms переводит вес из указанных единиц (kg или lb) в килограммы
+func ToKilograms(value float64, unit string) float64 {
+	if unit == "lb" {
+		return value / poundsPerKilogram
+	}
+	return value
+}
+
+// FromKilograms переводит вес в килограммах в указанные единицы
+func FromKilograms(value float64, unit string) float64 {
+	if unit == "lb" {
+		return value * poundsPerKilogram
+	}
+	return value
+}
+
+// ToCentimeters переводит длину из указанных единиц (cm или in) в сантиметры
+func ToCentimeters(value float64, unit string) float64 {
+	if unit == "in" {
+		return value * centimetersPerInch
+	}
+	return value
+}
+
+// FromCentimeters переводит длину в сантиметрах в указанные единицы
+func FromCentimeters(value float64, unit string) float64 {
+	if unit == "in" {
+		return value / centimetersPerInch
+	}
+	return value
+}
@@ -1,24 +1,103 @@
 package utils
 
 import (
+	"crypto/hmac"
 	"crypto/rand"
+	"crypto/sha256"
 	"encoding/hex"
+	"errors"
+	"strings"
 	"time"
 
 	"github.com/golang-jwt/jwt/v4"
 )
 
-var jwtKey = []byte("your_secret_key")
+// Ключ подписи сессионных и промежуточных токенов и CSRF-токенов
+var jwtKey = []byte(secretEnv("JWT_SECRET_KEY", "your_secret_key"))
 
-func GenerateJWT(userID, role string) (string, error) {
+// Токены принимаются только с алгоритмом, которым их подписывает GenerateJWT
+var jwtParserOptions = []jwt.ParserOption{jwt.WithValidMethods([]string{jwt.SigningMethodHS256.Alg()})}
+
+// SessionClaims — данные проверенного сессионного токена
+type SessionClaims struct {
+	UserID    string
+	Role      string
+	TwoFactor bool
+}
+
+// GenerateJWT выдаёт сессионный токен; twoFactor отмечает, что вход подтверждён вторым фактором
+func GenerateJWT(userID, role string, twoFactor bool) (string, error) {
 	token := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
 		"user_id": userID,
 		"role":    role,
+		"2fa":     twoFactor,
 		"exp":     time.Now().Add(time.Hour * 24).Unix(),
 	})
 	return token.SignedString(jwtKey)
 }
 
+// ParseSessionToken проверяет подпись и срок сессионного токена. Токен промежуточного шага
+// входа сессией не является и отклоняется.
+func ParseSessionToken(tokenString string) (SessionClaims, error) {
+	token, err := jwt.Parse(tokenString, func(token *jwt.Token) (interface{}, error) {
+		return jwtKey, nil
+	}, jwtParserOptions...)
+	if err != nil || !token.Valid {
+		return SessionClaims{}, errors.New("invalid or expired token")
+	}
+	claims := token.Claims.(jwt.MapClaims)
+	if _, ok := claims["purpose"]; ok {
+		return SessionClaims{}, errors.New("not a session token")
+	}
+	session := SessionClaims{}
+	session.UserID, _ = claims["user_id"].(string)
+	session.Role, _ = claims["role"].(string)
+	session.TwoFactor, _ = claims["2fa"].(bool)
+	return session, nil
+}
+
+// Срок действия токена между вводом пароля и вводом кода второго фактора
+const PreAuthTokenTTL = 5 * time.Minute
+
+// GeneratePreAuthToken выдаёт короткоживущий токен после проверки пароля. purpose
+// отличает его от сессионного, чтобы он не принимался вместо cookie.
+func GeneratePreAuthToken(userID, purpose string) (string, error) {
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
+		"user_id": userID,
+		"purpose": purpose,
+		"exp":     time.Now().Add(PreAuthTokenTTL).Unix(),
+	})
+	return token.SignedString(jwtKey)
+}
+
+// ParsePreAuthToken проверяет токен и возвращает ID пользователя и назначение
+func ParsePreAuthToken(tokenString string) (string, string, error) {
+	token, err := jwt.Parse(tokenString, func(token *jwt.Token) (interface{}, error) {
+		return jwtKey, nil
+	}, jwtParserOptions...)
+	if err != nil || !token.Valid {
+		return "", "", errors.New("invalid or expired token")
+	}
+	claims := token.Claims.(jwt.MapClaims)
+	userID, _ := claims["user_id"].(string)
+	purpose, _ := claims["purpose"].(string)
+	if userID == "" || purpose == "" {
+		return "", "", errors.New("invalid or expired token")
+	}
+	return userID, purpose, nil
+}
+
+// TwoFactorRequired сообщает, обязателен ли второй фактор для роли.
+// Роли задаются через TWO_FACTOR_REQUIRED_ROLES (через запятую), по умолчанию — administrator.
+func TwoFactorRequired(role string) bool {
+	for _, required := range strings.Split(getEnv("TWO_FACTOR_REQUIRED_ROLES", "administrator"), ",") {
+		if strings.TrimSpace(required) == role {
+			return true
+		}
+	}
+	return false
+}
+
 // GenerateVerificationToken generates a random verification token
 
 func GenerateVerificationToken() (string, error) {
@@ -34,3 +113,23 @@ func GenerateVerificationToken() (string, error) {
 	return hex.EncodeToString(bytes), nil
 
 }
+
+// GenerateCSRFToken возвращает CSRF-токен, привязанный к сессионному JWT
+func GenerateCSRFToken(sessionToken string) string {
+	mac := hmac.New(sha256.New, jwtKey)
+	mac.Write([]byte("csrf:" + sessionToken))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// HashToken возвращает SHA-256 одноразового токена для хранения в базе вместо самого токена
+func HashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// SecureCookies сообщает, ставить ли cookie флаг Secure. По умолчанию включён;
+// COOKIE_SECURE=false нужен только для локальной разработки по HTTP.
+func SecureCookies() bool {
+	value := strings.ToLower(getEnv("COOKIE_SECURE", "true"))
+	return value != "false" && value != "0"
+}
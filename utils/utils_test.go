@@ -0,0 +1,37 @@
+package utils
+
+import (
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v4"
+)
+
+func TestParseSessionToken(t *testing.T) {
+	token, err := GenerateJWT("u1", "administrator", true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	session, err := ParseSessionToken(token)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if session != (SessionClaims{UserID: "u1", Role: "administrator", TwoFactor: true}) {
+		t.Fatalf("unexpected claims %+v", session)
+	}
+
+	preAuth, _ := GeneratePreAuthToken("u1", "2fa")
+	if _, err := ParseSessionToken(preAuth); err == nil {
+		t.Fatal("pre-auth token accepted as a session")
+	}
+
+	claims := jwt.MapClaims{"user_id": "u1", "role": "administrator", "2fa": true, "exp": time.Now().Add(time.Hour).Unix()}
+	hs512, _ := jwt.NewWithClaims(jwt.SigningMethodHS512, claims).SignedString(jwtKey)
+	if _, err := ParseSessionToken(hs512); err == nil {
+		t.Fatal("token signed with another algorithm accepted")
+	}
+	forged, _ := jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString([]byte("your_secret_key_guess"))
+	if _, err := ParseSessionToken(forged); err == nil {
+		t.Fatal("token signed with another key accepted")
+	}
+}